@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+const (
+	addressBookTimeout = time.Minute
+	addressBookActor   = "address-book" // In the audit log
+)
+
+// addressBookDir is where the address book's git repository is checked
+// out, next to the state file
+func addressBookDir(stateFile string) string {
+	return filepath.Join(filepath.Dir(stateFile), "address-book")
+}
+
+// syncAddressBook pulls the address book from ADDRESS_BOOK_URL or
+// ADDRESS_BOOK_GIT and syncs the watchlist with it, recording each added
+// and removed address in the audit log. When the book can't be read, the
+// last synced one stays in effect.
+func syncAddressBook(config Config, m *monitor.Monitor, dir string) {
+	var data []byte
+	var err error
+	name := config.AddressBookURL
+	if config.AddressBookGit != "" {
+		name = config.AddressBookPath
+		data, err = pullAddressBook(config, dir)
+	} else {
+		data, err = fetchAddressBook(config)
+	}
+	if err != nil {
+		log.Printf("Error reading the address book: %v", err)
+		return
+	}
+	entries, err := parseAddressBook(name, data)
+	if err != nil {
+		log.Printf("Error parsing the address book: %v", err)
+		return
+	}
+	changes, err := m.SyncAddressBook(entries)
+	if err != nil {
+		log.Printf("Error syncing the address book: %v", err)
+		return
+	}
+	for _, address := range changes.Added {
+		auditLog(config.AuditLogFile, addressBookActor, RoleAdmin, "watch", address, true)
+	}
+	for _, address := range changes.Removed {
+		auditLog(config.AuditLogFile, addressBookActor, RoleAdmin, "unwatch", address, true)
+	}
+	for _, address := range changes.Relabeled {
+		auditLog(config.AuditLogFile, addressBookActor, RoleAdmin, "label", address, true)
+	}
+}
+
+// fetchAddressBook downloads the address book from ADDRESS_BOOK_URL,
+// sending ADDRESS_BOOK_TOKEN as a bearer token if set
+func fetchAddressBook(config Config) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, config.AddressBookURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if config.AddressBookToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.AddressBookToken)
+	}
+	if config.UserAgent != "" {
+		req.Header.Set("User-Agent", config.UserAgent)
+	}
+	client := http.Client{Timeout: addressBookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", config.AddressBookURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// pullAddressBook clones ADDRESS_BOOK_GIT into dir, or updates the clone
+// to the remote's latest commit, and reads ADDRESS_BOOK_PATH from it
+func pullAddressBook(config Config, dir string) ([]byte, error) {
+	if _, err := os.Stat(filepath.Join(dir, ".git")); errors.Is(err, os.ErrNotExist) {
+		if err := runGit("", "clone", "--depth", "1", config.AddressBookGit, dir); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := runGit(dir, "fetch", "--depth", "1", "origin", "HEAD"); err != nil {
+			return nil, err
+		}
+		if err := runGit(dir, "reset", "--hard", "FETCH_HEAD"); err != nil {
+			return nil, err
+		}
+	}
+	return os.ReadFile(filepath.Join(dir, filepath.FromSlash(config.AddressBookPath)))
+}
+
+// runGit runs a git command in dir, including its output in the error
+func runGit(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// parseAddressBook parses an address book as JSON, either a list of
+// {"address", "label"} objects or an object of labels by address, or else
+// as CSV with the address in the first column and the label in the second,
+// such as a Google Sheet exported as CSV. A CSV header row starting with
+// "address" is skipped.
+func parseAddressBook(name string, data []byte) ([]monitor.AddressBookEntry, error) {
+	trimmed := bytes.TrimSpace(data)
+	if strings.HasSuffix(strings.ToLower(name), ".json") || bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		var entries []monitor.AddressBookEntry
+		if bytes.HasPrefix(trimmed, []byte("{")) {
+			var labels map[string]string
+			if err := json.Unmarshal(trimmed, &labels); err != nil {
+				return nil, err
+			}
+			for address, label := range labels {
+				entries = append(entries, monitor.AddressBookEntry{Address: address, Label: label})
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Address < entries[j].Address })
+		} else if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, err
+		}
+		return cleanAddressBook(entries), nil
+	}
+
+	reader := csv.NewReader(bytes.NewReader(trimmed))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var entries []monitor.AddressBookEntry
+	for i, record := range records {
+		if i == 0 && strings.EqualFold(strings.TrimSpace(record[0]), "address") {
+			continue
+		}
+		entry := monitor.AddressBookEntry{Address: record[0]}
+		if len(record) > 1 {
+			entry.Label = record[1]
+		}
+		entries = append(entries, entry)
+	}
+	return cleanAddressBook(entries), nil
+}
+
+// cleanAddressBook trims the entries and drops those without an address or
+// commented out with #
+func cleanAddressBook(entries []monitor.AddressBookEntry) []monitor.AddressBookEntry {
+	var cleaned []monitor.AddressBookEntry
+	for _, entry := range entries {
+		entry.Address, entry.Label = strings.TrimSpace(entry.Address), strings.TrimSpace(entry.Label)
+		if entry.Address != "" && !strings.HasPrefix(entry.Address, "#") {
+			cleaned = append(cleaned, entry)
+		}
+	}
+	return cleaned
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// newAPIHandler builds the HTTP handler for the authenticated API, plus the
+// Slack OAuth redirect when slackApp is set and the Slack interactivity
+// endpoint when a signing secret is
+func newAPIHandler(config Config, m *monitor.Monitor, slackApp *notify.SlackApp) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/api/balances", requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleBalances(w, r, m)
+	}))
+	mux.Handle("/api/failures", requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleFailures(w, r, m)
+	}))
+	mux.Handle("/api/queue", requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleQueue(w, r, m)
+	}))
+	mux.Handle("/api/payouts", requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handlePayouts(w, r, m)
+	}))
+	mux.Handle("/api/utxos", requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleUTXOs(w, r, m)
+	}))
+	mux.Handle("/api/node", requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleNode(w, r, m)
+	}))
+	// A check saves state and sends alerts, so reading isn't enough
+	mux.Handle("/api/check", requireRole(config, RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		handleCheck(w, r, m)
+	}))
+	mux.Handle("/api/calendar.ics", queryToken(requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleCalendar(w, r, config, m)
+	})))
+	mux.Handle("/api/feed.atom", queryToken(requireRole(config, RoleRead, func(w http.ResponseWriter, r *http.Request) {
+		handleFeed(w, r, m)
+	})))
+	mux.Handle("/api/subscriptions", requireRole(config, RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		handleSubscriptions(w, r, config, m)
+	}))
+	// Tenants must prove they own the addresses they add; the operator's
+	// own admins needn't
+	var verifier *ownershipVerifier
+	if config.Tenant != "" {
+		verifier = newOwnershipVerifier(config)
+	}
+	mux.Handle("/api/watchlist", requireRole(config, RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		actor, _ := apiActor(config, r)
+		handleWatchlist(w, r, m, verifier, actor)
+	}))
+	mux.Handle("/api/mute", requireRole(config, RoleAdmin, func(w http.ResponseWriter, r *http.Request) {
+		handleMute(w, r, m)
+	}))
+	if slackApp != nil {
+		installer := &slackInstaller{config: config, app: slackApp, m: m, states: map[string]time.Time{}}
+		mux.Handle("/api/slack/install", requireRole(config, RoleAdmin, installer.handleInstall))
+		mux.HandleFunc(slackOAuthPath, installer.handleOAuth)
+	}
+	if config.SlackSigningSecret != "" {
+		actions := &slackActions{config: config, app: slackApp, m: m}
+		mux.HandleFunc(slackActionsPath, actions.handle)
+	}
+	return mux
+}
+
+// requireRole rejects requests whose bearer token doesn't grant at least the
+// given role, and records every attempt in the audit log
+func requireRole(config Config, required Role, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor, role := apiActor(config, r)
+		allowed := role >= required
+		auditLog(config.AuditLogFile, actor, role, r.Method+" "+r.URL.Path, r.URL.Query().Get("address"), allowed)
+
+		if role == RoleNone {
+			writeJSONError(w, http.StatusUnauthorized, "invalid or missing API token")
+			return
+		}
+		if !allowed {
+			writeJSONError(w, http.StatusForbidden, "token lacks the "+required.String()+" role")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// apiActor authenticates the bearer token of a request, and returns who
+// made it, as named in the audit log, e.g. "api:grafana", and its role
+func apiActor(config Config, r *http.Request) (string, Role) {
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	name, role := authenticateToken(config.APITokens, provided)
+	if name == "" {
+		return "api:anonymous", role
+	}
+	return "api:" + name, role
+}
+
+// handleBalances returns the balances of all watched addresses as last
+// saved, or of those carrying any of the tags given as ?tag=cold,hot,
+// without waiting for a check in progress. With ?maxStaleness=5m, those not
+// checked successfully within that long are re-checked in the background
+// and listed as "refreshing"; poll again for their new balances.
+func handleBalances(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	var maxStaleness time.Duration
+	if value := r.URL.Query().Get("maxStaleness"); value != "" {
+		var err error
+		if maxStaleness, err = time.ParseDuration(value); err != nil || maxStaleness < 0 {
+			writeJSONError(w, http.StatusBadRequest, "maxStaleness must be a duration like 30s or 5m")
+			return
+		}
+	}
+
+	tags := parseTags(r.URL.Query().Get("tag"))
+	cached := m.Cached()
+	balances := []monitor.BalanceData{}
+	addresses := []string{}
+	for _, balance := range cached.Balances {
+		if m.HasTag(balance.Address, tags...) {
+			balances = append(balances, balance)
+			addresses = append(addresses, balance.Address)
+		}
+	}
+	statuses := map[string]monitor.AddressStatus{}
+	for address, status := range cached.Status {
+		if m.HasTag(address, tags...) {
+			statuses[address] = status
+		}
+	}
+	response := map[string]interface{}{
+		"balances": balances,
+		"status":   statuses,
+		"tags":     m.Tags,
+		"signers":  cached.Signers,
+	}
+	if r.URL.Query().Has("maxStaleness") {
+		refreshing := m.RefreshStale(addresses, maxStaleness)
+		if refreshing == nil {
+			refreshing = []string{}
+		}
+		response["refreshing"] = refreshing
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// handleFailures returns the most recent notification delivery failures
+func handleFailures(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"failures": m.DeliveryFailures(),
+	})
+}
+
+// handleQueue returns the alerts waiting to be delivered
+func handleQueue(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"queue": m.Queued(),
+	})
+}
+
+// handlePayouts returns payout statistics for all watched addresses
+func handlePayouts(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"payouts": m.Payouts(),
+		"inflows": m.InflowStatuses(),
+	})
+}
+
+// handleNode returns the last observed status of the monitored node
+func handleNode(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	status := m.NodeStatus()
+	if status == nil {
+		writeJSONError(w, http.StatusNotFound, "no node is monitored or it hasn't been checked yet")
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleUTXOs returns the unspent output counts of all watched addresses
+func handleUTXOs(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"utxos": m.UTXOStats(),
+	})
+}
+
+// handleCheck runs an immediate balance check for one watched address, or
+// all of them when no address is given, and returns the results
+func handleCheck(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+
+	address := strings.TrimSpace(r.URL.Query().Get("address"))
+	if address == "" {
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"results": m.CheckAll(),
+		})
+		return
+	}
+
+	result, err := m.Check(address)
+	if errors.Is(err, monitor.ErrNotWatched) {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, monitor.ErrNotOwned) {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error checking balance for %s: %v", address, err)
+		result.Error = err.Error()
+		writeJSON(w, http.StatusBadGateway, result)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// handleWatchlist adds (POST) or removes (DELETE) a runtime-watched address.
+// With a verifier, a POST without &signature= returns a challenge to sign
+// with the address's key, and one with the signature adds the address; the
+// challenge is the actor's, so only the token that asked for it can use it.
+func handleWatchlist(w http.ResponseWriter, r *http.Request, m *monitor.Monitor, verifier *ownershipVerifier, actor string) {
+	address := strings.TrimSpace(r.URL.Query().Get("address"))
+	if address == "" {
+		writeJSONError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+
+	var err error
+	switch r.Method {
+	case http.MethodPost:
+		if _, err := m.Source.GetBalance(address); err != nil {
+			log.Printf("Error checking balance for %s: %v", address, err)
+			writeJSONError(w, http.StatusBadGateway, "could not verify address against the RPC")
+			return
+		}
+		if verifier != nil {
+			signature := r.URL.Query().Get("signature")
+			if signature == "" {
+				challenge, err := verifier.challenge(actor, address)
+				if errors.Is(err, errInvalidAddress) {
+					writeJSONError(w, http.StatusBadRequest, err.Error())
+					return
+				}
+				if err != nil {
+					log.Printf("Error issuing ownership challenge for %s: %v", address, err)
+					writeJSONError(w, http.StatusInternalServerError, "could not issue a challenge")
+					return
+				}
+				writeJSON(w, http.StatusPreconditionRequired, map[string]string{
+					"error":     "sign the challenge with the address's key and POST again with &signature=",
+					"challenge": challenge,
+				})
+				return
+			}
+			verified, err := verifier.verify(actor, signature)
+			if err != nil {
+				writeJSONError(w, http.StatusForbidden, "ownership not verified: "+err.Error())
+				return
+			}
+			if verified != address {
+				writeJSONError(w, http.StatusForbidden, "ownership not verified: the pending challenge is for "+verified)
+				return
+			}
+		}
+		err = m.Watch(address)
+	case http.MethodDelete:
+		err = m.Unwatch(address)
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST or DELETE")
+		return
+	}
+	if err != nil {
+		writeStateError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"address": address})
+}
+
+// handleMute mutes alerts for an address for a duration (POST) or lifts the
+// mute (DELETE)
+func handleMute(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	address := strings.TrimSpace(r.URL.Query().Get("address"))
+	if address == "" {
+		writeJSONError(w, http.StatusBadRequest, "address is required")
+		return
+	}
+
+	var until time.Time
+	switch r.Method {
+	case http.MethodPost:
+		duration, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || duration <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "duration must be a positive Go duration such as 1h or 30m")
+			return
+		}
+		until = time.Now().Add(duration)
+	case http.MethodDelete:
+	default:
+		w.Header().Set("Allow", "POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST or DELETE")
+		return
+	}
+
+	if err := m.Mute(address, until); err != nil {
+		writeStateError(w, err)
+		return
+	}
+	response := map[string]interface{}{"address": address, "muted": !until.IsZero()}
+	if !until.IsZero() {
+		response["mutedUntil"] = until.Format(time.RFC3339)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// writeStateError maps watchlist errors to HTTP status codes
+func writeStateError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, monitor.ErrNotWatched):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, monitor.ErrAlreadyWatched), errors.Is(err, monitor.ErrConfiguredAddress):
+		writeJSONError(w, http.StatusConflict, err.Error())
+	default:
+		log.Printf("Error saving state: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "could not save state")
+	}
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing API response: %v", err)
+	}
+}
+
+// writeJSONError writes an error message as a JSON response
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// runBalances prints the balances stored in balances.json, optionally only
+// those of addresses carrying any of the given tags
+func runBalances(args []string) {
+	flags := flag.NewFlagSet("balances", flag.ExitOnError)
+	tag := flags.String("tag", "", "only list addresses with any of these comma-separated tags")
+	flags.Parse(args)
+
+	// Nothing is sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	m := newMonitor(config, nil, monitor.FileStore{Path: balanceFile})
+	if err := m.Load(); err != nil {
+		log.Fatalf("Error loading %s: %v", balanceFile, err)
+	}
+	m.SummaryTags = parseTags(*tag)
+
+	balances := m.Summary()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ADDRESS\tLABEL\tTAGS\tBALANCE")
+	for _, balance := range balances {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", balance.Address, balance.Label, strings.Join(balance.Tags, ","), m.Format.Balance(balance.CurrentBalance))
+	}
+	total, _ := notify.SummaryTotals(balances)
+	fmt.Fprintf(w, "Total\t\t\t%s\n", m.Format.Balance(total))
+	w.Flush()
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/dedupe"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/rpc"
+)
+
+// benchCycle is what one measured check cycle did
+type benchCycle struct {
+	duration      time.Duration
+	requests      int64
+	changes       int
+	notifications int64
+	heap          uint64
+}
+
+// runBench checks simulated addresses against an in-process mock RPC for a
+// few cycles and reports cycle time, RPC calls, memory and notification
+// throughput, to size a deployment or catch performance regressions. It
+// uses the features enabled in .env, but never the real RPC, notifiers,
+// hook or balances.json; state is saved to a temporary directory, so
+// cycles include writing it as they would in production.
+func runBench(args []string) {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	addresses := flags.Int("addresses", 1000, "number of simulated addresses")
+	cycles := flags.Int("cycles", 5, "check cycles to measure after the initial one")
+	changeRate := flags.Float64("change-rate", 0.05, "fraction of addresses whose balance changes before each cycle")
+	rpcLatency := flags.Duration("rpc-latency", 0, "delay added to every mock RPC response, e.g. 20ms for a remote node")
+	notifyLatency := flags.Duration("notify-latency", 0, "time each simulated notification takes to send")
+	shards := flags.Int("shards", 0, "check in this many slices, like CHECK_SHARDS (default: from .env)")
+	maxCycle := flags.Duration("max-cycle", 0, "exit with status 1 if the mean cycle takes longer, for CI")
+	flags.Parse(args)
+	if *addresses < 1 || *cycles < 1 || *changeRate < 0 || *changeRate > 1 {
+		log.Fatal("bench needs at least 1 address and cycle, and a change rate between 0 and 1")
+	}
+
+	// Nothing real is queried or sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	server := rpc.NewMockServer()
+	var requests atomic.Int64
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Error starting mock RPC: %v", err)
+	}
+	go http.Serve(listener, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(*rpcLatency)
+		server.ServeHTTP(w, r)
+	}))
+
+	random := rand.New(rand.NewSource(1))
+	config.Addresses = make([]string, *addresses)
+	for i := range config.Addresses {
+		config.Addresses[i] = fmt.Sprintf("bench%08d", i)
+		server.SetBalance(config.Addresses[i], 1_000_000+random.Int63n(1_000_000_000), 0)
+	}
+	// Only the simulated addresses, against the mock, without side effects
+	config.RPCURL = "http://" + listener.Addr().String()
+	config.GraphQLURL = ""
+	config.ChainRPCURLs = nil
+	config.WatchPatterns = nil
+	config.Wallets = nil
+	config.AlertHookCommand = ""
+	config.DeliveryQueue = false
+	config.PriceProvider = ""
+	config.NodeStatusURL = ""
+	config.CatchUpAfter = 0
+	config.CycleDeadline = 0 // Cycles are timed in full
+	if *shards > 0 {
+		config.CheckShards = *shards
+	}
+
+	dir, err := os.MkdirTemp("", "nockchain-bench")
+	if err != nil {
+		log.Fatalf("Error creating state directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	stateFile := filepath.Join(dir, balanceFile)
+
+	counter := &benchNotifier{latency: *notifyLatency}
+	m := newMonitor(config, newBalanceSource(config, &http.Client{}), monitor.FileStore{Path: stateFile}, counter)
+	if config.DedupeTransactions {
+		notified, err := dedupe.Open(filepath.Join(dir, notifiedFile))
+		if err != nil {
+			log.Fatalf("Error opening record of reported transactions: %v", err)
+		}
+		defer notified.Close()
+		m.Notified = notified
+	}
+	if err := m.Load(); err != nil {
+		log.Fatalf("Error loading state: %v", err)
+	}
+	check := func() {
+		if config.CheckShards <= 1 {
+			m.CheckAll()
+			return
+		}
+		for {
+			if _, complete := m.CheckShard(); complete {
+				return
+			}
+		}
+	}
+	measure := func() benchCycle {
+		requests.Store(0)
+		counter.sent.Store(0)
+		start := time.Now()
+		check()
+		cycle := benchCycle{duration: time.Since(start), requests: requests.Load(), notifications: counter.sent.Load()}
+		runtime.GC()
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		cycle.heap = stats.HeapAlloc
+		return cycle
+	}
+
+	fmt.Printf("Benchmarking %d addresses, %d cycles, %.0f%% changing per cycle, RPC latency %s, notification latency %s, shards %d\n\n",
+		*addresses, *cycles, *changeRate*100, *rpcLatency, *notifyLatency, max(config.CheckShards, 1))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "CYCLE\tDURATION\tPER ADDRESS\tRPC CALLS\tCHANGES\tNOTIFICATIONS\tHEAP\t")
+	printCycle := func(name string, c benchCycle) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%d\t%s\t\n", name, c.duration.Round(time.Millisecond), (c.duration / time.Duration(*addresses)).Round(time.Microsecond), c.requests, c.changes, c.notifications, formatBytes(c.heap))
+	}
+
+	initial := measure()
+	initial.changes = *addresses
+	printCycle("initial", initial)
+	var total time.Duration
+	var worst benchCycle
+	var notifications int64
+	for i := 1; i <= *cycles; i++ {
+		changed := 0
+		for _, address := range config.Addresses {
+			if random.Float64() < *changeRate {
+				server.AddBalance(address, random.Int63n(2_000_000)-1_000_000, 1000)
+				changed++
+			}
+		}
+		c := measure()
+		c.changes = changed
+		printCycle(fmt.Sprint(i), c)
+		total += c.duration
+		if c.duration > worst.duration {
+			worst = c
+		}
+		notifications += c.notifications
+	}
+
+	start := time.Now()
+	m.SendSummary()
+	summaryTime := time.Since(start)
+	w.Flush()
+
+	mean := total / time.Duration(*cycles)
+	fmt.Println()
+	fmt.Printf("Mean cycle: %s, worst: %s (check interval %s)\n", mean.Round(time.Millisecond), worst.duration.Round(time.Millisecond), checkInterval)
+	if perAddress := mean / time.Duration(*addresses); perAddress > 0 {
+		fmt.Printf("Capacity: about %d addresses fit in one check interval at this rate\n", int64(checkInterval/perAddress))
+	}
+	if total > 0 && notifications > 0 {
+		fmt.Printf("Notifications: %d sent, %.1f per second of cycle time\n", notifications, float64(notifications)/total.Seconds())
+	}
+	fmt.Printf("Summary of %d addresses built and sent in %s\n", *addresses, summaryTime.Round(time.Millisecond))
+	if info, err := os.Stat(stateFile); err == nil {
+		fmt.Printf("State: %s saved to %s each cycle\n", formatBytes(uint64(info.Size())), balanceFile)
+	}
+	if *maxCycle > 0 && mean > *maxCycle {
+		fmt.Printf("FAIL: mean cycle %s exceeds -max-cycle %s\n", mean.Round(time.Millisecond), *maxCycle)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+}
+
+// benchNotifier counts notifications instead of sending them, taking
+// latency for each
+type benchNotifier struct {
+	latency time.Duration
+	sent    atomic.Int64
+}
+
+func (b *benchNotifier) Name() string { return "bench" }
+
+func (b *benchNotifier) NotifyChange(notify.Change) error { return b.send() }
+
+func (b *benchNotifier) NotifySummary([]notify.Balance) error { return b.send() }
+
+func (b *benchNotifier) NotifyAlert(notify.Alert) error { return b.send() }
+
+// send simulates delivering one notification
+func (b *benchNotifier) send() error {
+	time.Sleep(b.latency)
+	b.sent.Add(1)
+	return nil
+}
+
+// formatBytes renders a byte count in KiB or MiB
+func formatBytes(n uint64) string {
+	if n >= 1<<20 {
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	}
+	return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// calendarDays is how far ahead scheduled summaries and reports are listed
+const calendarDays = 14
+
+// calendarDomain makes event UIDs globally unique
+const calendarDomain = "nockchain-balance-alerter"
+
+// calendarEvent is one VEVENT of the feed
+type calendarEvent struct {
+	uid         string
+	start       time.Time
+	summary     string
+	description string
+	category    string
+}
+
+// runCalendar writes the balance changes recorded in balances.json and the
+// upcoming summaries and reports as an iCalendar (.ics) file
+func runCalendar(args []string) {
+	flags := flag.NewFlagSet("calendar", flag.ExitOnError)
+	tag := flags.String("tag", "", "only include addresses with any of these comma-separated tags")
+	output := flags.String("output", "", "file to write (default: standard output)")
+	flags.Parse(args)
+
+	// Nothing is sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	m := newMonitor(config, nil, monitor.FileStore{Path: balanceFile})
+	if err := m.Load(); err != nil {
+		log.Fatalf("Error loading %s: %v", balanceFile, err)
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	if err := writeCalendar(w, config, m, parseTags(*tag), time.Now()); err != nil {
+		log.Fatalf("Error writing calendar: %v", err)
+	}
+}
+
+// handleCalendar returns the calendar feed, of the addresses carrying any of
+// the tags given as ?tag=cold,hot
+func handleCalendar(w http.ResponseWriter, r *http.Request, config Config, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="nockchain.ics"`)
+	if err := writeCalendar(w, config, m, parseTags(r.URL.Query().Get("tag")), time.Now()); err != nil {
+		log.Printf("Error writing calendar: %v", err)
+	}
+}
+
+// queryToken lets clients that can't send headers, such as calendar apps
+// subscribing to a feed, pass their API token as ?token=
+func queryToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeCalendar writes the recorded balance changes of addresses with any of
+// the tags, and the summaries and reports due in the next calendarDays, as
+// an iCalendar file
+func writeCalendar(w io.Writer, config Config, m *monitor.Monitor, tags []string, now time.Time) error {
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		return err
+	}
+
+	var events []calendarEvent
+	for _, transfer := range m.Transfers(time.Time{}, now) {
+		if m.HasTag(transfer.Address, tags...) {
+			events = append(events, transferEvent(transfer, m.Labels[transfer.Address], m.Format))
+		}
+	}
+	until := now.AddDate(0, 0, calendarDays)
+	for _, j := range summaryJobs(config) {
+		title := "Balance summary"
+		if j.names != nil {
+			title = strings.Join(j.names, "/") + " balance summary"
+		}
+		for _, run := range nextRuns(now.In(location), until, j.schedule.Times, j.schedule.Days) {
+			events = append(events, scheduledEvent(title, run))
+		}
+	}
+	switch config.ReportSchedule {
+	case reportDaily:
+		for _, run := range nextRuns(now.In(location), until, []string{config.ReportTime}, nil) {
+			events = append(events, scheduledEvent("Daily earnings report", run))
+		}
+	case reportWeekly:
+		for _, run := range nextRuns(now.In(location), until, []string{config.ReportTime}, []time.Weekday{time.Monday}) {
+			events = append(events, scheduledEvent("Weekly earnings report", run))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].start.Before(events[j].start) })
+
+	var b strings.Builder
+	line := func(name, value string) { b.WriteString(foldCalendarLine(name + ":" + value)) }
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//Nockchain Balance Alerter//"+version+"//EN")
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+	line("X-WR-CALNAME", "Nockchain Balances")
+	stamp := now.UTC().Format(calendarTime)
+	for _, event := range events {
+		line("BEGIN", "VEVENT")
+		line("UID", event.uid)
+		line("DTSTAMP", stamp)
+		line("DTSTART", event.start.UTC().Format(calendarTime))
+		line("SUMMARY", escapeCalendarText(event.summary))
+		if event.description != "" {
+			line("DESCRIPTION", escapeCalendarText(event.description))
+		}
+		line("CATEGORIES", escapeCalendarText(event.category))
+		line("TRANSP", "TRANSPARENT")
+		line("END", "VEVENT")
+	}
+	line("END", "VCALENDAR")
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// calendarTime is the iCalendar UTC date-time format
+const calendarTime = "20060102T150405Z"
+
+// transferTitle describes a balance change in one line, e.g. "Treasury
+// received +1,250 $NOCK"
+func transferTitle(transfer monitor.Transfer, label string, format notify.Formatter) string {
+	name := label
+	if name == "" {
+		name = shortAddress(transfer.Address)
+	}
+	verb := "received"
+	if transfer.Amount < 0 {
+		verb = "sent"
+	}
+	return fmt.Sprintf("%s %s %s", name, verb, format.Delta(transfer.Amount))
+}
+
+// transferEvent describes a balance change as an event
+func transferEvent(transfer monitor.Transfer, label string, format notify.Formatter) calendarEvent {
+	return calendarEvent{
+		uid:         fmt.Sprintf("change-%s-%d@%s", transfer.Address, transfer.Time.Unix(), calendarDomain),
+		start:       transfer.Time,
+		summary:     transferTitle(transfer, label, format),
+		description: fmt.Sprintf("Address: %s\nBalance after: %s", transfer.Address, format.Balance(transfer.Balance)),
+		category:    "Balance change",
+	}
+}
+
+// scheduledEvent describes a summary or report due at run
+func scheduledEvent(title string, run time.Time) calendarEvent {
+	slug := strings.ToLower(strings.NewReplacer(" ", "-", "/", "-").Replace(title))
+	return calendarEvent{
+		uid:      fmt.Sprintf("%s-%d@%s", slug, run.Unix(), calendarDomain),
+		start:    run,
+		summary:  title,
+		category: "Scheduled",
+	}
+}
+
+// nextRuns returns the HH:MM times of day after now and up to until, in
+// now's location, only counting the given weekdays if there are any
+func nextRuns(now, until time.Time, times []string, days []time.Weekday) []time.Time {
+	var runs []time.Time
+	for day := now; !day.After(until.AddDate(0, 0, 1)); day = day.AddDate(0, 0, 1) {
+		if len(days) > 0 && !containsWeekday(days, day.Weekday()) {
+			continue
+		}
+		for _, clock := range times {
+			t, err := time.ParseInLocation("15:04", clock, now.Location())
+			if err != nil {
+				continue
+			}
+			run := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+			if run.After(now) && !run.After(until) {
+				runs = append(runs, run)
+			}
+		}
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].Before(runs[j]) })
+	return runs
+}
+
+// escapeCalendarText escapes a TEXT property value
+func escapeCalendarText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// foldCalendarLine ends a content line with CRLF, folding it into lines of
+// at most 75 octets without splitting a UTF-8 character
+func foldCalendarLine(s string) string {
+	var b strings.Builder
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space
+		limit = 74
+	}
+	b.WriteString(s + "\r\n")
+	return b.String()
+}
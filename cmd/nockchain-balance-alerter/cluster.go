@@ -0,0 +1,203 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/dedupe"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+const (
+	clusterHeartbeat = 20 * time.Second     // How often each instance marks itself live
+	clusterTTL       = 3 * clusterHeartbeat // How long an instance counts as live after its last heartbeat
+	heartbeatFile    = "heartbeat"
+)
+
+// instanceID is the pattern of an INSTANCE_ID, which names its directory
+// in CLUSTER_DIR
+var instanceID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// cluster implements monitor.Cluster over a directory shared by every
+// instance, e.g. on NFS. Each instance keeps its state in a subdirectory
+// named after its ID and touches a heartbeat file there; the live
+// instances split addresses by rendezvous hashing, so one joining or
+// leaving only moves the addresses it gains or loses.
+type cluster struct {
+	dir string
+	id  string
+
+	mu       sync.Mutex
+	members  []string                 // Live instance IDs, sorted
+	notified map[string]*dedupe.Store // Other instances' records of reported transactions, opened once
+}
+
+// newCluster joins the cluster in dir as the instance id
+func newCluster(dir, id string) (*cluster, error) {
+	c := &cluster{dir: dir, id: id}
+	if err := os.MkdirAll(filepath.Join(dir, id), 0755); err != nil {
+		return nil, err
+	}
+	if err := c.heartbeat(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// statePath returns where this instance keeps its balances.json
+func (c *cluster) statePath() string {
+	return filepath.Join(c.dir, c.id, balanceFile)
+}
+
+// heartbeat marks this instance live and refreshes which others are
+func (c *cluster) heartbeat() error {
+	now := time.Now()
+	if err := os.WriteFile(filepath.Join(c.dir, c.id, heartbeatFile), []byte(strconv.FormatInt(now.Unix(), 10)), 0644); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	members := []string{c.id}
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == c.id {
+			continue
+		}
+		if last, ok := c.lastHeartbeat(entry.Name()); ok && now.Sub(last) < clusterTTL {
+			members = append(members, entry.Name())
+		}
+	}
+	sort.Strings(members)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if strings.Join(members, ",") != strings.Join(c.members, ",") {
+		log.Printf("Cluster instances: %s", strings.Join(members, ", "))
+	}
+	c.members = members
+	return nil
+}
+
+// lastHeartbeat returns when an instance last marked itself live
+func (c *cluster) lastHeartbeat(id string) (time.Time, bool) {
+	data, err := os.ReadFile(filepath.Join(c.dir, id, heartbeatFile))
+	if err != nil {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unix, 0), true
+}
+
+// Owns implements monitor.Cluster: a key belongs to the live instance that
+// scores highest for it
+func (c *cluster) Owns(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var owner string
+	var best uint64
+	for _, member := range c.members {
+		sum := sha256.Sum256([]byte(member + "\x00" + key))
+		if score := binary.BigEndian.Uint64(sum[:8]); owner == "" || score > best {
+			owner, best = member, score
+		}
+	}
+	return owner == c.id
+}
+
+// leader reports whether this instance sends what concerns the whole
+// cluster, such as summaries: the live instance with the lowest ID
+func (c *cluster) leader() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.members) > 0 && c.members[0] == c.id
+}
+
+// Peers implements monitor.Cluster, reading the state every other instance
+// saved last
+func (c *cluster) Peers() []monitor.Peer {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		log.Printf("Error listing cluster instances: %v", err)
+		return nil
+	}
+	c.mu.Lock()
+	live := map[string]bool{}
+	for _, member := range c.members {
+		live[member] = true
+	}
+	c.mu.Unlock()
+
+	var peers []monitor.Peer
+	for _, entry := range entries {
+		if !entry.IsDir() || entry.Name() == c.id {
+			continue
+		}
+		path := filepath.Join(c.dir, entry.Name(), balanceFile)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		state, err := monitor.FileStore{Path: path}.Load()
+		if err != nil {
+			log.Printf("Error reading state of instance %s: %v", entry.Name(), err)
+			continue
+		}
+		peer := monitor.Peer{ID: entry.Name(), Live: live[entry.Name()], State: state}
+		if notified := c.peerNotified(entry.Name()); notified != nil {
+			peer.Notified = notified
+		}
+		peers = append(peers, peer)
+	}
+	return peers
+}
+
+// peerNotified returns another instance's record of reported transactions,
+// or nil if it doesn't keep one
+func (c *cluster) peerNotified(id string) *dedupe.Store {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if store, ok := c.notified[id]; ok {
+		return store
+	}
+	path := filepath.Join(c.dir, id, notifiedFile)
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+	store, err := dedupe.Open(path)
+	if err != nil {
+		log.Printf("Error opening reported transactions of instance %s: %v", id, err)
+		return nil
+	}
+	if c.notified == nil {
+		c.notified = map[string]*dedupe.Store{}
+	}
+	c.notified[id] = store
+	return store
+}
+
+// validateCluster checks the CLUSTER_DIR settings
+func validateCluster(config Config) error {
+	if !instanceID.MatchString(config.InstanceID) {
+		return fmt.Errorf("INSTANCE_ID must be letters, digits, ., - and _, got %q", config.InstanceID)
+	}
+	if config.TenantsDir != "" {
+		return fmt.Errorf("CLUSTER_DIR can't be combined with TENANTS_DIR")
+	}
+	if config.TelegramSubscriptions != "" {
+		return fmt.Errorf("CLUSTER_DIR can't be combined with TELEGRAM_SUBSCRIPTIONS, whose subscriptions only one instance would know")
+	}
+	return nil
+}
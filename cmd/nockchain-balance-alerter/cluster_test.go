@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// owners returns which of members owns each key
+func owners(members, keys []string) map[string]string {
+	owned := map[string]string{}
+	for _, id := range members {
+		c := &cluster{id: id, members: members}
+		for _, key := range keys {
+			if c.Owns(key) {
+				owned[key] = id
+			}
+		}
+	}
+	return owned
+}
+
+func TestClusterOwns(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("address-%d", i)
+	}
+	tests := []struct {
+		name    string
+		members []string
+	}{
+		{"single", []string{"a"}},
+		{"two", []string{"a", "b"}},
+		{"five", []string{"a", "b", "c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counts := map[string]int{}
+			for _, key := range keys {
+				var owner []string
+				for _, id := range tt.members {
+					if (&cluster{id: id, members: tt.members}).Owns(key) {
+						owner = append(owner, id)
+					}
+				}
+				if len(owner) != 1 {
+					t.Fatalf("%s owned by %v, want exactly one instance", key, owner)
+				}
+				counts[owner[0]]++
+			}
+			// Rendezvous hashing spreads keys roughly evenly
+			fair := len(keys) / len(tt.members)
+			for _, id := range tt.members {
+				if counts[id] < fair/2 || counts[id] > fair*3/2 {
+					t.Errorf("%s owns %d of %d keys, want about %d", id, counts[id], len(keys), fair)
+				}
+			}
+		})
+	}
+}
+
+func TestClusterOwnsMovesOnlyLeavingKeys(t *testing.T) {
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("address-%d", i)
+	}
+	tests := []struct {
+		name          string
+		before, after []string
+	}{
+		{"instance leaves", []string{"a", "b", "c"}, []string{"a", "c"}},
+		{"instance joins", []string{"a", "c"}, []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := owners(tt.before, keys), owners(tt.after, keys)
+			for _, key := range keys {
+				if before[key] != after[key] && before[key] != "b" && after[key] != "b" {
+					t.Errorf("%s moved from %s to %s, though neither joined nor left", key, before[key], after[key])
+				}
+			}
+		})
+	}
+}
+
+func TestClusterHeartbeat(t *testing.T) {
+	dir := t.TempDir()
+	for id, age := range map[string]time.Duration{"live": time.Second, "stale": 2 * clusterTTL} {
+		if err := os.MkdirAll(filepath.Join(dir, id), 0755); err != nil {
+			t.Fatal(err)
+		}
+		beat := strconv.FormatInt(time.Now().Add(-age).Unix(), 10)
+		if err := os.WriteFile(filepath.Join(dir, id, heartbeatFile), []byte(beat), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := newCluster(dir, "me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprint(c.members), "[live me]"; got != want {
+		t.Errorf("members = %s, want %s", got, want)
+	}
+	if c.leader() {
+		t.Error("me leads, want live, the lowest live ID")
+	}
+}
@@ -0,0 +1,1179 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/rpc"
+	"github.com/joho/godotenv"
+)
+
+// Config holds the application configuration
+type Config struct {
+	SlackBotToken            string                            `json:"slackBotToken"`
+	SlackChannel             string                            `json:"slackChannel"`
+	SlackClientID            string                            `json:"slackClientId"`
+	SlackClientSecret        string                            `json:"slackClientSecret"`
+	SlackRedirectURL         string                            `json:"slackRedirectUrl"`
+	SlackSigningSecret       string                            `json:"slackSigningSecret"`
+	TelegramBotToken         string                            `json:"telegramBotToken"`
+	TelegramChatID           string                            `json:"telegramChatID"`
+	TelegramThreadID         int64                             `json:"telegramThreadID"`
+	TelegramSummaryThreadID  int64                             `json:"telegramSummaryThreadID"`
+	TelegramActions          bool                              `json:"telegramActions"`
+	TelegramAdminIDs         []int64                           `json:"telegramAdminIDs"`
+	TelegramSubscriptions    string                            `json:"telegramSubscriptions"`
+	TelegramMaxSubscriptions int                               `json:"telegramMaxSubscriptions"`
+	Addresses                []string                          `json:"addresses"`
+	WatchPatterns            []string                          `json:"watchPatterns"`
+	RPCURL                   string                            `json:"rpcURL"`
+	UserAgent                string                            `json:"userAgent"`
+	OperatorContact          string                            `json:"operatorContact"`
+	RPCRecordFile            string                            `json:"rpcRecordFile"`
+	ChainRPCURLs             map[string]string                 `json:"chainRPCURLs"`
+	GraphQLURL               string                            `json:"graphqlURL"`
+	GraphQLQuery             string                            `json:"graphqlQuery"`
+	GraphQLBalancePath       string                            `json:"graphqlBalancePath"`
+	GraphQLRelatedPath       string                            `json:"graphqlRelatedPath"`
+	GraphQLMatchQuery        string                            `json:"graphqlMatchQuery"`
+	GraphQLMatchPath         string                            `json:"graphqlMatchPath"`
+	AddressDiscovery         string                            `json:"addressDiscovery"`
+	AlertOnTransactions      bool                              `json:"alertOnTransactions"`
+	MaxUTXOs                 int                               `json:"maxUTXOs"`
+	DustThreshold            int64                             `json:"dustThreshold"`
+	TrackFees                bool                              `json:"trackFees"`
+	ShowMemos                bool                              `json:"showMemos"`
+	MaxFee                   int64                             `json:"maxFee"`
+	TrackLocked              bool                              `json:"trackLocked"`
+	TrackSigners             bool                              `json:"trackSigners"`
+	DedupeTransactions       bool                              `json:"dedupeTransactions"`
+	CatchUpAfter             time.Duration                     `json:"catchUpAfter"`
+	CombineChanges           int                               `json:"combineChanges"`
+	CheckShards              int                               `json:"checkShards"`
+	CheckJitter              time.Duration                     `json:"checkJitter"`
+	ShuffleChecks            bool                              `json:"shuffleChecks"`
+	CombineMaxRows           int                               `json:"combineMaxRows"`
+	DeliveryQueue            bool                              `json:"deliveryQueue"`
+	SendTimeout              time.Duration                     `json:"sendTimeout"`
+	RPCTimeout               time.Duration                     `json:"rpcTimeout"`
+	CycleDeadline            time.Duration                     `json:"cycleDeadline"`
+	RPCBreakerThreshold      int                               `json:"rpcBreakerThreshold"`
+	RPCBreakerProbe          time.Duration                     `json:"rpcBreakerProbe"`
+	AlertOnRPCBreaker        bool                              `json:"alertOnRpcBreaker"`
+	StaleAfter               time.Duration                     `json:"staleAfter"`
+	ConfirmZero              bool                              `json:"confirmZero"`
+	FlapWindow               time.Duration                     `json:"flapWindow"`
+	NodeStatusURL            string                            `json:"nodeStatusURL"`
+	NodeRules                monitor.NodeRules                 `json:"nodeRules"`
+	Labels                   map[string]string                 `json:"labels"`
+	KnownAddressesFile       string                            `json:"knownAddressesFile"`
+	KnownAddresses           map[string]string                 `json:"-"` // Shipped database plus KnownAddressesFile
+	AddressBookURL           string                            `json:"addressBookURL"`
+	AddressBookGit           string                            `json:"addressBookGit"`
+	AddressBookPath          string                            `json:"addressBookPath"`
+	AddressBookToken         string                            `json:"addressBookToken"`
+	AddressBookInterval      time.Duration                     `json:"addressBookInterval"`
+	Groups                   map[string]string                 `json:"groups"`
+	Tags                     map[string][]string               `json:"tags"`
+	SummaryTags              []string                          `json:"summaryTags"`
+	AlertTags                []string                          `json:"alertTags"`
+	SummaryGroupBy           string                            `json:"summaryGroupBy"`
+	Wallets                  map[string]string                 `json:"wallets"`
+	WalletDeriveCommand      string                            `json:"walletDeriveCommand"`
+	OwnershipVerifyCommand   string                            `json:"ownershipVerifyCommand"`
+	AlertHookCommand         string                            `json:"alertHookCommand"`
+	AlertHookTimeout         time.Duration                     `json:"alertHookTimeout"`
+	WalletGapLimit           int                               `json:"walletGapLimit"`
+	CostBasis                map[string]float64                `json:"costBasis"`
+	SummaryMode              string                            `json:"summaryMode"`
+	NumberFormat             notify.NumberFormat               `json:"numberFormat"`
+	SlackUnits               notify.Units                      `json:"slackUnits"`
+	TelegramUnits            notify.Units                      `json:"telegramUnits"`
+	DiscordUnits             notify.Units                      `json:"discordUnits"`
+	SlackOverflow            string                            `json:"slackOverflow"`
+	TelegramOverflow         string                            `json:"telegramOverflow"`
+	DiscordOverflow          string                            `json:"discordOverflow"`
+	SlackLanguage            string                            `json:"slackLanguage"`
+	TelegramLanguage         string                            `json:"telegramLanguage"`
+	DiscordLanguage          string                            `json:"discordLanguage"`
+	TranslationsDir          string                            `json:"translationsDir"`
+	Severities               map[string]notify.Severity        `json:"severities"`
+	SilentSeverity           notify.Severity                   `json:"silentSeverity"`
+	SlackCriticalMention     string                            `json:"slackCriticalMention"`
+	DiscordCriticalMention   string                            `json:"discordCriticalMention"`
+	Denomination             notify.Denomination               `json:"denomination"`
+	Branding                 notify.Branding                   `json:"branding"`
+	SummarySort              string                            `json:"summarySort"`
+	SummaryChart             string                            `json:"summaryChart"`
+	SummaryStats             bool                              `json:"summaryStats"`
+	FlowPeriod               time.Duration                     `json:"flowPeriod"`
+	SparklinePoints          int                               `json:"sparklinePoints"`
+	ReportSchedule           string                            `json:"reportSchedule"`
+	ReportTime               string                            `json:"reportTime"`
+	ReportProjection         bool                              `json:"reportProjection"`
+	SummaryTimes             []string                          `json:"summaryTimes"`
+	SummarySchedules         map[string]SummarySchedule        `json:"summarySchedules"`
+	Timezone                 string                            `json:"timezone"`
+	StartupSnapshot          bool                              `json:"startupSnapshot"`
+	PayoutLatePct            float64                           `json:"payoutLatePercent"`
+	ExpectedInflows          map[string]monitor.ExpectedInflow `json:"expectedInflows"`
+	DormantAfter             time.Duration                     `json:"dormantAfter"`
+	TemplateDir              string                            `json:"templateDir"`
+	ExplorerURL              string                            `json:"explorerURL"`
+
+	DiscordBotToken     string   `json:"discordBotToken"`
+	DiscordChannelID    string   `json:"discordChannelID"`
+	DiscordGuildID      string   `json:"discordGuildID"`
+	DiscordAllowedRoles []string `json:"discordAllowedRoles"`
+	DiscordAdminRoles   []string `json:"discordAdminRoles"`
+
+	WebhookURL    string `json:"webhookURL"`
+	WebhookSource string `json:"webhookSource"`
+	WebhookToken  string `json:"webhookToken"`
+	WebhookSecret string `json:"webhookSecret"`
+
+	EventBridgeBus        string `json:"eventBridgeBus"`
+	EventBridgeDetailType string `json:"eventBridgeDetailType"`
+	EventBridgeSource     string `json:"eventBridgeSource"`
+	AWSRegion             string `json:"awsRegion"`
+	AWSAccessKeyID        string `json:"awsAccessKeyID"`
+	AWSSecretAccessKey    string `json:"awsSecretAccessKey"`
+	AWSSessionToken       string `json:"awsSessionToken"`
+
+	DesktopNotifications bool `json:"desktopNotifications"`
+
+	SyslogAddress  string `json:"syslogAddress"`
+	SyslogFacility string `json:"syslogFacility"`
+	SyslogAppName  string `json:"syslogAppName"`
+	SystemdJournal bool   `json:"systemdJournal"`
+
+	AlertmanagerURL    string            `json:"alertmanagerURL"`
+	AlertmanagerMode   string            `json:"alertmanagerMode"`
+	AlertmanagerLabels map[string]string `json:"alertmanagerLabels"`
+	AlertmanagerToken  string            `json:"alertmanagerToken"`
+
+	ArchiveSlackChannel     string   `json:"archiveSlackChannel"`
+	ArchiveTelegramChatID   string   `json:"archiveTelegramChatID"`
+	ArchiveDiscordChannelID string   `json:"archiveDiscordChannelID"`
+	ArchiveEmailTo          []string `json:"archiveEmailTo"`
+	SMTPAddr                string   `json:"smtpAddr"`
+	SMTPUsername            string   `json:"smtpUsername"`
+	SMTPPassword            string   `json:"smtpPassword"`
+	SMTPFrom                string   `json:"smtpFrom"`
+
+	PushgatewayURL      string `json:"pushgatewayURL"`
+	PushgatewayJob      string `json:"pushgatewayJob"`
+	PushgatewayInstance string `json:"pushgatewayInstance"`
+	PushgatewayToken    string `json:"pushgatewayToken"`
+
+	PriceProvider   string             `json:"priceProvider"`
+	PriceCoinID     string             `json:"priceCoinID"`
+	PriceSymbol     string             `json:"priceSymbol"`
+	PriceAPIKey     string             `json:"priceAPIKey"`
+	PriceURL        string             `json:"priceURL"`
+	PriceCurrencies []string           `json:"priceCurrencies"`
+	PriceCacheTTL   time.Duration      `json:"priceCacheTTL"`
+	PriceRules      monitor.PriceRules `json:"priceRules"`
+
+	APIListenAddr string     `json:"apiListenAddr"`
+	APIToken      string     `json:"apiToken"`
+	APITokens     []APIToken `json:"apiTokens"`
+	AuditLogFile  string     `json:"auditLogFile"`
+	TenantsDir    string     `json:"tenantsDir"`
+	Tenant        string     `json:"tenant"` // Name of the tenant this config is for, empty for the operator's
+	ClusterDir    string     `json:"clusterDir"`
+	InstanceID    string     `json:"instanceID"` // This instance's name in ClusterDir
+}
+
+const (
+	balanceFile   = "balances.json"
+	notifiedFile  = "notified.db" // With DEDUPE_TRANSACTIONS, next to balanceFile
+	slackAppFile  = "slack_installations.json"
+	checkInterval = 1 * time.Minute
+
+	summaryModePost   = "post"
+	summaryModePinned = "pinned"
+
+	reportDaily       = "daily"
+	reportWeekly      = "weekly"
+	defaultReportTime = "08:00"
+
+	subscriptionsOpen               = "open"
+	subscriptionsApproval           = "approval"
+	defaultTelegramMaxSubscriptions = 10
+
+	defaultSummaryTimes = "00:00,06:00,12:00,18:00"
+	summaryOff          = "off"
+	defaultTimezone     = "UTC"
+
+	defaultNumberLocale = "en"
+
+	defaultNodeMaxLag   = 10
+	defaultNodeMinPeers = 1
+
+	defaultAuditLog = "audit.log"
+
+	maxSparklinePoints = 24
+
+	defaultAddressBookInterval = 15 * time.Minute
+	defaultAddressBookPath     = "addresses.csv"
+
+	priceProviderCoinGecko     = "coingecko"
+	priceProviderCoinMarketCap = "coinmarketcap"
+	priceProviderURL           = "url"
+
+	defaultPriceCoinID   = "nockchain"
+	defaultPriceSymbol   = "NOCK"
+	defaultPriceCacheTTL = 5 * time.Minute
+)
+
+// hexColor matches the accent colors accepted by BRAND_COLOR_*
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// errNoNotifiers is returned by loadConfig, after everything else has been
+// parsed, when no notifier is configured
+var errNoNotifiers = errors.New("either SLACK_BOT_TOKEN and SLACK_CHANNEL, SLACK_CLIENT_ID, TELEGRAM_BOT_TOKEN and TELEGRAM_CHAT_ID, DISCORD_BOT_TOKEN and DISCORD_CHANNEL_ID, WEBHOOK_URL, EVENTBRIDGE_BUS, ALERTMANAGER_URL, DESKTOP_NOTIFICATIONS, SYSLOG_ADDRESS, or SYSTEMD_JOURNAL must be set")
+
+// alertmanagerLabelName matches valid Prometheus label names
+var alertmanagerLabelName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// SummarySchedule is when one notifier gets summaries, overriding
+// SUMMARY_TIMES
+type SummarySchedule struct {
+	Times []string       `json:"times"`          // HH:MM in TIMEZONE; none turns summaries off
+	Days  []time.Weekday `json:"days,omitempty"` // Every day if empty
+}
+
+// loadConfig loads configuration from environment variables
+func loadConfig() (Config, error) {
+	if err := godotenv.Load(envFile); err != nil {
+		log.Println("No .env file found, using environment variables directly")
+	}
+	return readConfig(envFile)
+}
+
+// readConfig checks the keys of the .env file at path and the types of the
+// settings' values, then reads every setting with getenv
+func readConfig(path string) (Config, error) {
+	if err := checkEnvFile(path); err != nil {
+		return Config{}, err
+	}
+	config := Config{
+		SlackBotToken:       getenv("SLACK_BOT_TOKEN"),
+		SlackChannel:        getenv("SLACK_CHANNEL"),
+		SlackClientID:       getenv("SLACK_CLIENT_ID"),
+		SlackClientSecret:   getenv("SLACK_CLIENT_SECRET"),
+		SlackRedirectURL:    getenv("SLACK_REDIRECT_URL"),
+		SlackSigningSecret:  getenv("SLACK_SIGNING_SECRET"),
+		TelegramBotToken:    getenv("TELEGRAM_BOT_TOKEN"),
+		TelegramChatID:      getenv("TELEGRAM_CHAT_ID"),
+		TelegramActions:     getenv("TELEGRAM_ACTIONS") == "true",
+		Addresses:           []string{},
+		Labels:              map[string]string{},
+		Groups:              map[string]string{},
+		Tags:                map[string][]string{},
+		Wallets:             map[string]string{},
+		WalletDeriveCommand: getenv("WALLET_DERIVE_COMMAND"),
+		AlertHookCommand:    getenv("ALERT_HOOK_COMMAND"),
+		RPCURL:              getenv("RPC_URL"),
+		UserAgent:           getenv("HTTP_USER_AGENT"),
+		OperatorContact:     getenv("OPERATOR_CONTACT"),
+		RPCRecordFile:       getenv("RPC_RECORD_FILE"),
+		ChainRPCURLs:        map[string]string{},
+		GraphQLURL:          getenv("GRAPHQL_URL"),
+		GraphQLQuery:        getenv("GRAPHQL_QUERY"),
+		GraphQLBalancePath:  getenv("GRAPHQL_BALANCE_PATH"),
+		GraphQLRelatedPath:  getenv("GRAPHQL_RELATED_PATH"),
+		GraphQLMatchQuery:   getenv("GRAPHQL_MATCH_QUERY"),
+		GraphQLMatchPath:    getenv("GRAPHQL_MATCH_PATH"),
+		AddressDiscovery:    strings.ToLower(getenv("ADDRESS_DISCOVERY")),
+		AlertOnTransactions: getenv("ALERT_ON_TRANSACTIONS") == "true",
+		AlertOnRPCBreaker:   getenv("ALERT_ON_RPC_BREAKER") == "true",
+		TrackFees:           getenv("TRACK_FEES") == "true",
+		ShowMemos:           getenv("SHOW_MEMOS") == "true",
+		TrackLocked:         getenv("TRACK_LOCKED") == "true",
+		TrackSigners:        getenv("TRACK_SIGNERS") == "true",
+		DedupeTransactions:  getenv("DEDUPE_TRANSACTIONS") == "true",
+		ConfirmZero:         getenv("CONFIRM_ZERO_BALANCE") == "true",
+		NodeStatusURL:       getenv("NODE_STATUS_URL"),
+		CostBasis:           map[string]float64{},
+		SummaryMode:         getenv("SUMMARY_MODE"),
+		SummarySort:         getenv("SUMMARY_SORT"),
+		SummaryChart:        getenv("SUMMARY_CHART"),
+		SummaryStats:        getenv("SUMMARY_STATS") == "true",
+		ReportSchedule:      getenv("REPORT_SCHEDULE"),
+		ReportTime:          getenv("REPORT_TIME"),
+		ReportProjection:    getenv("REPORT_PROJECTION") == "true",
+		Timezone:            getenv("TIMEZONE"),
+		StartupSnapshot:     getenv("STARTUP_SNAPSHOT") == "true",
+		DeliveryQueue:       getenv("DELIVERY_QUEUE") == "true",
+		TemplateDir:         getenv("TEMPLATE_DIR"),
+		TranslationsDir:     getenv("TRANSLATIONS_DIR"),
+		ExplorerURL:         getenv("EXPLORER_URL"),
+		DiscordBotToken:     getenv("DISCORD_BOT_TOKEN"),
+		DiscordChannelID:    getenv("DISCORD_CHANNEL_ID"),
+		WebhookURL:          getenv("WEBHOOK_URL"),
+		WebhookSource:       getenv("WEBHOOK_SOURCE"),
+		WebhookToken:        getenv("WEBHOOK_TOKEN"),
+		WebhookSecret:       getenv("WEBHOOK_SECRET"),
+		DiscordGuildID:      getenv("DISCORD_GUILD_ID"),
+		APIListenAddr:       getenv("API_LISTEN_ADDR"),
+		APIToken:            getenv("API_TOKEN"),
+		AuditLogFile:        getenv("AUDIT_LOG_FILE"),
+		PriceProvider:       strings.ToLower(getenv("PRICE_PROVIDER")),
+		PriceCoinID:         getenv("PRICE_COIN_ID"),
+		PriceSymbol:         getenv("PRICE_SYMBOL"),
+		PriceAPIKey:         getenv("PRICE_API_KEY"),
+		PriceURL:            getenv("PRICE_URL"),
+		PriceCurrencies:     []string{"usd"},
+		PriceCacheTTL:       defaultPriceCacheTTL,
+	}
+
+	if config.AuditLogFile == "" {
+		config.AuditLogFile = defaultAuditLog
+	}
+
+	if config.ExplorerURL == "" {
+		config.ExplorerURL = notify.DefaultExplorerURL
+	}
+	if strings.Count(config.ExplorerURL, "%s") != 1 {
+		return config, fmt.Errorf("EXPLORER_URL must contain exactly one %%s for the address, got %q", config.ExplorerURL)
+	}
+
+	if config.PriceCoinID == "" {
+		config.PriceCoinID = defaultPriceCoinID
+	}
+	if config.PriceSymbol == "" {
+		config.PriceSymbol = defaultPriceSymbol
+	}
+	if currencies := getenv("PRICE_CURRENCIES"); currencies != "" {
+		config.PriceCurrencies = strings.Split(strings.ToLower(currencies), ",")
+	}
+	config.CatchUpAfter = monitor.DefaultCatchUpAfter
+	if after := getenv("CATCH_UP_AFTER"); after != "" {
+		d, err := time.ParseDuration(after)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("invalid CATCH_UP_AFTER %q", after)
+		}
+		config.CatchUpAfter = d
+	}
+
+	if period := getenv("FLOW_PERIOD"); period != "" {
+		d, err := time.ParseDuration(period)
+		if err != nil || d < 0 || d > 30*24*time.Hour {
+			return config, fmt.Errorf("invalid FLOW_PERIOD %q, expected a duration up to 720h (the history kept)", period)
+		}
+		config.FlowPeriod = d
+	}
+
+	config.StaleAfter = monitor.DefaultStaleAfter
+	if after := getenv("STALE_AFTER"); after != "" {
+		d, err := time.ParseDuration(after)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("invalid STALE_AFTER %q", after)
+		}
+		config.StaleAfter = d
+	}
+
+	config.FlapWindow = monitor.DefaultFlapWindow
+	if window := getenv("FLAP_WINDOW"); window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("invalid FLAP_WINDOW %q", window)
+		}
+		config.FlapWindow = d
+	}
+
+	config.AlertHookTimeout = defaultHookTimeout
+	if timeout := getenv("ALERT_HOOK_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid ALERT_HOOK_TIMEOUT %q", timeout)
+		}
+		config.AlertHookTimeout = d
+	}
+
+	config.SendTimeout = monitor.DefaultSendTimeout
+	if timeout := getenv("SEND_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid SEND_TIMEOUT %q", timeout)
+		}
+		config.SendTimeout = d
+	}
+
+	config.RPCTimeout = defaultRPCTimeout
+	if timeout := getenv("RPC_TIMEOUT"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid RPC_TIMEOUT %q", timeout)
+		}
+		config.RPCTimeout = d
+	}
+
+	if deadline := getenv("CYCLE_DEADLINE"); deadline != "" {
+		d, err := time.ParseDuration(deadline)
+		if err != nil || d < 0 {
+			return config, fmt.Errorf("invalid CYCLE_DEADLINE %q", deadline)
+		}
+		config.CycleDeadline = d
+	}
+
+	config.RPCBreakerThreshold = rpc.DefaultBreakerThreshold
+	if threshold := getenv("RPC_BREAKER_THRESHOLD"); threshold != "" {
+		var err error
+		if config.RPCBreakerThreshold, err = strconv.Atoi(threshold); err != nil || config.RPCBreakerThreshold < 0 {
+			return config, fmt.Errorf("RPC_BREAKER_THRESHOLD must be a non-negative number, got %q", threshold)
+		}
+	}
+	config.RPCBreakerProbe = rpc.DefaultBreakerProbe
+	if probe := getenv("RPC_BREAKER_PROBE"); probe != "" {
+		d, err := time.ParseDuration(probe)
+		if err != nil || d <= 0 {
+			return config, fmt.Errorf("invalid RPC_BREAKER_PROBE %q", probe)
+		}
+		config.RPCBreakerProbe = d
+	}
+
+	if ttl := getenv("PRICE_CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return config, fmt.Errorf("invalid PRICE_CACHE_TTL: %w", err)
+		}
+		config.PriceCacheTTL = d
+	}
+	switch config.PriceProvider {
+	case "", priceProviderCoinGecko:
+	case priceProviderCoinMarketCap:
+		if config.PriceAPIKey == "" {
+			return config, fmt.Errorf("PRICE_API_KEY must be set for the coinmarketcap price provider")
+		}
+	case priceProviderURL:
+		if config.PriceURL == "" {
+			return config, fmt.Errorf("PRICE_URL must be set for the url price provider")
+		}
+	default:
+		return config, fmt.Errorf("PRICE_PROVIDER must be coingecko, coinmarketcap, or url, got %q", config.PriceProvider)
+	}
+
+	rules, err := parsePriceRules(config.PriceCurrencies[0])
+	if err != nil {
+		return config, err
+	}
+	config.PriceRules = rules
+	if config.PriceProvider == "" && (rules.Above > 0 || rules.Below > 0 || len(rules.Changes) > 0) {
+		return config, fmt.Errorf("PRICE_PROVIDER must be set to use PRICE_ALERT_* rules")
+	}
+
+	if config.SummaryMode == "" {
+		config.SummaryMode = summaryModePost
+	}
+	if config.SummaryMode != summaryModePost && config.SummaryMode != summaryModePinned {
+		return config, fmt.Errorf("SUMMARY_MODE must be %q or %q, got %q", summaryModePost, summaryModePinned, config.SummaryMode)
+	}
+	if err := notify.SortBalances(nil, config.SummarySort); err != nil {
+		return config, fmt.Errorf("SUMMARY_SORT must be %q, %q or %q: %w", notify.SortBalance, notify.SortChange, notify.SortLabel, err)
+	}
+	if late := getenv("PAYOUT_LATE_PERCENT"); late != "" {
+		if config.PayoutLatePct, err = strconv.ParseFloat(late, 64); err != nil || config.PayoutLatePct <= 0 {
+			return config, fmt.Errorf("PAYOUT_LATE_PERCENT must be a positive number, got %q", late)
+		}
+	}
+
+	locale := getenv("NUMBER_LOCALE")
+	if locale == "" {
+		locale = defaultNumberLocale
+	}
+	format, ok := notify.Locales[strings.ToLower(locale)]
+	if !ok {
+		return config, fmt.Errorf("NUMBER_LOCALE must be en, de, fr, ch, or raw, got %q", locale)
+	}
+	format.Compact = getenv("NUMBER_COMPACT") == "true"
+	config.NumberFormat = format
+
+	for name, units := range map[string]*notify.Units{
+		"SLACK_UNITS":    &config.SlackUnits,
+		"TELEGRAM_UNITS": &config.TelegramUnits,
+		"DISCORD_UNITS":  &config.DiscordUnits,
+	} {
+		if *units, err = notify.ParseUnits(getenv(name)); err != nil {
+			return config, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	for name, overflow := range map[string]*string{
+		"SLACK_OVERFLOW":    &config.SlackOverflow,
+		"TELEGRAM_OVERFLOW": &config.TelegramOverflow,
+		"DISCORD_OVERFLOW":  &config.DiscordOverflow,
+	} {
+		if *overflow, err = notify.ParseOverflow(getenv(name)); err != nil {
+			return config, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	language := getenv("LANGUAGE")
+	if language == "" {
+		language = notify.DefaultLanguage
+	}
+	for name, value := range map[string]*string{
+		"SLACK_LANGUAGE":    &config.SlackLanguage,
+		"TELEGRAM_LANGUAGE": &config.TelegramLanguage,
+		"DISCORD_LANGUAGE":  &config.DiscordLanguage,
+	} {
+		if *value = getenv(name); *value == "" {
+			*value = language
+		}
+		if _, err := notify.LoadTranslations(config.TranslationsDir, *value); err != nil {
+			return config, fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+
+	if config.Severities, err = parseSeverities(getenv("ALERT_SEVERITY")); err != nil {
+		return config, err
+	}
+	if silent := getenv("SILENT_SEVERITY"); silent != "" {
+		if config.SilentSeverity, err = notify.ParseSeverity(silent); err != nil {
+			return config, fmt.Errorf("invalid SILENT_SEVERITY: %w", err)
+		}
+	}
+	config.SlackCriticalMention = getenv("SLACK_CRITICAL_MENTION")
+	config.DiscordCriticalMention = getenv("DISCORD_CRITICAL_MENTION")
+
+	config.Branding = notify.DefaultBranding
+	for name, value := range map[string]*string{
+		"BRAND_CHANGE_EMOJI":  &config.Branding.ChangeEmoji,
+		"BRAND_CHANGE_TITLE":  &config.Branding.ChangeTitle,
+		"BRAND_SUMMARY_EMOJI": &config.Branding.SummaryEmoji,
+		"BRAND_SUMMARY_TITLE": &config.Branding.SummaryTitle,
+	} {
+		if v := getenv(name); v != "" {
+			*value = v
+		}
+	}
+	for name, color := range map[string]*string{
+		"BRAND_COLOR_INCREASE": &config.Branding.IncreaseColor,
+		"BRAND_COLOR_DECREASE": &config.Branding.DecreaseColor,
+		"BRAND_COLOR_NEW":      &config.Branding.NewColor,
+	} {
+		if v := getenv(name); v != "" {
+			if !hexColor.MatchString(v) {
+				return config, fmt.Errorf("%s must be a hex color like #2eb886, got %q", name, v)
+			}
+			*color = v
+		}
+	}
+
+	config.Denomination = notify.DefaultDenomination
+	if name := getenv("BASE_UNIT_NAME"); name != "" {
+		config.Denomination.BaseUnit = name
+	}
+	if name := getenv("UNIT_NAME"); name != "" {
+		config.Denomination.Unit = name
+	}
+	if per := getenv("BASE_UNITS_PER_UNIT"); per != "" {
+		if config.Denomination.BaseUnitsPerUnit, err = strconv.ParseInt(per, 10, 64); err != nil || config.Denomination.BaseUnitsPerUnit <= 0 {
+			return config, fmt.Errorf("BASE_UNITS_PER_UNIT must be a positive integer, got %q", per)
+		}
+	}
+	if decimals := getenv("UNIT_DECIMALS"); decimals != "" {
+		if config.Denomination.Decimals, err = strconv.Atoi(decimals); err != nil || config.Denomination.Decimals < 0 || config.Denomination.Decimals > 18 {
+			return config, fmt.Errorf("UNIT_DECIMALS must be between 0 and 18, got %q", decimals)
+		}
+	}
+
+	if points := getenv("SUMMARY_SPARKLINE"); points != "" {
+		if config.SparklinePoints, err = strconv.Atoi(points); err != nil || config.SparklinePoints < 2 || config.SparklinePoints > maxSparklinePoints {
+			return config, fmt.Errorf("SUMMARY_SPARKLINE must be a number of snapshots from 2 to %d, got %q", maxSparklinePoints, points)
+		}
+	}
+
+	if config.ExpectedInflows, err = parseExpectedInflows(getenv("EXPECTED_INFLOWS"), config.Denomination); err != nil {
+		return config, err
+	}
+
+	if days := getenv("DORMANT_DAYS"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			return config, fmt.Errorf("DORMANT_DAYS must be a positive number of days, got %q", days)
+		}
+		config.DormantAfter = time.Duration(n) * 24 * time.Hour
+	}
+
+	if config.ReportTime == "" {
+		config.ReportTime = defaultReportTime
+	}
+	if _, err := time.Parse("15:04", config.ReportTime); err != nil {
+		return config, fmt.Errorf("REPORT_TIME must be HH:MM, got %q", config.ReportTime)
+	}
+	if config.Timezone == "" {
+		config.Timezone = defaultTimezone
+	}
+	if _, err := time.LoadLocation(config.Timezone); err != nil {
+		return config, fmt.Errorf("TIMEZONE must be an IANA time zone such as Europe/Berlin: %w", err)
+	}
+	summaryTimes := getenv("SUMMARY_TIMES")
+	if summaryTimes == "" {
+		summaryTimes = defaultSummaryTimes
+	}
+	if config.SummaryTimes, err = parseSummaryTimes("SUMMARY_TIMES", summaryTimes); err != nil {
+		return config, err
+	}
+	config.SummarySchedules = map[string]SummarySchedule{}
+	for _, name := range summaryNotifiers {
+		prefix := strings.ToUpper(name)
+		times, days := getenv(prefix+"_SUMMARY_TIMES"), getenv(prefix+"_SUMMARY_DAYS")
+		if times == "" && days == "" {
+			continue
+		}
+		schedule := SummarySchedule{Times: config.SummaryTimes}
+		switch {
+		case strings.EqualFold(times, summaryOff):
+			schedule.Times = nil
+		case times != "":
+			if schedule.Times, err = parseSummaryTimes(prefix+"_SUMMARY_TIMES", times); err != nil {
+				return config, err
+			}
+		}
+		if schedule.Days, err = parseWeekdays(days); err != nil {
+			return config, fmt.Errorf("invalid %s_SUMMARY_DAYS: %w", prefix, err)
+		}
+		config.SummarySchedules[name] = schedule
+	}
+	if config.ReportSchedule != "" && config.ReportSchedule != reportDaily && config.ReportSchedule != reportWeekly {
+		return config, fmt.Errorf("REPORT_SCHEDULE must be %q or %q, got %q", reportDaily, reportWeekly, config.ReportSchedule)
+	}
+	switch config.SummaryChart {
+	case monitor.ChartNone, monitor.ChartPortfolio, monitor.ChartAddresses:
+	default:
+		return config, fmt.Errorf("SUMMARY_CHART must be %q or %q, got %q", monitor.ChartPortfolio, monitor.ChartAddresses, config.SummaryChart)
+	}
+
+	addresses := getenv("ADDRESSES")
+	if addresses != "" {
+		config.Addresses = strings.Split(addresses, ",")
+	}
+	for _, pattern := range strings.Split(getenv("WATCH_PATTERNS"), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			config.WatchPatterns = append(config.WatchPatterns, pattern)
+		}
+	}
+
+	if err := parseAddressMap("ADDRESS_LABELS", config.Labels); err != nil {
+		return config, err
+	}
+	config.KnownAddresses = monitor.DefaultKnownAddresses()
+	if config.KnownAddressesFile = getenv("KNOWN_ADDRESSES_FILE"); config.KnownAddressesFile != "" {
+		if err := monitor.LoadKnownAddresses(config.KnownAddressesFile, config.KnownAddresses); err != nil {
+			return config, fmt.Errorf("KNOWN_ADDRESSES_FILE: %w", err)
+		}
+	}
+	config.AddressBookURL = getenv("ADDRESS_BOOK_URL")
+	config.AddressBookGit = getenv("ADDRESS_BOOK_GIT")
+	config.AddressBookToken = getenv("ADDRESS_BOOK_TOKEN")
+	if config.AddressBookURL != "" && config.AddressBookGit != "" {
+		return config, fmt.Errorf("set only one of ADDRESS_BOOK_URL and ADDRESS_BOOK_GIT")
+	}
+	if config.AddressBookPath = getenv("ADDRESS_BOOK_PATH"); config.AddressBookPath == "" {
+		config.AddressBookPath = defaultAddressBookPath
+	}
+	config.AddressBookInterval = defaultAddressBookInterval
+	if interval := getenv("ADDRESS_BOOK_INTERVAL"); interval != "" {
+		d, err := time.ParseDuration(interval)
+		if err != nil || d < time.Minute {
+			return config, fmt.Errorf("ADDRESS_BOOK_INTERVAL must be a duration of at least 1m, got %q", interval)
+		}
+		config.AddressBookInterval = d
+	}
+	if err := parseAddressMap("ADDRESS_GROUPS", config.Groups); err != nil {
+		return config, err
+	}
+	if err := parseAddressTags(getenv("ADDRESS_TAGS"), config.Tags); err != nil {
+		return config, err
+	}
+	config.SummaryTags = parseTags(getenv("SUMMARY_TAGS"))
+	config.AlertTags = parseTags(getenv("ALERT_TAGS"))
+	config.SummaryGroupBy = strings.ToLower(getenv("SUMMARY_GROUP_BY"))
+	switch config.SummaryGroupBy {
+	case "":
+		config.SummaryGroupBy = monitor.GroupByGroup
+	case monitor.GroupByGroup, monitor.GroupByTag:
+	default:
+		return config, fmt.Errorf("SUMMARY_GROUP_BY must be %q or %q, got %q", monitor.GroupByGroup, monitor.GroupByTag, config.SummaryGroupBy)
+	}
+	if err := parseAddressMap("WALLETS", config.Wallets); err != nil {
+		return config, err
+	}
+	if len(config.Wallets) > 0 && config.WalletDeriveCommand == "" {
+		return config, fmt.Errorf("WALLET_DERIVE_COMMAND must be set to use WALLETS")
+	}
+	if thread := getenv("TELEGRAM_THREAD_ID"); thread != "" {
+		if config.TelegramThreadID, err = strconv.ParseInt(thread, 10, 64); err != nil || config.TelegramThreadID <= 0 {
+			return config, fmt.Errorf("TELEGRAM_THREAD_ID must be a topic ID, got %q", thread)
+		}
+	}
+	config.TelegramSummaryThreadID = config.TelegramThreadID
+	if thread := getenv("TELEGRAM_SUMMARY_THREAD_ID"); thread != "" {
+		if config.TelegramSummaryThreadID, err = strconv.ParseInt(thread, 10, 64); err != nil || config.TelegramSummaryThreadID <= 0 {
+			return config, fmt.Errorf("TELEGRAM_SUMMARY_THREAD_ID must be a topic ID, got %q", thread)
+		}
+	}
+
+	if admins := getenv("TELEGRAM_ADMIN_IDS"); admins != "" {
+		for _, admin := range strings.Split(admins, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(admin), 10, 64)
+			if err != nil {
+				return config, fmt.Errorf("invalid TELEGRAM_ADMIN_IDS entry %q, expected a user ID", admin)
+			}
+			config.TelegramAdminIDs = append(config.TelegramAdminIDs, id)
+		}
+	}
+
+	config.TelegramSubscriptions = getenv("TELEGRAM_SUBSCRIPTIONS")
+	switch config.TelegramSubscriptions {
+	case "":
+	case subscriptionsOpen, subscriptionsApproval:
+		if config.TelegramBotToken == "" {
+			return config, fmt.Errorf("TELEGRAM_BOT_TOKEN must be set when TELEGRAM_SUBSCRIPTIONS is set")
+		}
+		if config.TelegramSubscriptions == subscriptionsApproval && config.TelegramChatID == "" {
+			return config, fmt.Errorf("TELEGRAM_CHAT_ID must be set to receive subscription requests when TELEGRAM_SUBSCRIPTIONS=%s", subscriptionsApproval)
+		}
+	default:
+		return config, fmt.Errorf("TELEGRAM_SUBSCRIPTIONS must be %q or %q, got %q", subscriptionsOpen, subscriptionsApproval, config.TelegramSubscriptions)
+	}
+	config.TelegramMaxSubscriptions = defaultTelegramMaxSubscriptions
+	if limit := getenv("TELEGRAM_MAX_SUBSCRIPTIONS"); limit != "" {
+		if config.TelegramMaxSubscriptions, err = strconv.Atoi(limit); err != nil || config.TelegramMaxSubscriptions <= 0 {
+			return config, fmt.Errorf("TELEGRAM_MAX_SUBSCRIPTIONS must be a positive integer, got %q", limit)
+		}
+	}
+
+	if limit := getenv("MAX_UTXOS"); limit != "" {
+		if config.MaxUTXOs, err = strconv.Atoi(limit); err != nil || config.MaxUTXOs <= 0 {
+			return config, fmt.Errorf("MAX_UTXOS must be a positive integer, got %q", limit)
+		}
+	}
+	if dust := getenv("DUST_THRESHOLD"); dust != "" {
+		if config.DustThreshold, err = strconv.ParseInt(dust, 10, 64); err != nil || config.DustThreshold <= 0 {
+			return config, fmt.Errorf("DUST_THRESHOLD must be a positive amount of nick, got %q", dust)
+		}
+	}
+	if combine := getenv("COMBINE_CHANGES"); combine != "" {
+		if config.CombineChanges, err = strconv.Atoi(combine); err != nil || config.CombineChanges < 0 {
+			return config, fmt.Errorf("COMBINE_CHANGES must be a number of addresses, got %q", combine)
+		}
+	}
+	if shards := getenv("CHECK_SHARDS"); shards != "" {
+		// At most one shard a second, so slices never overlap
+		if config.CheckShards, err = strconv.Atoi(shards); err != nil || config.CheckShards < 1 || config.CheckShards > int(checkInterval/time.Second) {
+			return config, fmt.Errorf("CHECK_SHARDS must be between 1 and %d, got %q", int(checkInterval/time.Second), shards)
+		}
+	}
+	if jitter := getenv("CHECK_JITTER"); jitter != "" {
+		// A check delayed past the next one's turn would make that skip
+		interval := checkInterval / time.Duration(max(config.CheckShards, 1))
+		if config.CheckJitter, err = time.ParseDuration(jitter); err != nil || config.CheckJitter < 0 || config.CheckJitter >= interval {
+			return config, fmt.Errorf("CHECK_JITTER must be a duration under %s, the time between checks, got %q", interval, jitter)
+		}
+	}
+	config.ShuffleChecks = getenv("SHUFFLE_CHECKS") == "true"
+	config.CombineMaxRows = monitor.DefaultCombineMaxRows
+	if rows := getenv("COMBINE_MAX_ROWS"); rows != "" {
+		if config.CombineMaxRows, err = strconv.Atoi(rows); err != nil || config.CombineMaxRows <= 0 {
+			return config, fmt.Errorf("COMBINE_MAX_ROWS must be a positive integer, got %q", rows)
+		}
+	}
+
+	if fee := getenv("MAX_FEE"); fee != "" {
+		if config.MaxFee, err = strconv.ParseInt(fee, 10, 64); err != nil || config.MaxFee <= 0 {
+			return config, fmt.Errorf("MAX_FEE must be a positive amount of nick, got %q", fee)
+		}
+	}
+
+	config.NodeRules = monitor.NodeRules{MaxLag: defaultNodeMaxLag, MinPeers: defaultNodeMinPeers}
+	if lag := getenv("NODE_MAX_LAG"); lag != "" {
+		if config.NodeRules.MaxLag, err = strconv.ParseInt(lag, 10, 64); err != nil || config.NodeRules.MaxLag < 0 {
+			return config, fmt.Errorf("NODE_MAX_LAG must be a number of blocks, got %q", lag)
+		}
+	}
+	if peers := getenv("NODE_MIN_PEERS"); peers != "" {
+		if config.NodeRules.MinPeers, err = strconv.Atoi(peers); err != nil || config.NodeRules.MinPeers < 0 {
+			return config, fmt.Errorf("NODE_MIN_PEERS must be a number of peers, got %q", peers)
+		}
+	}
+
+	if gap := getenv("WALLET_GAP_LIMIT"); gap != "" {
+		if config.WalletGapLimit, err = strconv.Atoi(gap); err != nil || config.WalletGapLimit <= 0 {
+			return config, fmt.Errorf("WALLET_GAP_LIMIT must be a positive integer, got %q", gap)
+		}
+	}
+
+	if config.UserAgent == "" {
+		config.UserAgent = rpc.DefaultUserAgent + "/" + version
+	}
+
+	if err := parseAddressMap("CHAIN_RPC_URLS", config.ChainRPCURLs); err != nil {
+		return config, err
+	}
+	for chain := range config.ChainRPCURLs {
+		if strings.Contains(chain, ":") || chain == rpc.DefaultChain {
+			return config, fmt.Errorf("invalid CHAIN_RPC_URLS chain name %q", chain)
+		}
+	}
+
+	if config.GraphQLURL != "" && (config.GraphQLQuery == "" || config.GraphQLBalancePath == "") {
+		return config, fmt.Errorf("GRAPHQL_QUERY and GRAPHQL_BALANCE_PATH must be set to use GRAPHQL_URL")
+	}
+	if config.GraphQLURL != "" && len(config.WatchPatterns) > 0 && (config.GraphQLMatchQuery == "" || config.GraphQLMatchPath == "") {
+		return config, fmt.Errorf("GRAPHQL_MATCH_QUERY and GRAPHQL_MATCH_PATH must be set to use WATCH_PATTERNS with GRAPHQL_URL")
+	}
+
+	switch config.AddressDiscovery {
+	case monitor.DiscoveryOff:
+	case monitor.DiscoverySuggest, monitor.DiscoveryAuto:
+		if config.GraphQLURL != "" && config.GraphQLRelatedPath == "" {
+			return config, fmt.Errorf("ADDRESS_DISCOVERY needs GRAPHQL_RELATED_PATH with GRAPHQL_URL")
+		}
+	default:
+		return config, fmt.Errorf("ADDRESS_DISCOVERY must be %q or %q, got %q", monitor.DiscoverySuggest, monitor.DiscoveryAuto, config.AddressDiscovery)
+	}
+
+	costs := map[string]string{}
+	if err := parseAddressMap("COST_BASIS", costs); err != nil {
+		return config, err
+	}
+	for address, cost := range costs {
+		if config.CostBasis[address], err = strconv.ParseFloat(cost, 64); err != nil || config.CostBasis[address] < 0 {
+			return config, fmt.Errorf("invalid COST_BASIS for %s: %q", address, cost)
+		}
+	}
+	if len(config.CostBasis) > 0 && config.PriceProvider == "" {
+		return config, fmt.Errorf("PRICE_PROVIDER must be set to use COST_BASIS")
+	}
+
+	roles := getenv("DISCORD_ALLOWED_ROLES")
+	if roles != "" {
+		config.DiscordAllowedRoles = strings.Split(roles, ",")
+	}
+	adminRoles := getenv("DISCORD_ADMIN_ROLES")
+	if adminRoles != "" {
+		config.DiscordAdminRoles = strings.Split(adminRoles, ",")
+	}
+
+	tokens, err := parseAPITokens(getenv("API_TOKENS"))
+	if err != nil {
+		return config, err
+	}
+	config.APITokens = tokens
+	if config.APIToken != "" {
+		config.APITokens = append(config.APITokens, APIToken{Name: "default", Token: config.APIToken, Role: RoleAdmin})
+	}
+
+	if config.APIListenAddr != "" && len(config.APITokens) == 0 {
+		return config, fmt.Errorf("API_TOKEN or API_TOKENS must be set when API_LISTEN_ADDR is set")
+	}
+
+	config.TenantsDir = getenv("TENANTS_DIR")
+	config.OwnershipVerifyCommand = getenv("OWNERSHIP_VERIFY_COMMAND")
+
+	config.ClusterDir = getenv("CLUSTER_DIR")
+	if config.ClusterDir != "" {
+		config.InstanceID = getenv("INSTANCE_ID")
+		if config.InstanceID == "" {
+			config.InstanceID, _ = os.Hostname()
+		}
+		if err := validateCluster(config); err != nil {
+			return config, err
+		}
+	}
+
+	if config.SlackClientID != "" {
+		if config.SlackClientSecret == "" || config.SlackRedirectURL == "" {
+			return config, fmt.Errorf("SLACK_CLIENT_SECRET and SLACK_REDIRECT_URL must be set when SLACK_CLIENT_ID is set")
+		}
+		if u, err := url.Parse(config.SlackRedirectURL); err != nil || u.Path != slackOAuthPath {
+			return config, fmt.Errorf("SLACK_REDIRECT_URL must be the API's %s URL, got %q", slackOAuthPath, config.SlackRedirectURL)
+		}
+		if config.APIListenAddr == "" {
+			return config, fmt.Errorf("API_LISTEN_ADDR must be set to serve the Slack OAuth redirect")
+		}
+	}
+	if config.SlackSigningSecret != "" && config.APIListenAddr == "" {
+		return config, fmt.Errorf("API_LISTEN_ADDR must be set to receive Slack button presses")
+	}
+
+	config.EventBridgeBus = getenv("EVENTBRIDGE_BUS")
+	config.EventBridgeDetailType = getenv("EVENTBRIDGE_DETAIL_TYPE")
+	config.EventBridgeSource = getenv("EVENTBRIDGE_SOURCE")
+	config.AWSRegion = getenv("AWS_REGION")
+	config.AWSAccessKeyID = getenv("AWS_ACCESS_KEY_ID")
+	config.AWSSecretAccessKey = getenv("AWS_SECRET_ACCESS_KEY")
+	config.AWSSessionToken = getenv("AWS_SESSION_TOKEN")
+	if config.EventBridgeBus != "" {
+		if config.AWSRegion == "" {
+			return config, fmt.Errorf("AWS_REGION must be set to use EVENTBRIDGE_BUS")
+		}
+		if config.AWSAccessKeyID == "" || config.AWSSecretAccessKey == "" {
+			return config, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to use EVENTBRIDGE_BUS")
+		}
+		if strings.HasPrefix(config.EventBridgeSource, "aws.") {
+			return config, fmt.Errorf("EVENTBRIDGE_SOURCE must not start with \"aws.\", got %q", config.EventBridgeSource)
+		}
+	}
+
+	config.DesktopNotifications = getenv("DESKTOP_NOTIFICATIONS") == "true"
+	config.SyslogAddress = getenv("SYSLOG_ADDRESS")
+	config.SyslogAppName = getenv("SYSLOG_APP_NAME")
+	config.SystemdJournal = getenv("SYSTEMD_JOURNAL") == "true"
+	if config.SyslogFacility, err = notify.ParseSyslogFacility(getenv("SYSLOG_FACILITY")); err != nil {
+		return config, fmt.Errorf("invalid SYSLOG_FACILITY: %w", err)
+	}
+	if config.SyslogAddress != "" {
+		u, err := url.Parse(config.SyslogAddress)
+		if err != nil || !slices.Contains([]string{"udp", "tcp", "unix"}, u.Scheme) {
+			return config, fmt.Errorf("SYSLOG_ADDRESS must be udp://host:port, tcp://host:port or unix:///path, got %q", config.SyslogAddress)
+		}
+	}
+
+	config.AlertmanagerURL = getenv("ALERTMANAGER_URL")
+	config.AlertmanagerToken = getenv("ALERTMANAGER_TOKEN")
+	if config.AlertmanagerMode, err = notify.ParseAlertmanagerMode(getenv("ALERTMANAGER_MODE")); err != nil {
+		return config, fmt.Errorf("invalid ALERTMANAGER_MODE: %w", err)
+	}
+	config.AlertmanagerLabels = map[string]string{}
+	if err := parseAddressMap("ALERTMANAGER_LABELS", config.AlertmanagerLabels); err != nil {
+		return config, err
+	}
+	for name := range config.AlertmanagerLabels {
+		if !alertmanagerLabelName.MatchString(name) {
+			return config, fmt.Errorf("invalid ALERTMANAGER_LABELS label name %q", name)
+		}
+	}
+
+	config.ArchiveSlackChannel = getenv("ARCHIVE_SLACK_CHANNEL")
+	config.ArchiveTelegramChatID = getenv("ARCHIVE_TELEGRAM_CHAT_ID")
+	config.ArchiveDiscordChannelID = getenv("ARCHIVE_DISCORD_CHANNEL_ID")
+	config.ArchiveEmailTo = parseTags(getenv("ARCHIVE_EMAIL_TO"))
+	config.SMTPAddr = getenv("SMTP_ADDR")
+	config.SMTPUsername = getenv("SMTP_USERNAME")
+	config.SMTPPassword = getenv("SMTP_PASSWORD")
+	config.SMTPFrom = getenv("SMTP_FROM")
+	if config.ArchiveSlackChannel != "" && config.SlackBotToken == "" {
+		return config, fmt.Errorf("SLACK_BOT_TOKEN must be set when ARCHIVE_SLACK_CHANNEL is set")
+	}
+	if config.ArchiveTelegramChatID != "" && config.TelegramBotToken == "" {
+		return config, fmt.Errorf("TELEGRAM_BOT_TOKEN must be set when ARCHIVE_TELEGRAM_CHAT_ID is set")
+	}
+	if config.ArchiveDiscordChannelID != "" && config.DiscordBotToken == "" {
+		return config, fmt.Errorf("DISCORD_BOT_TOKEN must be set when ARCHIVE_DISCORD_CHANNEL_ID is set")
+	}
+	if len(config.ArchiveEmailTo) > 0 {
+		if config.SMTPFrom == "" {
+			return config, fmt.Errorf("SMTP_FROM must be set when ARCHIVE_EMAIL_TO is set")
+		}
+		if _, _, err := net.SplitHostPort(config.SMTPAddr); err != nil {
+			return config, fmt.Errorf("SMTP_ADDR must be host:port when ARCHIVE_EMAIL_TO is set, got %q", config.SMTPAddr)
+		}
+	}
+
+	config.PushgatewayURL = getenv("PUSHGATEWAY_URL")
+	config.PushgatewayJob = getenv("PUSHGATEWAY_JOB")
+	config.PushgatewayInstance = getenv("PUSHGATEWAY_INSTANCE")
+	config.PushgatewayToken = getenv("PUSHGATEWAY_TOKEN")
+	if config.PushgatewayJob == "" {
+		config.PushgatewayJob = defaultPushgatewayJob
+	}
+	if config.PushgatewayURL != "" {
+		if u, err := url.Parse(config.PushgatewayURL); err != nil || u.Scheme == "" || u.Host == "" {
+			return config, fmt.Errorf("invalid PUSHGATEWAY_URL %q", config.PushgatewayURL)
+		}
+	}
+
+	if (config.SlackBotToken == "" || config.SlackChannel == "") && config.SlackClientID == "" &&
+		(config.TelegramBotToken == "" || config.TelegramChatID == "") &&
+		(config.DiscordBotToken == "" || config.DiscordChannelID == "") && config.WebhookURL == "" && config.EventBridgeBus == "" &&
+		config.AlertmanagerURL == "" && !config.DesktopNotifications && config.SyslogAddress == "" && !config.SystemdJournal {
+		return config, errNoNotifiers
+	}
+
+	return config, nil
+}
+
+// parseSeverities parses ALERT_SEVERITY, rule:severity pairs such as
+// price:info,decrease:critical
+func parseSeverities(value string) (map[string]notify.Severity, error) {
+	severities := map[string]notify.Severity{}
+	if value == "" {
+		return severities, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		rule, name, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !slices.Contains(monitor.AlertRules, rule) {
+			return nil, fmt.Errorf("invalid ALERT_SEVERITY entry %q, expected rule:severity with a rule from %s", entry, strings.Join(monitor.AlertRules, ", "))
+		}
+		severity, err := notify.ParseSeverity(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ALERT_SEVERITY entry %q: %w", entry, err)
+		}
+		severities[rule] = severity
+	}
+	return severities, nil
+}
+
+// parseAddressMap parses an environment variable of address=value pairs into m
+func parseAddressMap(name string, m map[string]string) error {
+	value := getenv(name)
+	if value == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		address, v, ok := strings.Cut(entry, "=")
+		address, v = strings.TrimSpace(address), strings.TrimSpace(v)
+		if !ok || address == "" || v == "" {
+			return fmt.Errorf("invalid %s entry %q, expected address=value", name, entry)
+		}
+		m[address] = v
+	}
+	return nil
+}
+
+// parseExpectedInflows parses address=amount/window pairs, such as
+// 3L1P...AUMw=50/24h for at least 50 units a day, with the amount in the
+// denomination's display unit
+func parseExpectedInflows(value string, denomination notify.Denomination) (map[string]monitor.ExpectedInflow, error) {
+	if value == "" {
+		return nil, nil
+	}
+	inflows := map[string]monitor.ExpectedInflow{}
+	for _, entry := range strings.Split(value, ",") {
+		address, expectation, _ := strings.Cut(entry, "=")
+		amount, window, _ := strings.Cut(expectation, "/")
+		address = strings.TrimSpace(address)
+		units, err1 := strconv.ParseFloat(strings.TrimSpace(amount), 64)
+		period, err2 := time.ParseDuration(strings.TrimSpace(window))
+		if address == "" || err1 != nil || err2 != nil || units <= 0 || period < time.Hour || period > monitor.MaxInflowWindow {
+			return nil, fmt.Errorf("invalid EXPECTED_INFLOWS entry %q, expected address=amount/window with a window from 1h to %.0fh", entry, monitor.MaxInflowWindow.Hours())
+		}
+		inflows[address] = monitor.ExpectedInflow{Amount: int64(units * float64(denomination.BaseUnitsPerUnit)), Window: period}
+	}
+	return inflows, nil
+}
+
+// parseAddressTags parses address=tag pairs into m; an address takes several
+// tags as address=hot|pool or by repeating it
+func parseAddressTags(value string, m map[string][]string) error {
+	if value == "" {
+		return nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		address, tags, ok := strings.Cut(entry, "=")
+		address = strings.TrimSpace(address)
+		parsed := parseTags(strings.ReplaceAll(tags, "|", ","))
+		if !ok || address == "" || len(parsed) == 0 {
+			return fmt.Errorf("invalid ADDRESS_TAGS entry %q, expected address=tag or address=tag|tag", entry)
+		}
+		for _, tag := range parsed {
+			if !slices.Contains(m[address], tag) {
+				m[address] = append(m[address], tag)
+			}
+		}
+	}
+	return nil
+}
+
+// parseTags parses a comma-separated list of tags, which are
+// case-insensitive
+func parseTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parsePriceRules parses PRICE_ALERT_ABOVE, PRICE_ALERT_BELOW, and
+// PRICE_ALERT_CHANGE (window:percent pairs such as 1h:5,24h:10)
+func parsePriceRules(currency string) (monitor.PriceRules, error) {
+	rules := monitor.PriceRules{Currency: currency}
+	var err error
+	if above := getenv("PRICE_ALERT_ABOVE"); above != "" {
+		if rules.Above, err = strconv.ParseFloat(above, 64); err != nil {
+			return rules, fmt.Errorf("invalid PRICE_ALERT_ABOVE: %w", err)
+		}
+	}
+	if below := getenv("PRICE_ALERT_BELOW"); below != "" {
+		if rules.Below, err = strconv.ParseFloat(below, 64); err != nil {
+			return rules, fmt.Errorf("invalid PRICE_ALERT_BELOW: %w", err)
+		}
+	}
+	if changes := getenv("PRICE_ALERT_CHANGE"); changes != "" {
+		for _, entry := range strings.Split(changes, ",") {
+			window, percent, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok {
+				return rules, fmt.Errorf("invalid PRICE_ALERT_CHANGE entry %q, expected window:percent", entry)
+			}
+			rule := monitor.PriceChangeRule{}
+			if rule.Window, err = time.ParseDuration(window); err != nil {
+				return rules, fmt.Errorf("invalid PRICE_ALERT_CHANGE window %q: %w", window, err)
+			}
+			if rule.Percent, err = strconv.ParseFloat(percent, 64); err != nil || rule.Percent <= 0 {
+				return rules, fmt.Errorf("invalid PRICE_ALERT_CHANGE percent %q", percent)
+			}
+			rules.Changes = append(rules.Changes, rule)
+		}
+	}
+	return rules, nil
+}
+
+// parseSummaryTimes parses comma-separated HH:MM times of day
+func parseSummaryTimes(name, value string) ([]string, error) {
+	var times []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		if _, err := time.Parse("15:04", t); err != nil {
+			return nil, fmt.Errorf("%s must be comma-separated HH:MM times, got %q", name, t)
+		}
+		times = append(times, t)
+	}
+	return times, nil
+}
+
+// parseWeekdays parses comma-separated weekday names such as "monday" or
+// "mon,thu"
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range parseTags(value) {
+		found := false
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			full := strings.ToLower(day.String())
+			if name == full || name == full[:3] {
+				days = append(days, day)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown weekday %q", name)
+		}
+	}
+	return days, nil
+}
+
+// formatter returns how amounts are written, in the configured
+// denomination and number format
+func (c Config) formatter() notify.Formatter {
+	return notify.Formatter{Denomination: c.Denomination, NumberFormat: c.NumberFormat}
+}
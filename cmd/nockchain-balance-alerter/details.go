@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+// rawTransactions fetches the recent transactions of an address exactly as
+// the RPC returned them, indented for reading
+func rawTransactions(address string, m *monitor.Monitor) ([]byte, error) {
+	source, ok := m.Source.(monitor.RawTransactionSource)
+	if !ok {
+		return nil, fmt.Errorf("raw transactions aren't available from this RPC")
+	}
+	raw, err := source.RawTransactions(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("raw transactions aren't available from this RPC")
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, raw, "", "  "); err != nil {
+		return nil, fmt.Errorf("decoding raw transactions: %w", err)
+	}
+	indented.WriteByte('\n')
+	return indented.Bytes(), nil
+}
+
+// detailsFilename names the raw transaction file of an address
+func detailsFilename(address string) string {
+	_, plain := monitor.SplitAddress(address)
+	if len(plain) > 12 {
+		plain = plain[:12]
+	}
+	return "transactions-" + plain + ".json"
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/bwmarrin/discordgo"
+)
+
+// discordCommands are the slash commands registered by the Discord bot
+var discordCommands = []*discordgo.ApplicationCommand{
+	{
+		Name:        "balance",
+		Description: "Show the balance of a watched address, or all of them",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "address",
+				Description: "Nockchain address to query",
+				Required:    false,
+			},
+		},
+	},
+	{
+		Name:        "watch",
+		Description: "Add an address to the watchlist",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "address",
+				Description: "Nockchain address to watch",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "unwatch",
+		Description: "Remove an address added with /watch",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "address",
+				Description: "Nockchain address to stop watching",
+				Required:    true,
+			},
+		},
+	},
+	{
+		Name:        "mute",
+		Description: "Mute alerts for an address",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "address",
+				Description: "Nockchain address to mute",
+				Required:    true,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "duration",
+				Description: "How long to mute, e.g. 1h or 30m; 0 unmutes",
+				Required:    true,
+			},
+		},
+	},
+}
+
+// discordCommandRoles is the minimum role required for each slash command
+var discordCommandRoles = map[string]Role{
+	"balance": RoleRead,
+	"watch":   RoleAdmin,
+	"unwatch": RoleAdmin,
+	"mute":    RoleAdmin,
+}
+
+// startDiscordBot connects to Discord, registers the slash commands, and
+// serves them until the process exits
+func startDiscordBot(config Config, m *monitor.Monitor) (*discordgo.Session, error) {
+	session, err := discordgo.New("Bot " + config.DiscordBotToken)
+	if err != nil {
+		return nil, err
+	}
+	session.Identify.Intents = discordgo.IntentsGuilds
+	translations, err := notify.LoadTranslations(config.TranslationsDir, config.DiscordLanguage)
+	if err != nil {
+		return nil, err
+	}
+	templates := notify.Templates{Translations: translations, Format: config.formatter(), Branding: config.Branding}
+
+	session.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
+		if i.Type != discordgo.InteractionApplicationCommand {
+			return
+		}
+		handleDiscordCommand(s, i, config, m, templates)
+	})
+
+	if err := session.Open(); err != nil {
+		return nil, err
+	}
+
+	if _, err := session.ApplicationCommandBulkOverwrite(session.State.User.ID, config.DiscordGuildID, discordCommands); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("registering slash commands: %w", err)
+	}
+
+	return session, nil
+}
+
+// handleDiscordCommand dispatches a slash command and replies to it
+func handleDiscordCommand(s *discordgo.Session, i *discordgo.InteractionCreate, config Config, m *monitor.Monitor, templates notify.Templates) {
+	data := i.ApplicationCommandData()
+
+	options := map[string]string{}
+	for _, option := range data.Options {
+		options[option.Name] = strings.TrimSpace(option.StringValue())
+	}
+
+	role := discordMemberRole(i.Member, config)
+	required, known := discordCommandRoles[data.Name]
+	allowed := known && role >= required
+	auditLog(config.AuditLogFile, discordActor(i.Interaction), role, "/"+data.Name, options["address"], allowed)
+
+	var reply string
+	switch {
+	case !known:
+		reply = fmt.Sprintf("Unknown command `/%s`", data.Name)
+	case !allowed:
+		reply = "⛔ You don't have permission to use this command."
+	case data.Name == "balance":
+		reply = discordBalanceReply(options["address"], m, config.DiscordUnits, templates)
+	case data.Name == "watch":
+		reply = discordWatchReply(options["address"], m)
+	case data.Name == "unwatch":
+		reply = discordUnwatchReply(options["address"], m)
+	case data.Name == "mute":
+		reply = discordMuteReply(options["address"], options["duration"], m)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: reply,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to Discord command /%s: %v", data.Name, err)
+	}
+}
+
+// discordMemberRole resolves the role of a guild member. Without
+// DISCORD_ALLOWED_ROLES everyone may read; without DISCORD_ADMIN_ROLES every
+// reader is also an admin.
+func discordMemberRole(member *discordgo.Member, config Config) Role {
+	if len(config.DiscordAdminRoles) > 0 && discordMemberHasRole(member, config.DiscordAdminRoles) {
+		return RoleAdmin
+	}
+	if len(config.DiscordAllowedRoles) > 0 && !discordMemberHasRole(member, config.DiscordAllowedRoles) {
+		return RoleNone
+	}
+	if len(config.DiscordAdminRoles) == 0 {
+		return RoleAdmin
+	}
+	return RoleRead
+}
+
+// discordMemberHasRole reports whether a guild member holds one of the roles
+func discordMemberHasRole(member *discordgo.Member, roles []string) bool {
+	if member == nil {
+		return false // Direct messages carry no roles
+	}
+	for _, role := range member.Roles {
+		for _, wanted := range roles {
+			if role == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// discordActor identifies the user behind an interaction for the audit log
+func discordActor(i *discordgo.Interaction) string {
+	user := i.User
+	if i.Member != nil && i.Member.User != nil {
+		user = i.Member.User
+	}
+	if user == nil {
+		return "discord:unknown"
+	}
+	return fmt.Sprintf("discord:%s(%s)", user.Username, user.ID)
+}
+
+// discordBalanceReply builds the /balance response, querying the RPC for a
+// specific address or listing the stored balances when none is given
+func discordBalanceReply(address string, m *monitor.Monitor, units notify.Units, templates notify.Templates) string {
+	tr := templates.Translations
+	if address != "" {
+		balance, err := m.Source.GetBalance(address)
+		if err != nil {
+			log.Printf("Error checking balance for %s: %v", address, err)
+			return fmt.Sprintf("⚠️ Could not fetch the balance for `%s`", address)
+		}
+		return fmt.Sprintf("**%s**: `%s`\n**%s**: %s", tr.T("Address"), address, tr.T("Balance"), templates.Format.Balance(balance))
+	}
+
+	return notify.CreateDiscordSummaryMessage(m.Summary(), units, templates)
+}
+
+// discordWatchReply adds an address to the persisted watchlist so the next
+// check cycle picks it up
+func discordWatchReply(address string, m *monitor.Monitor) string {
+	if address == "" {
+		return "Please provide an address to watch."
+	}
+	balance, err := m.Source.GetBalance(address)
+	if err != nil {
+		log.Printf("Error checking balance for %s: %v", address, err)
+		return fmt.Sprintf("⚠️ Could not verify `%s` against the RPC", address)
+	}
+
+	if err := m.Watch(address); err != nil {
+		return discordStateErrorReply(address, err)
+	}
+	return fmt.Sprintf("👀 Now watching `%s`\n**Balance**: %s", address, m.Format.Balance(balance))
+}
+
+// discordUnwatchReply removes an address added at runtime from the watchlist
+func discordUnwatchReply(address string, m *monitor.Monitor) string {
+	if err := m.Unwatch(address); err != nil {
+		return discordStateErrorReply(address, err)
+	}
+	return fmt.Sprintf("🙈 Stopped watching `%s`", address)
+}
+
+// discordMuteReply mutes alerts for an address, or unmutes it for a zero duration
+func discordMuteReply(address, duration string, m *monitor.Monitor) string {
+	d, err := time.ParseDuration(duration)
+	if duration == "0" {
+		d, err = 0, nil
+	}
+	if err != nil || d < 0 {
+		return "Duration must look like `1h` or `30m`, or `0` to unmute."
+	}
+
+	var until time.Time
+	if d > 0 {
+		until = time.Now().Add(d)
+	}
+	if err := m.Mute(address, until); err != nil {
+		return discordStateErrorReply(address, err)
+	}
+	if until.IsZero() {
+		return fmt.Sprintf("🔔 Alerts for `%s` unmuted", address)
+	}
+	return fmt.Sprintf("🔕 Alerts for `%s` muted until %s", address, until.Format(time.RFC3339))
+}
+
+// discordStateErrorReply turns a watchlist error into a user-facing reply
+func discordStateErrorReply(address string, err error) string {
+	switch {
+	case errors.Is(err, monitor.ErrAlreadyWatched):
+		return fmt.Sprintf("`%s` is already being watched.", address)
+	case errors.Is(err, monitor.ErrNotWatched):
+		return fmt.Sprintf("`%s` is not being watched.", address)
+	case errors.Is(err, monitor.ErrConfiguredAddress):
+		return fmt.Sprintf("`%s` is set in ADDRESSES and can't be changed from Discord.", address)
+	default:
+		log.Printf("Error saving state: %v", err)
+		return "⚠️ Could not save the watchlist."
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envFile is the file loadConfig reads settings from, if it exists
+const envFile = ".env"
+
+// settingType is the kind of value a setting holds, which checkEnvFile
+// checks values against
+type settingType string
+
+const (
+	settingString   settingType = "string"
+	settingBool     settingType = "boolean"
+	settingInteger  settingType = "integer"
+	settingNumber   settingType = "number"
+	settingDuration settingType = "duration"
+	settingURL      settingType = "URL"
+)
+
+// settings lists every setting and its type. getenv only reads settings
+// listed here, so it is also the list the keys of the .env file are checked
+// against.
+var settings = map[string]settingType{
+	"ADDRESSES":                  settingString,
+	"ADDRESS_BOOK_GIT":           settingString,
+	"ADDRESS_BOOK_INTERVAL":      settingDuration,
+	"ADDRESS_BOOK_PATH":          settingString,
+	"ADDRESS_BOOK_TOKEN":         settingString,
+	"ADDRESS_BOOK_URL":           settingURL,
+	"ADDRESS_DISCOVERY":          settingString,
+	"ADDRESS_GROUPS":             settingString,
+	"ADDRESS_LABELS":             settingString,
+	"ADDRESS_TAGS":               settingString,
+	"ALERTMANAGER_LABELS":        settingString,
+	"ALERTMANAGER_MODE":          settingString,
+	"ALERTMANAGER_TOKEN":         settingString,
+	"ALERTMANAGER_URL":           settingURL,
+	"ALERT_HOOK_COMMAND":         settingString,
+	"ALERT_HOOK_TIMEOUT":         settingDuration,
+	"ALERT_ON_RPC_BREAKER":       settingBool,
+	"ALERT_ON_TRANSACTIONS":      settingBool,
+	"ALERT_SEVERITY":             settingString,
+	"ALERT_TAGS":                 settingString,
+	"API_LISTEN_ADDR":            settingString,
+	"API_TOKEN":                  settingString,
+	"API_TOKENS":                 settingString,
+	"ARCHIVE_DISCORD_CHANNEL_ID": settingString,
+	"ARCHIVE_EMAIL_TO":           settingString,
+	"ARCHIVE_SLACK_CHANNEL":      settingString,
+	"ARCHIVE_TELEGRAM_CHAT_ID":   settingString,
+	"AUDIT_LOG_FILE":             settingString,
+	"AWS_ACCESS_KEY_ID":          settingString,
+	"AWS_REGION":                 settingString,
+	"AWS_SECRET_ACCESS_KEY":      settingString,
+	"AWS_SESSION_TOKEN":          settingString,
+	"BASE_UNITS_PER_UNIT":        settingInteger,
+	"BASE_UNIT_NAME":             settingString,
+	"BRAND_CHANGE_EMOJI":         settingString,
+	"BRAND_CHANGE_TITLE":         settingString,
+	"BRAND_COLOR_DECREASE":       settingString,
+	"BRAND_COLOR_INCREASE":       settingString,
+	"BRAND_COLOR_NEW":            settingString,
+	"BRAND_SUMMARY_EMOJI":        settingString,
+	"BRAND_SUMMARY_TITLE":        settingString,
+	"CATCH_UP_AFTER":             settingDuration,
+	"CHAIN_RPC_URLS":             settingString,
+	"CHECK_JITTER":               settingDuration,
+	"CHECK_SHARDS":               settingInteger,
+	"CLUSTER_DIR":                settingString,
+	"COMBINE_CHANGES":            settingInteger,
+	"COMBINE_MAX_ROWS":           settingInteger,
+	"CONFIRM_ZERO_BALANCE":       settingBool,
+	"COST_BASIS":                 settingString,
+	"CYCLE_DEADLINE":             settingDuration,
+	"DEDUPE_TRANSACTIONS":        settingBool,
+	"DELIVERY_QUEUE":             settingBool,
+	"DESKTOP_NOTIFICATIONS":      settingBool,
+	"DESKTOP_SUMMARY_DAYS":       settingString,
+	"DESKTOP_SUMMARY_TIMES":      settingString,
+	"DISCORD_ADMIN_ROLES":        settingString,
+	"DISCORD_ALLOWED_ROLES":      settingString,
+	"DISCORD_BOT_TOKEN":          settingString,
+	"DISCORD_CHANNEL_ID":         settingString,
+	"DISCORD_CRITICAL_MENTION":   settingString,
+	"DISCORD_GUILD_ID":           settingString,
+	"DISCORD_LANGUAGE":           settingString,
+	"DISCORD_OVERFLOW":           settingString,
+	"DISCORD_SUMMARY_DAYS":       settingString,
+	"DISCORD_SUMMARY_TIMES":      settingString,
+	"DISCORD_UNITS":              settingString,
+	"DORMANT_DAYS":               settingInteger,
+	"DUST_THRESHOLD":             settingInteger,
+	"EVENTBRIDGE_BUS":            settingString,
+	"EVENTBRIDGE_DETAIL_TYPE":    settingString,
+	"EVENTBRIDGE_SOURCE":         settingString,
+	"EVENTBRIDGE_SUMMARY_DAYS":   settingString,
+	"EVENTBRIDGE_SUMMARY_TIMES":  settingString,
+	"EXPECTED_INFLOWS":           settingString,
+	"EXPLORER_URL":               settingString,
+	"FLAP_WINDOW":                settingDuration,
+	"FLOW_PERIOD":                settingDuration,
+	"GRAPHQL_BALANCE_PATH":       settingString,
+	"GRAPHQL_MATCH_PATH":         settingString,
+	"GRAPHQL_MATCH_QUERY":        settingString,
+	"GRAPHQL_QUERY":              settingString,
+	"GRAPHQL_RELATED_PATH":       settingString,
+	"GRAPHQL_URL":                settingURL,
+	"HTTP_USER_AGENT":            settingString,
+	"INSTANCE_ID":                settingString,
+	"JOURNAL_SUMMARY_DAYS":       settingString,
+	"JOURNAL_SUMMARY_TIMES":      settingString,
+	"KNOWN_ADDRESSES_FILE":       settingString,
+	"LANGUAGE":                   settingString,
+	"MAX_FEE":                    settingInteger,
+	"MAX_UTXOS":                  settingInteger,
+	"NODE_MAX_LAG":               settingInteger,
+	"NODE_MIN_PEERS":             settingInteger,
+	"NODE_STATUS_URL":            settingURL,
+	"NUMBER_COMPACT":             settingBool,
+	"NUMBER_LOCALE":              settingString,
+	"OPERATOR_CONTACT":           settingString,
+	"OWNERSHIP_VERIFY_COMMAND":   settingString,
+	"PAYOUT_LATE_PERCENT":        settingNumber,
+	"PRICE_ALERT_ABOVE":          settingNumber,
+	"PRICE_ALERT_BELOW":          settingNumber,
+	"PRICE_ALERT_CHANGE":         settingString,
+	"PRICE_API_KEY":              settingString,
+	"PRICE_CACHE_TTL":            settingDuration,
+	"PRICE_COIN_ID":              settingString,
+	"PRICE_CURRENCIES":           settingString,
+	"PRICE_PROVIDER":             settingString,
+	"PRICE_SYMBOL":               settingString,
+	"PRICE_URL":                  settingURL,
+	"PUSHGATEWAY_INSTANCE":       settingString,
+	"PUSHGATEWAY_JOB":            settingString,
+	"PUSHGATEWAY_TOKEN":          settingString,
+	"PUSHGATEWAY_URL":            settingURL,
+	"REPORT_PROJECTION":          settingBool,
+	"REPORT_SCHEDULE":            settingString,
+	"REPORT_TIME":                settingString,
+	"RPC_BREAKER_PROBE":          settingDuration,
+	"RPC_BREAKER_THRESHOLD":      settingInteger,
+	"RPC_RECORD_FILE":            settingString,
+	"RPC_TIMEOUT":                settingDuration,
+	"RPC_URL":                    settingURL,
+	"SEND_TIMEOUT":               settingDuration,
+	"SHOW_MEMOS":                 settingBool,
+	"SHUFFLE_CHECKS":             settingBool,
+	"SILENT_SEVERITY":            settingString,
+	"SLACK_BOT_TOKEN":            settingString,
+	"SLACK_CHANNEL":              settingString,
+	"SLACK_CLIENT_ID":            settingString,
+	"SLACK_CLIENT_SECRET":        settingString,
+	"SLACK_CRITICAL_MENTION":     settingString,
+	"SLACK_LANGUAGE":             settingString,
+	"SLACK_OVERFLOW":             settingString,
+	"SLACK_REDIRECT_URL":         settingURL,
+	"SLACK_SIGNING_SECRET":       settingString,
+	"SLACK_SUMMARY_DAYS":         settingString,
+	"SLACK_SUMMARY_TIMES":        settingString,
+	"SLACK_UNITS":                settingString,
+	"SMTP_ADDR":                  settingString,
+	"SMTP_FROM":                  settingString,
+	"SMTP_PASSWORD":              settingString,
+	"SMTP_USERNAME":              settingString,
+	"STALE_AFTER":                settingDuration,
+	"STARTUP_SNAPSHOT":           settingBool,
+	"STRICT_CONFIG":              settingBool,
+	"SUMMARY_CHART":              settingString,
+	"SUMMARY_GROUP_BY":           settingString,
+	"SUMMARY_MODE":               settingString,
+	"SUMMARY_SORT":               settingString,
+	"SUMMARY_SPARKLINE":          settingInteger,
+	"SUMMARY_STATS":              settingBool,
+	"SUMMARY_TAGS":               settingString,
+	"SUMMARY_TIMES":              settingString,
+	"SYSLOG_ADDRESS":             settingString,
+	"SYSLOG_APP_NAME":            settingString,
+	"SYSLOG_FACILITY":            settingString,
+	"SYSLOG_SUMMARY_DAYS":        settingString,
+	"SYSLOG_SUMMARY_TIMES":       settingString,
+	"SYSTEMD_JOURNAL":            settingBool,
+	"TELEGRAM_ACTIONS":           settingBool,
+	"TELEGRAM_ADMIN_IDS":         settingString,
+	"TELEGRAM_BOT_TOKEN":         settingString,
+	"TELEGRAM_CHAT_ID":           settingString,
+	"TELEGRAM_LANGUAGE":          settingString,
+	"TELEGRAM_MAX_SUBSCRIPTIONS": settingInteger,
+	"TELEGRAM_OVERFLOW":          settingString,
+	"TELEGRAM_SUBSCRIPTIONS":     settingString,
+	"TELEGRAM_SUMMARY_DAYS":      settingString,
+	"TELEGRAM_SUMMARY_THREAD_ID": settingInteger,
+	"TELEGRAM_SUMMARY_TIMES":     settingString,
+	"TELEGRAM_THREAD_ID":         settingInteger,
+	"TELEGRAM_UNITS":             settingString,
+	"TEMPLATE_DIR":               settingString,
+	"TENANTS_DIR":                settingString,
+	"TIMEZONE":                   settingString,
+	"TRACK_FEES":                 settingBool,
+	"TRACK_LOCKED":               settingBool,
+	"TRACK_SIGNERS":              settingBool,
+	"TRANSLATIONS_DIR":           settingString,
+	"UNIT_DECIMALS":              settingInteger,
+	"UNIT_NAME":                  settingString,
+	"WALLETS":                    settingString,
+	"WALLET_DERIVE_COMMAND":      settingString,
+	"WALLET_GAP_LIMIT":           settingInteger,
+	"WATCH_PATTERNS":             settingString,
+	"WEBHOOK_SECRET":             settingString,
+	"WEBHOOK_SOURCE":             settingString,
+	"WEBHOOK_SUMMARY_DAYS":       settingString,
+	"WEBHOOK_SUMMARY_TIMES":      settingString,
+	"WEBHOOK_TOKEN":              settingString,
+	"WEBHOOK_URL":                settingURL,
+}
+
+// tenantEnv, while a tenant's config is read, holds the settings of its
+// .env file, which replace the environment
+var tenantEnv map[string]string
+
+// getenv returns a setting from the environment. It panics if the setting
+// isn't in settings.
+func getenv(name string) string {
+	if _, ok := settings[name]; !ok {
+		panic("getenv: unlisted setting " + name)
+	}
+	if tenantEnv != nil {
+		return tenantEnv[name]
+	}
+	return os.Getenv(name)
+}
+
+// checkEnvFile reports each line of the .env file that isn't a known
+// setting, with the closest known one, so a misspelt key isn't silently
+// ignored, and each setting whose value isn't of its type, such as
+// CATCH_UP_AFTER: invalid duration "5x". The problems are returned as an
+// error, or with STRICT_CONFIG=false logged as warnings.
+func checkEnvFile(path string) error {
+	strict := getenv("STRICT_CONFIG") != "false"
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var problems []error
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			key, _, ok = strings.Cut(key, ":")
+		}
+		key = strings.TrimSpace(key)
+		if !ok {
+			problems = append(problems, fmt.Errorf("%s:%d: expected KEY=value", path, i+1))
+		} else if _, known := settings[key]; !known {
+			problem := fmt.Sprintf("%s:%d: unknown setting %s", path, i+1, key)
+			if closest := closestSetting(key); closest != "" {
+				problem += fmt.Sprintf(" (did you mean %s?)", closest)
+			}
+			problems = append(problems, errors.New(problem))
+		}
+	}
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := getenv(name); value != "" && !validSetting(settings[name], value) {
+			problems = append(problems, fmt.Errorf("%s: invalid %s %q", name, settings[name], value))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if !strict {
+		for _, problem := range problems {
+			log.Printf("Warning: %v", problem)
+		}
+		return nil
+	}
+	return errors.Join(problems...)
+}
+
+// validSetting reports whether value is of the given type. Booleans must be
+// true or false, as anything else reads as false.
+func validSetting(typ settingType, value string) bool {
+	var err error
+	switch typ {
+	case settingBool:
+		return value == "true" || value == "false"
+	case settingInteger:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case settingNumber:
+		_, err = strconv.ParseFloat(value, 64)
+	case settingDuration:
+		_, err = time.ParseDuration(value)
+	case settingURL:
+		u, parseErr := url.Parse(value)
+		return parseErr == nil && u.Scheme != "" && u.Host != ""
+	}
+	return err == nil
+}
+
+// closestSetting returns the known setting nearest to a misspelt key, or ""
+// if none is close
+func closestSetting(key string) string {
+	best, bestDistance := "", len(key)/3+1
+	for setting := range settings {
+		if d := editDistance(key, setting); d < bestDistance || d == bestDistance && setting < best {
+			best, bestDistance = setting, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
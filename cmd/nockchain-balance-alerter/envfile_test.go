@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/joho/godotenv"
+)
+
+// withEnvFile writes content to a .env file and makes its settings the ones
+// getenv returns, as for a tenant
+func withEnvFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), envFile)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	values, err := godotenv.Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenantEnv = values
+	t.Cleanup(func() { tenantEnv = nil })
+	return path
+}
+
+func TestCheckEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr []string // Substrings of the error, none if empty
+	}{
+		{"valid", "RPC_URL=https://nockblocks.com/rpc\nCATCH_UP_AFTER=2h\nTRACK_FEES=true\nMAX_UTXOS=50\nPAYOUT_LATE_PERCENT=12.5\n# comment\n", nil},
+		{"empty values", "CATCH_UP_AFTER=\nTRACK_FEES=\n", nil},
+		{"unknown key", "SUMARY_TIMES=08:00\n", []string{"unknown setting SUMARY_TIMES (did you mean SUMMARY_TIMES?)"}},
+		{"duration", "CATCH_UP_AFTER=5x\n", []string{`CATCH_UP_AFTER: invalid duration "5x"`}},
+		{"boolean", "TRACK_FEES=yes\n", []string{`TRACK_FEES: invalid boolean "yes"`}},
+		{"integer", "MAX_UTXOS=ten\n", []string{`MAX_UTXOS: invalid integer "ten"`}},
+		{"number", "PRICE_ALERT_ABOVE=1,5\n", []string{`PRICE_ALERT_ABOVE: invalid number "1,5"`}},
+		{"url", "WEBHOOK_URL=example.com/hook\n", []string{`WEBHOOK_URL: invalid URL "example.com/hook"`}},
+		{"every problem", "SUMARY_TIMES=08:00\nSTALE_AFTER=soon\n", []string{"SUMARY_TIMES", "STALE_AFTER"}},
+		{"warn only", "STRICT_CONFIG=false\nSUMARY_TIMES=08:00\nSTALE_AFTER=soon\n", nil},
+		{"invalid strict", "STRICT_CONFIG=no\n", []string{`STRICT_CONFIG: invalid boolean "no"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkEnvFile(withEnvFile(t, tt.content))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("checkEnvFile error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkEnvFile error = nil, want %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("checkEnvFile error = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEnvExampleIsValid(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", ".env.example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkEnvFile(withEnvFile(t, string(data))); err != nil {
+		t.Errorf(".env.example: %v", err)
+	}
+}
+
+func TestReadConfigReadsListedSettings(t *testing.T) {
+	// getenv panics on a setting missing from settings
+	path := withEnvFile(t, "WEBHOOK_URL=https://example.com/hook\nADDRESSES=3L1PzYk9AUMw\n")
+	if _, err := readConfig(path); err != nil {
+		t.Fatalf("readConfig error = %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Formats written by the export subcommand
+const (
+	exportFormatKoinly       = "koinly"       // Koinly universal CSV
+	exportFormatCoinTracking = "cointracking" // CoinTracking CSV import
+)
+
+// exportDate is the layout of the --since and --until flags
+const exportDate = "2006-01-02"
+
+// runExport writes the balance changes recorded in balances.json as CSV
+// for tax and accounting tools, one row per change with the amount in
+// $NOCK and, when prices were enabled at the time, its fiat value
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	format := flags.String("format", exportFormatKoinly, "CSV format: koinly or cointracking")
+	since := flags.String("since", "", "first day to export, YYYY-MM-DD in UTC (default: all recorded history)")
+	until := flags.String("until", "", "last day to export, YYYY-MM-DD in UTC (default: today)")
+	tag := flags.String("tag", "", "only export addresses with any of these comma-separated tags")
+	currency := flags.String("currency", "", "fiat currency of values (default: the first PRICE_CURRENCIES)")
+	output := flags.String("output", "", "file to write (default: standard output)")
+	flags.Parse(args)
+	if *format != exportFormatKoinly && *format != exportFormatCoinTracking {
+		log.Fatalf("--format must be %s or %s, got %q", exportFormatKoinly, exportFormatCoinTracking, *format)
+	}
+	from, to, err := exportRange(*since, *until)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Nothing is sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		log.Fatalf("Error loading config: %v", err)
+	}
+	if *currency == "" && len(config.PriceCurrencies) > 0 {
+		*currency = config.PriceCurrencies[0]
+	}
+
+	m := newMonitor(config, nil, monitor.FileStore{Path: balanceFile})
+	if err := m.Load(); err != nil {
+		log.Fatalf("Error loading %s: %v", balanceFile, err)
+	}
+	tags := parseTags(*tag)
+	var transfers []monitor.Transfer
+	for _, transfer := range m.Transfers(from, to) {
+		if m.HasTag(transfer.Address, tags...) {
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *output, err)
+		}
+		defer file.Close()
+		w = file
+	}
+	export := exportKoinly
+	if *format == exportFormatCoinTracking {
+		export = exportCoinTracking
+	}
+	if err := export(w, transfers, m.Labels, m.Format, strings.ToLower(*currency)); err != nil {
+		log.Fatalf("Error writing export: %v", err)
+	}
+	if *output != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %d transfers to %s\n", len(transfers), *output)
+	}
+}
+
+// exportRange parses the --since and --until days into the times they
+// span; both are optional
+func exportRange(since, until string) (from, to time.Time, err error) {
+	to = time.Now()
+	if since != "" {
+		if from, err = time.Parse(exportDate, since); err != nil {
+			return from, to, fmt.Errorf("--since must be YYYY-MM-DD, got %q", since)
+		}
+	}
+	if until != "" {
+		day, err := time.Parse(exportDate, until)
+		if err != nil {
+			return from, to, fmt.Errorf("--until must be YYYY-MM-DD, got %q", until)
+		}
+		to = day.Add(24*time.Hour - time.Second)
+	}
+	if to.Before(from) {
+		return from, to, errors.New("--until is before --since")
+	}
+	return from, to, nil
+}
+
+// exportTicker returns the display unit as a ticker, e.g. NOCK
+func exportTicker(format notify.Formatter) string {
+	return strings.ToUpper(strings.TrimPrefix(format.Unit(), "$"))
+}
+
+// exportAmount formats an amount in nick as unsigned display units with
+// full precision
+func exportAmount(nick int64, format notify.Formatter) string {
+	if nick < 0 {
+		nick = -nick
+	}
+	return strconv.FormatFloat(format.ToUnits(nick), 'f', -1, 64)
+}
+
+// exportValue returns the fiat value of a transfer when it happened, or
+// false if no price in currency was recorded
+func exportValue(transfer monitor.Transfer, format notify.Formatter, currency string) (string, bool) {
+	unitPrice, ok := transfer.Quote[currency]
+	if !ok || currency == "" {
+		return "", false
+	}
+	value := format.ToUnits(transfer.Amount) * unitPrice
+	if value < 0 {
+		value = -value
+	}
+	return strconv.FormatFloat(value, 'f', 2, 64), true
+}
+
+// exportName describes the address of a transfer by its label, if any
+func exportName(address string, labels map[string]string) string {
+	if label := labels[address]; label != "" {
+		return label + " (" + address + ")"
+	}
+	return address
+}
+
+// exportKoinly writes Koinly's universal CSV: received and sent amounts,
+// the net worth at the time, and the address as the description
+func exportKoinly(w io.Writer, transfers []monitor.Transfer, labels map[string]string, format notify.Formatter, currency string) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency",
+		"Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency", "Label", "Description", "TxHash"})
+	ticker := exportTicker(format)
+	for _, transfer := range transfers {
+		row := make([]string, 12)
+		row[0] = transfer.Time.UTC().Format("2006-01-02 15:04:05 UTC")
+		if transfer.Amount < 0 {
+			row[1], row[2] = exportAmount(transfer.Amount, format), ticker
+		} else {
+			row[3], row[4] = exportAmount(transfer.Amount, format), ticker
+		}
+		if value, ok := exportValue(transfer, format, currency); ok {
+			row[7], row[8] = value, strings.ToUpper(currency)
+		}
+		row[10] = exportName(transfer.Address, labels)
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportCoinTracking writes CoinTracking's CSV import format: deposits and
+// withdrawals with the address as the exchange, so each wallet is tracked
+// separately, and the fiat value at the time in the comment
+func exportCoinTracking(w io.Writer, transfers []monitor.Transfer, labels map[string]string, format notify.Formatter, currency string) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"Type", "Buy Amount", "Buy Currency", "Sell Amount", "Sell Currency",
+		"Fee", "Fee Currency", "Exchange", "Trade-Group", "Comment", "Date"})
+	ticker := exportTicker(format)
+	for _, transfer := range transfers {
+		row := make([]string, 11)
+		if transfer.Amount < 0 {
+			row[0], row[3], row[4] = "Withdrawal", exportAmount(transfer.Amount, format), ticker
+		} else {
+			row[0], row[1], row[2] = "Deposit", exportAmount(transfer.Amount, format), ticker
+		}
+		row[7] = exportName(transfer.Address, labels)
+		if value, ok := exportValue(transfer, format, currency); ok {
+			row[9] = fmt.Sprintf("Value %s %s", value, strings.ToUpper(currency))
+		}
+		row[10] = transfer.Time.UTC().Format("2006-01-02 15:04:05")
+		writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
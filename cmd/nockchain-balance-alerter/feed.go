@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// feedEntries caps the number of entries in the Atom feed
+const feedEntries = 50
+
+// atomFeed is an Atom (RFC 4287) feed document
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomAuthor is the author of the feed
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomEntry is one balance change
+type atomEntry struct {
+	ID       string       `xml:"id"`
+	Title    string       `xml:"title"`
+	Updated  string       `xml:"updated"`
+	Category atomCategory `xml:"category"`
+	Content  string       `xml:"content"`
+}
+
+// atomCategory tags an entry with the address it is about, so readers and
+// automations can filter on it
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// handleFeed returns the recent balance changes as an Atom feed, of one
+// address with ?address=, of one summary group with ?group=, or of the
+// addresses carrying any of the tags given as ?tag=cold,hot
+func handleFeed(w http.ResponseWriter, r *http.Request, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+
+	query := r.URL.Query()
+	address, group, tags := query.Get("address"), query.Get("group"), parseTags(query.Get("tag"))
+	title := "Nockchain balance changes"
+	switch {
+	case address != "":
+		title += ": " + exportName(address, m.Labels)
+	case group != "":
+		title += ": " + group
+	}
+	var transfers []monitor.Transfer
+	for _, transfer := range m.Transfers(time.Time{}, time.Now()) {
+		if (address == "" || transfer.Address == address) && (group == "" || m.Groups[transfer.Address] == group) && m.HasTag(transfer.Address, tags...) {
+			transfers = append(transfers, transfer)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	if err := writeFeed(w, title, r.URL.RawQuery, transfers, m.Labels, m.Format, time.Now()); err != nil {
+		log.Printf("Error writing feed: %v", err)
+	}
+}
+
+// writeFeed writes the newest feedEntries transfers as an Atom feed whose
+// ID is derived from the filter, so each filtered feed is its own feed
+func writeFeed(w io.Writer, title, filter string, transfers []monitor.Transfer, labels map[string]string, format notify.Formatter, now time.Time) error {
+	feed := atomFeed{
+		ID:      "urn:" + calendarDomain + ":feed:" + filterWithoutToken(filter),
+		Title:   title,
+		Updated: now.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "Nockchain Balance Alerter"},
+	}
+	// Transfers are oldest first; feeds list the newest first
+	for i := len(transfers) - 1; i >= 0 && len(feed.Entries) < feedEntries; i-- {
+		transfer := transfers[i]
+		if len(feed.Entries) == 0 {
+			feed.Updated = transfer.Time.UTC().Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:       fmt.Sprintf("urn:%s:change:%s:%d", calendarDomain, transfer.Address, transfer.Time.Unix()),
+			Title:    transferTitle(transfer, labels[transfer.Address], format),
+			Updated:  transfer.Time.UTC().Format(time.RFC3339),
+			Category: atomCategory{Term: transfer.Address},
+			Content: fmt.Sprintf("%s: %s → %s at %s", exportName(transfer.Address, labels), format.Delta(transfer.Amount),
+				format.Balance(transfer.Balance), transfer.Time.UTC().Format("2006-01-02 15:04 UTC")),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(feed)
+}
+
+// filterWithoutToken drops the API token from a feed's query string, so it
+// doesn't end up in the feed ID
+func filterWithoutToken(rawQuery string) string {
+	query, _ := url.ParseQuery(rawQuery)
+	query.Del("token")
+	if len(query) == 0 {
+		return "all"
+	}
+	return query.Encode()
+}
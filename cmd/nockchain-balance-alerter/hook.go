@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+// defaultHookTimeout is how long the alert hook may run before the alert is
+// sent as usual
+const defaultHookTimeout = 5 * time.Second
+
+// commandHook decides on alerts by running an external program, in any
+// language, with the event as JSON on stdin; it prints a decision as JSON,
+// or nothing to send the alert as usual
+type commandHook struct {
+	command string
+	timeout time.Duration
+}
+
+// Decide implements monitor.Hook
+func (h commandHook) Decide(event monitor.Event) (monitor.Decision, error) {
+	input, err := json.Marshal(event)
+	if err != nil {
+		return monitor.Decision{}, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	args := strings.Fields(h.command)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	output, err := cmd.Output()
+	if err != nil {
+		return monitor.Decision{}, fmt.Errorf("running %s: %w", args[0], err)
+	}
+	var decision monitor.Decision
+	if len(bytes.TrimSpace(output)) == 0 {
+		return decision, nil
+	}
+	if err := json.Unmarshal(output, &decision); err != nil {
+		return monitor.Decision{}, fmt.Errorf("%s printed %q, expected a JSON decision: %w", args[0], output, err)
+	}
+	return decision, nil
+}
+
+// newHook builds the configured alert hook, or nil
+func newHook(config Config) monitor.Hook {
+	if config.AlertHookCommand == "" {
+		return nil
+	}
+	return commandHook{command: config.AlertHookCommand, timeout: config.AlertHookTimeout}
+}
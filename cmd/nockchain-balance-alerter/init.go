@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/rpc"
+	"github.com/bwmarrin/discordgo"
+)
+
+// wizard asks the init questions on a terminal
+type wizard struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// ask prints a question and returns the trimmed answer, or def if the
+// answer is empty
+func (w *wizard) ask(question, def string) string {
+	if def != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", question)
+	}
+	answer, err := w.in.ReadString('\n')
+	if err != nil && answer == "" {
+		if err == io.EOF {
+			fmt.Fprintln(w.out)
+			log.Fatal("Setup cancelled")
+		}
+		log.Fatalf("Error reading answer: %v", err)
+	}
+	if answer = strings.TrimSpace(answer); answer == "" {
+		return def
+	}
+	return answer
+}
+
+// confirm asks a yes/no question
+func (w *wizard) confirm(question string, def bool) bool {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	switch strings.ToLower(w.ask(question+" ("+hint+")", "")) {
+	case "y", "yes":
+		return true
+	case "n", "no":
+		return false
+	default:
+		return def
+	}
+}
+
+// envSetting is one line of the written .env file
+type envSetting struct {
+	key, value string
+}
+
+// runInit asks for notifier credentials and addresses, tests them, and
+// writes a .env file
+func runInit(args []string) {
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	output := flags.String("output", envFile, "file to write the settings to")
+	force := flags.Bool("force", false, "overwrite the file if it exists")
+	flags.Parse(args)
+
+	if _, err := os.Stat(*output); err == nil && !*force {
+		log.Fatalf("%s already exists; use --force to overwrite it", *output)
+	}
+
+	w := &wizard{in: bufio.NewReader(os.Stdin), out: os.Stdout}
+	fmt.Fprintln(w.out, "This sets up nockchain-balance-alerter. Press Enter to skip optional questions.")
+
+	var written []envSetting
+	fmt.Fprintln(w.out, "\nNotifiers (at least one is needed)")
+	for {
+		written = append(written, w.askSlack()...)
+		written = append(written, w.askTelegram()...)
+		written = append(written, w.askDiscord()...)
+		written = append(written, w.askDesktop()...)
+		if len(written) > 0 {
+			break
+		}
+		fmt.Fprintln(w.out, "No notifier was set up; alerts need somewhere to go.")
+	}
+
+	fmt.Fprintln(w.out, "\nNode")
+	rpcURL := w.ask("JSON-RPC URL", rpc.DefaultURL)
+	if rpcURL != rpc.DefaultURL {
+		written = append(written, envSetting{"RPC_URL", rpcURL})
+	}
+
+	fmt.Fprintln(w.out, "\nAddresses (one per line, empty line to finish)")
+	addresses := w.askAddresses(rpc.NewClient(rpcURL))
+	written = append(written, envSetting{"ADDRESSES", strings.Join(addresses, ",")})
+
+	if err := writeEnvFile(*output, written); err != nil {
+		log.Fatalf("Error writing %s: %v", *output, err)
+	}
+	fmt.Fprintf(w.out, "\nWrote %s. See .env.example for every other setting, then start the alerter with:\n  go run ./cmd/nockchain-balance-alerter\n", *output)
+}
+
+// askSlack asks for a Slack bot token and channel until a test message
+// goes through or the user skips Slack
+func (w *wizard) askSlack() []envSetting {
+	for {
+		token := w.ask("Slack bot token (xoxb-...)", "")
+		if token == "" {
+			return nil
+		}
+		channel := w.ask("Slack channel", "#nock-balances")
+		if w.test(&notify.Slack{BotToken: token, Channel: channel}) {
+			return []envSetting{{"SLACK_BOT_TOKEN", token}, {"SLACK_CHANNEL", channel}}
+		}
+	}
+}
+
+// askTelegram asks for a Telegram bot token and chat ID until a test
+// message goes through or the user skips Telegram
+func (w *wizard) askTelegram() []envSetting {
+	for {
+		token := w.ask("Telegram bot token", "")
+		if token == "" {
+			return nil
+		}
+		chatID := w.ask("Telegram chat ID", "")
+		if w.test(&notify.Telegram{BotToken: token, ChatID: chatID}) {
+			return []envSetting{{"TELEGRAM_BOT_TOKEN", token}, {"TELEGRAM_CHAT_ID", chatID}}
+		}
+	}
+}
+
+// askDiscord asks for a Discord bot token and channel ID until a test
+// message goes through or the user skips Discord
+func (w *wizard) askDiscord() []envSetting {
+	for {
+		token := w.ask("Discord bot token", "")
+		if token == "" {
+			return nil
+		}
+		channelID := w.ask("Discord channel ID", "")
+		// Messages are sent over REST, so the gateway isn't opened
+		session, err := discordgo.New("Bot " + token)
+		if err != nil {
+			fmt.Fprintf(w.out, "✗ Invalid Discord token: %v\n", err)
+			continue
+		}
+		if w.test(&notify.Discord{Session: session, ChannelID: channelID}) {
+			return []envSetting{{"DISCORD_BOT_TOKEN", token}, {"DISCORD_CHANNEL_ID", channelID}}
+		}
+	}
+}
+
+// askDesktop offers desktop notifications, sending a test one if chosen
+func (w *wizard) askDesktop() []envSetting {
+	if !w.confirm("Desktop notifications on this computer?", false) {
+		return nil
+	}
+	if w.test(&notify.Desktop{}) {
+		return []envSetting{{"DESKTOP_NOTIFICATIONS", "true"}}
+	}
+	return nil
+}
+
+// test sends a test message through a notifier and reports whether it
+// should be kept: it worked, or the user keeps it despite the error
+func (w *wizard) test(n notify.Notifier) bool {
+	err := n.NotifyAlert(notify.Alert{
+		Emoji:    "👋",
+		Title:    "nockchain-balance-alerter setup test",
+		Fields:   []notify.Field{{Name: "Status", Value: "Alerts will be delivered here"}},
+		Time:     time.Now(),
+		Severity: notify.SeverityInfo,
+	})
+	if err == nil {
+		fmt.Fprintf(w.out, "✓ %s: test message sent\n", n.Name())
+		return true
+	}
+	fmt.Fprintf(w.out, "✗ %s: sending a test message failed: %v\n", n.Name(), err)
+	return w.confirm("Keep these settings anyway?", false)
+}
+
+// askAddresses reads addresses until an empty line, checking each against
+// the RPC; at least one is required
+func (w *wizard) askAddresses(client *rpc.Client) []string {
+	var addresses []string
+	for {
+		address := w.ask("Address", "")
+		if address == "" {
+			if len(addresses) > 0 {
+				return addresses
+			}
+			fmt.Fprintln(w.out, "At least one address is needed.")
+			continue
+		}
+		balance, err := client.GetBalance(address)
+		if err != nil {
+			fmt.Fprintf(w.out, "✗ Looking up %s failed: %v\n", address, err)
+			if !w.confirm("Watch it anyway?", false) {
+				continue
+			}
+		} else {
+			fmt.Fprintf(w.out, "✓ Balance: %s\n", notify.FormatBalance(balance))
+		}
+		addresses = append(addresses, address)
+	}
+}
+
+// writeEnvFile writes the settings as a .env file, readable only by the
+// owner since it holds bot tokens
+func writeEnvFile(path string, settings []envSetting) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Written by nockchain-balance-alerter init on %s\n", time.Now().Format("2006-01-02"))
+	for _, s := range settings {
+		fmt.Fprintf(&b, "%s=%s\n", s.key, s.value)
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
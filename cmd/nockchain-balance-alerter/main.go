@@ -0,0 +1,570 @@
+// Command nockchain-balance-alerter monitors nockchain addresses and sends
+// balance change alerts and periodic summaries to Slack, Telegram, and Discord.
+package main
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+	_ "time/tzdata" // TIMEZONE works on hosts without a zoneinfo database
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/dedupe"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/node"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/rpc"
+	"github.com/go-co-op/gocron"
+)
+
+// version is reported in the User-Agent; release builds set it with
+// -ldflags "-X main.version=v1.2.3"
+var version = "dev"
+
+func main() {
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+	runMonitor()
+}
+
+// runMonitor loads the config, starts the bots and scheduled checks, and
+// runs until the process is stopped
+func runMonitor() {
+	config, err := loadConfig()
+	// The operator's own config needs no notifiers when it only hosts tenants
+	if err != nil && !(errors.Is(err, errNoNotifiers) && config.TenantsDir != "") {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	slackApp := newSlackApp(config)
+	m := startMonitor(config, "", balanceFile, slackApp)
+
+	var tenants map[string]http.Handler
+	if config.TenantsDir != "" {
+		if tenants, err = startTenants(config); err != nil {
+			log.Fatalf("Error starting tenants: %v", err)
+		}
+	}
+
+	if config.APIListenAddr != "" {
+		go func() {
+			log.Printf("API listening on %s", config.APIListenAddr)
+			if err := http.ListenAndServe(config.APIListenAddr, withTenants(newAPIHandler(config, m, slackApp), tenants)); err != nil {
+				log.Fatalf("Error serving API: %v", err)
+			}
+		}()
+	}
+
+	// Keep the program running
+	select {}
+}
+
+// startMonitor starts the bots and scheduled checks of one monitor, the
+// operator's own or, when tenant is set, a tenant's, keeping its state in
+// stateFile
+func startMonitor(config Config, tenant, stateFile string, slackApp *notify.SlackApp) *monitor.Monitor {
+	prefix := ""
+	if tenant != "" {
+		prefix = tenant + " "
+	}
+	// In a cluster, the state lives in this instance's directory of the
+	// shared CLUSTER_DIR, where the others can take addresses over from it
+	var c *cluster
+	if config.ClusterDir != "" {
+		var err error
+		if c, err = newCluster(config.ClusterDir, config.InstanceID); err != nil {
+			log.Fatalf("Error joining cluster: %v", err)
+		}
+		stateFile = c.statePath()
+	}
+	// leader reports whether this instance sends what covers every address,
+	// such as summaries, which only one instance of a cluster may
+	leader := func() bool { return c == nil || c.leader() }
+
+	httpClient, breaker := newHTTPClient(config)
+	m := newMonitor(config, newBalanceSource(config, httpClient), monitor.FileStore{Path: stateFile}, newNotifiers(config, slackApp)...)
+	if c != nil {
+		m.Cluster = c
+	}
+	if breaker != nil {
+		m.Circuits = breaker
+	}
+	if config.DedupeTransactions {
+		notified, err := dedupe.Open(filepath.Join(filepath.Dir(stateFile), notifiedFile))
+		if err != nil {
+			log.Fatalf("Error opening record of reported transactions: %v", err)
+		}
+		m.Notified = notified
+	}
+	for _, archive := range newArchives(config) {
+		m.AddArchive(archive)
+	}
+	if err := m.Load(); err != nil {
+		log.Fatalf("Error loading state: %v", err)
+	}
+
+	if config.DiscordBotToken != "" {
+		session, err := startDiscordBot(config, m)
+		if err != nil {
+			log.Fatalf("Error starting Discord bot: %v", err)
+		}
+		if config.DiscordChannelID != "" {
+			templates := mustLoadTemplates(config, "discord")
+			m.AddNotifier(&notify.Discord{Session: session, ChannelID: config.DiscordChannelID, Templates: templates, Units: config.DiscordUnits, Delivery: discordDelivery(config), Overflow: config.DiscordOverflow})
+		}
+		if config.ArchiveDiscordChannelID != "" {
+			templates := mustLoadTemplates(config, "discord")
+			m.AddArchive(&notify.Discord{Session: session, ChannelID: config.ArchiveDiscordChannelID, Templates: templates, Units: config.DiscordUnits, Delivery: notify.Delivery{SilentUpTo: notify.SeverityCritical}, Overflow: config.DiscordOverflow})
+		}
+		log.Println("Discord bot connected. Listening for slash commands...")
+	}
+
+	var subscribers *telegramSubscribers
+	if config.TelegramSubscriptions != "" {
+		subscribers = newTelegramSubscribers(config, m)
+		m.AddNotifier(subscribers)
+	}
+	if (config.TelegramActions || subscribers != nil) && config.TelegramBotToken != "" {
+		go startTelegramBot(config, m, subscribers)
+		log.Println("Listening for Telegram alert buttons and commands...")
+	}
+
+	// Deliver queued alerts once every notifier is registered
+	if config.DeliveryQueue {
+		go m.RunDelivery(nil)
+	}
+
+	location, err := time.LoadLocation(config.Timezone)
+	if err != nil {
+		log.Fatalf("Error loading time zone: %v", err)
+	}
+	scheduler := gocron.NewScheduler(location)
+
+	if c != nil {
+		_, err = scheduler.Every(clusterHeartbeat).WaitForSchedule().Do(job(prefix+"cluster heartbeat", func() {
+			if err := c.heartbeat(); err != nil {
+				log.Printf("Error writing cluster heartbeat: %v", err)
+			}
+		}))
+		if err != nil {
+			log.Fatalf("Error scheduling cluster heartbeat: %v", err)
+		}
+	}
+
+	// Follow the address book, pulling it before the first balance check
+	if config.AddressBookURL != "" || config.AddressBookGit != "" {
+		sync := job(prefix+"address book sync", func() { syncAddressBook(config, m, addressBookDir(stateFile)) })
+		sync()
+		if _, err := scheduler.Every(config.AddressBookInterval).WaitForSchedule().Do(sync); err != nil {
+			log.Fatalf("Error scheduling address book sync: %v", err)
+		}
+	}
+
+	// Schedule balance check every minute, pushing metrics after each. With
+	// CHECK_SHARDS, one slice of the watchlist is checked at a time, evenly
+	// spread over the minute, and metrics are pushed after each full cycle.
+	push := func() {
+		if config.PushgatewayURL != "" {
+			if err := pushMetrics(config, m); err != nil {
+				log.Printf("Error pushing metrics: %v", err)
+			}
+		}
+	}
+	// With CHECK_JITTER, every check, the first included, starts after a
+	// random delay of up to that long, so instances started together don't
+	// keep querying the RPC in the same second
+	jitter := func() {
+		if config.CheckJitter > 0 {
+			time.Sleep(time.Duration(rand.Int63n(int64(config.CheckJitter))))
+		}
+	}
+	if config.CheckShards > 1 {
+		_, err = scheduler.Every(checkInterval / time.Duration(config.CheckShards)).Do(job(prefix+"balance check", func() {
+			jitter()
+			if _, complete := m.CheckShard(); complete {
+				push()
+			}
+		}))
+	} else {
+		_, err = scheduler.Every(checkInterval).Do(job(prefix+"balance check", func() {
+			jitter()
+			m.CheckAll()
+			push()
+		}))
+	}
+	if err != nil {
+		log.Fatalf("Error scheduling balance check: %v", err)
+	}
+
+	// Schedule price rule checks alongside balance checks
+	if config.PriceProvider != "" {
+		_, err = scheduler.Every(checkInterval).Do(job(prefix+"price check", func() {
+			if leader() {
+				m.CheckPrice()
+			}
+		}))
+		if err != nil {
+			log.Fatalf("Error scheduling price check: %v", err)
+		}
+	}
+
+	// Schedule node checks alongside balance checks
+	if config.NodeStatusURL != "" {
+		_, err = scheduler.Every(checkInterval).Do(job(prefix+"node check", func() {
+			if leader() {
+				m.CheckNode()
+			}
+		}))
+		if err != nil {
+			log.Fatalf("Error scheduling node check: %v", err)
+		}
+	}
+
+	// Schedule overdue payout checks alongside balance checks
+	if config.PayoutLatePct > 0 {
+		_, err = scheduler.Every(checkInterval).Do(job(prefix+"payout check", m.CheckPayouts))
+		if err != nil {
+			log.Fatalf("Error scheduling payout check: %v", err)
+		}
+	}
+
+	// Schedule expected inflow checks alongside balance checks
+	if len(config.ExpectedInflows) > 0 {
+		_, err = scheduler.Every(checkInterval).Do(job(prefix+"inflow check", m.CheckExpectedInflows))
+		if err != nil {
+			log.Fatalf("Error scheduling inflow check: %v", err)
+		}
+	}
+
+	// Schedule summaries at fixed times of day, so restarts don't shift them
+	if err := scheduleSummaries(scheduler, config, m, leader); err != nil {
+		log.Fatalf("Error scheduling summary: %v", err)
+	}
+
+	// Schedule the earnings report every day, or every Monday
+	switch config.ReportSchedule {
+	case reportDaily:
+		_, err = scheduler.Every(1).Day().At(config.ReportTime).Do(job(prefix+"report", func() {
+			if leader() {
+				m.SendReport("Daily", 24*time.Hour)
+			}
+		}))
+	case reportWeekly:
+		_, err = scheduler.Every(1).Monday().At(config.ReportTime).Do(job(prefix+"report", func() {
+			if leader() {
+				m.SendReport("Weekly", 7*24*time.Hour)
+			}
+		}))
+	}
+	if err != nil {
+		log.Fatalf("Error scheduling report: %v", err)
+	}
+
+	// Send anything that came due while the alerter was down
+	if leader() {
+		job(prefix+"catch-up", func() { catchUpSchedules(config, m, location) })()
+	}
+
+	// Show the baseline so operators can see monitoring is live
+	if config.StartupSnapshot {
+		job(prefix+"startup snapshot", func() {
+			m.CheckAll()
+			if leader() {
+				m.SendStartup()
+			}
+		})()
+	}
+
+	scheduler.StartAsync()
+	if tenant == "" {
+		log.Println("Cron job started. Monitoring addresses...")
+	} else {
+		log.Printf("Cron job started. Monitoring addresses of tenant %s...", tenant)
+	}
+	return m
+}
+
+// runCommand runs a subcommand instead of the monitor
+func runCommand(name string, args []string) {
+	switch name {
+	case "replay":
+		runReplay(args)
+	case "mockrpc":
+		runMockRPC(args)
+	case "notify":
+		runNotify(args)
+	case "balances":
+		runBalances(args)
+	case "status":
+		runStatus(args)
+	case "init":
+		runInit(args)
+	case "service":
+		runService(args)
+	case "tui":
+		runTUI(args)
+	case "export":
+		runExport(args)
+	case "calendar":
+		runCalendar(args)
+	case "purge":
+		runPurge(args)
+	case "bench":
+		runBench(args)
+	default:
+		log.Fatalf("Unknown command %q; available: init, replay, mockrpc, notify, balances, status, service, tui, export, calendar, purge, bench", name)
+	}
+}
+
+// newNotifiers builds the configured Slack and Telegram notifiers, plus one
+// per workspace that installed slackApp; Discord is added once its bot
+// session is up
+func newNotifiers(config Config, slackApp *notify.SlackApp) []notify.Notifier {
+	var notifiers []notify.Notifier
+	if config.SlackBotToken != "" && config.SlackChannel != "" {
+		templates := mustLoadTemplates(config, "slack")
+		notifiers = append(notifiers, &notify.Slack{BotToken: config.SlackBotToken, Channel: config.SlackChannel, Templates: templates, Units: config.SlackUnits, Delivery: slackDelivery(config), Overflow: config.SlackOverflow, Actions: config.SlackSigningSecret != ""})
+	}
+	if slackApp != nil {
+		templates := mustLoadTemplates(config, "slack")
+		for _, installation := range slackApp.Installations() {
+			slack := slackApp.Notifier(installation.TeamID, templates, config.SlackUnits)
+			slack.Delivery = slackDelivery(config)
+			slack.Overflow = config.SlackOverflow
+			slack.Actions = config.SlackSigningSecret != ""
+			notifiers = append(notifiers, slack)
+		}
+	}
+	if config.TelegramBotToken != "" && config.TelegramChatID != "" {
+		templates := mustLoadTemplates(config, "telegram")
+		notifiers = append(notifiers, &notify.Telegram{BotToken: config.TelegramBotToken, ChatID: config.TelegramChatID, AlertThreadID: config.TelegramThreadID, SummaryThreadID: config.TelegramSummaryThreadID, Actions: config.TelegramActions, Templates: templates, Units: config.TelegramUnits, Delivery: notify.Delivery{SilentUpTo: config.SilentSeverity}, Overflow: config.TelegramOverflow})
+	}
+	if config.WebhookURL != "" {
+		webhook := &notify.Webhook{URL: config.WebhookURL, Source: config.WebhookSource, Secret: config.WebhookSecret}
+		if config.WebhookToken != "" {
+			webhook.Headers = map[string]string{"Authorization": "Bearer " + config.WebhookToken}
+		}
+		notifiers = append(notifiers, webhook)
+	}
+	if config.EventBridgeBus != "" {
+		notifiers = append(notifiers, &notify.EventBridge{
+			Region: config.AWSRegion,
+			Credentials: notify.AWSCredentials{
+				AccessKeyID:     config.AWSAccessKeyID,
+				SecretAccessKey: config.AWSSecretAccessKey,
+				SessionToken:    config.AWSSessionToken,
+			},
+			EventBus:   config.EventBridgeBus,
+			DetailType: config.EventBridgeDetailType,
+			Source:     config.EventBridgeSource,
+		})
+	}
+	if config.AlertmanagerURL != "" {
+		alertmanager := &notify.Alertmanager{URL: config.AlertmanagerURL, Mode: config.AlertmanagerMode, Labels: config.AlertmanagerLabels, ExplorerURL: config.ExplorerURL, Format: config.formatter(), Branding: config.Branding}
+		if config.AlertmanagerToken != "" {
+			alertmanager.Headers = map[string]string{"Authorization": "Bearer " + config.AlertmanagerToken}
+		}
+		notifiers = append(notifiers, alertmanager)
+	}
+	if config.DesktopNotifications {
+		notifiers = append(notifiers, &notify.Desktop{Format: config.formatter(), Branding: config.Branding})
+	}
+	if config.SyslogAddress != "" {
+		notifiers = append(notifiers, &notify.Syslog{Address: config.SyslogAddress, Facility: config.SyslogFacility, AppName: config.SyslogAppName, Format: config.formatter(), Branding: config.Branding})
+	}
+	if config.SystemdJournal {
+		notifiers = append(notifiers, &notify.Journal{Identifier: config.SyslogAppName, Format: config.formatter(), Branding: config.Branding})
+	}
+	return notifiers
+}
+
+// newArchives builds the configured archive notifiers, which get a copy of
+// every alert and summary; they are delivered silently and without
+// buttons, as nobody acts on them. The Discord archive is added once its bot
+// session is up.
+func newArchives(config Config) []notify.Notifier {
+	var archives []notify.Notifier
+	if config.ArchiveSlackChannel != "" {
+		archives = append(archives, &notify.Slack{BotToken: config.SlackBotToken, Channel: config.ArchiveSlackChannel, Templates: mustLoadTemplates(config, "slack"), Units: config.SlackUnits, Overflow: config.SlackOverflow})
+	}
+	if config.ArchiveTelegramChatID != "" {
+		archives = append(archives, &notify.Telegram{BotToken: config.TelegramBotToken, ChatID: config.ArchiveTelegramChatID, Templates: mustLoadTemplates(config, "telegram"), Units: config.TelegramUnits, Delivery: notify.Delivery{SilentUpTo: notify.SeverityCritical}, Overflow: config.TelegramOverflow})
+	}
+	if len(config.ArchiveEmailTo) > 0 {
+		archives = append(archives, &notify.Email{Addr: config.SMTPAddr, Username: config.SMTPUsername, Password: config.SMTPPassword, From: config.SMTPFrom, To: config.ArchiveEmailTo, Templates: mustLoadTemplates(config, "email")})
+	}
+	return archives
+}
+
+// slackDelivery returns how loudly Slack delivers each severity
+func slackDelivery(config Config) notify.Delivery {
+	return notify.Delivery{SilentUpTo: config.SilentSeverity, Mention: config.SlackCriticalMention}
+}
+
+// discordDelivery returns how loudly Discord delivers each severity
+func discordDelivery(config Config) notify.Delivery {
+	return notify.Delivery{SilentUpTo: config.SilentSeverity, Mention: config.DiscordCriticalMention}
+}
+
+// newMonitor builds a monitor with every configured option; the caller
+// loads its state
+func newMonitor(config Config, source monitor.BalanceSource, store monitor.Store, notifiers ...notify.Notifier) *monitor.Monitor {
+	m := monitor.New(source, store, config.Addresses, notifiers...)
+	m.Patterns = config.WatchPatterns
+	m.Labels = config.Labels
+	m.KnownAddresses = config.KnownAddresses
+	m.Groups = config.Groups
+	m.Tags = config.Tags
+	m.SummaryTags = config.SummaryTags
+	m.AlertTags = config.AlertTags
+	m.SummaryGroupBy = config.SummaryGroupBy
+	m.Wallets = newWallets(config)
+	m.ExpectedInflows = config.ExpectedInflows
+	m.DormantAfter = config.DormantAfter
+	m.ReportProjection = config.ReportProjection
+	m.Discovery = config.AddressDiscovery
+	m.AlertOnTransactions = config.AlertOnTransactions
+	m.MaxUTXOs = config.MaxUTXOs
+	m.DustThreshold = config.DustThreshold
+	m.TrackFees = config.TrackFees
+	m.ShowMemos = config.ShowMemos
+	m.MaxFee = config.MaxFee
+	m.TrackLocked = config.TrackLocked
+	m.TrackSigners = config.TrackSigners
+	m.DedupeTransactions = config.DedupeTransactions
+	m.CatchUpAfter = config.CatchUpAfter
+	m.CombineChanges = config.CombineChanges
+	m.CheckShards = config.CheckShards
+	m.CycleDeadline = config.CycleDeadline
+	m.ShuffleChecks = config.ShuffleChecks
+	m.AlertOnCircuits = config.AlertOnRPCBreaker
+	m.CombineMaxRows = config.CombineMaxRows
+	m.QueueDelivery = config.DeliveryQueue
+	m.SendTimeout = config.SendTimeout
+	m.StaleAfter = config.StaleAfter
+	m.ConfirmZero = config.ConfirmZero
+	m.FlapWindow = config.FlapWindow
+	m.Hook = newHook(config)
+	m.Severities = config.Severities
+	m.Prices = newPriceProvider(config)
+	m.PriceRules = config.PriceRules
+	if m.Prices != nil {
+		m.CostCurrency = config.PriceCurrencies[0]
+		m.CostBasis = config.CostBasis
+	}
+	if config.NodeStatusURL != "" {
+		m.Node = &node.Client{URL: config.NodeStatusURL}
+		m.NodeRules = config.NodeRules
+	}
+	m.PayoutLatePercent = config.PayoutLatePct
+	m.SummarySort = config.SummarySort
+	m.Chart = config.SummaryChart
+	m.Format = config.formatter()
+	m.Branding = config.Branding
+	m.SummaryStats = config.SummaryStats
+	m.FlowPeriod = config.FlowPeriod
+	m.SparklinePoints = config.SparklinePoints
+	m.PinnedSummary = config.SummaryMode == summaryModePinned
+	return m
+}
+
+// mustLoadTemplates loads the message template overrides and translations
+// for one notifier
+func mustLoadTemplates(config Config, prefix string) notify.Templates {
+	language := map[string]string{
+		"slack":    config.SlackLanguage,
+		"telegram": config.TelegramLanguage,
+		"discord":  config.DiscordLanguage,
+	}[prefix]
+	translations, err := notify.LoadTranslations(config.TranslationsDir, language)
+	if err != nil {
+		log.Fatalf("Error loading %s translations: %v", prefix, err)
+	}
+	templates, err := notify.LoadTemplates(config.TemplateDir, prefix, config.ExplorerURL, translations, config.formatter(), config.Branding)
+	if err != nil {
+		log.Fatalf("Error loading %s templates: %v", prefix, err)
+	}
+	return templates
+}
+
+// defaultRPCTimeout is how long each RPC or GraphQL request may take unless
+// RPC_TIMEOUT says otherwise
+const defaultRPCTimeout = 30 * time.Second
+
+// newHTTPClient returns the client used for RPC and GraphQL requests, which
+// gives up on each after RPC_TIMEOUT, stops querying an endpoint after
+// RPC_BREAKER_THRESHOLD failures in a row and records every response when
+// RPC_RECORD_FILE is set. The breaker is nil when disabled.
+func newHTTPClient(config Config) (*http.Client, *rpc.Breaker) {
+	var transport http.RoundTripper
+	if config.RPCRecordFile != "" {
+		transport = &rpc.Recorder{Path: config.RPCRecordFile}
+	}
+	if config.RPCBreakerThreshold == 0 {
+		return &http.Client{Timeout: config.RPCTimeout, Transport: transport}, nil
+	}
+	breaker := &rpc.Breaker{
+		Threshold: config.RPCBreakerThreshold,
+		Probe:     config.RPCBreakerProbe,
+		Next:      transport,
+		OnChange: func(endpoint string, open bool, err error) {
+			if open {
+				log.Printf("RPC endpoint %s failed %d times in a row, last with %v; pausing requests to it and probing every %s", endpoint, config.RPCBreakerThreshold, err, config.RPCBreakerProbe)
+			} else {
+				log.Printf("RPC endpoint %s answered again; resuming requests", endpoint)
+			}
+		},
+	}
+	return &http.Client{Timeout: config.RPCTimeout, Transport: breaker}, breaker
+}
+
+// newBalanceSource builds the nockchain JSON-RPC or GraphQL client, routing
+// "chain:address" addresses to any extra chains configured in CHAIN_RPC_URLS
+func newBalanceSource(config Config, httpClient *http.Client) monitor.BalanceSource {
+	headers := map[string]string{}
+	if config.OperatorContact != "" {
+		headers["From"] = config.OperatorContact
+	}
+	newClient := func(url string) *rpc.Client {
+		client := rpc.NewClient(url)
+		client.UserAgent = config.UserAgent
+		client.Headers = headers
+		client.HTTPClient = httpClient
+		return client
+	}
+
+	var source monitor.ChainAdapter = newClient(config.RPCURL)
+	if config.GraphQLURL != "" {
+		source = &rpc.GraphQL{URL: config.GraphQLURL, Query: config.GraphQLQuery, BalancePath: config.GraphQLBalancePath, RelatedPath: config.GraphQLRelatedPath, MatchQuery: config.GraphQLMatchQuery, MatchPath: config.GraphQLMatchPath, UserAgent: config.UserAgent, Headers: headers, HTTPClient: httpClient}
+	}
+	var adapters []monitor.ChainAdapter
+	for chain, url := range config.ChainRPCURLs {
+		client := newClient(url)
+		client.Name = chain
+		adapters = append(adapters, client)
+	}
+	return monitor.NewMultiChain(source, adapters...)
+}
+
+// newPriceProvider builds the configured fiat price provider, or nil when
+// prices are disabled
+func newPriceProvider(config Config) price.Provider {
+	var provider price.Provider
+	switch config.PriceProvider {
+	case priceProviderCoinGecko:
+		provider = &price.CoinGecko{CoinID: config.PriceCoinID, Currencies: config.PriceCurrencies, APIKey: config.PriceAPIKey}
+	case priceProviderCoinMarketCap:
+		provider = &price.CoinMarketCap{Symbol: config.PriceSymbol, Currencies: config.PriceCurrencies, APIKey: config.PriceAPIKey}
+	case priceProviderURL:
+		provider = &price.URL{URL: config.PriceURL}
+	default:
+		return nil
+	}
+	return price.NewCache(provider, config.PriceCacheTTL)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/rpc"
+)
+
+// mockScript scripts balance changes on the mock RPC server
+type mockScript struct {
+	Balances map[string]int64 `json:"balances"` // Starting balances
+	Steps    []mockStep       `json:"steps"`
+	Repeat   bool             `json:"repeat"` // Start over after the last step
+}
+
+// mockStep is one scripted change, applied After the previous one
+type mockStep struct {
+	After   string `json:"after"` // Duration, e.g. "1m"
+	Address string `json:"address"`
+	Balance *int64 `json:"balance"` // New balance, or
+	Delta   int64  `json:"delta"`   // change to it
+	Fee     int64  `json:"fee"`
+	Memo    string `json:"memo"` // Of the step's transaction
+	Locked  *int64 `json:"locked"`
+	Blocks  int64  `json:"blocks"` // Blocks to add to the chain height
+
+	// Counterparties are the addresses the step's transaction spent from
+	// or paid to
+	Counterparties []string `json:"counterparties"`
+
+	// Signers, when set, locks the address to any Threshold of these keys;
+	// an empty list makes it single-signer again
+	Signers   *[]string `json:"signers"`
+	Threshold int       `json:"threshold"`
+
+	wait time.Duration
+}
+
+// runMockRPC serves a fake nockblocks JSON-RPC endpoint for end-to-end tests
+// and demos
+func runMockRPC(args []string) {
+	flags := flag.NewFlagSet("mockrpc", flag.ExitOnError)
+	listen := flags.String("listen", "127.0.0.1:8545", "address to serve the mock RPC on")
+	scriptFile := flags.String("script", "", "JSON file of scripted balance changes")
+	blockTime := flags.Duration("block-time", time.Minute, "how often the chain height grows by one block; 0 disables")
+	flags.Parse(args)
+
+	server := rpc.NewMockServer()
+	if *scriptFile != "" {
+		script, err := loadMockScript(*scriptFile)
+		if err != nil {
+			log.Fatalf("Error loading mock script: %v", err)
+		}
+		for address, balance := range script.Balances {
+			server.SetBalance(address, balance, 0)
+		}
+		go playMockScript(server, script)
+	}
+	if *blockTime > 0 {
+		go func() {
+			for range time.Tick(*blockTime) {
+				server.AdvanceHeight(1)
+			}
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", server)
+	mux.Handle("/mock", server.ControlHandler())
+	log.Printf("Mock RPC listening on http://%s; set RPC_URL to it. Change balances with POST /mock?address=...&balance=N", *listen)
+	log.Fatal(http.ListenAndServe(*listen, mux))
+}
+
+// loadMockScript reads and validates a mock script
+func loadMockScript(path string) (mockScript, error) {
+	var script mockScript
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return script, err
+	}
+	if err := json.Unmarshal(data, &script); err != nil {
+		return script, err
+	}
+	for i := range script.Steps {
+		step := &script.Steps[i]
+		if step.After != "" {
+			if step.wait, err = time.ParseDuration(step.After); err != nil {
+				return script, fmt.Errorf("step %d: invalid after %q", i+1, step.After)
+			}
+		}
+		if step.Address == "" && (step.Balance != nil || step.Delta != 0 || step.Locked != nil || step.Signers != nil) {
+			return script, fmt.Errorf("step %d: address is required", i+1)
+		}
+	}
+	if script.Repeat && len(script.Steps) > 0 {
+		var total time.Duration
+		for _, step := range script.Steps {
+			total += step.wait
+		}
+		if total == 0 {
+			return script, fmt.Errorf("a repeating script needs steps with a non-zero after")
+		}
+	}
+	return script, nil
+}
+
+// playMockScript applies the script's steps as their time comes
+func playMockScript(server *rpc.MockServer, script mockScript) {
+	for {
+		for i, step := range script.Steps {
+			time.Sleep(step.wait)
+			switch {
+			case step.Balance != nil:
+				server.SetBalance(step.Address, *step.Balance, step.Fee)
+			case step.Delta != 0:
+				server.AddBalance(step.Address, step.Delta, step.Fee)
+			}
+			if step.Memo != "" {
+				server.SetMemo(step.Address, step.Memo)
+			}
+			if len(step.Counterparties) > 0 {
+				server.SetCounterparties(step.Address, step.Counterparties)
+			}
+			if step.Locked != nil {
+				server.SetLocked(step.Address, *step.Locked)
+			}
+			if step.Signers != nil {
+				server.SetSigners(step.Address, step.Threshold, *step.Signers)
+			}
+			if step.Blocks != 0 {
+				server.AdvanceHeight(step.Blocks)
+			}
+			log.Printf("Applied mock step %d (%s)", i+1, step.Address)
+		}
+		if !script.Repeat {
+			return
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+	"github.com/bwmarrin/discordgo"
+)
+
+// Channels accepted by notify test
+const (
+	channelSlack        = "slack"
+	channelTelegram     = "telegram"
+	channelDiscord      = "discord"
+	channelWebhook      = "webhook"
+	channelEventBridge  = "eventbridge"
+	channelAlertmanager = "alertmanager"
+	channelDesktop      = "desktop"
+	channelSyslog       = "syslog"
+	channelJournal      = "journal"
+	channelAll          = "all"
+)
+
+// runNotify runs a notify subcommand; "test" is the only one
+func runNotify(args []string) {
+	if len(args) == 0 || args[0] != "test" {
+		log.Fatalf("Usage: %s notify test [--channel slack|telegram|discord|webhook|eventbridge|alertmanager|desktop|syslog|journal|all]", os.Args[0])
+	}
+	flags := flag.NewFlagSet("notify test", flag.ExitOnError)
+	channel := flags.String("channel", channelAll, "notifier to test: slack, telegram, discord, webhook, eventbridge, alertmanager, desktop, syslog, journal, or all")
+	flags.Parse(args[1:])
+
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	notifiers, err := testNotifiers(config, strings.ToLower(*channel))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	change, balances := sampleMessages(config)
+	failed := false
+	for _, n := range notifiers {
+		if err := n.NotifyChange(change); err != nil {
+			log.Printf("✗ %s: sending the sample change alert failed: %v", n.Name(), err)
+			failed = true
+			continue
+		}
+		if err := n.NotifySummary(balances); err != nil {
+			log.Printf("✗ %s: sending the sample summary failed: %v", n.Name(), err)
+			failed = true
+			continue
+		}
+		log.Printf("✓ %s: sample change alert and summary sent", n.Name())
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// testNotifiers builds the notifiers for a channel name, failing if the
+// channel isn't configured
+func testNotifiers(config Config, channel string) ([]notify.Notifier, error) {
+	var notifiers []notify.Notifier
+	for _, n := range newNotifiers(config, newSlackApp(config)) {
+		if channel == channelAll || strings.EqualFold(n.Name(), channel) {
+			notifiers = append(notifiers, n)
+		}
+	}
+	if (channel == channelAll || channel == channelDiscord) && config.DiscordBotToken != "" && config.DiscordChannelID != "" {
+		// Messages are sent over REST, so the gateway isn't opened
+		session, err := discordgo.New("Bot " + config.DiscordBotToken)
+		if err != nil {
+			return nil, err
+		}
+		templates := mustLoadTemplates(config, "discord")
+		notifiers = append(notifiers, &notify.Discord{Session: session, ChannelID: config.DiscordChannelID, Templates: templates, Units: config.DiscordUnits, Delivery: discordDelivery(config), Overflow: config.DiscordOverflow})
+	}
+
+	switch channel {
+	case channelSlack, channelTelegram, channelDiscord, channelWebhook, channelEventBridge, channelAlertmanager, channelDesktop, channelSyslog, channelJournal, channelAll:
+	default:
+		return nil, fmt.Errorf("--channel must be %s, %s, %s, %s, %s, %s, %s, %s, %s, or %s, got %q", channelSlack, channelTelegram, channelDiscord, channelWebhook, channelEventBridge, channelAlertmanager, channelDesktop, channelSyslog, channelJournal, channelAll, channel)
+	}
+	if len(notifiers) == 0 {
+		return nil, fmt.Errorf("%s is not configured", channel)
+	}
+	return notifiers, nil
+}
+
+// sampleMessages builds a sample change alert and summary for the first
+// configured address, with fiat values when prices are enabled
+func sampleMessages(config Config) (notify.Change, []notify.Balance) {
+	address, label := "3L1PsampleAddressAUMw", "Sample address"
+	if len(config.Addresses) > 0 {
+		address, label = config.Addresses[0], config.Labels[config.Addresses[0]]
+	}
+	var quote price.Quote
+	if provider := newPriceProvider(config); provider != nil {
+		var err error
+		if quote, err = provider.Quote(); err != nil {
+			log.Printf("Error fetching price, sending samples without fiat values: %v", err)
+		}
+	}
+
+	perUnit := config.Denomination.BaseUnitsPerUnit
+	now := time.Now()
+	change := notify.Change{
+		Address:    address,
+		Label:      label,
+		OldBalance: 10 * perUnit,
+		NewBalance: 15 * perUnit,
+		Time:       now,
+		Quote:      quote,
+	}
+	balances := []notify.Balance{{
+		Address:        address,
+		Label:          label,
+		Group:          config.Groups[address],
+		CurrentBalance: change.NewBalance,
+		LastUpdated:    now,
+		Changes:        []notify.PeriodChange{{Period: "24h", Delta: change.Delta()}},
+		Quote:          quote,
+	}}
+	return change, balances
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ownershipChallengeTTL is how long a challenge can be signed
+const ownershipChallengeTTL = time.Hour
+
+// ownershipSignature is the charset of signatures: base58, base64 and hex,
+// not starting with - so the command can't take one for a flag
+var ownershipSignature = regexp.MustCompile(`^[A-Za-z0-9+/=_.:][A-Za-z0-9+/=_.:-]*$`)
+
+// maxSignatureLength bounds the signatures passed to the command
+const maxSignatureLength = 1024
+
+// ownershipAddress is the charset of addresses challenged for: base58 and
+// chain-prefixed entries such as "eth:0x...", starting with a letter or
+// digit so the command can't take one for a flag
+var ownershipAddress = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.:-]*$`)
+
+// maxAddressLength bounds the addresses passed to the command
+const maxAddressLength = 256
+
+// errInvalidAddress is returned when a challenge is requested for something
+// that can't be an address
+var errInvalidAddress = errors.New("addresses must be letters, digits, _, ., : and -, starting with a letter or digit")
+
+// errNoChallenge is returned when a signature is sent without a pending
+// challenge
+var errNoChallenge = errors.New("no pending challenge; request one first")
+
+// ownershipVerifier proves that whoever asks to follow an address holds its
+// key: it issues a one-time challenge message for them to sign with their
+// wallet, and checks the signature by running OWNERSHIP_VERIFY_COMMAND,
+// with {address}, {message} and {signature} in its arguments replaced and
+// the signature on its standard input. The command must exit with status 0
+// only for a valid signature.
+type ownershipVerifier struct {
+	command string
+
+	mu         sync.Mutex
+	challenges map[string]ownershipChallenge // By requester, e.g. "telegram:42"
+}
+
+// ownershipChallenge is a message a requester must sign to prove they own
+// an address
+type ownershipChallenge struct {
+	address string
+	message string
+	expires time.Time
+}
+
+// newOwnershipVerifier returns a verifier running the configured command,
+// or nil if ownership isn't verified
+func newOwnershipVerifier(config Config) *ownershipVerifier {
+	if config.OwnershipVerifyCommand == "" {
+		return nil
+	}
+	return &ownershipVerifier{command: config.OwnershipVerifyCommand, challenges: map[string]ownershipChallenge{}}
+}
+
+// challenge returns a new message for a requester to sign with the key of
+// address, replacing any challenge they had pending. Addresses that could
+// be taken for a flag of the command are refused with errInvalidAddress.
+func (v *ownershipVerifier) challenge(requester, address string) (string, error) {
+	if len(address) > maxAddressLength || !ownershipAddress.MatchString(address) {
+		return "", errInvalidAddress
+	}
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("generating challenge nonce: %w", err)
+	}
+	now := time.Now()
+	message := fmt.Sprintf("nockchain-balance-alerter ownership %s %s %d", address, hex.EncodeToString(nonce), now.Unix())
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for key, c := range v.challenges {
+		if now.After(c.expires) {
+			delete(v.challenges, key)
+		}
+	}
+	v.challenges[requester] = ownershipChallenge{address: address, message: message, expires: now.Add(ownershipChallengeTTL)}
+	return message, nil
+}
+
+// verify checks a requester's signature of their pending challenge, and
+// returns the address it proves ownership of. The challenge can only be
+// used once.
+func (v *ownershipVerifier) verify(requester, signature string) (string, error) {
+	if len(signature) > maxSignatureLength || !ownershipSignature.MatchString(signature) {
+		return "", errors.New("signatures must be base58, base64 or hex")
+	}
+	v.mu.Lock()
+	c, ok := v.challenges[requester]
+	if ok && time.Now().Before(c.expires) {
+		delete(v.challenges, requester)
+	}
+	v.mu.Unlock()
+	if !ok || time.Now().After(c.expires) {
+		return "", errNoChallenge
+	}
+
+	args := strings.Fields(v.command)
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "{address}", c.address)
+		arg = strings.ReplaceAll(arg, "{message}", c.message)
+		args[i] = strings.ReplaceAll(arg, "{signature}", signature)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(signature + "\n")
+	output, err := cmd.CombinedOutput()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		log.Printf("Ownership of %s not verified for %s: %s", c.address, requester, strings.TrimSpace(string(output)))
+		return "", fmt.Errorf("the signature doesn't match %s", c.address)
+	case err != nil:
+		return "", fmt.Errorf("running %s: %w", args[0], err)
+	}
+	return c.address, nil
+}
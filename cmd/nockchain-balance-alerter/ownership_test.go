@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestOwnershipChallenge(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		wantErr error
+	}{
+		{"base58", "3L1PzYk9AUMw", nil},
+		{"chain prefix", "eth:0xAbC123", nil},
+		{"flag", "--output=/etc/passwd", errInvalidAddress},
+		{"short flag", "-x", errInvalidAddress},
+		{"space", "3L1P AUMw", errInvalidAddress},
+		{"empty", "", errInvalidAddress},
+		{"too long", strings.Repeat("a", maxAddressLength+1), errInvalidAddress},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newOwnershipVerifier(Config{OwnershipVerifyCommand: "true"})
+			message, err := v.challenge("api:test", tt.address)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("challenge error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if _, pending := v.challenges["api:test"]; pending {
+					t.Error("refused address left a pending challenge")
+				}
+				return
+			}
+			if !strings.Contains(message, tt.address) {
+				t.Errorf("challenge %q doesn't name %s", message, tt.address)
+			}
+		})
+	}
+}
+
+func TestOwnershipChallengesDiffer(t *testing.T) {
+	v := newOwnershipVerifier(Config{OwnershipVerifyCommand: "true"})
+	first, err := v.challenge("api:test", "3L1PzYk9AUMw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := v.challenge("api:test", "3L1PzYk9AUMw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == second {
+		t.Errorf("two challenges in the same second are both %q", first)
+	}
+}
+
+func TestOwnershipVerify(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		challenge bool
+		signature string
+		wantErr   bool
+	}{
+		{"valid", "true {address} {message} {signature}", true, "c2lnbmF0dXJl", false},
+		{"rejected", "false {address} {message} {signature}", true, "c2lnbmF0dXJl", true},
+		{"no challenge", "true", false, "c2lnbmF0dXJl", true},
+		{"flag signature", "true", true, "-rf", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newOwnershipVerifier(Config{OwnershipVerifyCommand: tt.command})
+			if tt.challenge {
+				if _, err := v.challenge("api:test", "3L1PzYk9AUMw"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			address, err := v.verify("api:test", tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && address != "3L1PzYk9AUMw" {
+				t.Errorf("verify = %q, want 3L1PzYk9AUMw", address)
+			}
+		})
+	}
+}
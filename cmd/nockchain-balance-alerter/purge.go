@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+// purgeActor names the purge subcommand in the audit log
+const purgeActor = "cli"
+
+// purgeTarget is a monitor whose stored data purge cleans up: the
+// operator's, or one tenant's
+type purgeTarget struct {
+	name   string // Tenant name, or "" for the operator
+	config Config
+	state  string // Path of its balances.json
+}
+
+// runPurge deletes everything stored about an address, for when its owner
+// asks for their data to be removed: its state in the operator's and every
+// tenant's balances.json, and the lines mentioning it in their audit logs
+// and RPC recordings. The alerter should be stopped first, or it writes
+// the address back from memory on its next save.
+func runPurge(args []string) {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	address := flags.String("address", "", "address whose data to delete (required)")
+	flags.Parse(args)
+	if *address == "" {
+		log.Fatal("purge needs --address")
+	}
+
+	// Nothing is sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	targets, err := purgeTargets(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Check every config first, so a refusal leaves nothing half purged
+	for _, target := range targets {
+		for _, configured := range target.config.Addresses {
+			if configured == *address {
+				log.Fatalf("%s is still in ADDRESSES of %s; remove it there first", *address, target)
+			}
+		}
+	}
+
+	// Instances of a cluster share their logs
+	purged := map[string]bool{}
+	for _, target := range targets {
+		m := newMonitor(target.config, nil, monitor.FileStore{Path: target.state})
+		if err := m.Load(); err != nil {
+			log.Fatalf("Error loading %s: %v", target.state, err)
+		}
+		result, err := m.Purge(*address)
+		if err != nil {
+			log.Fatalf("Error purging %s: %v", target.state, err)
+		}
+		if result.Found {
+			fmt.Printf("%s: removed state, %d queued alerts and %d delivery failures\n", target.state, result.Queued, result.Failures)
+		}
+		if purged[target.config.AuditLogFile] {
+			continue
+		}
+		for _, path := range []string{target.config.AuditLogFile, target.config.RPCRecordFile} {
+			purged[path] = true
+			removed, err := purgeLines(path, *address)
+			if err != nil {
+				log.Fatalf("Error purging %s: %v", path, err)
+			}
+			if removed > 0 {
+				fmt.Printf("%s: removed %d lines\n", path, removed)
+			}
+		}
+		// The address itself stays out of the log that was just cleaned
+		auditLog(target.config.AuditLogFile, purgeActor, RoleAdmin, "purge", "", true)
+	}
+	fmt.Printf("Purged %s\n", *address)
+}
+
+// purgeTargets lists the operator's monitor, or with CLUSTER_DIR every
+// instance's, and with TENANTS_DIR every tenant's
+func purgeTargets(config Config) ([]purgeTarget, error) {
+	targets := []purgeTarget{{config: config, state: balanceFile}}
+	if config.ClusterDir != "" {
+		entries, err := os.ReadDir(config.ClusterDir)
+		if err != nil {
+			return nil, err
+		}
+		targets = nil
+		for _, entry := range entries {
+			if entry.IsDir() {
+				targets = append(targets, purgeTarget{config: config, state: filepath.Join(config.ClusterDir, entry.Name(), balanceFile)})
+			}
+		}
+	}
+	if config.TenantsDir == "" {
+		return targets, nil
+	}
+	entries, err := os.ReadDir(config.TenantsDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		dir := filepath.Join(config.TenantsDir, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, envFile)); err != nil {
+			continue
+		}
+		tenant, err := loadTenantConfig(dir, config)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", entry.Name(), err)
+		}
+		tenant.Tenant = entry.Name()
+		targets = append(targets, purgeTarget{name: entry.Name(), config: tenant, state: filepath.Join(dir, balanceFile)})
+	}
+	return targets, nil
+}
+
+// String names the target in messages
+func (t purgeTarget) String() string {
+	if t.name == "" {
+		return "the operator config"
+	}
+	return "tenant " + t.name
+}
+
+// purgeLines rewrites a line-per-entry log without the lines that mention
+// address, returning how many it dropped. A missing file has none.
+func purgeLines(path, address string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var kept bytes.Buffer
+	removed := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if bytes.Contains(scanner.Bytes(), []byte(address)) {
+			removed++
+			continue
+		}
+		kept.Write(scanner.Bytes())
+		kept.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	// Replace the file in one step, so a crash can't leave it truncated
+	tmp := path + ".purge"
+	if err := os.WriteFile(tmp, kept.Bytes(), info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	return removed, os.Rename(tmp, path)
+}
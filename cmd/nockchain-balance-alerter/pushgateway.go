@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+// defaultPushgatewayJob is the job label metrics are pushed under when
+// PUSHGATEWAY_JOB isn't set
+const defaultPushgatewayJob = "nockchain_balance_alerter"
+
+// pushgatewayTimeout bounds a push, so an unreachable gateway can't hold up
+// the next check
+const pushgatewayTimeout = 10 * time.Second
+
+// pushMetrics replaces the metrics grouped under the configured job and
+// instance on the Pushgateway with the monitor's current ones
+func pushMetrics(config Config, m *monitor.Monitor) error {
+	target := strings.TrimSuffix(config.PushgatewayURL, "/") + "/metrics/job/" + url.PathEscape(config.PushgatewayJob)
+	if config.PushgatewayInstance != "" {
+		target += "/instance/" + url.PathEscape(config.PushgatewayInstance)
+	}
+	req, err := http.NewRequest(http.MethodPut, target, bytes.NewReader(m.Metrics()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if config.PushgatewayToken != "" {
+		req.Header.Set("Authorization", "Bearer "+config.PushgatewayToken)
+	}
+	client := http.Client{Timeout: pushgatewayTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway: %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
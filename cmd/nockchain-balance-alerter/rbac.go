@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Role is the access level granted to an API token or chat user
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleRead
+	RoleAdmin
+)
+
+// String returns the name used for the role in configuration and audit entries
+func (r Role) String() string {
+	switch r {
+	case RoleRead:
+		return "read"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// APIToken is a named bearer token and the role it grants
+type APIToken struct {
+	Name  string `json:"name"`
+	Token string `json:"token"`
+	Role  Role   `json:"role"`
+}
+
+// AuditEntry records who attempted which action and whether it was allowed
+type AuditEntry struct {
+	Time    string `json:"time"`
+	Actor   string `json:"actor"`
+	Role    string `json:"role"`
+	Action  string `json:"action"`
+	Target  string `json:"target,omitempty"`
+	Allowed bool   `json:"allowed"`
+}
+
+// auditMu serializes writes to the audit log
+var auditMu sync.Mutex
+
+// parseRole parses a role name from configuration
+func parseRole(name string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "read":
+		return RoleRead, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, fmt.Errorf("unknown role %q, expected read or admin", name)
+	}
+}
+
+// parseAPITokens parses API_TOKENS entries of the form name:token:role
+func parseAPITokens(value string) ([]APIToken, error) {
+	var tokens []APIToken
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid API_TOKENS entry %q, expected name:token:role", entry)
+		}
+		role, err := parseRole(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("API_TOKENS entry %q: %w", parts[0], err)
+		}
+		tokens = append(tokens, APIToken{Name: parts[0], Token: parts[1], Role: role})
+	}
+	return tokens, nil
+}
+
+// authenticateToken returns the name and role of the API token matching
+// provided, or RoleNone if there is no match
+func authenticateToken(tokens []APIToken, provided string) (string, Role) {
+	for _, token := range tokens {
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token.Token)) == 1 {
+			return token.Name, token.Role
+		}
+	}
+	return "", RoleNone
+}
+
+// auditLog appends an entry to the audit log file
+func auditLog(path, actor string, role Role, action, target string, allowed bool) {
+	if path == "" {
+		return // Skip if auditing is disabled
+	}
+	entry := AuditEntry{
+		Time:    time.Now().Format(time.RFC3339),
+		Actor:   actor,
+		Role:    role.String(),
+		Action:  action,
+		Target:  target,
+		Allowed: allowed,
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding audit entry: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		log.Printf("Error opening audit log: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("Error writing audit log: %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/go-co-op/gocron"
+)
+
+// catchUpSchedules sends the summary and earnings report right away if the
+// alerter was down when one was due, so a restart never silently skips a
+// day. Nothing is sent if the state has never recorded one being sent.
+func catchUpSchedules(config Config, m *monitor.Monitor, location *time.Location) {
+	now := time.Now().In(location)
+	checked := false
+	checkOnce := func() {
+		if !checked {
+			m.CheckAll()
+			checked = true
+		}
+	}
+
+	for _, j := range summaryJobs(config) {
+		if last, due := j.last(m), previousRun(now, j.schedule.Times, j.schedule.Days); !last.IsZero() && last.Before(due) {
+			log.Printf("%s due at %s was missed, sending it now", j.name(), due.Format("2006-01-02 15:04 MST"))
+			checkOnce()
+			j.send(m)
+		}
+	}
+
+	if config.ReportSchedule == "" {
+		return
+	}
+	name, period, days := "Daily", 24*time.Hour, []time.Weekday(nil)
+	if config.ReportSchedule == reportWeekly {
+		name, period, days = "Weekly", 7*24*time.Hour, []time.Weekday{time.Monday}
+	}
+	if last, due := m.LastReport(), previousRun(now, []string{config.ReportTime}, days); !last.IsZero() && last.Before(due) {
+		log.Printf("%s report due at %s was missed, sending it now", name, due.Format("2006-01-02 15:04 MST"))
+		checkOnce()
+		m.SendReport(name, period)
+	}
+}
+
+// previousRun returns the latest of the HH:MM times of day at or before now,
+// in now's location, only counting the given weekdays if there are any
+func previousRun(now time.Time, times []string, days []time.Weekday) time.Time {
+	var latest time.Time
+	for back := 0; back <= 7; back++ {
+		day := now.AddDate(0, 0, -back)
+		if len(days) > 0 && !containsWeekday(days, day.Weekday()) {
+			continue
+		}
+		for _, clock := range times {
+			t, err := time.ParseInLocation("15:04", clock, now.Location())
+			if err != nil {
+				continue
+			}
+			run := time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+			if !run.After(now) && run.After(latest) {
+				latest = run
+			}
+		}
+		if !latest.IsZero() {
+			return latest
+		}
+	}
+	return latest
+}
+
+// containsWeekday reports whether days includes day
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// summaryNotifiers are the notifiers that can have their own summary
+// schedule, by name
+var summaryNotifiers = []string{"Slack", "Telegram", "Discord", "Webhook", "EventBridge", "Desktop", "Syslog", "Journal"}
+
+// summaryJob is a summary schedule and the notifiers following it
+type summaryJob struct {
+	names    []string // nil means every notifier
+	schedule SummarySchedule
+}
+
+// summaryJobs groups the notifiers by summary schedule; notifiers without a
+// schedule of their own follow SUMMARY_TIMES, and those whose summaries are
+// off get none
+func summaryJobs(config Config) []summaryJob {
+	all := summaryJob{schedule: SummarySchedule{Times: config.SummaryTimes}}
+	if len(config.SummarySchedules) == 0 {
+		return []summaryJob{all}
+	}
+	var jobs []summaryJob
+	for _, name := range summaryNotifiers {
+		schedule, ok := config.SummarySchedules[name]
+		switch {
+		case !ok:
+			all.names = append(all.names, name)
+		case len(schedule.Times) > 0:
+			jobs = append(jobs, summaryJob{names: []string{name}, schedule: schedule})
+		}
+	}
+	if len(all.names) > 0 {
+		jobs = append([]summaryJob{all}, jobs...)
+	}
+	return jobs
+}
+
+// name describes the job in logs, e.g. "Slack summary"
+func (j summaryJob) name() string {
+	if j.names == nil {
+		return "summary"
+	}
+	return strings.Join(j.names, "/") + " summary"
+}
+
+// send sends the summary to the job's notifiers
+func (j summaryJob) send(m *monitor.Monitor) {
+	if j.names == nil {
+		m.SendSummary()
+		return
+	}
+	m.SendSummaryTo(j.names...)
+}
+
+// last returns when the job's notifiers last got a summary
+func (j summaryJob) last(m *monitor.Monitor) time.Time {
+	if j.names == nil {
+		return m.LastSummary()
+	}
+	return m.LastSummaryTo(j.names...)
+}
+
+// scheduleSummaries schedules every summary job, daily or on the chosen
+// weekdays, sent only while leader reports true
+func scheduleSummaries(scheduler *gocron.Scheduler, config Config, m *monitor.Monitor, leader func() bool) error {
+	for _, j := range summaryJobs(config) {
+		j := j
+		s := scheduler.Every(1)
+		if len(j.schedule.Days) == 0 {
+			s = s.Day()
+		}
+		for _, day := range j.schedule.Days {
+			s = s.Weekday(day)
+		}
+		if _, err := s.At(strings.Join(j.schedule.Times, ";")).Do(job(j.name(), func() {
+			if leader() {
+				j.send(m)
+			}
+		})); err != nil {
+			return fmt.Errorf("%s: %w", j.name(), err)
+		}
+	}
+	return nil
+}
+
+// job wraps a scheduled function so a panic is logged instead of killing
+// the process, and a run is skipped while the previous one is still going,
+// so slow cycles never stack up
+func job(name string, fn func()) func() {
+	var running atomic.Bool
+	return func() {
+		if !running.CompareAndSwap(false, true) {
+			log.Printf("Skipping %s: the previous run is still going", name)
+			return
+		}
+		defer running.Store(false)
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Recovered from panic in %s: %v\n%s", name, r, debug.Stack())
+			}
+		}()
+		fn()
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// serviceName is the name the alerter is registered under with the
+// service manager
+const serviceName = "nockchain-balance-alerter"
+
+// serviceDescription describes the service in the service manager
+const serviceDescription = "Monitors Nockchain address balances and sends alerts"
+
+// serviceLogFile is where the service's log goes, in its working directory,
+// on platforms without a system log the service manager writes to
+const serviceLogFile = "alerter.log"
+
+// runService installs, removes, starts or stops the alerter as a background
+// service that the OS restarts if it exits: a Windows service, a launchd
+// agent on macOS, or a systemd unit on Linux. The service runs in the
+// directory it was installed from, so it uses that .env and balances.json.
+func runService(args []string) {
+	usage := "usage: nockchain-balance-alerter service install|uninstall|start|stop"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+	var err error
+	switch args[0] {
+	case "install":
+		var exe, dir string
+		if exe, dir, err = serviceTarget(); err == nil {
+			if err = installService(exe, dir); err == nil {
+				fmt.Printf("Installed service %s running %s in %s; start it with: nockchain-balance-alerter service start\n", serviceName, exe, dir)
+			}
+		}
+	case "uninstall":
+		if err = uninstallService(); err == nil {
+			fmt.Printf("Uninstalled service %s\n", serviceName)
+		}
+	case "start":
+		if err = startService(); err == nil {
+			fmt.Printf("Started service %s\n", serviceName)
+		}
+	case "stop":
+		if err = stopService(); err == nil {
+			fmt.Printf("Stopped service %s\n", serviceName)
+		}
+	case "run":
+		// Started by the service manager, see installService
+		flags := flag.NewFlagSet("service run", flag.ExitOnError)
+		dir := flags.String("dir", "", "working directory holding .env and balances.json")
+		flags.Parse(args[1:])
+		if *dir != "" {
+			if err := os.Chdir(*dir); err != nil {
+				log.Fatalf("Error changing to %s: %v", *dir, err)
+			}
+		}
+		runServiceMain()
+		return
+	default:
+		log.Fatal(usage)
+	}
+	if err != nil {
+		log.Fatalf("Error: service %s: %v", args[0], err)
+	}
+}
+
+// serviceTarget returns the absolute path of this executable and the
+// current directory, which the installed service runs in
+func serviceTarget() (exe, dir string, err error) {
+	if exe, err = os.Executable(); err != nil {
+		return "", "", err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return "", "", err
+	}
+	if dir, err = os.Getwd(); err != nil {
+		return "", "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, envFile)); err != nil {
+		return "", "", fmt.Errorf("no %s in %s; run install from the directory holding your config, or create one with: nockchain-balance-alerter init", envFile, dir)
+	}
+	return exe, dir, nil
+}
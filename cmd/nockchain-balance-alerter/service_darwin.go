@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// launchdLabel is the launchd job label
+const launchdLabel = "com.github.anilcse.nockchain-balance-alerter"
+
+// launchdPlist is the job definition: run at load and whenever it exits,
+// in the install directory, logging to serviceLogFile there
+const launchdPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>%s</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<true/>
+	<key>ThrottleInterval</key>
+	<integer>10</integer>
+	<key>StandardOutPath</key>
+	<string>%s</string>
+	<key>StandardErrorPath</key>
+	<string>%s</string>
+</dict>
+</plist>
+`
+
+// launchdPath returns where the job definition goes: a system daemon when
+// installed by root, otherwise an agent of the current user
+func launchdPath() (string, error) {
+	if os.Geteuid() == 0 {
+		return filepath.Join("/Library/LaunchDaemons", launchdLabel+".plist"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library/LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// installService writes the launchd job definition
+func installService(exe, dir string) error {
+	path, err := launchdPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists; uninstall it first", path)
+	}
+	logPath := filepath.Join(dir, serviceLogFile)
+	plist := fmt.Sprintf(launchdPlist, plistString(launchdLabel), plistString(exe), plistString(dir), plistString(logPath), plistString(logPath))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(plist), 0o644)
+}
+
+// uninstallService unloads and removes the launchd job
+func uninstallService() error {
+	path, err := launchdPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s is not installed: %w", serviceName, err)
+	}
+	launchctl("unload", path)
+	return os.Remove(path)
+}
+
+// startService loads the launchd job, which starts it and keeps it loaded
+// across logins and reboots
+func startService() error {
+	path, err := launchdPath()
+	if err != nil {
+		return err
+	}
+	return launchctl("load", "-w", path)
+}
+
+// stopService unloads the launchd job, which stops it until started again
+func stopService() error {
+	path, err := launchdPath()
+	if err != nil {
+		return err
+	}
+	return launchctl("unload", "-w", path)
+}
+
+// runServiceMain runs the monitor; launchd starts it directly
+func runServiceMain() {
+	runMonitor()
+}
+
+// launchctl runs launchctl, which reports some failures only on its output
+func launchctl(args ...string) error {
+	output, err := exec.Command("launchctl", args...).CombinedOutput()
+	if message := strings.TrimSpace(string(output)); message != "" && (err != nil || strings.Contains(message, "error")) {
+		return fmt.Errorf("launchctl %s: %s", args[0], message)
+	}
+	return err
+}
+
+// plistString escapes a value for a plist <string>
+func plistString(value string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// systemdUnit is the unit file: restart whenever it exits, in the install
+// directory, logging to the journal
+const systemdUnit = `[Unit]
+Description=%s
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+ExecStart=%s
+WorkingDirectory=%s
+Restart=always
+RestartSec=10
+
+[Install]
+WantedBy=%s
+`
+
+// systemdUser reports whether the unit is a user unit, for installs by
+// anyone but root
+func systemdUser() bool {
+	return os.Geteuid() != 0
+}
+
+// systemdPath returns where the unit file goes
+func systemdPath() (string, error) {
+	if !systemdUser() {
+		return filepath.Join("/etc/systemd/system", serviceName+".service"), nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "systemd/user", serviceName+".service"), nil
+}
+
+// installService writes and enables a systemd unit
+func installService(exe, dir string) error {
+	path, err := systemdPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists; uninstall it first", path)
+	}
+	target := "multi-user.target"
+	if systemdUser() {
+		target = "default.target"
+	}
+	// % starts a systemd specifier
+	escape := strings.NewReplacer("%", "%%").Replace
+	unit := fmt.Sprintf(systemdUnit, serviceDescription, escape(strconv.Quote(exe)), escape(dir), target)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(unit), 0o644); err != nil {
+		return err
+	}
+	if err := systemctl("daemon-reload"); err != nil {
+		os.Remove(path)
+		return err
+	}
+	if err := systemctl("enable", serviceName); err != nil {
+		os.Remove(path)
+		systemctl("daemon-reload")
+		return err
+	}
+	return nil
+}
+
+// uninstallService stops, disables and removes the systemd unit
+func uninstallService() error {
+	path, err := systemdPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%s is not installed: %w", serviceName, err)
+	}
+	systemctl("disable", "--now", serviceName)
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+// startService starts the systemd unit
+func startService() error {
+	return systemctl("start", serviceName)
+}
+
+// stopService stops the systemd unit
+func stopService() error {
+	return systemctl("stop", serviceName)
+}
+
+// runServiceMain runs the monitor; systemd starts it directly
+func runServiceMain() {
+	runMonitor()
+}
+
+// systemctl runs systemctl against the system or user manager
+func systemctl(args ...string) error {
+	if systemdUser() {
+		args = append([]string{"--user"}, args...)
+	}
+	output, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("systemctl %s: %w: %s", strings.Join(args, " "), err, message)
+		}
+		return fmt.Errorf("systemctl %s: %w", strings.Join(args, " "), err)
+	}
+	return nil
+}
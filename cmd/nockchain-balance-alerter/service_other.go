@@ -0,0 +1,25 @@
+//go:build !windows && !darwin && !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// errServiceUnsupported is returned by the service subcommands on platforms
+// without a supported service manager
+var errServiceUnsupported = errors.New("not supported on " + runtime.GOOS + "; run the alerter under your init system or a process supervisor")
+
+func installService(exe, dir string) error { return errServiceUnsupported }
+
+func uninstallService() error { return errServiceUnsupported }
+
+func startService() error { return errServiceUnsupported }
+
+func stopService() error { return errServiceUnsupported }
+
+// runServiceMain runs the monitor
+func runServiceMain() {
+	runMonitor()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// installService registers a Windows service that starts with the system
+// and is restarted by the service manager whenever it exits
+func installService(exe, dir string) error {
+	m, err := connectServiceManager()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("%s is already installed; uninstall it first", serviceName)
+	}
+	s, err := m.CreateService(serviceName, exe, mgr.Config{
+		DisplayName: "Nockchain Balance Alerter",
+		Description: serviceDescription,
+		StartType:   mgr.StartAutomatic,
+	}, "service", "run", "-dir", dir)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	restart := mgr.RecoveryAction{Type: mgr.ServiceRestart, Delay: 10 * time.Second}
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{restart, restart, restart}, uint32((24 * time.Hour).Seconds())); err != nil {
+		s.Delete()
+		return fmt.Errorf("setting restart on failure: %w", err)
+	}
+	return nil
+}
+
+// uninstallService stops and removes the Windows service
+func uninstallService() error {
+	m, err := connectServiceManager()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("%s is not installed: %w", serviceName, err)
+	}
+	defer s.Close()
+	stopWindowsService(s)
+	return s.Delete()
+}
+
+// startService starts the installed Windows service
+func startService() error {
+	m, err := connectServiceManager()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("%s is not installed: %w", serviceName, err)
+	}
+	defer s.Close()
+	return s.Start()
+}
+
+// stopService stops the Windows service and waits for it to exit
+func stopService() error {
+	m, err := connectServiceManager()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("%s is not installed: %w", serviceName, err)
+	}
+	defer s.Close()
+	return stopWindowsService(s)
+}
+
+// connectServiceManager connects to the service control manager, which
+// needs an elevated prompt
+func connectServiceManager() (*mgr.Mgr, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to the service manager (run as Administrator): %w", err)
+	}
+	return m, nil
+}
+
+// stopWindowsService asks a running service to stop and waits up to 30s
+// for it to do so
+func stopWindowsService(s *mgr.Service) error {
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("%s did not stop within 30s", serviceName)
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runServiceMain runs the monitor under the service control manager, with
+// the log in serviceLogFile since services have no console
+func runServiceMain() {
+	isService, err := svc.IsWindowsService()
+	if err != nil {
+		log.Fatalf("Error detecting the service manager: %v", err)
+	}
+	if !isService {
+		runMonitor()
+		return
+	}
+	file, err := os.OpenFile(serviceLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err == nil {
+		log.SetOutput(file)
+	}
+	if err := svc.Run(serviceName, windowsService{}); err != nil {
+		log.Fatalf("Error running service: %v", err)
+	}
+}
+
+// windowsService runs the monitor until the service manager stops it
+type windowsService struct{}
+
+// Execute implements svc.Handler
+func (windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+	go runMonitor()
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for request := range requests {
+		switch request.Cmd {
+		case svc.Interrogate:
+			changes <- request.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			log.Println("Service stopping")
+			changes <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
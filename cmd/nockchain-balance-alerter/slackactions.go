@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/slack-go/slack"
+)
+
+// slackActionsPath is the Slack app's interactivity request URL, where
+// button presses are sent
+const slackActionsPath = "/slack/actions"
+
+// slackActions answers presses of the buttons on Slack alerts. Slack signs
+// each request with the app's signing secret instead of a bearer token.
+type slackActions struct {
+	config Config
+	app    *notify.SlackApp // Supplies the bot token of installed workspaces, if set
+	m      *monitor.Monitor
+}
+
+// handle verifies a button press and acknowledges it at once, posting the
+// result afterwards since Slack waits only three seconds
+func (s *slackActions) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	verifier, err := slack.NewSecretsVerifier(r.Header, s.config.SlackSigningSecret)
+	if err != nil {
+		http.Error(w, "missing Slack signature", http.StatusUnauthorized)
+		return
+	}
+	body, err := io.ReadAll(io.TeeReader(http.MaxBytesReader(w, r.Body, 1<<20), &verifier))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := verifier.Ensure(); err != nil {
+		http.Error(w, "invalid Slack signature", http.StatusUnauthorized)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	callback, err := slack.InteractionCallbackParse(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	for _, action := range callback.ActionCallback.BlockActions {
+		if action.ActionID != notify.SlackActionDetails {
+			continue
+		}
+		address := action.Value
+		watched := s.m.IsWatched(address)
+		actor := "slack:" + callback.Team.ID + "/" + callback.User.Name
+		auditLog(s.config.AuditLogFile, actor, RoleRead, "button "+action.ActionID, address, watched)
+		if watched {
+			go s.postDetails(callback, address)
+		}
+	}
+}
+
+// postDetails uploads the raw transactions of an address into the thread
+// of the alert whose button was pressed
+func (s *slackActions) postDetails(callback slack.InteractionCallback, address string) {
+	token := s.config.SlackBotToken
+	if s.app != nil && callback.Team.ID != "" {
+		if installed, err := s.app.Token(callback.Team.ID); err == nil {
+			token = installed
+		}
+	}
+	data, err := rawTransactions(address, s.m)
+	if err != nil {
+		log.Printf("Error fetching raw transactions for %s: %v", address, err)
+		return
+	}
+	threadTS := callback.Container.MessageTs
+	if threadTS == "" {
+		threadTS = callback.Message.Timestamp
+	}
+	title := "Raw transactions of " + subscriptionName(address, s.m)
+	if err := notify.SlackReplyFile(token, callback.Channel.ID, threadTS, detailsFilename(address), title, data); err != nil {
+		log.Printf("Error posting Slack transaction details: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// slackOAuthPath is where Slack redirects the installer's browser
+const slackOAuthPath = "/slack/oauth"
+
+// slackStateTTL is how long an install link stays valid
+const slackStateTTL = 10 * time.Minute
+
+// newSlackApp builds the Slack OAuth app and loads its installations, or
+// returns nil when SLACK_CLIENT_ID isn't set
+func newSlackApp(config Config) *notify.SlackApp {
+	if config.SlackClientID == "" {
+		return nil
+	}
+	app := &notify.SlackApp{
+		ClientID:     config.SlackClientID,
+		ClientSecret: config.SlackClientSecret,
+		RedirectURL:  config.SlackRedirectURL,
+		Path:         slackAppFile,
+	}
+	if err := app.Load(); err != nil {
+		log.Fatalf("Error loading Slack installations: %v", err)
+	}
+	return app
+}
+
+// slackInstaller issues install links and completes the OAuth redirect,
+// adding a notifier for each newly installed workspace
+type slackInstaller struct {
+	config Config
+	app    *notify.SlackApp
+	m      *monitor.Monitor
+
+	mu     sync.Mutex
+	states map[string]time.Time // Expiry of each issued OAuth state
+}
+
+// handleInstall returns a one-time link that installs the app into a
+// workspace
+func (s *slackInstaller) handleInstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	state := hex.EncodeToString(random)
+
+	s.mu.Lock()
+	now := time.Now()
+	for issued, expiry := range s.states {
+		if now.After(expiry) {
+			delete(s.states, issued)
+		}
+	}
+	s.states[state] = now.Add(slackStateTTL)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{"url": s.app.AuthorizeURL(state)})
+}
+
+// handleOAuth completes an install. Slack redirects the installer's browser
+// here, so there is no bearer token; the state issued by handleInstall
+// proves an admin started the install.
+func (s *slackInstaller) handleOAuth(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	state := query.Get("state")
+	s.mu.Lock()
+	expiry, ok := s.states[state]
+	delete(s.states, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(expiry) {
+		http.Error(w, "This install link is invalid or has expired; request a new one from /api/slack/install.", http.StatusBadRequest)
+		return
+	}
+	if reason := query.Get("error"); reason != "" {
+		http.Error(w, "Slack install was cancelled: "+reason, http.StatusBadRequest)
+		return
+	}
+
+	installation, added, err := s.app.Install(query.Get("code"))
+	if err != nil {
+		log.Printf("Error installing Slack app: %v", err)
+		http.Error(w, "Slack install failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	auditLog(s.config.AuditLogFile, "slack:"+installation.TeamID, RoleAdmin, "install Slack app", installation.Channel, true)
+	if added {
+		slack := s.app.Notifier(installation.TeamID, mustLoadTemplates(s.config, "slack"), s.config.SlackUnits)
+		slack.Delivery = slackDelivery(s.config)
+		slack.Overflow = s.config.SlackOverflow
+		slack.Actions = s.config.SlackSigningSecret != ""
+		s.m.AddNotifier(slack)
+	}
+	log.Printf("Slack app installed into %s, posting to %s", installation.TeamName, installation.Channel)
+	fmt.Fprintf(w, "Installed into %s. Alerts will be posted to %s.\n", installation.TeamName, installation.Channel)
+}
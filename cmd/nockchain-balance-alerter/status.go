@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Formats printed by the status subcommand
+const (
+	statusFormatNagios = "nagios" // Nagios plugin output and exit code
+	statusFormatZabbix = "zabbix" // zabbix_sender input for trapper items
+)
+
+// Nagios plugin states, which are also the exit codes
+const (
+	stateOK       = 0
+	stateWarning  = 1
+	stateCritical = 2
+	stateUnknown  = 3
+)
+
+// stateNames are the Nagios names of the plugin states
+var stateNames = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// addressCheck is the health of one address as reported by status
+type addressCheck struct {
+	balance notify.Balance
+	state   int
+	reason  string
+	age     time.Duration // Since the last successful check, -1 if never
+}
+
+// runStatus reports the health of every watched address from
+// balances.json, as a Nagios plugin or as zabbix_sender input, for NOC
+// tooling that predates chat alerts
+func runStatus(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	format := flags.String("format", statusFormatNagios, "output format: nagios or zabbix")
+	tag := flags.String("tag", "", "only report addresses with any of these comma-separated tags")
+	warning := flags.Int64("warning", 0, "warn when a balance is below this many nick")
+	critical := flags.Int64("critical", 0, "critical when a balance is below this many nick")
+	host := flags.String("host", "-", "zabbix host name; - uses the zabbix_sender -s or agent config host")
+	flags.Parse(args)
+
+	// Nothing is sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		fmt.Printf("BALANCE UNKNOWN - loading config: %v\n", err)
+		os.Exit(stateUnknown)
+	}
+
+	m := newMonitor(config, nil, monitor.FileStore{Path: balanceFile})
+	if err := m.Load(); err != nil {
+		fmt.Printf("BALANCE UNKNOWN - loading %s: %v\n", balanceFile, err)
+		os.Exit(stateUnknown)
+	}
+	m.SummaryTags = parseTags(*tag)
+	checks := addressChecks(m, *warning, *critical, time.Now())
+
+	switch *format {
+	case statusFormatNagios:
+		os.Exit(printNagios(os.Stdout, checks, *warning, *critical, m.Format))
+	case statusFormatZabbix:
+		printZabbix(os.Stdout, checks, *host)
+	default:
+		log.Fatalf("--format must be %s or %s, got %q", statusFormatNagios, statusFormatZabbix, *format)
+	}
+}
+
+// addressChecks rates each address: critical when it is stale or below
+// critical, warning when its last check failed or it is below warning
+func addressChecks(m *monitor.Monitor, warning, critical int64, now time.Time) []addressCheck {
+	statuses := m.AddressStatuses()
+	var checks []addressCheck
+	for _, balance := range m.Summary() {
+		check := addressCheck{balance: balance, age: -1}
+		if !balance.LastSuccess.IsZero() {
+			check.age = now.Sub(balance.LastSuccess)
+		}
+		status := statuses[balance.Address]
+		switch {
+		case balance.Stale:
+			check.state, check.reason = stateCritical, "stale, last success "+check.age.Round(time.Second).String()+" ago"
+		case critical > 0 && balance.CurrentBalance < critical:
+			check.state, check.reason = stateCritical, "below "+m.Format.Balance(critical)
+		case status.LastError != "":
+			check.state, check.reason = stateWarning, "last check failed: "+strings.ReplaceAll(status.LastError, "|", "/") // | starts Nagios perfdata
+		case warning > 0 && balance.CurrentBalance < warning:
+			check.state, check.reason = stateWarning, "below "+m.Format.Balance(warning)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// printNagios prints the worst state with a one-line summary and perfdata,
+// then a line per address, and returns the exit code
+func printNagios(w io.Writer, checks []addressCheck, warning, critical int64, format notify.Formatter) int {
+	if len(checks) == 0 {
+		fmt.Fprintln(w, "BALANCE UNKNOWN - no balances recorded yet")
+		return stateUnknown
+	}
+	state := stateOK
+	var problems []string
+	var total int64
+	for _, check := range checks {
+		total += check.balance.CurrentBalance
+		if check.state > state {
+			state = check.state
+		}
+		if check.state != stateOK {
+			problems = append(problems, fmt.Sprintf("%s %s", addressName(check.balance), check.reason))
+		}
+	}
+	summary := fmt.Sprintf("%d addresses, total %s", len(checks), format.Balance(total))
+	if len(problems) > 0 {
+		summary = strings.Join(problems, "; ")
+	}
+
+	thresholds := ";"
+	if warning > 0 || critical > 0 {
+		thresholds = fmt.Sprintf("%s;%s", nagiosThreshold(warning), nagiosThreshold(critical))
+	}
+	perfdata := []string{fmt.Sprintf("total=%d", total)}
+	for _, check := range checks {
+		perfdata = append(perfdata, fmt.Sprintf("'%s'=%d;%s;0", perfdataLabel(addressName(check.balance)), check.balance.CurrentBalance, thresholds))
+	}
+
+	fmt.Fprintf(w, "BALANCE %s - %s | %s\n", stateNames[state], summary, strings.Join(perfdata, " "))
+	for _, check := range checks {
+		line := fmt.Sprintf("[%s] %s: %s", stateNames[check.state], addressName(check.balance), format.Balance(check.balance.CurrentBalance))
+		if check.reason != "" {
+			line += " (" + check.reason + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	return state
+}
+
+// nagiosThreshold formats a minimum balance as a Nagios range that alerts
+// below it, or nothing when unset
+func nagiosThreshold(min int64) string {
+	if min <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:", min)
+}
+
+// perfdataLabel strips the characters Nagios perfdata labels can't contain
+func perfdataLabel(name string) string {
+	return strings.NewReplacer("'", "", "=", "").Replace(name)
+}
+
+// addressName returns an address's label, or the address itself
+func addressName(balance notify.Balance) string {
+	if balance.Label != "" {
+		return balance.Label
+	}
+	return balance.Address
+}
+
+// printZabbix prints zabbix_sender input lines, "<host> <key> <value>", for
+// trapper items keyed by address
+func printZabbix(w io.Writer, checks []addressCheck, host string) {
+	for _, check := range checks {
+		address := check.balance.Address
+		fmt.Fprintf(w, "%s nockchain.balance[%s] %d\n", host, address, check.balance.CurrentBalance)
+		fmt.Fprintf(w, "%s nockchain.status[%s] %d\n", host, address, check.state)
+		age := int64(-1)
+		if check.age >= 0 {
+			age = int64(check.age.Seconds())
+		}
+		fmt.Fprintf(w, "%s nockchain.check.age[%s] %d\n", host, address, age)
+		fmt.Fprintf(w, "%s nockchain.problem[%s] %q\n", host, address, check.reason)
+	}
+}
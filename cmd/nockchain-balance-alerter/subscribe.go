@@ -0,0 +1,486 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// telegramSubscribers sends change alerts to the chats that subscribed
+// themselves to the changed address, following each chat's preferences. It
+// keeps its own copy of the subscriptions, since notifiers are called while
+// the monitor is locked.
+type telegramSubscribers struct {
+	telegram *notify.Telegram // Formats and sends alerts; ChatID is set per subscriber
+	config   Config
+	verifier *ownershipVerifier // Set when subscribers must prove they own the address
+
+	mu        sync.Mutex
+	chats     map[string][]int64 // Approved subscribers by address
+	prefs     map[int64]monitor.SubscriberPrefs
+	templates map[string]notify.Templates // By language chosen with /language
+}
+
+// newTelegramSubscribers builds the subscriber notifier from the Telegram
+// settings, without the operator's chat, topics or buttons
+func newTelegramSubscribers(config Config, m *monitor.Monitor) *telegramSubscribers {
+	s := &telegramSubscribers{
+		telegram: &notify.Telegram{
+			BotToken:  config.TelegramBotToken,
+			Templates: mustLoadTemplates(config, "telegram"),
+			Units:     config.TelegramUnits,
+			Delivery:  notify.Delivery{SilentUpTo: config.SilentSeverity},
+			Overflow:  config.TelegramOverflow,
+		},
+		config:    config,
+		verifier:  newOwnershipVerifier(config),
+		templates: map[string]notify.Templates{},
+	}
+	s.update(m)
+	return s
+}
+
+// update refreshes the copy of the subscriptions and preferences, loading
+// the templates of any newly chosen language
+func (s *telegramSubscribers) update(m *monitor.Monitor) {
+	chats := map[string][]int64{}
+	for _, subscription := range m.Subscriptions() {
+		if subscription.Approved {
+			chats[subscription.Address] = append(chats[subscription.Address], subscription.ChatID)
+		}
+	}
+	prefs := m.SubscriberPrefs()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats, s.prefs = chats, prefs
+	for _, p := range prefs {
+		if _, loaded := s.templates[p.Language]; p.Language == "" || loaded {
+			continue
+		}
+		templates, err := subscriberTemplates(s.config, p.Language)
+		if err != nil {
+			log.Printf("Error loading %s templates for subscribers: %v", p.Language, err)
+			continue
+		}
+		s.templates[p.Language] = templates
+	}
+}
+
+// subscriberTemplates loads the Telegram templates in a subscriber's
+// language
+func subscriberTemplates(config Config, language string) (notify.Templates, error) {
+	translations, err := notify.LoadTranslations(config.TranslationsDir, language)
+	if err != nil {
+		return notify.Templates{}, err
+	}
+	return notify.LoadTemplates(config.TemplateDir, "telegram", config.ExplorerURL, translations, config.formatter(), config.Branding)
+}
+
+// Name implements notify.Notifier
+func (s *telegramSubscribers) Name() string { return "Telegram subscribers" }
+
+// NotifyChange implements notify.Notifier, sending the alert to every
+// subscriber of the address whose minimum change it reaches, silently in
+// their quiet hours
+func (s *telegramSubscribers) NotifyChange(change notify.Change) error {
+	s.mu.Lock()
+	var recipients []notify.Telegram
+	for _, chat := range s.chats[change.Address] {
+		prefs := s.prefs[chat]
+		if delta := change.Delta(); prefs.MinDelta > 0 && !change.Initial && max(delta, -delta) < prefs.MinDelta {
+			continue
+		}
+		telegram := *s.telegram
+		telegram.ChatID = strconv.FormatInt(chat, 10)
+		if templates, ok := s.templates[prefs.Language]; ok {
+			telegram.Templates = templates
+		}
+		if inQuietHours(prefs, change.Time, s.config.Timezone) {
+			telegram.Delivery.SilentUpTo = notify.SeverityCritical
+		}
+		recipients = append(recipients, telegram)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, telegram := range recipients {
+		if err := telegram.NotifyChange(change); err != nil {
+			errs = append(errs, fmt.Errorf("chat %s: %w", telegram.ChatID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// inQuietHours reports whether t falls in a subscriber's quiet hours, in
+// their time zone or else the alerter's
+func inQuietHours(prefs monitor.SubscriberPrefs, t time.Time, timezone string) bool {
+	if prefs.QuietStart == "" || prefs.QuietEnd == "" {
+		return false
+	}
+	if prefs.Timezone != "" {
+		timezone = prefs.Timezone
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	clock := t.In(location).Format("15:04")
+	if prefs.QuietStart <= prefs.QuietEnd {
+		return clock >= prefs.QuietStart && clock < prefs.QuietEnd
+	}
+	return clock >= prefs.QuietStart || clock < prefs.QuietEnd
+}
+
+// NotifySummary implements notify.Notifier; summaries cover every address,
+// so subscribers don't get them
+func (s *telegramSubscribers) NotifySummary([]notify.Balance) error { return nil }
+
+// NotifyAlert implements notify.Notifier; other alerts aren't about one
+// subscriber's address, so subscribers don't get them
+func (s *telegramSubscribers) NotifyAlert(notify.Alert) error { return nil }
+
+// handleTelegramMessage answers the subscription commands: /start with the
+// key of a deep link, /subscribe <address>, /unsubscribe [address] and
+// /subscriptions
+func handleTelegramMessage(bot *notify.Telegram, message notify.TelegramMessage, config Config, m *monitor.Monitor, subscribers *telegramSubscribers) {
+	command, argument, _ := strings.Cut(strings.TrimSpace(message.Text), " ")
+	command, _, _ = strings.Cut(command, "@") // Commands in groups may name the bot
+	argument = strings.TrimSpace(argument)
+	chat := message.Chat.ID
+
+	var reply string
+	switch command {
+	case "/start":
+		if argument == "" {
+			reply = telegramSubscribeHelp
+			break
+		}
+		address := telegramAddress(argument, m)
+		if address == "" {
+			reply = "This link is for an address that is no longer watched."
+			break
+		}
+		reply = subscribeChat(bot, message, address, false, config, m, subscribers.verifier)
+	case "/subscribe":
+		if argument == "" || strings.ContainsAny(argument, " \t") {
+			reply = "Usage: /subscribe <address>"
+			break
+		}
+		reply = subscribeChat(bot, message, argument, false, config, m, subscribers.verifier)
+	case "/verify":
+		if subscribers.verifier == nil || argument == "" {
+			reply = "Usage: /verify <signature>, after /subscribe"
+			break
+		}
+		address, err := subscribers.verifier.verify(fmt.Sprintf("telegram:%d", chat), argument)
+		if err != nil {
+			reply = "⚠️ Ownership not verified: " + err.Error() + ". Send /subscribe again for a new challenge."
+			break
+		}
+		reply = subscribeChat(bot, message, address, true, config, m, subscribers.verifier)
+	case "/unsubscribe":
+		if err := m.Unsubscribe(chat, argument); err != nil {
+			reply = "You aren't subscribed to that address."
+		} else if argument == "" {
+			reply = "🔕 Unsubscribed from every address."
+		} else {
+			reply = "🔕 Unsubscribed from " + subscriptionName(argument, m) + "."
+		}
+	case "/subscriptions":
+		var lines []string
+		for _, subscription := range m.Subscriptions() {
+			if subscription.ChatID != chat {
+				continue
+			}
+			line := "• " + subscriptionName(subscription.Address, m)
+			if !subscription.Approved {
+				line += " (waiting for approval)"
+			}
+			lines = append(lines, line)
+		}
+		reply = "You have no subscriptions."
+		if len(lines) > 0 {
+			reply = "Your subscriptions:\n" + strings.Join(lines, "\n")
+		}
+	case "/settings":
+		reply = describeSubscriberPrefs(m.SubscriberPrefs()[chat], config)
+	case "/mindelta", "/quiet", "/timezone", "/language":
+		prefs, err := changeSubscriberPrefs(m.SubscriberPrefs()[chat], command, argument, config)
+		switch {
+		case err != nil:
+			reply = err.Error()
+		case m.SetSubscriberPrefs(chat, prefs) != nil:
+			reply = "Subscribe to an address first, then change your settings."
+		default:
+			reply = describeSubscriberPrefs(prefs, config)
+		}
+	default:
+		// Not a subscription command, e.g. a message in a group
+		return
+	}
+	subscribers.update(m)
+
+	if err := bot.SendTo(strconv.FormatInt(chat, 10), notify.EscapeMarkdownV2(reply)); err != nil {
+		log.Printf("Error replying to Telegram command %s: %v", command, err)
+	}
+}
+
+// telegramSubscribeHelp answers /start without a deep link
+const telegramSubscribeHelp = `Send /subscribe <address> to get alerts when its balance changes, /subscriptions to list yours, and /unsubscribe to stop. If asked to prove you own the address, sign the message you are given and send /verify <signature>.
+
+Settings, for all your subscriptions:
+/mindelta <amount> only alerts on changes of at least this many $NOCK
+/quiet 22:00-07:00 sends alerts silently at night
+/timezone Europe/Berlin sets the time zone of your quiet hours
+/language es sends alerts in another language
+Send any of them with "off" to undo it, or /settings to see yours.`
+
+// changeSubscriberPrefs applies a settings command to a subscriber's
+// preferences; its errors are replies to the subscriber
+func changeSubscriberPrefs(prefs monitor.SubscriberPrefs, command, argument string, config Config) (monitor.SubscriberPrefs, error) {
+	off := argument == "off"
+	switch command {
+	case "/mindelta":
+		prefs.MinDelta = 0
+		if off {
+			break
+		}
+		amount, err := strconv.ParseFloat(argument, 64)
+		if err != nil || amount <= 0 {
+			return prefs, errors.New("Usage: /mindelta <amount in " + config.Denomination.Unit + ">, or /mindelta off")
+		}
+		prefs.MinDelta = int64(amount * float64(config.Denomination.BaseUnitsPerUnit))
+	case "/quiet":
+		prefs.QuietStart, prefs.QuietEnd = "", ""
+		if off {
+			break
+		}
+		start, end, _ := strings.Cut(argument, "-")
+		from, err1 := time.Parse("15:04", strings.TrimSpace(start))
+		until, err2 := time.Parse("15:04", strings.TrimSpace(end))
+		if err1 != nil || err2 != nil || from.Equal(until) {
+			return prefs, errors.New("Usage: /quiet 22:00-07:00, or /quiet off")
+		}
+		prefs.QuietStart, prefs.QuietEnd = from.Format("15:04"), until.Format("15:04")
+	case "/timezone":
+		prefs.Timezone = ""
+		if off {
+			break
+		}
+		if _, err := time.LoadLocation(argument); err != nil || argument == "" {
+			return prefs, errors.New("Usage: /timezone <Area/City> such as Europe/Berlin, or /timezone off")
+		}
+		prefs.Timezone = argument
+	case "/language":
+		prefs.Language = ""
+		if off {
+			break
+		}
+		language := strings.ToLower(argument)
+		if _, err := notify.LoadTranslations(config.TranslationsDir, language); err != nil || language == "" {
+			return prefs, errors.New("Usage: /language <code>, one of " + strings.Join(notify.Languages(), ", "))
+		}
+		prefs.Language = language
+	}
+	return prefs, nil
+}
+
+// describeSubscriberPrefs lists a subscriber's settings
+func describeSubscriberPrefs(prefs monitor.SubscriberPrefs, config Config) string {
+	minimum := "every change"
+	if prefs.MinDelta > 0 {
+		minimum = "changes of at least " + config.formatter().Units(prefs.MinDelta)
+	}
+	quiet := "none"
+	if prefs.QuietStart != "" {
+		timezone := prefs.Timezone
+		if timezone == "" {
+			timezone = config.Timezone
+		}
+		quiet = fmt.Sprintf("%s-%s %s, sent silently", prefs.QuietStart, prefs.QuietEnd, timezone)
+	}
+	language := prefs.Language
+	if language == "" {
+		language = config.TelegramLanguage
+	}
+	return fmt.Sprintf("Your settings:\nAlerts: %s\nQuiet hours: %s\nLanguage: %s", minimum, quiet, language)
+}
+
+// subscribeChat subscribes the chat a message came from to an address, right
+// away when subscriptions are open or pending the operator's approval, and
+// returns the reply. With a verifier, the subscriber is first challenged to
+// prove they own the address, and subscribed once verified.
+func subscribeChat(bot *notify.Telegram, message notify.TelegramMessage, address string, verified bool, config Config, m *monitor.Monitor, verifier *ownershipVerifier) string {
+	chat := message.Chat.ID
+	count := 0
+	for _, subscription := range m.Subscriptions() {
+		if subscription.ChatID == chat {
+			count++
+		}
+	}
+	actor := fmt.Sprintf("telegram:%s(%d)", message.From.Username, message.From.ID)
+	open := config.TelegramSubscriptions == subscriptionsOpen
+	if count >= config.TelegramMaxSubscriptions || open && !m.IsWatched(address) {
+		auditLog(config.AuditLogFile, actor, RoleNone, "subscribe", address, false)
+		if count >= config.TelegramMaxSubscriptions {
+			return fmt.Sprintf("You can subscribe to at most %d addresses; /unsubscribe from one first.", config.TelegramMaxSubscriptions)
+		}
+		return "Only addresses watched by this alerter can be subscribed to."
+	}
+	if verifier != nil && !verified {
+		challenge, err := verifier.challenge(fmt.Sprintf("telegram:%d", chat), address)
+		if errors.Is(err, errInvalidAddress) {
+			return "That isn't an address: " + err.Error() + "."
+		}
+		if err != nil {
+			log.Printf("Error issuing ownership challenge for %s: %v", address, err)
+			return "⚠️ Could not issue a challenge, please try again later."
+		}
+		return "To prove you own " + subscriptionName(address, m) + ", sign this message with its key in your wallet within an hour and send /verify <signature>:\n\n" + challenge
+	}
+
+	err := m.Subscribe(chat, message.From.Username, address, open, verified)
+	auditLog(config.AuditLogFile, actor, RoleNone, "subscribe", address, err == nil)
+	switch {
+	case errors.Is(err, monitor.ErrAlreadySubscribed):
+		return "You are already subscribed to " + subscriptionName(address, m) + "."
+	case err != nil:
+		log.Printf("Error subscribing chat %d to %s: %v", chat, address, err)
+		return "⚠️ Could not subscribe, please try again later."
+	case open:
+		return "🔔 Subscribed to balance changes of " + subscriptionName(address, m) + ". Send /unsubscribe to stop."
+	}
+
+	key := fmt.Sprintf("%d:%s", chat, notify.TelegramAddressKey(address))
+	requester := "A user"
+	if message.From.Username != "" {
+		requester = "@" + message.From.Username
+	}
+	request := fmt.Sprintf("📝 %s (chat %d) asks for alerts on %s", requester, chat, subscriptionName(address, m))
+	if verified {
+		request += " and proved owning it"
+	}
+	if !m.IsWatched(address) {
+		request += ", which isn't watched yet; approving watches it"
+	}
+	err = bot.SendTo(config.TelegramChatID, notify.EscapeMarkdownV2(request),
+		notify.TelegramButton{Text: "✅ Approve", Data: notify.TelegramActionApprove + ":" + key},
+		notify.TelegramButton{Text: "❌ Deny", Data: notify.TelegramActionDeny + ":" + key})
+	if err != nil {
+		log.Printf("Error sending subscription request of chat %d: %v", chat, err)
+	}
+	return "⏳ Your request for " + subscriptionName(address, m) + " was sent to the operator; you'll be told once it is approved."
+}
+
+// handleSubscriptionDecision approves or denies a subscription request
+// from the buttons in the operator's chat, and tells the subscriber
+func handleSubscriptionDecision(bot *notify.Telegram, query notify.TelegramCallbackQuery, action, key string, config Config, m *monitor.Monitor, subscribers *telegramSubscribers) {
+	role := telegramUserRole(query.From.ID, config)
+	allowed := role >= RoleAdmin
+	chatID, addressKey, _ := strings.Cut(key, ":")
+	chat, _ := strconv.ParseInt(chatID, 10, 64)
+	var pending *monitor.Subscription
+	for _, subscription := range m.Subscriptions() {
+		if subscription.ChatID == chat && notify.TelegramAddressKey(subscription.Address) == addressKey {
+			pending = &subscription
+			break
+		}
+	}
+	address := ""
+	if pending != nil {
+		address = pending.Address
+	}
+	actor := fmt.Sprintf("telegram:%s(%d)", query.From.Username, query.From.ID)
+	auditLog(config.AuditLogFile, actor, role, "button "+action, address, allowed)
+
+	var answer, notice string
+	switch {
+	case !allowed:
+		answer = "⛔ You don't have permission to do this."
+	case pending == nil:
+		answer = "This request was withdrawn."
+	case pending.Approved:
+		answer = "Already approved."
+	case action == notify.TelegramActionDeny:
+		if err := m.Unsubscribe(chat, address); err != nil {
+			log.Printf("Error denying subscription of chat %d: %v", chat, err)
+		}
+		answer, notice = "Denied", "❌ Your request for alerts on "+subscriptionName(address, m)+" was denied."
+	default:
+		if !m.IsWatched(address) {
+			if _, err := m.Source.GetBalance(address); err != nil {
+				log.Printf("Error checking balance for %s: %v", address, err)
+				answer = "⚠️ Could not verify the address against the RPC."
+				break
+			}
+			if err := m.Watch(address); err != nil && !errors.Is(err, monitor.ErrAlreadyWatched) {
+				log.Printf("Error watching %s: %v", address, err)
+				answer = "⚠️ Could not watch the address."
+				break
+			}
+		}
+		if err := m.ApproveSubscription(chat, address); err != nil {
+			log.Printf("Error approving subscription of chat %d: %v", chat, err)
+			answer = "⚠️ Could not approve the request."
+			break
+		}
+		answer, notice = "Approved", "🔔 Your request was approved: you'll get alerts when the balance of "+subscriptionName(address, m)+" changes."
+	}
+	subscribers.update(m)
+
+	if notice != "" {
+		if err := bot.SendTo(strconv.FormatInt(chat, 10), notify.EscapeMarkdownV2(notice)); err != nil {
+			log.Printf("Error telling chat %d about its subscription: %v", chat, err)
+		}
+	}
+	if err := bot.AnswerCallback(query.ID, answer); err != nil {
+		log.Printf("Error answering Telegram button %s: %v", action, err)
+	}
+}
+
+// subscriptionName describes an address to subscribers by its label, or
+// shortened
+func subscriptionName(address string, m *monitor.Monitor) string {
+	if label := m.Labels[address]; label != "" {
+		return label
+	}
+	return shortAddress(address)
+}
+
+// handleSubscriptions lists the Telegram subscriptions, and the deep link
+// that subscribes a chat to each watched address
+func handleSubscriptions(w http.ResponseWriter, r *http.Request, config Config, m *monitor.Monitor) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	if config.TelegramSubscriptions == "" {
+		writeJSONError(w, http.StatusNotFound, "TELEGRAM_SUBSCRIPTIONS is not set")
+		return
+	}
+
+	username, err := (&notify.Telegram{BotToken: config.TelegramBotToken}).Username()
+	if err != nil {
+		log.Printf("Error reading the Telegram bot's username: %v", err)
+		writeJSONError(w, http.StatusBadGateway, "could not read the bot's username from Telegram")
+		return
+	}
+	links := map[string]string{}
+	for _, address := range m.WatchedAddresses() {
+		links[address] = "https://t.me/" + username + "?start=" + notify.TelegramAddressKey(address)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"subscriptions": m.Subscriptions(),
+		"links":         links,
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// telegramPollTimeout is how long each getUpdates call waits for a button
+// press
+const telegramPollTimeout = 30 * time.Second
+
+// telegramMuteDuration is how long the Mute button mutes an address
+const telegramMuteDuration = time.Hour
+
+// telegramMaxTransactions is how many transaction IDs the Recent txs
+// button lists
+const telegramMaxTransactions = 10
+
+// telegramActionRoles is the minimum role required for each alert button
+var telegramActionRoles = map[string]Role{
+	notify.TelegramActionMute:         RoleAdmin,
+	notify.TelegramActionTransactions: RoleRead,
+	notify.TelegramActionDetails:      RoleRead,
+}
+
+// startTelegramBot answers presses of the alert buttons, and subscription
+// commands when subscribers is set, until the process exits
+func startTelegramBot(config Config, m *monitor.Monitor, subscribers *telegramSubscribers) {
+	bot := &notify.Telegram{BotToken: config.TelegramBotToken}
+	var offset int64
+	for {
+		updates, err := bot.Updates(offset, telegramPollTimeout)
+		if err != nil {
+			log.Printf("Error reading Telegram updates: %v", err)
+			time.Sleep(5 * time.Second)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateID + 1
+			if update.CallbackQuery != nil {
+				handleTelegramCallback(bot, *update.CallbackQuery, config, m, subscribers)
+			}
+			if update.Message != nil && subscribers != nil {
+				handleTelegramMessage(bot, *update.Message, config, m, subscribers)
+			}
+		}
+	}
+}
+
+// handleTelegramCallback runs the action of a pressed alert button
+func handleTelegramCallback(bot *notify.Telegram, query notify.TelegramCallbackQuery, config Config, m *monitor.Monitor, subscribers *telegramSubscribers) {
+	action, key, _ := strings.Cut(query.Data, ":")
+	if (action == notify.TelegramActionApprove || action == notify.TelegramActionDeny) && subscribers != nil {
+		handleSubscriptionDecision(bot, query, action, key, config, m, subscribers)
+		return
+	}
+	address := telegramAddress(key, m)
+
+	role := telegramUserRole(query.From.ID, config)
+	required, known := telegramActionRoles[action]
+	allowed := known && role >= required
+	actor := fmt.Sprintf("telegram:%s(%d)", query.From.Username, query.From.ID)
+	auditLog(config.AuditLogFile, actor, role, "button "+action, address, allowed)
+
+	var answer string
+	switch {
+	case !known:
+		answer = "Unknown action"
+	case !allowed:
+		answer = "⛔ You don't have permission to do this."
+	case address == "":
+		answer = "This address is no longer watched."
+	case action == notify.TelegramActionMute:
+		until := time.Now().Add(telegramMuteDuration)
+		if err := m.Mute(address, until); err != nil {
+			log.Printf("Error muting %s: %v", address, err)
+			answer = "⚠️ Could not mute this address."
+		} else {
+			answer = fmt.Sprintf("🔕 Muted until %s", until.Format("15:04 MST"))
+		}
+	case action == notify.TelegramActionTransactions:
+		if err := bot.Reply(query, telegramTransactionsReply(address, m)); err != nil {
+			log.Printf("Error replying to Telegram button: %v", err)
+			answer = "⚠️ Could not send the transactions."
+		}
+	case action == notify.TelegramActionDetails:
+		data, err := rawTransactions(address, m)
+		if err != nil {
+			log.Printf("Error fetching raw transactions for %s: %v", address, err)
+			answer = "⚠️ Could not fetch the transaction details."
+		} else if err := bot.ReplyDocument(query, detailsFilename(address), data, "Raw transactions of "+subscriptionName(address, m)); err != nil {
+			log.Printf("Error replying to Telegram button: %v", err)
+			answer = "⚠️ Could not send the transaction details."
+		}
+	}
+
+	if err := bot.AnswerCallback(query.ID, answer); err != nil {
+		log.Printf("Error answering Telegram button %s: %v", action, err)
+	}
+}
+
+// telegramAddress resolves the address key of a button to a watched address
+func telegramAddress(key string, m *monitor.Monitor) string {
+	for _, address := range m.WatchedAddresses() {
+		if notify.TelegramAddressKey(address) == key {
+			return address
+		}
+	}
+	return ""
+}
+
+// telegramUserRole resolves the role of a Telegram user. Without
+// TELEGRAM_ADMIN_IDS everyone in the chat is an admin, since only chat
+// members see the buttons.
+func telegramUserRole(userID int64, config Config) Role {
+	if len(config.TelegramAdminIDs) == 0 {
+		return RoleAdmin
+	}
+	for _, id := range config.TelegramAdminIDs {
+		if id == userID {
+			return RoleAdmin
+		}
+	}
+	return RoleRead
+}
+
+// telegramTransactionsReply lists the most recent transactions of an
+// address as a MarkdownV2 message
+func telegramTransactionsReply(address string, m *monitor.Monitor) string {
+	source, ok := m.Source.(monitor.TransactionSource)
+	if !ok {
+		return notify.EscapeMarkdownV2("Transaction history isn't available from this RPC.")
+	}
+	ids, err := source.TransactionIDs(address)
+	if err != nil {
+		log.Printf("Error fetching transactions for %s: %v", address, err)
+		return notify.EscapeMarkdownV2("⚠️ Could not fetch the transactions.")
+	}
+	if len(ids) == 0 {
+		return notify.EscapeMarkdownV2("No transactions found.")
+	}
+	if len(ids) > telegramMaxTransactions {
+		ids = ids[:telegramMaxTransactions]
+	}
+	message := "🧾 *Recent transactions*\n"
+	for i, id := range ids {
+		message += fmt.Sprintf("%s `%s`\n", notify.EscapeMarkdownV2(fmt.Sprintf("%d.", i+1)), notify.EscapeMarkdownV2Code(id))
+	}
+	return message
+}
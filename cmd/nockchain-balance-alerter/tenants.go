@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"github.com/joho/godotenv"
+)
+
+// tenantPathPrefix is where each tenant's API is served, followed by the
+// tenant's name, e.g. /tenants/alice/api/balances
+const tenantPathPrefix = "/tenants/"
+
+// tenantCommands are the settings that run a command on the operator's
+// host, which a tenant's .env may not set. The operator's own
+// OWNERSHIP_VERIFY_COMMAND and WALLET_DERIVE_COMMAND apply to every tenant
+// instead, and its ALERT_HOOK_COMMAND to none.
+var tenantCommands = []string{"ALERT_HOOK_COMMAND", "OWNERSHIP_VERIFY_COMMAND", "WALLET_DERIVE_COMMAND"}
+
+// tenantName is the pattern of a tenant's directory name, which appears in
+// its API paths
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// startTenants starts a monitor for every subdirectory of the operator's
+// TENANTS_DIR holding a .env file, and returns the API handler of each by
+// tenant name. Each tenant has its own addresses, notifiers, message format,
+// API tokens and balances.json, kept in its directory; of the operator's
+// settings only the commands in tenantCommands carry over, so tenants can't
+// opt out of verification or run commands of their own.
+func startTenants(operator Config) (map[string]http.Handler, error) {
+	dir := operator.TenantsDir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	handlers := map[string]http.Handler{}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(path, envFile)); err != nil {
+			continue
+		}
+		name := entry.Name()
+		if !tenantName.MatchString(name) {
+			return nil, fmt.Errorf("tenant %q: names must be lowercase letters, digits, - and _", name)
+		}
+		config, err := loadTenantConfig(path, operator)
+		if err != nil {
+			return nil, fmt.Errorf("tenant %s: %w", name, err)
+		}
+		config.Tenant = name
+		log.Printf("Starting tenant %s with %d addresses", name, len(config.Addresses))
+		m := startMonitor(config, name, filepath.Join(path, balanceFile), nil)
+		handlers[name] = http.StripPrefix(tenantPathPrefix+name, newAPIHandler(config, m, nil))
+	}
+	if len(handlers) == 0 {
+		return nil, fmt.Errorf("no tenant directories with a %s in %s", envFile, dir)
+	}
+	return handlers, nil
+}
+
+// loadTenantConfig reads the config of the tenant in dir from its .env file
+// alone, ignoring the environment, with the operator's commands in place of
+// its own. Its audit log defaults to dir.
+func loadTenantConfig(dir string, operator Config) (Config, error) {
+	path := filepath.Join(dir, envFile)
+	values, err := godotenv.Read(path)
+	if err != nil {
+		return Config{}, err
+	}
+	for _, name := range tenantCommands {
+		if values[name] != "" {
+			return Config{}, fmt.Errorf("%s isn't supported for tenants, which can't run commands on the operator's host", name)
+		}
+	}
+	values["OWNERSHIP_VERIFY_COMMAND"] = operator.OwnershipVerifyCommand
+	values["WALLET_DERIVE_COMMAND"] = operator.WalletDeriveCommand
+	tenantEnv = values
+	defer func() { tenantEnv = nil }()
+
+	config, err := readConfig(path)
+	if err != nil {
+		return config, err
+	}
+	if config.SlackClientID != "" {
+		return config, errors.New("SLACK_CLIENT_ID isn't supported for tenants; use SLACK_BOT_TOKEN")
+	}
+	if values["AUDIT_LOG_FILE"] == "" {
+		config.AuditLogFile = filepath.Join(dir, defaultAuditLog)
+	}
+	return config, nil
+}
+
+// withTenants serves each tenant's API under tenantPathPrefix, and
+// everything else with the operator's API
+func withTenants(operator http.Handler, tenants map[string]http.Handler) http.Handler {
+	if len(tenants) == 0 {
+		return operator
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", operator)
+	for name, handler := range tenants {
+		mux.Handle(tenantPathPrefix+name+"/", handler)
+	}
+	return mux
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Terminal control sequences used by the dashboard
+const (
+	ansiAltScreen  = "\x1b[?1049h\x1b[?25l" // Switch to the alternate screen, hide the cursor
+	ansiMainScreen = "\x1b[?25h\x1b[?1049l" // Show the cursor, back to the main screen
+	ansiClear      = "\x1b[H\x1b[2J"
+	ansiBold       = "\x1b[1m"
+	ansiReset      = "\x1b[0m"
+)
+
+// runTUI shows a read-only dashboard of the balances, notifier health and
+// recent activity recorded in balances.json, reloaded every --interval, for
+// operators watching a running alerter over SSH. It never checks balances
+// or sends anything itself.
+func runTUI(args []string) {
+	flags := flag.NewFlagSet("tui", flag.ExitOnError)
+	interval := flags.Duration("interval", 2*time.Second, "how often to reload balances.json")
+	tag := flags.String("tag", "", "only show addresses with any of these comma-separated tags")
+	flags.Parse(args)
+	if *interval <= 0 {
+		log.Fatal("--interval must be positive")
+	}
+
+	// Nothing is sent, so notifiers are optional
+	config, err := loadConfig()
+	if err != nil && !errors.Is(err, errNoNotifiers) {
+		log.Fatalf("Error loading config: %v", err)
+	}
+
+	m := newMonitor(config, nil, monitor.FileStore{Path: balanceFile})
+	m.SummaryTags = parseTags(*tag)
+	names := notifierNames(config)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	os.Stdout.WriteString(ansiAltScreen)
+	defer os.Stdout.WriteString(ansiMainScreen)
+	for {
+		// A failed load, e.g. while the alerter is rewriting the file,
+		// keeps the last good state
+		loadErr := m.Load()
+		width, height := terminalSize()
+		var screen bytes.Buffer
+		screen.WriteString(ansiClear)
+		renderDashboard(&screen, m, names, loadErr, time.Now(), width, height)
+		os.Stdout.Write(screen.Bytes())
+
+		select {
+		case <-ticker.C:
+		case <-signals:
+			return
+		}
+	}
+}
+
+// notifierNames returns the names of the configured notifiers
+func notifierNames(config Config) []string {
+	var names []string
+	for _, notifier := range newNotifiers(config, nil) {
+		names = append(names, notifier.Name())
+	}
+	if config.DiscordBotToken != "" && config.DiscordChannelID != "" {
+		names = append(names, "Discord")
+	}
+	return names
+}
+
+// renderDashboard writes the dashboard, cut to fit a width by height
+// terminal
+func renderDashboard(w *bytes.Buffer, m *monitor.Monitor, names []string, loadErr error, now time.Time, width, height int) {
+	var lines []string
+	headings := map[int]bool{}
+	heading := func(title string) {
+		lines = append(lines, "", title)
+		headings[len(lines)-1] = true
+	}
+
+	header := "Nockchain Balance Alerter · last check never"
+	if lastChecked := m.LastChecked(); !lastChecked.IsZero() {
+		header = "Nockchain Balance Alerter · last check " + formatAgo(now, lastChecked)
+	}
+	lines = append(lines, header+" · "+now.Format("15:04:05")+" · Ctrl+C to quit")
+	if loadErr != nil {
+		lines = append(lines, fmt.Sprintf("Error reading %s, showing the last good copy: %v", balanceFile, loadErr))
+	}
+
+	checks := addressChecks(m, 0, 0, now)
+	labels := map[string]string{}
+	heading("BALANCES")
+	var total int64
+	lines = append(lines, tabulate(func(t *tabwriter.Writer) {
+		fmt.Fprintln(t, "ADDRESS\tLABEL\tBALANCE\t24H\tLAST CHANGE\tSTATUS")
+		for _, check := range checks {
+			balance := check.balance
+			labels[balance.Address] = balance.Label
+			total += balance.CurrentBalance
+			day := "-"
+			for _, change := range balance.Changes {
+				if change.Period == "24h" {
+					day = m.Format.Delta(change.Delta)
+				}
+			}
+			lastChange := "-"
+			if !balance.LastUpdated.IsZero() && balance.LastUpdated.Unix() > 0 {
+				lastChange = formatAgo(now, balance.LastUpdated)
+			}
+			status := stateNames[check.state]
+			if check.reason != "" {
+				status += ": " + check.reason
+			}
+			fmt.Fprintf(t, "%s\t%s\t%s\t%s\t%s\t%s\n", shortAddress(balance.Address), balance.Label, m.Format.Balance(balance.CurrentBalance), day, lastChange, status)
+		}
+		fmt.Fprintf(t, "Total\t\t%s\t\t\t\n", m.Format.Balance(total))
+	})...)
+
+	heading("NOTIFIERS")
+	lines = append(lines, tabulate(func(t *tabwriter.Writer) {
+		fmt.Fprintln(t, "NAME\tSTATUS\tQUEUED\tLAST FAILURE")
+		for _, health := range notifierHealth(m, names, now) {
+			fmt.Fprintf(t, "%s\t%s\t%d\t%s\n", health.name, health.status, health.queued, health.lastError)
+		}
+	})...)
+
+	heading("ACTIVITY")
+	activities := m.Activities(height)
+	if len(activities) == 0 {
+		lines = append(lines, "No changes or failures recorded yet")
+	}
+	for _, activity := range activities {
+		lines = append(lines, formatActivity(activity, labels, m.Format, now))
+	}
+
+	if height > 0 && len(lines) > height-1 {
+		lines = lines[:height-1]
+	}
+	for i, line := range lines {
+		if width > 0 && utf8.RuneCountInString(line) > width {
+			line = string([]rune(line)[:width-1]) + "…"
+		}
+		if headings[i] {
+			line = ansiBold + line + ansiReset
+		}
+		w.WriteString(line + "\n")
+	}
+}
+
+// tabulate renders a table into lines
+func tabulate(write func(t *tabwriter.Writer)) []string {
+	var b bytes.Buffer
+	t := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	write(t)
+	t.Flush()
+	return strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+}
+
+// notifierStatus is the delivery health of one notifier
+type notifierStatus struct {
+	name      string
+	status    string
+	queued    int
+	lastError string
+}
+
+// notifierHealth rates each configured notifier, plus any others with
+// failures or queued alerts: retrying while queued alerts have failed, failed
+// when its last failure was within a day, otherwise ok
+func notifierHealth(m *monitor.Monitor, names []string, now time.Time) []notifierStatus {
+	byName := map[string]*notifierStatus{}
+	var statuses []*notifierStatus
+	get := func(name string) *notifierStatus {
+		if byName[name] == nil {
+			byName[name] = &notifierStatus{name: name, status: "ok", lastError: "-"}
+			statuses = append(statuses, byName[name])
+		}
+		return byName[name]
+	}
+	for _, name := range names {
+		get(name)
+	}
+	for _, failure := range m.DeliveryFailures() {
+		// Failures are recorded oldest first
+		status := get(failure.Notifier)
+		at := time.Unix(failure.Time, 0)
+		status.lastError = formatAgo(now, at) + ": " + failure.Error
+		if now.Sub(at) < 24*time.Hour {
+			status.status = "failed " + formatAgo(now, at)
+		}
+	}
+	for _, queued := range m.Queued() {
+		status := get(queued.Notifier)
+		status.queued++
+		if queued.Attempts > 0 {
+			status.status = "retrying"
+			if queued.LastError != "" {
+				status.lastError = "now: " + queued.LastError
+			}
+		}
+	}
+	result := make([]notifierStatus, len(statuses))
+	for i, status := range statuses {
+		result[i] = *status
+	}
+	return result
+}
+
+// formatActivity renders one line of the activity feed
+func formatActivity(activity monitor.Activity, labels map[string]string, format notify.Formatter, now time.Time) string {
+	at := activity.Time.Local().Format("15:04:05")
+	if now.Sub(activity.Time) > 20*time.Hour {
+		at = activity.Time.Local().Format("Jan 02 15:04")
+	}
+	subject := ""
+	if activity.Address != "" {
+		subject = shortAddress(activity.Address)
+		if label := labels[activity.Address]; label != "" {
+			subject = label
+		}
+	}
+	switch activity.Kind {
+	case monitor.ActivityChange:
+		return fmt.Sprintf("%-12s  %-16s  %s: %s → %s", at, activity.Kind, subject, format.Delta(activity.Delta), format.Balance(activity.Balance))
+	case monitor.ActivityDeliveryFailed:
+		if subject != "" {
+			subject = " (" + subject + ")"
+		}
+		return fmt.Sprintf("%-12s  %-16s  %s%s: %s", at, activity.Kind, activity.Notifier, subject, activity.Error)
+	default:
+		return fmt.Sprintf("%-12s  %-16s  %s: %s", at, activity.Kind, subject, activity.Error)
+	}
+}
+
+// formatAgo formats how long before now t was, coarsely, e.g. "45s ago"
+// or "3h ago"
+func formatAgo(now, t time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds ago", int(d.Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd ago", int(d.Hours()/24))
+}
+
+// shortAddress shortens an address to its start and end, to keep tables
+// narrow
+func shortAddress(address string) string {
+	if utf8.RuneCountInString(address) <= 20 {
+		return address
+	}
+	runes := []rune(address)
+	return string(runes[:10]) + "…" + string(runes[len(runes)-8:])
+}
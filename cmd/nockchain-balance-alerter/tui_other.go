@@ -0,0 +1,8 @@
+//go:build !unix && !windows
+
+package main
+
+// terminalSize reports an unknown size, so nothing is cut
+func terminalSize() (width, height int) {
+	return 0, 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/monitor"
+)
+
+// commandDeriver derives wallet addresses by running an external wallet
+// tool, with {key} and {index} in its arguments replaced by the extended
+// public key and the address index; the tool must print the address
+type commandDeriver struct {
+	command string
+	key     string
+}
+
+// DeriveAddress implements monitor.Deriver
+func (d commandDeriver) DeriveAddress(index int) (string, error) {
+	args := strings.Fields(d.command)
+	for i, arg := range args {
+		arg = strings.ReplaceAll(arg, "{key}", d.key)
+		args[i] = strings.ReplaceAll(arg, "{index}", strconv.Itoa(index))
+	}
+	output, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		return "", fmt.Errorf("running %s: %w", args[0], err)
+	}
+	address := strings.TrimSpace(string(output))
+	if address == "" || strings.ContainsAny(address, " \n") {
+		return "", fmt.Errorf("%s printed %q, expected a single address", args[0], address)
+	}
+	return address, nil
+}
+
+// newWallets builds the configured wallets
+func newWallets(config Config) []monitor.Wallet {
+	var wallets []monitor.Wallet
+	for name, key := range config.Wallets {
+		wallets = append(wallets, monitor.Wallet{
+			Name:     name,
+			Deriver:  commandDeriver{command: config.WalletDeriveCommand, key: key},
+			GapLimit: config.WalletGapLimit,
+		})
+	}
+	return wallets
+}
@@ -1,13 +1,30 @@
-module NockBalBot
+module github.com/anilcse/nockchain-balance-alerter
 
-go 1.20
+go 1.22
 
 require (
-	github.com/go-co-op/gocron v1.37.0 // indirect
-	github.com/google/uuid v1.4.0 // indirect
+	github.com/bwmarrin/discordgo v0.28.1
+	github.com/go-co-op/gocron v1.37.0
+	github.com/joho/godotenv v1.5.1
+	github.com/slack-go/slack v0.17.3
+	github.com/wcharczuk/go-chart/v2 v2.1.2
+	golang.org/x/sys v0.22.0
+	modernc.org/sqlite v1.34.5
+)
+
+require (
+	github.com/dustin/go-humanize v1.0.1 // indirect
+	github.com/golang/freetype v0.0.0-20170609003504-e2365dfdc4a0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
 	github.com/gorilla/websocket v1.5.3 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/ncruces/go-strftime v0.1.9 // indirect
+	github.com/remyoudompheng/bigfft v0.0.0-20230129092748-24d4a6f8daec // indirect
 	github.com/robfig/cron/v3 v3.0.1 // indirect
-	github.com/slack-go/slack v0.17.3 // indirect
 	go.uber.org/atomic v1.9.0 // indirect
+	golang.org/x/crypto v0.23.0 // indirect
+	golang.org/x/image v0.18.0 // indirect
+	modernc.org/libc v1.55.3 // indirect
+	modernc.org/mathutil v1.6.0 // indirect
+	modernc.org/memory v1.8.0 // indirect
 )
@@ -0,0 +1,72 @@
+// Package chart renders balance history as PNG line charts.
+package chart
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	gochart "github.com/wcharczuk/go-chart/v2"
+)
+
+// Point is a balance, in $NOCK, from Time onwards
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// Series is the balance history of one line on the chart
+type Series struct {
+	Name   string
+	Points []Point
+}
+
+// PNG renders the series as a step chart ending at now. Balances only change
+// at recorded points, so each value is held flat until the next one.
+func PNG(title string, series []Series, now time.Time) ([]byte, error) {
+	graph := gochart.Chart{
+		Title:  title,
+		Width:  800,
+		Height: 400,
+		Background: gochart.Style{
+			Padding: gochart.Box{Top: 50, Left: 20, Right: 20, Bottom: 20},
+		},
+		XAxis: gochart.XAxis{ValueFormatter: gochart.TimeValueFormatterWithFormat("Jan 2")},
+		YAxis: gochart.YAxis{
+			ValueFormatter: func(v interface{}) string {
+				return fmt.Sprintf("%.2f", v.(float64))
+			},
+		},
+	}
+	for _, s := range series {
+		if len(s.Points) == 0 {
+			continue
+		}
+		line := gochart.TimeSeries{Name: s.Name}
+		for i, point := range s.Points {
+			if i > 0 {
+				// Hold the previous value up to this change
+				line.XValues = append(line.XValues, point.Time)
+				line.YValues = append(line.YValues, s.Points[i-1].Value)
+			}
+			line.XValues = append(line.XValues, point.Time)
+			line.YValues = append(line.YValues, point.Value)
+		}
+		last := s.Points[len(s.Points)-1]
+		line.XValues = append(line.XValues, now)
+		line.YValues = append(line.YValues, last.Value)
+		graph.Series = append(graph.Series, line)
+	}
+	if len(graph.Series) == 0 {
+		return nil, fmt.Errorf("chart: no data")
+	}
+	if len(graph.Series) > 1 {
+		graph.Elements = []gochart.Renderable{gochart.LegendLeft(&graph)}
+	}
+
+	var buf bytes.Buffer
+	if err := graph.Render(gochart.PNG, &buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,110 @@
+// Package dedupe keeps, in SQLite, which transactions alerts have reported,
+// so the monitor never reports the same on-chain event twice.
+package dedupe
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // Registers the "sqlite" driver
+)
+
+// schema creates the table of reported transactions. It uses the default
+// rollback journal rather than WAL, which needs shared memory and so
+// doesn't work on the network filesystems a cluster directory may be on.
+const schema = `
+CREATE TABLE IF NOT EXISTS notified (
+	address  TEXT NOT NULL,
+	tx       TEXT NOT NULL,
+	notified INTEGER NOT NULL,
+	PRIMARY KEY (address, tx)
+) WITHOUT ROWID;
+CREATE INDEX IF NOT EXISTS notified_time ON notified (notified);
+`
+
+// busyTimeout is how long a write waits for another process, such as a
+// cluster peer taking addresses over, to finish reading
+const busyTimeout = 5 * time.Second
+
+// Store is a SQLite database of reported transactions, implementing
+// monitor.NotifiedStore
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens the database at path, creating it if needed
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)", path, busyTimeout.Milliseconds()))
+	if err != nil {
+		return nil, err
+	}
+	// One connection serializes writers, which SQLite would anyway
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Notified returns when each reported transaction of an address was
+// reported, as Unix time by transaction ID
+func (s *Store) Notified(address string) (map[string]int64, error) {
+	rows, err := s.db.Query(`SELECT tx, notified FROM notified WHERE address = ?`, address)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	notified := map[string]int64{}
+	for rows.Next() {
+		var id string
+		var at int64
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		notified[id] = at
+	}
+	return notified, rows.Err()
+}
+
+// Record records transactions, by address, as reported at a time, in one
+// transaction
+func (s *Store) Record(ids map[string][]string, at time.Time) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	insert, err := tx.Prepare(`INSERT INTO notified (address, tx, notified) VALUES (?, ?, ?)
+		ON CONFLICT (address, tx) DO UPDATE SET notified = excluded.notified`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for address, txids := range ids {
+		for _, id := range txids {
+			if _, err := insert.Exec(address, id, at.Unix()); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// Expire forgets transactions reported before a time
+func (s *Store) Expire(before time.Time) error {
+	_, err := s.db.Exec(`DELETE FROM notified WHERE notified < ?`, before.Unix())
+	return err
+}
+
+// Forget forgets the reported transactions of an address
+func (s *Store) Forget(address string) error {
+	_, err := s.db.Exec(`DELETE FROM notified WHERE address = ?`, address)
+	return err
+}
@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"sort"
+	"time"
+)
+
+// Activity kinds
+const (
+	ActivityChange         = "change"          // A balance changed
+	ActivityCheckFailed    = "check failed"    // An address's last check failed
+	ActivityDeliveryFailed = "delivery failed" // A notification couldn't be delivered
+)
+
+// Activity is something recorded in the state, for activity feeds
+type Activity struct {
+	Time     time.Time
+	Kind     string // See the Activity constants
+	Address  string // Empty for deliveries not about one address
+	Notifier string // Delivery failures only
+	Balance  int64  // New balance of changes
+	Delta    int64  // Change in balance of changes
+	Error    string // Failures only
+}
+
+// Activities returns the balance changes in the history, the last failed check
+// of each address still failing, and the recorded delivery failures, newest
+// first and at most limit of them
+func (m *Monitor) Activities(limit int) []Activity {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var activities []Activity
+	for address, history := range m.state.BalanceHistory {
+		// The first sample is where the history starts, not a change
+		for i := 1; i < len(history); i++ {
+			activities = append(activities, Activity{
+				Time:    time.Unix(history[i].Time, 0),
+				Kind:    ActivityChange,
+				Address: address,
+				Balance: history[i].Balance,
+				Delta:   history[i].Balance - history[i-1].Balance,
+			})
+		}
+	}
+	for address, status := range m.state.AddressStatus {
+		if status.LastError != "" {
+			activities = append(activities, Activity{Time: time.Unix(status.LastErrorAt, 0), Kind: ActivityCheckFailed, Address: address, Error: status.LastError})
+		}
+	}
+	for _, failure := range m.state.DeliveryFailures {
+		activities = append(activities, Activity{Time: time.Unix(failure.Time, 0), Kind: ActivityDeliveryFailed, Address: failure.Address, Notifier: failure.Notifier, Error: failure.Error})
+	}
+	sort.SliceStable(activities, func(i, j int) bool { return activities[i].Time.After(activities[j].Time) })
+	if limit > 0 && len(activities) > limit {
+		activities = activities[:limit]
+	}
+	return activities
+}
+
+// LastChecked returns when CheckAll last completed, zero if never
+func (m *Monitor) LastChecked() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state.LastChecked == 0 {
+		return time.Time{}
+	}
+	return time.Unix(m.state.LastChecked, 0)
+}
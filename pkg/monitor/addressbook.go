@@ -0,0 +1,121 @@
+package monitor
+
+import (
+	"errors"
+	"log"
+)
+
+// ErrEmptyAddressBook is returned when a synced address book lists no
+// addresses, which is more likely a broken source than an intended change
+var ErrEmptyAddressBook = errors.New("the address book lists no addresses")
+
+// AddressBookEntry is an address listed in the team's address book, the
+// external source of truth for the watchlist
+type AddressBookEntry struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+}
+
+// AddressBookChanges reports what a sync of the address book changed
+type AddressBookChanges struct {
+	Added     []string
+	Removed   []string
+	Relabeled []string
+}
+
+// SyncAddressBook replaces the address book with entries: new addresses
+// are watched, addresses no longer listed stop being watched unless they
+// are configured or watched otherwise, and labels follow the book except
+// where the config labels an address. Addresses in the book count as
+// configured, so they can't be unwatched at runtime.
+func (m *Monitor) SyncAddressBook(entries []AddressBookEntry) (AddressBookChanges, error) {
+	var changes AddressBookChanges
+	if len(entries) == 0 {
+		return changes, ErrEmptyAddressBook
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	previous := map[string]string{}
+	for _, entry := range m.state.AddressBook {
+		previous[entry.Address] = entry.Label
+	}
+	listed := map[string]bool{}
+	var book []AddressBookEntry
+	for _, entry := range entries {
+		if entry.Address == "" || listed[entry.Address] {
+			continue
+		}
+		listed[entry.Address] = true
+		book = append(book, entry)
+		label, known := previous[entry.Address]
+		switch {
+		case !known && !m.isWatched(entry.Address):
+			changes.Added = append(changes.Added, entry.Address)
+		case known && label != entry.Label:
+			changes.Relabeled = append(changes.Relabeled, entry.Address)
+		}
+	}
+	m.state.AddressBook = book
+	for _, entry := range m.state.AddressBook {
+		delete(previous, entry.Address)
+	}
+	for address := range previous {
+		if !m.isWatched(address) {
+			changes.Removed = append(changes.Removed, address)
+			m.forget(address)
+		}
+	}
+	for _, address := range changes.Added {
+		log.Printf("Watching %s from the address book", address)
+	}
+	for _, address := range changes.Removed {
+		log.Printf("No longer watching %s, which left the address book", address)
+	}
+	m.labelAddressBook()
+	m.updateCache()
+	return changes, m.Store.Save(m.state)
+}
+
+// AddressBook returns the addresses last synced from the address book
+func (m *Monitor) AddressBook() []AddressBookEntry {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]AddressBookEntry(nil), m.state.AddressBook...)
+}
+
+// inAddressBook reports whether the last sync listed an address; callers
+// must hold m.mu
+func (m *Monitor) inAddressBook(address string) bool {
+	for _, entry := range m.state.AddressBook {
+		if entry.Address == address {
+			return true
+		}
+	}
+	return false
+}
+
+// labelAddressBook sets Labels to the configured labels plus those of the
+// address book. Labels is replaced rather than changed in place, as it is
+// read without holding m.mu. Callers must hold m.mu.
+func (m *Monitor) labelAddressBook() {
+	if m.configLabels == nil {
+		if len(m.state.AddressBook) == 0 {
+			return
+		}
+		m.configLabels = m.Labels
+		if m.configLabels == nil {
+			m.configLabels = map[string]string{}
+		}
+	}
+	labels := make(map[string]string, len(m.configLabels)+len(m.state.AddressBook))
+	for _, entry := range m.state.AddressBook {
+		if entry.Label != "" {
+			labels[entry.Address] = entry.Label
+		}
+	}
+	for address, label := range m.configLabels {
+		labels[address] = label
+	}
+	m.Labels = labels
+}
@@ -0,0 +1,70 @@
+package monitor
+
+import (
+	"log"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// archiveNotifier is a notifier added with AddArchive. Its name is set
+// apart from the interactive notifier of the same kind, so summary
+// schedules and alert hook routes naming that one don't apply to it.
+type archiveNotifier struct {
+	notify.Notifier
+}
+
+// Name implements notify.Notifier
+func (a archiveNotifier) Name() string {
+	return a.Notifier.Name() + " archive"
+}
+
+// AddArchive adds a notifier that receives a copy of every change alert,
+// alert and summary for retention, regardless of mutes, alert and summary
+// tags, and alert hook suppression or routing. Archives don't get pinned
+// summaries or charts.
+func (m *Monitor) AddArchive(n notify.Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.archives = append(m.archives, archiveNotifier{n})
+}
+
+// withArchives returns the notifiers followed by the archives, in a new
+// slice; callers must hold m.mu
+func (m *Monitor) withArchives(notifiers []notify.Notifier) []notify.Notifier {
+	return append(append(make([]notify.Notifier, 0, len(notifiers)+len(m.archives)), notifiers...), m.archives...)
+}
+
+// isArchive reports whether a notifier was added with AddArchive
+func isArchive(n notify.Notifier) bool {
+	_, ok := n.(archiveNotifier)
+	return ok
+}
+
+// archiveChange sends a change that filters kept from the interactive
+// notifiers to the archives only; callers must hold m.mu
+func (m *Monitor) archiveChange(change notify.Change) {
+	if len(m.archives) == 0 {
+		return
+	}
+	if change.Key == "" {
+		change.Key = changeKey(change.Address, change.OldBalance, change.NewBalance, change.Time.Truncate(idempotencyBucket).Unix(), nil)
+	}
+	rule, severity := changeRule(change)
+	change.Severity = m.severity(rule, severity)
+	m.deliver(m.archives, &change, nil)
+}
+
+// archiveSummary sends a summary of every balance, ignoring SummaryTags,
+// to the archives; callers must hold m.mu
+func (m *Monitor) archiveSummary() {
+	if len(m.archives) == 0 {
+		return
+	}
+	balances := m.summary(nil)
+	for _, n := range m.archives {
+		if err := n.NotifySummary(balances); err != nil {
+			log.Printf("Error sending %s summary: %v", n.Name(), err)
+			m.recordFailure(n.Name(), "summary", "", err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Snapshot is a copy of the balances as last saved, which readers get
+// without waiting for a check in progress
+type Snapshot struct {
+	Balances []BalanceData            `json:"balances"`
+	Status   map[string]AddressStatus `json:"status"`
+	Signers  map[string]SigningConfig `json:"signers"`
+}
+
+// snapshotCache holds the latest Snapshot and the addresses being
+// refreshed for RefreshStale. It has its own lock, since m.mu is held
+// for the whole of a check, RPC calls included.
+type snapshotCache struct {
+	mu         sync.Mutex
+	snapshot   Snapshot
+	refreshing map[string]bool
+}
+
+// Cached returns the balances, check statuses and signing configurations as
+// of the last save, without waiting for a check in progress
+func (m *Monitor) Cached() Snapshot {
+	m.cache.mu.Lock()
+	defer m.cache.mu.Unlock()
+	return m.cache.snapshot
+}
+
+// updateCache replaces the cached snapshot with the current state; callers
+// must hold m.mu
+func (m *Monitor) updateCache() {
+	snapshot := Snapshot{
+		Balances: append([]BalanceData{}, m.state.Balances.List()...),
+		Status:   make(map[string]AddressStatus, len(m.state.AddressStatus)),
+		Signers:  make(map[string]SigningConfig, len(m.state.Signers)),
+	}
+	for address, status := range m.state.AddressStatus {
+		snapshot.Status[address] = status
+	}
+	for address, config := range m.state.Signers {
+		snapshot.Signers[address] = config
+	}
+	m.cache.mu.Lock()
+	m.cache.snapshot = snapshot
+	m.cache.mu.Unlock()
+}
+
+// RefreshStale re-checks in the background those of addresses that haven't
+// been checked successfully within maxAge, skipping any already being
+// refreshed, and returns the ones it started on. Their new balances show in
+// Cached once checked; changes alert as on a scheduled check.
+func (m *Monitor) RefreshStale(addresses []string, maxAge time.Duration) []string {
+	cutoff := m.now().Add(-maxAge).Unix()
+	m.cache.mu.Lock()
+	if m.cache.refreshing == nil {
+		m.cache.refreshing = map[string]bool{}
+	}
+	var stale []string
+	for _, address := range addresses {
+		if m.cache.refreshing[address] || m.cache.snapshot.Status[address].LastSuccess > cutoff {
+			continue
+		}
+		m.cache.refreshing[address] = true
+		stale = append(stale, address)
+	}
+	m.cache.mu.Unlock()
+	if len(stale) == 0 {
+		return nil
+	}
+
+	go func() {
+		for _, address := range stale {
+			if _, err := m.Check(address); err != nil && !errors.Is(err, ErrNotWatched) && !errors.Is(err, ErrNotOwned) {
+				log.Printf("Error refreshing balance for %s: %v", address, err)
+			}
+			m.cache.mu.Lock()
+			delete(m.cache.refreshing, address)
+			m.cache.mu.Unlock()
+		}
+	}()
+	return stale
+}
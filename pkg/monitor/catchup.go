@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// DefaultCatchUpAfter is the downtime after which the first check sends one
+// catch-up message instead of individual change alerts
+const DefaultCatchUpAfter = 10 * time.Minute
+
+// offline reports whether the last completed check is older than
+// CatchUpAfter, i.e. the monitor was down; callers must hold m.mu
+func (m *Monitor) offline(now time.Time) bool {
+	return m.CatchUpAfter > 0 && m.state.LastChecked > 0 && now.Sub(time.Unix(m.state.LastChecked, 0)) > m.CatchUpAfter
+}
+
+// reportChange sends a change alert, or holds it for the catch-up message
+// while catching up or for the combined alert while checking all addresses;
+// callers must hold m.mu
+func (m *Monitor) reportChange(change notify.Change) {
+	if m.catchingUp {
+		m.caughtUp = append(m.caughtUp, change)
+		return
+	}
+	if m.combining {
+		m.combined = append(m.combined, change)
+		return
+	}
+	m.notifyChange(change)
+}
+
+// notifyCatchUp sends one "while you were away" alert listing the changes
+// found by the first check after downtime; callers must hold m.mu
+func (m *Monitor) notifyCatchUp(changes []notify.Change, since, now time.Time) {
+	offline := formatWindow(now.Sub(since).Round(time.Minute))
+	if len(changes) == 0 {
+		log.Printf("No balance changes while offline for %s", offline)
+		return
+	}
+
+	fields := []notify.Field{{Name: "Offline", Value: fmt.Sprintf("since %s (%s)", since.Format(time.RFC3339), offline)}}
+	for _, change := range changes {
+		fields = append(fields, m.changeField(change))
+	}
+	m.notifyAlert(notify.Alert{
+		Emoji:    "👋",
+		Title:    fmt.Sprintf("While you were away: %d balance changes", len(changes)),
+		Rule:     RuleCatchUp,
+		Severity: notify.SeverityWarning,
+		Fields:   fields,
+		Time:     now,
+	})
+}
+
+// changeField formats a change as one row of a message listing several
+func (m *Monitor) changeField(change notify.Change) notify.Field {
+	value := "First seen with " + m.Format.Balance(change.NewBalance)
+	if !change.Initial {
+		value = fmt.Sprintf("%s → %s\n%s %s", m.Format.Balance(change.OldBalance), m.Format.Balance(change.NewBalance),
+			notify.DirectionEmoji(change.Delta()), m.Format.Delta(change.Delta()))
+	}
+	return notify.Field{Name: change.Address + labelText(change.Label), Value: value}
+}
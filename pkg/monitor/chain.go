@@ -0,0 +1,54 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChainAdapter is a BalanceSource for one chain or indexer API. Adapters for
+// other chains only need to report balances in that chain's base unit.
+type ChainAdapter interface {
+	BalanceSource
+	// Chain names the adapter; addresses on it are written "chain:address"
+	Chain() string
+}
+
+// MultiChain is a BalanceSource that routes "chain:address" to the named
+// adapter and plain addresses to the default one, so a single monitor can
+// watch several chains with unified alerts
+type MultiChain struct {
+	Default  ChainAdapter
+	adapters map[string]ChainAdapter
+}
+
+// NewMultiChain routes plain addresses to def and prefixed addresses to the
+// matching adapter
+func NewMultiChain(def ChainAdapter, adapters ...ChainAdapter) *MultiChain {
+	mc := &MultiChain{Default: def, adapters: map[string]ChainAdapter{def.Chain(): def}}
+	for _, adapter := range adapters {
+		mc.adapters[adapter.Chain()] = adapter
+	}
+	return mc
+}
+
+// GetBalance implements BalanceSource
+func (mc *MultiChain) GetBalance(address string) (int64, error) {
+	chain, plain := SplitAddress(address)
+	if chain == "" {
+		return mc.Default.GetBalance(plain)
+	}
+	adapter, ok := mc.adapters[chain]
+	if !ok {
+		return 0, fmt.Errorf("no adapter for chain %q", chain)
+	}
+	return adapter.GetBalance(plain)
+}
+
+// SplitAddress splits "chain:address" into its parts; plain addresses have
+// an empty chain
+func SplitAddress(address string) (chain, plain string) {
+	if chain, plain, ok := strings.Cut(address, ":"); ok {
+		return chain, plain
+	}
+	return "", address
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Circuits reports the health of the endpoints behind the balance source
+// as seen by a circuit breaker, see rpc.Breaker
+type Circuits interface {
+	// Circuits returns the last error of each endpoint whose circuit is
+	// open, and "" for each whose last request succeeded; endpoints in
+	// neither state are left out
+	Circuits() map[string]string
+}
+
+// circuitOpenError is implemented by errors of requests a circuit breaker
+// refused without sending, such as rpc.CircuitOpenError
+type circuitOpenError interface {
+	CircuitOpen() bool
+}
+
+// isCircuitOpen reports whether err is a request refused by an open
+// circuit, which checks don't log one by one
+func isCircuitOpen(err error) bool {
+	var open circuitOpenError
+	return errors.As(err, &open) && open.CircuitOpen()
+}
+
+// checkCircuits records which endpoints have open circuits, for metrics,
+// and with AlertOnCircuits alerts once when each opens and once when it
+// closes. An endpoint only counts as recovered once it has answered, so a
+// restart during an outage doesn't report it recovered. Callers must hold
+// m.mu.
+func (m *Monitor) checkCircuits() {
+	if m.Circuits == nil {
+		return
+	}
+	circuits := m.Circuits.Circuits()
+	endpoints := make([]string, 0, len(circuits))
+	for endpoint := range circuits {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	now := m.now()
+	for _, endpoint := range endpoints {
+		lastError := circuits[endpoint]
+		since, wasOpen := m.state.OpenCircuits[endpoint]
+		switch {
+		case lastError != "" && !wasOpen:
+			if m.state.OpenCircuits == nil {
+				m.state.OpenCircuits = map[string]int64{}
+			}
+			m.state.OpenCircuits[endpoint] = now.Unix()
+			if m.AlertOnCircuits {
+				m.notifyAlert(notify.Alert{
+					Emoji:    "🔌",
+					Title:    "RPC endpoint failing",
+					Rule:     RuleRPC,
+					Severity: notify.SeverityCritical,
+					Fields: []notify.Field{
+						{Name: "Endpoint", Value: endpoint},
+						{Name: "Error", Value: lastError},
+					},
+					Time: now,
+				})
+			}
+		case lastError == "" && wasOpen:
+			delete(m.state.OpenCircuits, endpoint)
+			if m.AlertOnCircuits {
+				down := time.Unix(since, 0)
+				m.notifyAlert(notify.Alert{
+					Emoji:    "✅",
+					Title:    "RPC endpoint recovered",
+					Rule:     RuleRPC,
+					Severity: notify.SeverityInfo,
+					Fields: []notify.Field{
+						{Name: "Endpoint", Value: endpoint},
+						{Name: "Offline", Value: fmt.Sprintf("since %s (%s)", down.Format(time.RFC3339), now.Sub(down).Round(time.Second))},
+					},
+					Time: now,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package monitor
+
+import "log"
+
+// Cluster splits the watchlist between several instances of the alerter,
+// so each address is checked, and alerted on, by exactly one of them
+type Cluster interface {
+	// Owns reports whether this instance checks an address, or a wallet
+	// given as "wallet <name>"
+	Owns(key string) bool
+	// Peers returns the state last saved by every other instance, including
+	// ones that have stopped, whose addresses this one may have taken over
+	Peers() []Peer
+}
+
+// Peer is another instance of a Cluster
+type Peer struct {
+	ID       string
+	Live     bool // Whether it is still running
+	State    State
+	Notified NotifiedStore // Its record of reported transactions, if any
+}
+
+// owned returns those of addresses this instance checks: the ones the
+// cluster assigns to it, plus runtime-watched ones, which stay with the
+// instance they were added on. An address that moved here takes over the
+// state of the instance that checked it last, so the move doesn't alert
+// as a newly watched address. One that moved away keeps its state until
+// its new owner has checked it, so the owner can take it over, and is
+// dropped then. Callers must hold m.mu.
+func (m *Monitor) owned(addresses []string) []string {
+	if m.Cluster == nil {
+		return addresses
+	}
+	runtime := map[string]bool{}
+	for _, address := range m.state.WatchedAddresses {
+		runtime[address] = true
+	}
+	var peers *peerIndex
+	loadPeers := func() *peerIndex {
+		if peers == nil {
+			peers = newPeerIndex(m.Cluster.Peers(), false)
+		}
+		return peers
+	}
+	kept := addresses[:0:0]
+	for _, address := range addresses {
+		if !runtime[address] && !m.Cluster.Owns(address) {
+			delete(m.claimed, address)
+			if m.state.Balances.Has(address) {
+				if from, ok := loadPeers().latest(address); ok && from.Live && m.staler(address, from) {
+					log.Printf("%s moved to instance %s", address, from.ID)
+					m.drop(address)
+				}
+			}
+			continue
+		}
+		// Peers only need reading when an address has just become this
+		// instance's, not on every check
+		if !m.state.Balances.Has(address) || !m.claimed[address] {
+			if from, ok := loadPeers().latest(address); ok && (!m.state.Balances.Has(address) || m.staler(address, from)) {
+				log.Printf("Taking over %s from instance %s", address, from.ID)
+				m.drop(address)
+				m.adopt(address, from.State)
+				m.adoptNotified(address, from.Notified)
+			}
+		}
+		if m.claimed == nil {
+			m.claimed = map[string]bool{}
+		}
+		m.claimed[address] = true
+		kept = append(kept, address)
+	}
+	return kept
+}
+
+// staler reports whether a peer checked address successfully more recently
+// than this instance; callers must hold m.mu
+func (m *Monitor) staler(address string, peer Peer) bool {
+	return peer.State.AddressStatus[address].LastSuccess > m.state.AddressStatus[address].LastSuccess
+}
+
+// ownedWallets returns the wallets this instance checks, taking over and
+// dropping their derived addresses like owned; callers must hold m.mu
+func (m *Monitor) ownedWallets() []Wallet {
+	if m.Cluster == nil {
+		return m.Wallets
+	}
+	var peers *peerIndex
+	var kept []Wallet
+	for _, w := range m.Wallets {
+		derived := m.state.WalletAddresses[w.Name]
+		if !m.Cluster.Owns("wallet " + w.Name) {
+			if len(derived) > 0 {
+				log.Printf("Wallet %s moved to another instance", w.Name)
+				for _, address := range derived {
+					m.drop(address)
+				}
+				delete(m.state.WalletAddresses, w.Name)
+			}
+			continue
+		}
+		if len(derived) == 0 {
+			if peers == nil {
+				peers = newPeerIndex(m.Cluster.Peers(), false)
+			}
+			if from, ok := peers.wallet(w.Name); ok {
+				log.Printf("Taking over wallet %s from instance %s", w.Name, from.ID)
+				if m.state.WalletAddresses == nil {
+					m.state.WalletAddresses = map[string][]string{}
+				}
+				m.state.WalletAddresses[w.Name] = append([]string{}, from.State.WalletAddresses[w.Name]...)
+				for _, address := range from.State.WalletAddresses[w.Name] {
+					m.adopt(address, from.State)
+				}
+			}
+		}
+		kept = append(kept, w)
+	}
+	return kept
+}
+
+// withPeers runs fn with the addresses and wallets that live instances
+// check added to the state, so summaries and reports cover the whole
+// cluster, and drops them again afterwards; callers must hold m.mu
+func (m *Monitor) withPeers(fn func()) {
+	if m.Cluster == nil {
+		fn()
+		return
+	}
+	peers := newPeerIndex(m.Cluster.Peers(), true)
+	var adopted, wallets []string
+	for address := range peers.addresses {
+		from, _ := peers.latest(address)
+		if m.state.Balances.Has(address) {
+			if m.Cluster.Owns(address) || !m.staler(address, from) {
+				continue
+			}
+			m.drop(address) // A stale copy of an address that moved away
+		}
+		m.adopt(address, from.State)
+		adopted = append(adopted, address)
+	}
+	for name := range peers.wallets {
+		if _, ok := m.state.WalletAddresses[name]; !ok {
+			from, _ := peers.wallet(name)
+			if m.state.WalletAddresses == nil {
+				m.state.WalletAddresses = map[string][]string{}
+			}
+			m.state.WalletAddresses[name] = from.State.WalletAddresses[name]
+			wallets = append(wallets, name)
+		}
+	}
+	defer func() {
+		for _, address := range adopted {
+			m.drop(address)
+		}
+		for _, name := range wallets {
+			delete(m.state.WalletAddresses, name)
+		}
+	}()
+	fn()
+}
+
+// peerIndex finds which peer holds the freshest state of each address and
+// wallet
+type peerIndex struct {
+	peers     []Peer
+	addresses map[string]int // Index of the peer that checked it successfully last
+	wallets   map[string]int // Index of the peer with the most derived addresses
+}
+
+// newPeerIndex indexes the state of peers, or of the live ones only
+func newPeerIndex(peers []Peer, liveOnly bool) *peerIndex {
+	index := &peerIndex{addresses: map[string]int{}, wallets: map[string]int{}}
+	for _, peer := range peers {
+		if liveOnly && !peer.Live {
+			continue
+		}
+		i := len(index.peers)
+		index.peers = append(index.peers, peer)
+		for _, b := range peer.State.Balances.List() {
+			if j, ok := index.addresses[b.Address]; ok && index.peers[j].State.AddressStatus[b.Address].LastSuccess >= peer.State.AddressStatus[b.Address].LastSuccess {
+				continue
+			}
+			index.addresses[b.Address] = i
+		}
+		for name, derived := range peer.State.WalletAddresses {
+			if j, ok := index.wallets[name]; ok && len(index.peers[j].State.WalletAddresses[name]) >= len(derived) {
+				continue
+			}
+			index.wallets[name] = i
+		}
+	}
+	return index
+}
+
+// latest returns the peer that checked address successfully most recently
+func (p *peerIndex) latest(address string) (Peer, bool) {
+	i, ok := p.addresses[address]
+	if !ok {
+		return Peer{}, false
+	}
+	return p.peers[i], true
+}
+
+// wallet returns the peer that derived the most addresses of a wallet
+func (p *peerIndex) wallet(name string) (Peer, bool) {
+	i, ok := p.wallets[name]
+	if !ok {
+		return Peer{}, false
+	}
+	return p.peers[i], true
+}
+
+// adopt copies the stored balance, mute and other state of an address from
+// another instance's state, the reverse of drop; callers must hold m.mu
+func (m *Monitor) adopt(address string, from State) {
+	if b, ok := from.Balances.Get(address); ok {
+		m.state.Balances.Set(b)
+	}
+	copyEntry(&m.state.MutedUntil, from.MutedUntil, address)
+	copyEntry(&m.state.BalanceHistory, from.BalanceHistory, address)
+	copyEntry(&m.state.CostBasis, from.CostBasis, address)
+	copyEntry(&m.state.SeenTransactions, from.SeenTransactions, address)
+	copyEntry(&m.state.UTXOs, from.UTXOs, address)
+	copyEntry(&m.state.LockedBalances, from.LockedBalances, address)
+	copyEntry(&m.state.AddressStatus, from.AddressStatus, address)
+	copyEntry(&m.state.PendingZero, from.PendingZero, address)
+	copyEntry(&m.state.Flapping, from.Flapping, address)
+	copyEntry(&m.state.LastActivity, from.LastActivity, address)
+	copyEntry(&m.state.Signers, from.Signers, address)
+}
+
+// copyEntry copies the entry for key from one map to another, creating the
+// destination map if needed
+func copyEntry[V any](to *map[string]V, from map[string]V, key string) {
+	value, ok := from[key]
+	if !ok {
+		return
+	}
+	if *to == nil {
+		*to = map[string]V{}
+	}
+	(*to)[key] = value
+}
@@ -0,0 +1,67 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// DefaultCombineMaxRows is how many addresses a combined change alert lists
+// before the rest overflow into another message
+const DefaultCombineMaxRows = 20
+
+// notifyCombined sends the changes found by one check cycle as combined
+// alerts of at most CombineMaxRows addresses each, or as individual alerts
+// when fewer than CombineChanges addresses changed; callers must hold m.mu
+func (m *Monitor) notifyCombined(changes []notify.Change) {
+	if len(changes) < m.CombineChanges {
+		for _, change := range changes {
+			m.notifyChange(change)
+		}
+		return
+	}
+	rows := m.CombineMaxRows
+	if rows <= 0 {
+		rows = DefaultCombineMaxRows
+	}
+
+	// The combined alert is as severe as its most severe change
+	var net int64
+	var moved bool // Whether any change isn't a first balance
+	rule, severity := "", notify.SeverityDefault
+	for _, change := range changes {
+		if !change.Initial {
+			net += change.Delta()
+			moved = true
+		}
+		changeRule, def := changeRule(change)
+		if s := m.severity(changeRule, def); s > severity {
+			rule, severity = changeRule, s
+		}
+	}
+
+	now := m.now()
+	pages := (len(changes) + rows - 1) / rows
+	for page := 0; page < pages; page++ {
+		title := fmt.Sprintf("%d balance changes", len(changes))
+		if pages > 1 {
+			title += fmt.Sprintf(" (%d/%d)", page+1, pages)
+		}
+		var fields []notify.Field
+		if page == 0 && moved {
+			fields = append(fields, notify.Field{Name: "Net Change", Value: notify.DirectionEmoji(net) + " " + m.Format.Delta(net)})
+		}
+		end := min((page+1)*rows, len(changes))
+		for _, change := range changes[page*rows : end] {
+			fields = append(fields, m.changeField(change))
+		}
+		m.notifyAlert(notify.Alert{
+			Emoji:    m.branding().ChangeEmoji,
+			Title:    title,
+			Rule:     rule,
+			Severity: severity,
+			Fields:   fields,
+			Time:     now,
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package monitor
+
+import (
+	"log"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+)
+
+// CostBasis is the acquisition cost of the part of an address's balance
+// whose cost is known, using the average cost method
+type CostBasis struct {
+	Cost       float64 `json:"cost"`                 // In CostCurrency
+	Nick       int64   `json:"nick"`                 // Amount the cost covers
+	Configured float64 `json:"configured,omitempty"` // Cost it was seeded from, see Monitor.CostBasis
+}
+
+// applyConfiguredCost resets the cost basis of addresses whose configured
+// cost changed since it was last applied; callers must hold m.mu
+func (m *Monitor) applyConfiguredCost() {
+	for _, b := range m.state.Balances.List() {
+		configured, ok := m.CostBasis[b.Address]
+		if !ok || m.state.CostBasis[b.Address].Configured == configured {
+			continue
+		}
+		m.setCostBasis(b.Address, CostBasis{Cost: configured, Nick: b.CurrentBalance, Configured: configured})
+	}
+}
+
+// updateCostBasis adjusts an address's cost basis for a balance change.
+// Receipts are added at the current price, or left uncovered if there is no
+// price, and spends reduce the basis proportionally. Callers must hold m.mu.
+func (m *Monitor) updateCostBasis(address string, oldBalance, newBalance int64, initial bool, quote price.Quote) {
+	if m.CostCurrency == "" {
+		return
+	}
+	if configured, ok := m.CostBasis[address]; ok && initial {
+		m.setCostBasis(address, CostBasis{Cost: configured, Nick: newBalance, Configured: configured})
+		return
+	}
+
+	basis := m.state.CostBasis[address]
+	delta := newBalance - oldBalance
+	switch {
+	case delta > 0:
+		unitPrice, ok := quote[m.CostCurrency]
+		if !ok {
+			log.Printf("No %s price for %s, leaving received amount out of its cost basis", m.CostCurrency, address)
+			return
+		}
+		basis.Cost += m.Format.ToUnits(delta) * unitPrice
+		basis.Nick += delta
+	case delta < 0 && basis.Nick > 0:
+		remaining := basis.Nick + delta
+		if remaining < 0 {
+			remaining = 0
+		}
+		basis.Cost *= float64(remaining) / float64(basis.Nick)
+		basis.Nick = remaining
+	default:
+		return
+	}
+	m.setCostBasis(address, basis)
+}
+
+// setCostBasis stores an address's cost basis; callers must hold m.mu
+func (m *Monitor) setCostBasis(address string, basis CostBasis) {
+	if m.state.CostBasis == nil {
+		m.state.CostBasis = map[string]CostBasis{}
+	}
+	m.state.CostBasis[address] = basis
+}
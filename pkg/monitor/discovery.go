@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"log"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Address discovery modes
+const (
+	DiscoveryOff     = ""
+	DiscoverySuggest = "suggest" // Alert once about each new related address
+	DiscoveryAuto    = "auto"    // Add new related addresses to the watchlist
+)
+
+// AddressDiscoverer is implemented by balance sources that can list the
+// other addresses in an address's transactions, such as change addresses
+type AddressDiscoverer interface {
+	RelatedAddresses(address string) ([]string, error)
+}
+
+// RelatedAddresses implements AddressDiscoverer by asking the address's
+// adapter, if it supports discovery
+func (mc *MultiChain) RelatedAddresses(address string) ([]string, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	discoverer, ok := adapter.(AddressDiscoverer)
+	if !ok {
+		return nil, nil
+	}
+	related, err := discoverer.RelatedAddresses(plain)
+	if chain != "" {
+		for i := range related {
+			related[i] = chain + ":" + related[i]
+		}
+	}
+	return related, err
+}
+
+// discover looks for unwatched addresses in the transactions of an address
+// whose balance changed, and suggests or watches each one once, except
+// known third-party addresses; callers must hold m.mu
+func (m *Monitor) discover(address string) {
+	discoverer, ok := m.Source.(AddressDiscoverer)
+	if !ok || m.Discovery == DiscoveryOff {
+		return
+	}
+	related, err := discoverer.RelatedAddresses(address)
+	if err != nil {
+		log.Printf("Error discovering addresses related to %s: %v", address, err)
+		return
+	}
+
+	now := m.now()
+	for _, candidate := range related {
+		if m.isWatched(candidate) || m.walletOf(candidate) != "" || m.KnownAddresses[candidate] != "" || m.state.DiscoveredAddresses[candidate] != 0 {
+			continue
+		}
+		if m.state.DiscoveredAddresses == nil {
+			m.state.DiscoveredAddresses = map[string]int64{}
+		}
+		m.state.DiscoveredAddresses[candidate] = now.Unix()
+
+		alert := notify.Alert{
+			Emoji:    "🔎",
+			Title:    "New related address",
+			Rule:     RuleDiscovery,
+			Severity: notify.SeverityInfo,
+			Fields: []notify.Field{
+				{Name: "Address", Value: candidate},
+				{Name: "Seen In", Value: address + labelText(m.Labels[address])},
+			},
+			Time: now,
+		}
+		if m.Discovery == DiscoveryAuto {
+			m.state.WatchedAddresses = append(m.state.WatchedAddresses, candidate)
+			alert.Fields = append(alert.Fields, notify.Field{Name: "Action", Value: "Added to the watchlist"})
+		} else {
+			alert.Fields = append(alert.Fields, notify.Field{Name: "Action", Value: "Watch it with /watch or POST /api/watchlist"})
+		}
+		m.notifyAlert(alert)
+	}
+}
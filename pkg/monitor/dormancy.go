@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// ActivitySource is implemented by balance sources that report when the
+// newest transaction touching an address was made, so the dormancy of an
+// address first seen by the monitor can be dated from the chain
+type ActivitySource interface {
+	// LastActivity returns the time of the newest transaction, or the
+	// zero time if it is unknown
+	LastActivity(address string) (time.Time, error)
+}
+
+// LastActivity implements ActivitySource by asking the address's adapter,
+// if it reports transaction times
+func (mc *MultiChain) LastActivity(address string) (time.Time, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(ActivitySource)
+	if !ok {
+		return time.Time{}, nil
+	}
+	return source.LastActivity(plain)
+}
+
+// recordActivity notes that the balance of an address moved at now and
+// returns when it last moved before, from the recorded activity or else
+// the balance's last change at since. A new address is dated from its
+// newest transaction if the source reports it. Callers must hold m.mu.
+func (m *Monitor) recordActivity(address string, initial bool, since int64, now time.Time) time.Time {
+	if m.DormantAfter <= 0 {
+		return time.Time{}
+	}
+	if m.state.LastActivity == nil {
+		m.state.LastActivity = map[string]int64{}
+	}
+	if initial {
+		moved := now
+		if source, ok := m.Source.(ActivitySource); ok {
+			last, err := source.LastActivity(address)
+			if err != nil {
+				log.Printf("Error fetching the last activity of %s: %v", address, err)
+			} else if !last.IsZero() && last.Before(now) {
+				moved = last
+			}
+		}
+		m.state.LastActivity[address] = moved.Unix()
+		return time.Time{}
+	}
+	previous, ok := m.state.LastActivity[address]
+	if !ok {
+		previous = since
+	}
+	m.state.LastActivity[address] = now.Unix()
+	return unixTime(previous)
+}
+
+// checkDormant alerts when funds leave an address whose balance hadn't
+// moved for at least DormantAfter, the classic sign of a compromised cold
+// wallet; callers must hold m.mu
+func (m *Monitor) checkDormant(change notify.Change, lastMoved time.Time) {
+	if m.DormantAfter <= 0 || change.Initial || change.Delta() >= 0 || lastMoved.IsZero() {
+		return
+	}
+	dormant := change.Time.Sub(lastMoved)
+	if dormant < m.DormantAfter {
+		return
+	}
+	fields := []notify.Field{
+		{Name: "Address", Value: change.Address + labelText(change.Label)},
+		{Name: "Dormant For", Value: formatDays(dormant)},
+		{Name: "Last Activity", Value: lastMoved.UTC().Format("2006-01-02")},
+		{Name: "Moved", Value: m.Format.Balance(-change.Delta())},
+		{Name: "Balance", Value: m.Format.Balance(change.NewBalance)},
+	}
+	if len(change.SentTo) > 0 {
+		fields = append(fields, notify.Field{Name: "Sent To", Value: notify.FormatCounterparties(change.SentTo)})
+	}
+	m.notifyAlert(notify.Alert{
+		Emoji:    "🧊",
+		Title:    "Dormant funds moved",
+		Rule:     RuleDormant,
+		Severity: notify.SeverityCritical,
+		Fields:   fields,
+		Time:     change.Time,
+	})
+}
+
+// formatDays formats a long duration in whole days, e.g. "412 days"
+func formatDays(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
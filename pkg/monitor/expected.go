@@ -0,0 +1,122 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// MaxInflowWindow is the longest window an expected inflow can be checked
+// over, as balance history isn't kept for longer
+const MaxInflowWindow = historyRetention
+
+// ExpectedInflow is how much an address should receive over a trailing
+// window, e.g. at least 50 $NOCK a day from a pool
+type ExpectedInflow struct {
+	Amount int64         // nick
+	Window time.Duration // At most MaxInflowWindow
+}
+
+// InflowStatus compares what an address received over the window of its
+// expected inflow with the expectation
+type InflowStatus struct {
+	Address    string        `json:"address"`
+	Label      string        `json:"label,omitempty"`
+	Expected   int64         `json:"expected"` // nick
+	Received   int64         `json:"received"` // nick
+	Window     time.Duration `json:"-"`
+	WindowSecs int64         `json:"windowSeconds"`
+	Short      bool          `json:"short"`
+	// Evaluated is false until the balance history covers the whole window
+	Evaluated bool `json:"evaluated"`
+}
+
+// InflowStatuses returns how every address with an expected inflow is
+// doing against it
+func (m *Monitor) InflowStatuses() []InflowStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	var statuses []InflowStatus
+	for _, address := range m.watchedAddresses() {
+		if expected, ok := m.ExpectedInflows[address]; ok {
+			statuses = append(statuses, m.inflowStatus(address, expected, now))
+		}
+	}
+	return statuses
+}
+
+// inflowStatus reconciles an address's received amount with its expected
+// inflow; callers must hold m.mu
+func (m *Monitor) inflowStatus(address string, expected ExpectedInflow, now time.Time) InflowStatus {
+	status := InflowStatus{Address: address, Label: m.Labels[address], Expected: expected.Amount, Window: expected.Window, WindowSecs: int64(expected.Window.Seconds())}
+	history := m.state.BalanceHistory[address]
+	if len(history) == 0 || history[0].Time > now.Add(-expected.Window).Unix() {
+		return status
+	}
+	status.Evaluated = true
+	status.Received = m.periodFlow(address, expected.Window, now).Received
+	status.Short = status.Received < expected.Amount
+	return status
+}
+
+// CheckExpectedInflows alerts once when an address received less than its
+// expected inflow over the window, and once more when it is back on
+// schedule. Addresses are only checked once their balance history covers
+// the whole window.
+func (m *Monitor) CheckExpectedInflows() {
+	if len(m.ExpectedInflows) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	changed := false
+	for _, address := range m.watchedAddresses() {
+		expected, ok := m.ExpectedInflows[address]
+		if !ok {
+			continue
+		}
+		status := m.inflowStatus(address, expected, now)
+		_, alerted := m.state.InflowShortfalls[address]
+		if !status.Evaluated || status.Short == alerted {
+			continue
+		}
+
+		if status.Short {
+			if m.state.InflowShortfalls == nil {
+				m.state.InflowShortfalls = map[string]int64{}
+			}
+			m.state.InflowShortfalls[address] = now.Unix()
+		} else {
+			delete(m.state.InflowShortfalls, address)
+		}
+		changed = true
+		if m.isMuted(address, now) {
+			continue
+		}
+		alert := notify.Alert{
+			Emoji:    "📥",
+			Title:    "Inflow below expectation",
+			Rule:     RuleInflow,
+			Severity: notify.SeverityWarning,
+			Fields: []notify.Field{
+				{Name: "Address", Value: address + labelText(status.Label)},
+				{Name: "Expected", Value: fmt.Sprintf("%s per %s", m.Format.Balance(status.Expected), formatWindow(expected.Window))},
+				{Name: "Received", Value: m.Format.Balance(status.Received)},
+			},
+			Time: now,
+		}
+		if status.Short {
+			alert.Fields = append(alert.Fields, notify.Field{Name: "Shortfall", Value: m.Format.Balance(status.Expected - status.Received)})
+		} else {
+			alert.Emoji, alert.Title, alert.Severity = "✅", "Inflow back on schedule", notify.SeverityInfo
+		}
+		m.notifyAlert(alert)
+	}
+	if changed {
+		m.save()
+	}
+}
@@ -0,0 +1,119 @@
+package monitor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// DefaultFlapWindow is how recent balance changes must be to count towards
+// flapping, and how long readings must stay put before alerts resume
+const DefaultFlapWindow = 15 * time.Minute
+
+// flapSamples is how many readings within the flap window, alternating
+// between at most two values, mark an address as flapping
+const flapSamples = 4
+
+// FlapState records an address whose balance readings are flapping
+type FlapState struct {
+	Since    int64 `json:"since"`
+	Reported int64 `json:"reported"` // Balance as of the last change alert sent
+}
+
+// checkFlapping detects balances oscillating between two values and reports
+// whether the change alert for this check should be suppressed. The first
+// flap sends one "unstable readings" warning; once the balance has stayed
+// put for the flap window, a "stable again" alert is sent along with one
+// change alert if the settled balance differs from the last one reported.
+// Callers must hold m.mu.
+func (m *Monitor) checkFlapping(result CheckResult, change notify.Change) bool {
+	if m.FlapWindow <= 0 {
+		return false
+	}
+	address := result.Address
+	now := m.now()
+	recent := samplesSince(m.state.BalanceHistory[address], now.Add(-m.FlapWindow))
+	fields := []notify.Field{{Name: "Address", Value: address + labelText(m.Labels[address])}}
+
+	if flap, flapping := m.state.Flapping[address]; flapping {
+		if len(recent) > 0 {
+			return result.Changed
+		}
+		delete(m.state.Flapping, address)
+		m.notifyAlert(notify.Alert{
+			Emoji:    "✅",
+			Title:    "Balance readings stable again",
+			Rule:     RuleFlapping,
+			Severity: notify.SeverityInfo,
+			Fields: append(fields,
+				notify.Field{Name: "Balance", Value: m.Format.Balance(result.CurrentBalance)},
+				notify.Field{Name: "Unstable For", Value: formatWindow(now.Sub(time.Unix(flap.Since, 0)).Round(time.Minute))},
+			),
+			Time: now,
+		})
+		if result.CurrentBalance != flap.Reported {
+			m.reportChange(notify.Change{
+				Address:    address,
+				Label:      m.Labels[address],
+				OldBalance: flap.Reported,
+				NewBalance: result.CurrentBalance,
+				Time:       now,
+				Quote:      m.quote(),
+			})
+		}
+		return true
+	}
+
+	if !result.Changed || change.Initial {
+		return false
+	}
+	values, ok := flapValues(recent, m.Format)
+	if !ok {
+		return false
+	}
+	if m.state.Flapping == nil {
+		m.state.Flapping = map[string]FlapState{}
+	}
+	m.state.Flapping[address] = FlapState{Since: now.Unix(), Reported: change.OldBalance}
+	m.notifyAlert(notify.Alert{
+		Emoji:    "⚠️",
+		Title:    "Unstable balance readings",
+		Rule:     RuleFlapping,
+		Severity: notify.SeverityWarning,
+		Fields: append(fields,
+			notify.Field{Name: "Readings", Value: strings.Join(values, " ↔ ")},
+			notify.Field{Name: "Changes", Value: fmt.Sprintf("%d in the last %s", len(recent)-1, formatWindow(m.FlapWindow))},
+			notify.Field{Name: "Alerts", Value: fmt.Sprintf("Paused until readings hold for %s", formatWindow(m.FlapWindow))},
+		),
+		Time: now,
+	})
+	return true
+}
+
+// samplesSince returns the samples recorded at or after t
+func samplesSince(history []BalanceSample, t time.Time) []BalanceSample {
+	i := len(history)
+	for i > 0 && history[i-1].Time >= t.Unix() {
+		i--
+	}
+	return history[i:]
+}
+
+// flapValues reports whether the samples alternate between at most two
+// values often enough to count as flapping, and returns those values
+func flapValues(samples []BalanceSample, format notify.Formatter) ([]string, bool) {
+	if len(samples) < flapSamples {
+		return nil, false
+	}
+	var values []string
+	seen := map[int64]bool{}
+	for _, sample := range samples {
+		if !seen[sample.Balance] {
+			seen[sample.Balance] = true
+			values = append(values, format.Balance(sample.Balance))
+		}
+	}
+	return values, len(values) <= 2
+}
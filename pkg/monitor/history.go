@@ -0,0 +1,257 @@
+package monitor
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/chart"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+)
+
+// Summary chart modes
+const (
+	ChartNone      = ""
+	ChartPortfolio = "portfolio" // One line for the total balance
+	ChartAddresses = "addresses" // One line per address
+)
+
+// historyRetention is how far back balance history is kept
+const historyRetention = 30 * 24 * time.Hour
+
+// summaryPeriods are the windows shown as change columns in summaries
+var summaryPeriods = []struct {
+	Name   string
+	Window time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+}
+
+// BalanceSample is a recorded balance; a sample is stored whenever the
+// balance changes, so the balance at any time is the latest sample before it
+type BalanceSample struct {
+	Time    int64       `json:"time"`
+	Balance int64       `json:"balance"`
+	Quote   price.Quote `json:"quote,omitempty"` // Fiat price of $NOCK when recorded, if known
+}
+
+// recordBalance appends a sample to an address's history and drops samples
+// older than the retention period; callers must hold m.mu
+func (m *Monitor) recordBalance(address string, balance int64, now time.Time, quote price.Quote) {
+	if m.state.BalanceHistory == nil {
+		m.state.BalanceHistory = map[string][]BalanceSample{}
+	}
+	history := append(m.state.BalanceHistory[address], BalanceSample{Time: now.Unix(), Balance: balance, Quote: quote})
+	m.state.BalanceHistory[address] = trimBalanceHistory(history, now.Add(-historyRetention))
+}
+
+// seedHistory records the stored balances of addresses that have no history
+// yet, e.g. from a state file written by an older version; callers must
+// hold m.mu
+func (m *Monitor) seedHistory() {
+	for _, b := range m.state.Balances.List() {
+		if len(m.state.BalanceHistory[b.Address]) == 0 {
+			m.recordBalance(b.Address, b.CurrentBalance, time.Unix(b.LastUpdated, 0), nil)
+		}
+	}
+}
+
+// periodChanges returns the change in an address's balance over each summary
+// period, skipping periods that reach back before its history starts;
+// callers must hold m.mu
+func (m *Monitor) periodChanges(address string, current int64, now time.Time) []notify.PeriodChange {
+	history := m.state.BalanceHistory[address]
+	var changes []notify.PeriodChange
+	for _, period := range summaryPeriods {
+		reference, ok := balanceAt(history, now.Add(-period.Window))
+		if !ok {
+			continue
+		}
+		changes = append(changes, notify.PeriodChange{Period: period.Name, Delta: current - reference})
+	}
+	return changes
+}
+
+// Transfer is a change in an address's balance between two checks, which
+// can be several transactions that arrived in between
+type Transfer struct {
+	Address string
+	Time    time.Time
+	Amount  int64       // nick, positive when received
+	Balance int64       // Balance after the transfer
+	Quote   price.Quote // Fiat price of $NOCK at the time, nil if unknown
+}
+
+// Transfers returns the balance changes in the history from since up to
+// until, oldest first. An address's first recorded balance isn't a
+// transfer.
+func (m *Monitor) Transfers(since, until time.Time) []Transfer {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var transfers []Transfer
+	for address, history := range m.state.BalanceHistory {
+		for i := 1; i < len(history); i++ {
+			at := time.Unix(history[i].Time, 0)
+			if at.Before(since) || at.After(until) {
+				continue
+			}
+			transfers = append(transfers, Transfer{
+				Address: address,
+				Time:    at,
+				Amount:  history[i].Balance - history[i-1].Balance,
+				Balance: history[i].Balance,
+				Quote:   history[i].Quote,
+			})
+		}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		if !transfers[i].Time.Equal(transfers[j].Time) {
+			return transfers[i].Time.Before(transfers[j].Time)
+		}
+		return transfers[i].Address < transfers[j].Address
+	})
+	return transfers
+}
+
+// periodFlow returns what an address received and sent over the trailing
+// period, adding up the increases and decreases between checks; callers
+// must hold m.mu
+func (m *Monitor) periodFlow(address string, period time.Duration, now time.Time) *notify.Flow {
+	flow := &notify.Flow{Period: formatWindow(period)}
+	history := m.state.BalanceHistory[address]
+	cutoff := now.Add(-period).Unix()
+	for i := 1; i < len(history); i++ {
+		if history[i].Time <= cutoff {
+			continue
+		}
+		if delta := history[i].Balance - history[i-1].Balance; delta > 0 {
+			flow.Received += delta
+		} else {
+			flow.Sent -= delta
+		}
+	}
+	return flow
+}
+
+// trend returns the latest points balance snapshots of an address, oldest
+// first; callers must hold m.mu
+func (m *Monitor) trend(address string, points int) []int64 {
+	history := m.state.BalanceHistory[address]
+	if len(history) > points {
+		history = history[len(history)-points:]
+	}
+	trend := make([]int64, len(history))
+	for i, sample := range history {
+		trend[i] = sample.Balance
+	}
+	return trend
+}
+
+// balanceAt returns the balance recorded at or before t
+func balanceAt(history []BalanceSample, t time.Time) (int64, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Time <= t.Unix() {
+			return history[i].Balance, true
+		}
+	}
+	return 0, false
+}
+
+// trimBalanceHistory drops samples older than cutoff, keeping the newest one
+// before it since it holds the balance at the cutoff
+func trimBalanceHistory(history []BalanceSample, cutoff time.Time) []BalanceSample {
+	for len(history) > 1 && history[1].Time <= cutoff.Unix() {
+		history = history[1:]
+	}
+	return history
+}
+
+// sendChart renders the balance history chart and posts it to every notifier
+// that supports images, or only the named ones if names isn't nil; callers
+// must hold m.mu
+func (m *Monitor) sendChart(names []string) {
+	now := m.now()
+	var series []chart.Series
+	unit := m.Format.Unit()
+	title := "Portfolio balance (" + unit + "), last 30 days"
+	if m.Chart == ChartAddresses {
+		series = m.addressSeries(now)
+		title = "Balances (" + unit + "), last 30 days"
+	} else {
+		series = []chart.Series{m.portfolioSeries(now)}
+	}
+	png, err := chart.PNG(title, series, now)
+	if err != nil {
+		log.Printf("Error rendering summary chart: %v", err)
+		return
+	}
+	for _, n := range m.notifiers {
+		sender, ok := n.(notify.ChartSender)
+		if !ok || names != nil && !containsName(names, n.Name()) {
+			continue
+		}
+		if err := sender.SendChart(png, "📈 "+title); err != nil {
+			log.Printf("Error sending %s chart: %v", n.Name(), err)
+			m.recordFailure(n.Name(), "summary", "", err)
+		}
+	}
+}
+
+// addressSeries returns the balance history of each address, clipped to the
+// retention period; callers must hold m.mu
+func (m *Monitor) addressSeries(now time.Time) []chart.Series {
+	cutoff := now.Add(-historyRetention)
+	var series []chart.Series
+	for _, b := range m.state.Balances.List() {
+		name := m.Labels[b.Address]
+		if name == "" {
+			name = b.Address
+		}
+		s := chart.Series{Name: name}
+		for _, sample := range m.state.BalanceHistory[b.Address] {
+			t := time.Unix(sample.Time, 0)
+			if t.Before(cutoff) {
+				t = cutoff
+			}
+			s.Points = append(s.Points, chart.Point{Time: t, Value: m.Format.ToUnits(sample.Balance)})
+		}
+		series = append(series, s)
+	}
+	return series
+}
+
+// portfolioSeries returns the total balance across addresses at every time
+// any of them changed; callers must hold m.mu
+func (m *Monitor) portfolioSeries(now time.Time) chart.Series {
+	cutoff := now.Add(-historyRetention)
+	var times []int64
+	seen := map[int64]bool{}
+	for _, b := range m.state.Balances.List() {
+		for _, sample := range m.state.BalanceHistory[b.Address] {
+			t := sample.Time
+			if t < cutoff.Unix() {
+				t = cutoff.Unix()
+			}
+			if !seen[t] {
+				seen[t] = true
+				times = append(times, t)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	s := chart.Series{Name: "Total"}
+	for _, t := range times {
+		var total int64
+		for _, b := range m.state.Balances.List() {
+			if balance, ok := balanceAt(m.state.BalanceHistory[b.Address], time.Unix(t, 0)); ok {
+				total += balance
+			}
+		}
+		s.Points = append(s.Points, chart.Point{Time: time.Unix(t, 0), Value: m.Format.ToUnits(total)})
+	}
+	return s
+}
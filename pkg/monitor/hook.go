@@ -0,0 +1,87 @@
+package monitor
+
+import (
+	"log"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Event kinds passed to a Hook
+const (
+	EventChange = "change" // Balance change alert
+	EventAlert  = "alert"  // Any other alert
+)
+
+// Event describes an alert about to be sent, for a Hook to decide on
+type Event struct {
+	Kind       string         `json:"kind"`
+	Rule       string         `json:"rule"`
+	Severity   string         `json:"severity"`
+	Address    string         `json:"address,omitempty"`
+	Label      string         `json:"label,omitempty"`
+	OldBalance int64          `json:"oldBalance,omitempty"` // nick, changes only
+	NewBalance int64          `json:"newBalance,omitempty"` // nick, changes only
+	Delta      int64          `json:"delta,omitempty"`      // nick, changes only
+	Initial    bool           `json:"initial,omitempty"`
+	Title      string         `json:"title,omitempty"` // Other alerts only
+	Fields     []notify.Field `json:"fields,omitempty"`
+	Tags       []string       `json:"tags,omitempty"`
+	Time       time.Time      `json:"time"`
+}
+
+// Decision is what a Hook decided about an event; the zero Decision sends
+// it as usual
+type Decision struct {
+	Suppress  bool     `json:"suppress"`
+	Severity  string   `json:"severity,omitempty"`  // Replaces the event's severity
+	Notifiers []string `json:"notifiers,omitempty"` // Only these notifiers by name, e.g. "Slack"; all if empty
+}
+
+// Hook runs custom logic on every alert before it is sent, to suppress it,
+// change its severity, or route it to some notifiers only
+type Hook interface {
+	Decide(event Event) (Decision, error)
+}
+
+// decide asks the hook about an event, sending it as usual if there is no
+// hook or the hook fails; callers must hold m.mu
+func (m *Monitor) decide(event Event) Decision {
+	if m.Hook == nil {
+		return Decision{}
+	}
+	decision, err := m.Hook.Decide(event)
+	if err != nil {
+		log.Printf("Error running alert hook, sending %s alert as usual: %v", event.Rule, err)
+		return Decision{}
+	}
+	return decision
+}
+
+// routed returns the notifiers a decision sends to; callers must hold m.mu
+func (m *Monitor) routed(decision Decision) []notify.Notifier {
+	if len(decision.Notifiers) == 0 {
+		return m.notifiers
+	}
+	var notifiers []notify.Notifier
+	for _, n := range m.notifiers {
+		if containsName(decision.Notifiers, n.Name()) {
+			notifiers = append(notifiers, n)
+		}
+	}
+	return notifiers
+}
+
+// applySeverity returns the severity a decision sets, or severity if it
+// sets none or an unknown one
+func applySeverity(decision Decision, severity notify.Severity) notify.Severity {
+	if decision.Severity == "" {
+		return severity
+	}
+	override, err := notify.ParseSeverity(decision.Severity)
+	if err != nil {
+		log.Printf("Ignoring alert hook severity: %v", err)
+		return severity
+	}
+	return override
+}
@@ -0,0 +1,88 @@
+package monitor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// idempotencyBucket is the time bucket alerts without a better identity are
+// keyed by: the same alert raised again within it counts as a re-send
+const idempotencyBucket = 10 * time.Minute
+
+// sentKeyRetention is how long sent idempotency keys are remembered
+const sentKeyRetention = 24 * time.Hour
+
+// idempotencyKey hashes the parts that identify an alert
+func idempotencyKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// changeKey identifies a balance change by the transactions that caused
+// it, or else by the balance it moved from, when that balance was
+// recorded, and the balance it moved to
+func changeKey(address string, oldBalance, newBalance, since int64, transactions []string) string {
+	if len(transactions) > 0 {
+		ids := append([]string{}, transactions...)
+		sort.Strings(ids)
+		return idempotencyKey(append([]string{"change", address}, ids...)...)
+	}
+	return idempotencyKey("change", address, fmt.Sprint(oldBalance), fmt.Sprint(newBalance), fmt.Sprint(since))
+}
+
+// alertKey identifies an alert by its content and time bucket
+func alertKey(alert notify.Alert) string {
+	parts := []string{"alert", alert.Rule, alert.Title, fmt.Sprint(alert.Time.Truncate(idempotencyBucket).Unix())}
+	for _, field := range alert.Fields {
+		parts = append(parts, field.Name, field.Value)
+	}
+	return idempotencyKey(parts...)
+}
+
+// entryKey returns the idempotency key of a queued or outgoing alert
+func entryKey(change *notify.Change, alert *notify.Alert) string {
+	if change != nil {
+		return change.Key
+	}
+	return alert.Key
+}
+
+// wasSent reports whether a notifier got the alert with key within
+// sentKeyRetention; callers must hold m.mu
+func (m *Monitor) wasSent(notifier, key string) bool {
+	at := m.state.SentKeys[notifier+" "+key]
+	return key != "" && at != 0 && m.now().Sub(time.Unix(at, 0)) <= sentKeyRetention
+}
+
+// markSent records that a notifier got the alert with key. A Store that is
+// a SentRecorder writes it to disk straight away, so a crash before the
+// state is next saved doesn't send the alert again; saving the whole state
+// per alert would be slow with many addresses. Keys older than
+// sentKeyRetention are dropped. Callers must hold m.mu.
+func (m *Monitor) markSent(notifier, key string) {
+	if key == "" {
+		return
+	}
+	now := m.now()
+	if m.state.SentKeys == nil {
+		m.state.SentKeys = map[string]int64{}
+	}
+	for sent, at := range m.state.SentKeys {
+		if now.Sub(time.Unix(at, 0)) > sentKeyRetention {
+			delete(m.state.SentKeys, sent)
+		}
+	}
+	m.state.SentKeys[notifier+" "+key] = now.Unix()
+	if recorder, ok := m.Store.(SentRecorder); ok {
+		if err := recorder.RecordSent(notifier+" "+key, now); err != nil {
+			log.Printf("Error recording sent alert %s: %v", key, err)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package monitor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// countingNotifier counts the alerts it is sent
+type countingNotifier struct {
+	name   string
+	alerts int
+}
+
+func (n *countingNotifier) Name() string                                  { return n.name }
+func (n *countingNotifier) NotifyChange(change notify.Change) error       { n.alerts++; return nil }
+func (n *countingNotifier) NotifySummary(balances []notify.Balance) error { return nil }
+func (n *countingNotifier) NotifyAlert(alert notify.Alert) error          { n.alerts++; return nil }
+
+func TestSentAlertsSurviveCrash(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		notifier string
+		key      string
+		later    time.Duration // When the restarted monitor delivers
+		want     int           // Alerts the restarted monitor sends
+	}{
+		{"same alert", "Slack", "k1", time.Minute, 0},
+		{"other notifier", "Telegram", "k1", time.Minute, 1},
+		{"other alert", "Slack", "k2", time.Minute, 1},
+		{"after retention", "Slack", "k1", sentKeyRetention + time.Hour, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := FileStore{Path: filepath.Join(t.TempDir(), "balances.json")}
+			now := start
+			clock := func() time.Time { return now }
+			sent := &countingNotifier{name: "Slack"}
+			m := &Monitor{Store: store, Clock: clock, notifiers: []notify.Notifier{sent}}
+			m.mu.Lock()
+			m.deliver(m.notifiers, nil, &notify.Alert{Title: "test", Key: "k1"})
+			m.mu.Unlock()
+			if sent.alerts != 1 {
+				t.Fatalf("first delivery sent %d alerts, want 1", sent.alerts)
+			}
+			// Crash without saving: no m.save()
+
+			now = start.Add(tt.later)
+			resent := &countingNotifier{name: tt.notifier}
+			restarted := &Monitor{Store: store, Clock: clock, notifiers: []notify.Notifier{resent}}
+			if err := restarted.Load(); err != nil {
+				t.Fatal(err)
+			}
+			restarted.mu.Lock()
+			restarted.deliver(restarted.notifiers, nil, &notify.Alert{Title: "test", Key: tt.key})
+			restarted.mu.Unlock()
+			if resent.alerts != tt.want {
+				t.Errorf("restarted monitor sent %d alerts, want %d", resent.alerts, tt.want)
+			}
+		})
+	}
+}
+
+func TestSentLogFoldedIntoState(t *testing.T) {
+	store := FileStore{Path: filepath.Join(t.TempDir(), "balances.json")}
+	m := &Monitor{Store: store}
+	m.mu.Lock()
+	m.markSent("Slack", "k1")
+	m.save()
+	m.mu.Unlock()
+	if _, err := os.Stat(store.sentPath()); !os.IsNotExist(err) {
+		t.Errorf("sent log still there after save: %v", err)
+	}
+
+	// A line cut short by a crash is skipped
+	if err := os.WriteFile(store.sentPath(), []byte("1700000000 Slack k2\n17000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.SentKeys["Slack k1"] == 0 || state.SentKeys["Slack k2"] != 1700000000 || len(state.SentKeys) != 2 {
+		t.Errorf("SentKeys = %v, want Slack k1 from the state and Slack k2 from the log", state.SentKeys)
+	}
+}
@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// knownAddresses is the shipped database of known third-party addresses
+//
+//go:embed known_addresses.txt
+var knownAddresses string
+
+// DefaultKnownAddresses returns the shipped database of known third-party
+// addresses, such as exchange deposit wallets, pools and bridges, by
+// address
+func DefaultKnownAddresses() map[string]string {
+	known, err := ParseKnownAddresses(strings.NewReader(knownAddresses))
+	if err != nil {
+		panic(err)
+	}
+	return known
+}
+
+// LoadKnownAddresses reads a known addresses file, adding its names to
+// known and replacing the names of addresses already in it
+func LoadKnownAddresses(path string, known map[string]string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	extra, err := ParseKnownAddresses(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for address, name := range extra {
+		known[address] = name
+	}
+	return nil
+}
+
+// ParseKnownAddresses parses a known addresses database: one address per
+// line followed by its name, with blank lines and lines starting with #
+// ignored
+func ParseKnownAddresses(r io.Reader) (map[string]string, error) {
+	known := map[string]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		address, name, _ := strings.Cut(text, " ")
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("line %d: expected an address followed by its name", line)
+		}
+		known[address] = name
+	}
+	return known, scanner.Err()
+}
+
+// counterparties returns the other addresses in the transactions of an
+// address whose balance went down, named after the operator's labels or
+// the known addresses, or nil if the source can't list them; callers must
+// hold m.mu
+func (m *Monitor) counterparties(address string) []notify.Counterparty {
+	discoverer, ok := m.Source.(AddressDiscoverer)
+	if !ok {
+		return nil
+	}
+	related, err := discoverer.RelatedAddresses(address)
+	if err != nil {
+		log.Printf("Error listing the recipients of %s: %v", address, err)
+		return nil
+	}
+	var parties []notify.Counterparty
+	for _, other := range related {
+		label := m.Labels[other]
+		if label == "" {
+			label = m.KnownAddresses[other]
+		}
+		parties = append(parties, notify.Counterparty{Address: other, Label: label, Known: m.KnownAddresses[other] != ""})
+	}
+	return parties
+}
@@ -0,0 +1,91 @@
+package monitor
+
+import (
+	"log"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// LockSource is implemented by balance sources that report how much of an
+// address's balance is locked or staked rather than liquid
+type LockSource interface {
+	// LockedBalance returns the locked part of the balance in nick
+	LockedBalance(address string) (int64, error)
+}
+
+// LockedBalance implements LockSource by asking the address's adapter, if
+// it reports locked balances
+func (mc *MultiChain) LockedBalance(address string) (int64, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(LockSource)
+	if !ok {
+		return 0, nil
+	}
+	return source.LockedBalance(plain)
+}
+
+// checkLocked refreshes the locked part of an address's balance and alerts
+// when it drops: as an unlock when the liquid part grew by as much, and as
+// a possible slashing otherwise. Callers must hold m.mu.
+func (m *Monitor) checkLocked(result CheckResult) {
+	source, ok := m.Source.(LockSource)
+	if !ok || !m.TrackLocked {
+		return
+	}
+	address := result.Address
+	locked, err := source.LockedBalance(address)
+	if err != nil {
+		log.Printf("Error fetching locked balance for %s: %v", address, err)
+		return
+	}
+
+	previous, known := m.state.LockedBalances[address]
+	if !known && locked == 0 {
+		return
+	}
+	if m.state.LockedBalances == nil {
+		m.state.LockedBalances = map[string]int64{}
+	}
+	m.state.LockedBalances[address] = locked
+
+	now := m.now()
+	if !known || locked >= previous || m.isMuted(address, now) {
+		return
+	}
+	decrease := previous - locked
+	liquidGain := (result.CurrentBalance - locked) - (result.PreviousBalance - previous)
+	fields := []notify.Field{{Name: "Address", Value: address + labelText(m.Labels[address])}}
+	if liquidGain >= decrease {
+		m.notifyAlert(notify.Alert{
+			Emoji:    "🔓",
+			Title:    "Funds unlocked",
+			Rule:     RuleUnlock,
+			Severity: notify.SeverityInfo,
+			Fields: append(fields,
+				notify.Field{Name: "Unlocked", Value: m.Format.Balance(decrease)},
+				notify.Field{Name: "Still Locked", Value: m.Format.Balance(locked)},
+				notify.Field{Name: "Liquid", Value: m.Format.Balance(result.CurrentBalance - locked)},
+			),
+			Time: now,
+		})
+		return
+	}
+
+	lost := decrease - max(liquidGain, 0)
+	m.notifyAlert(notify.Alert{
+		Emoji:    "⚠️",
+		Title:    "Locked balance decreased",
+		Rule:     RuleLockedDecrease,
+		Severity: notify.SeverityCritical,
+		Fields: append(fields,
+			notify.Field{Name: "Locked Change", Value: m.Format.Delta(-decrease)},
+			notify.Field{Name: "Not Returned to Liquid", Value: m.Format.Balance(lost)},
+			notify.Field{Name: "Still Locked", Value: m.Format.Balance(locked)},
+		),
+		Time: now,
+	})
+}
@@ -0,0 +1,100 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Metrics returns the stored balances and the status of the latest checks
+// in the Prometheus text exposition format, for pushing to a Pushgateway
+func (m *Monitor) Metrics() []byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+	gauge := func(name, help string) {
+		fmt.Fprintf(&buf, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+
+	gauge("nockchain_balance_nick", "Balance of the address in nick.")
+	for _, b := range m.state.Balances.List() {
+		fmt.Fprintf(&buf, "nockchain_balance_nick{%s} %d\n", m.metricLabels(b.Address), b.CurrentBalance)
+	}
+	if m.TrackLocked {
+		gauge("nockchain_balance_locked_nick", "Locked or staked part of the balance in nick.")
+		for _, b := range m.state.Balances.List() {
+			fmt.Fprintf(&buf, "nockchain_balance_locked_nick{%s} %d\n", m.metricLabels(b.Address), m.state.LockedBalances[b.Address])
+		}
+	}
+	gauge("nockchain_balance_last_change_timestamp_seconds", "When the balance last changed.")
+	for _, b := range m.state.Balances.List() {
+		fmt.Fprintf(&buf, "nockchain_balance_last_change_timestamp_seconds{%s} %d\n", m.metricLabels(b.Address), b.LastUpdated)
+	}
+
+	gauge("nockchain_check_up", "Whether the latest check of the address succeeded.")
+	for _, b := range m.state.Balances.List() {
+		up := 0
+		if status, ok := m.state.AddressStatus[b.Address]; ok && status.LastError == "" {
+			up = 1
+		}
+		fmt.Fprintf(&buf, "nockchain_check_up{%s} %d\n", m.metricLabels(b.Address), up)
+	}
+	gauge("nockchain_check_last_success_timestamp_seconds", "When the address was last checked successfully.")
+	for _, b := range m.state.Balances.List() {
+		fmt.Fprintf(&buf, "nockchain_check_last_success_timestamp_seconds{%s} %d\n", m.metricLabels(b.Address), m.state.AddressStatus[b.Address].LastSuccess)
+	}
+
+	if m.Circuits != nil {
+		gauge("nockchain_rpc_circuit_open", "Whether the circuit breaker has stopped querying the RPC endpoint after consecutive failures.")
+		endpoints := map[string]int{}
+		for endpoint, lastError := range m.Circuits.Circuits() {
+			if lastError == "" {
+				endpoints[endpoint] = 0
+			}
+		}
+		for endpoint := range m.state.OpenCircuits {
+			endpoints[endpoint] = 1
+		}
+		names := make([]string, 0, len(endpoints))
+		for endpoint := range endpoints {
+			names = append(names, endpoint)
+		}
+		sort.Strings(names)
+		for _, endpoint := range names {
+			fmt.Fprintf(&buf, "nockchain_rpc_circuit_open{endpoint=%s} %d\n", metricLabelValue(endpoint), endpoints[endpoint])
+		}
+	}
+
+	gauge("nockchain_alerter_last_check_timestamp_seconds", "When the alerter last finished checking every address.")
+	fmt.Fprintf(&buf, "nockchain_alerter_last_check_timestamp_seconds %d\n", m.state.LastChecked)
+	gauge("nockchain_alerter_delivery_queue_length", "Alerts waiting to be delivered.")
+	fmt.Fprintf(&buf, "nockchain_alerter_delivery_queue_length %d\n", len(m.state.Queue))
+	gauge("nockchain_alerter_delivery_failures", "Recent alerts that could not be delivered.")
+	fmt.Fprintf(&buf, "nockchain_alerter_delivery_failures %d\n", len(m.state.DeliveryFailures))
+	return buf.Bytes()
+}
+
+// metricLabels returns the Prometheus labels of an address; callers must
+// hold m.mu
+func (m *Monitor) metricLabels(address string) string {
+	labels := "address=" + metricLabelValue(address)
+	if label := m.Labels[address]; label != "" {
+		labels += ",label=" + metricLabelValue(label)
+	}
+	group := m.Groups[address]
+	if wallet := m.walletOf(address); wallet != "" && group == "" {
+		group = wallet
+	}
+	if group != "" {
+		labels += ",group=" + metricLabelValue(group)
+	}
+	return labels
+}
+
+// metricLabelValue quotes a label value, escaping backslashes, quotes and
+// newlines as the exposition format requires
+func metricLabelValue(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
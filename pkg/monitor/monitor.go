@@ -0,0 +1,1021 @@
+// Package monitor watches nockchain addresses for balance changes and
+// dispatches alerts and summaries to notifiers.
+package monitor
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+)
+
+// maxDeliveryFailures bounds how many recent delivery failures are kept
+const maxDeliveryFailures = 100
+
+var (
+	ErrAlreadyWatched    = errors.New("address is already being watched")
+	ErrNotWatched        = errors.New("address is not being watched")
+	ErrConfiguredAddress = errors.New("address is configured and can't be removed at runtime")
+	ErrNotOwned          = errors.New("address is checked by another instance of the cluster")
+)
+
+// BalanceSource looks up the current balance of an address in nick
+type BalanceSource interface {
+	GetBalance(address string) (int64, error)
+}
+
+// CheckResult reports the outcome of checking a single address
+type CheckResult struct {
+	Address         string `json:"address"`
+	PreviousBalance int64  `json:"previousBalance"`
+	CurrentBalance  int64  `json:"currentBalance"`
+	Changed         bool   `json:"changed"`
+	Error           string `json:"error,omitempty"`
+
+	// ZeroUnconfirmed is set when a non-zero balance read as zero and the
+	// next check has to confirm it before it counts as a change
+	ZeroUnconfirmed bool `json:"zeroUnconfirmed,omitempty"`
+
+	// Transactions lists new transaction IDs when transactions are tracked
+	Transactions []string `json:"transactions,omitempty"`
+
+	lastMoved time.Time // When the balance last moved before this change
+}
+
+// Monitor checks a watchlist of addresses and notifies on balance changes.
+// All methods are safe for concurrent use.
+type Monitor struct {
+	Source    BalanceSource
+	Store     Store
+	Addresses []string // Configured addresses; more can be added with Watch
+
+	// Patterns are watched address sets defined by the indexer, such as a
+	// prefix glob or a pool account, whose members are refreshed at the
+	// start of every CheckAll. They need a Source that implements
+	// AddressMatcher.
+	Patterns []string
+
+	// Wallets are extended public keys whose derived addresses are checked
+	// together and alerted as one balance
+	Wallets []Wallet
+
+	// Discovery controls what happens to unwatched addresses found in the
+	// transactions of watched ones, see the Discovery constants. It needs
+	// a Source that implements AddressDiscoverer.
+	Discovery string
+
+	// AlertOnTransactions alerts on every new transaction touching a
+	// watched address, even if it leaves the balance unchanged. It needs a
+	// Source that implements TransactionSource.
+	AlertOnTransactions bool
+
+	// TrackFees shows the network fee paid in outgoing change alerts, and
+	// MaxFee alerts when a transfer paid more than this many nick; 0
+	// disables. Both need a Source that implements TransactionSource and
+	// FeeSource.
+	TrackFees bool
+	MaxFee    int64
+
+	// ShowMemos adds the memos of new transactions, such as the round
+	// identifiers pools tag payouts with, to change alerts, sanitized. It
+	// needs a Source that implements TransactionSource and MemoSource.
+	ShowMemos bool
+
+	// TrackLocked tracks the locked or staked part of each balance, shows
+	// it in summaries, and alerts on unlocks and unexplained decreases. It
+	// needs a Source that implements LockSource.
+	TrackLocked bool
+
+	// TrackSigners tracks the signing configuration of each address, shows
+	// multisig thresholds in summaries, and alerts when the threshold or
+	// signer set changes. It needs a Source that implements SignerSource.
+	TrackSigners bool
+
+	// DedupeTransactions remembers which transactions alerts have reported,
+	// by address and transaction ID, in Notified, so restarts and
+	// overlapping rules never report the same on-chain event twice. It
+	// needs a Source that implements TransactionSource, and does nothing
+	// without Notified.
+	DedupeTransactions bool
+	Notified           NotifiedStore
+
+	// MaxUTXOs alerts once when an address holds more unspent outputs than
+	// this, and DustThreshold alerts when outputs smaller than this many
+	// nick arrive; 0 disables either. Both need a Source that implements
+	// UTXOSource.
+	MaxUTXOs      int
+	DustThreshold int64
+
+	// Labels maps addresses to human-readable names shown in alerts
+	Labels map[string]string
+
+	// KnownAddresses names third-party addresses such as exchange deposit
+	// wallets, which outgoing change alerts show as recipients and
+	// discovery never suggests; see DefaultKnownAddresses. Recipients need
+	// a Source that implements AddressDiscoverer.
+	KnownAddresses map[string]string
+
+	// Groups maps addresses to the group they are subtotalled under in summaries
+	Groups map[string]string
+
+	// Tags maps addresses to free-form tags such as hot, cold or pool.
+	// SummaryTags limits summaries, and AlertTags change and transaction
+	// alerts, to addresses carrying any of their tags; empty includes all.
+	// Derived wallet addresses inherit the tags of their wallet.
+	Tags        map[string][]string
+	SummaryTags []string
+	AlertTags   []string
+
+	// SummaryGroupBy chooses what summaries are subtotalled by, see the
+	// GroupBy constants
+	SummaryGroupBy string
+
+	// Prices adds fiat values to alerts and summaries when set
+	Prices price.Provider
+
+	// CostCurrency enables cost basis tracking in this quoted currency:
+	// received amounts are costed at the price when they arrive
+	CostCurrency string
+
+	// CostBasis optionally sets the acquisition cost of an address's
+	// balance, in CostCurrency, instead of costing it at the current price
+	CostBasis map[string]float64
+
+	// PriceRules configures alerts on the price itself, see CheckPrice
+	PriceRules PriceRules
+
+	// Node optionally polls a node operator's own nockchain node, alerting
+	// per NodeRules, see CheckNode
+	Node      NodeStatusSource
+	NodeRules NodeRules
+
+	// PayoutLatePercent makes CheckPayouts alert when an address's next
+	// payout is overdue by more than this percentage of its usual cadence;
+	// 0 disables
+	PayoutLatePercent float64
+
+	// ReportProjection adds to earnings reports what each address would
+	// receive over the next week and month at its earn rate of the last
+	// week
+	ReportProjection bool
+
+	// ExpectedInflows are how much addresses should receive over a
+	// trailing window; CheckExpectedInflows alerts when they fall short
+	ExpectedInflows map[string]ExpectedInflow
+
+	// SummarySort orders summary rows, see the notify.Sort constants
+	SummarySort string
+
+	// Chart posts a balance history chart with each summary to notifiers
+	// that implement notify.ChartSender, see the Chart constants
+	Chart string
+
+	// Format writes amounts in the monitor's own alerts, such as payout
+	// and locked balance alerts, and Branding sets their emojis; notifiers
+	// have their own for balance changes and summaries
+	Format   notify.Formatter
+	Branding notify.Branding
+
+	// Clock returns the current time; time.Now if nil. Replays set it to
+	// the time of each recorded check.
+	Clock func() time.Time
+
+	// PinnedSummary makes SendSummary edit a pinned message in place on
+	// notifiers that implement notify.Pinner
+	PinnedSummary bool
+
+	// SummaryStats follows each summary with the alerting activity since
+	// the previous one: alerts sent, RPC errors, the largest change and
+	// the net flow. Pinned summaries don't get it.
+	SummaryStats bool
+
+	// FlowPeriod adds what each address received and sent over this
+	// trailing period to summaries; 0 disables
+	FlowPeriod time.Duration
+
+	// SparklinePoints adds a sparkline of the latest this many balance
+	// snapshots next to each address in summaries; 0 disables
+	SparklinePoints int
+
+	// DormantAfter alerts when funds leave an address whose balance hadn't
+	// moved for at least this long, dated from the newest transaction for
+	// addresses first seen with a Source that implements ActivitySource;
+	// 0 disables
+	DormantAfter time.Duration
+
+	// Cluster, when set, splits the watchlist with other instances, and
+	// CheckAll and CheckShard only check this instance's share
+	Cluster Cluster
+
+	// CheckShards splits the watchlist into this many slices that
+	// CheckShard checks one at a time, spreading the RPC load of a large
+	// watchlist over the check interval; 0 or 1 means CheckAll checks
+	// every address at once
+	CheckShards int
+
+	// CycleDeadline bounds how long CheckAll spends checking addresses, or
+	// CheckShard each slice its share of it; addresses not reached in time
+	// are checked first the next time, and wallets only if time remains.
+	// 0 disables.
+	CycleDeadline time.Duration
+
+	// ShuffleChecks checks addresses in a new random order each cycle,
+	// instead of in the order they are watched
+	ShuffleChecks bool
+
+	// Circuits reports RPC endpoints a circuit breaker has stopped
+	// querying, which checks record for metrics and, with AlertOnCircuits,
+	// alert on when they fail and recover
+	Circuits        Circuits
+	AlertOnCircuits bool
+
+	// CatchUpAfter makes the first check after this much downtime send one
+	// catch-up message listing every change instead of individual alerts;
+	// 0 disables
+	CatchUpAfter time.Duration
+
+	mu        sync.Mutex
+	notifiers []notify.Notifier
+	archives  []notify.Notifier // Added with AddArchive
+	state     State
+	cache     snapshotCache    // For Cached and RefreshStale
+	claimed   map[string]bool  // Addresses Cluster assigned here at the last check
+	leftOver  map[int][]string // Addresses a cycle ran out of time for, by shard
+
+	pendingNotified map[string][]string // Reported transactions Notified failed to record, by address
+
+	// ConfirmZero requires two consecutive zero readings before a non-zero
+	// balance counts as emptied
+	ConfirmZero bool
+
+	// StaleAfter marks summary rows whose address hasn't been checked
+	// successfully for this long; 0 disables
+	StaleAfter time.Duration
+
+	// FlapWindow collapses balances that oscillate between two values within
+	// this window into one warning; 0 disables
+	FlapWindow time.Duration
+
+	// Severities overrides the severity of alert rules, see AlertRules
+	Severities map[string]notify.Severity
+
+	// Hook optionally decides on every alert before it is sent, see Hook
+	Hook Hook
+
+	// CombineChanges sends the changes found by one CheckAll as a single
+	// alert when at least this many addresses changed; 0 disables.
+	// CombineMaxRows caps the addresses listed per combined alert, the rest
+	// overflow into further alerts; DefaultCombineMaxRows if 0.
+	CombineChanges int
+	CombineMaxRows int
+
+	// QueueDelivery queues change and other alerts in the state instead of
+	// sending them during the check, for RunDelivery to deliver with
+	// retries; queued alerts survive restarts
+	QueueDelivery bool
+	wake          chan struct{}
+
+	// SendTimeout is how long each notifier gets to deliver an alert, sent
+	// to all notifiers at once; DefaultSendTimeout if 0
+	SendTimeout time.Duration
+
+	// Changes held for the catch-up message during the first check after
+	// downtime
+	catchingUp bool
+	caughtUp   []notify.Change
+
+	// Changes held for the combined alert during CheckAll
+	combining bool
+	combined  []notify.Change
+
+	// Labels as configured, before the address book's were added
+	configLabels map[string]string
+}
+
+// New creates a monitor for the given addresses
+func New(source BalanceSource, store Store, addresses []string, notifiers ...notify.Notifier) *Monitor {
+	return &Monitor{
+		Source:    source,
+		Store:     store,
+		Addresses: addresses,
+		notifiers: notifiers,
+	}
+}
+
+// AddNotifier registers another notifier
+func (m *Monitor) AddNotifier(n notify.Notifier) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notifiers = append(m.notifiers, n)
+}
+
+// Load restores the state from the store
+func (m *Monitor) Load() error {
+	state, err := m.Store.Load()
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+	m.seedHistory()
+	m.applyConfiguredCost()
+	m.labelAddressBook()
+	m.updateCache()
+	return nil
+}
+
+// now returns the current time according to Clock
+func (m *Monitor) now() time.Time {
+	if m.Clock == nil {
+		return time.Now()
+	}
+	return m.Clock()
+}
+
+// save persists the state, logging failures; callers must hold m.mu
+func (m *Monitor) save() {
+	m.updateCache()
+	m.flushNotified()
+	if err := m.Store.Save(m.state); err != nil {
+		log.Printf("Error saving state: %v", err)
+	}
+}
+
+// Balances returns a copy of the stored balances
+func (m *Monitor) Balances() []BalanceData {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]BalanceData{}, m.state.Balances.List()...)
+}
+
+// Summary returns the stored balances as summary rows
+func (m *Monitor) Summary() []notify.Balance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.summary(m.SummaryTags)
+}
+
+// summary builds summary rows from the state of the addresses carrying
+// any of summaryTags, or all if empty; callers must hold m.mu
+func (m *Monitor) summary(summaryTags []string) []notify.Balance {
+	var balances []notify.Balance
+	m.withPeers(func() { balances = m.summaryRows(summaryTags) })
+	return balances
+}
+
+// summaryRows implements summary for the addresses in the state; callers
+// must hold m.mu
+func (m *Monitor) summaryRows(summaryTags []string) []notify.Balance {
+	quote := m.quote()
+	now := m.now()
+	balances := make([]notify.Balance, 0, m.state.Balances.Len())
+	for _, b := range m.state.Balances.List() {
+		group, tags := m.Groups[b.Address], m.Tags[b.Address]
+		if wallet := m.walletOf(b.Address); wallet != "" {
+			if b.CurrentBalance == 0 {
+				continue // Unused derived address
+			}
+			if group == "" {
+				group = wallet
+			}
+			if len(tags) == 0 {
+				tags = m.Tags[wallet]
+			}
+		}
+		if !hasAnyTag(tags, summaryTags) {
+			continue
+		}
+		if m.SummaryGroupBy == GroupByTag {
+			group = ""
+			if len(tags) > 0 {
+				group = tags[0]
+			}
+		}
+		var cost CostBasis
+		costCurrency := ""
+		if m.CostCurrency != "" {
+			cost, costCurrency = m.state.CostBasis[b.Address], m.CostCurrency
+		}
+		lastSuccess, stale := m.lastSuccess(b.Address, now)
+		var flow *notify.Flow
+		if m.FlowPeriod > 0 {
+			flow = m.periodFlow(b.Address, m.FlowPeriod, now)
+		}
+		var trend []int64
+		if m.SparklinePoints > 0 {
+			trend = m.trend(b.Address, m.SparklinePoints)
+		}
+		balances = append(balances, notify.Balance{
+			Address:        b.Address,
+			Label:          m.Labels[b.Address],
+			Group:          group,
+			Tags:           tags,
+			CurrentBalance: b.CurrentBalance,
+			Locked:         m.state.LockedBalances[b.Address],
+			Signing:        m.signing(b.Address),
+			LastUpdated:    time.Unix(b.LastUpdated, 0),
+			LastSuccess:    lastSuccess,
+			Stale:          stale,
+			Changes:        m.periodChanges(b.Address, b.CurrentBalance, now),
+			Flow:           flow,
+			Trend:          trend,
+			Quote:          quote,
+			CostBasis:      cost.Cost,
+			CostNick:       cost.Nick,
+			CostCurrency:   costCurrency,
+		})
+	}
+	if err := notify.SortBalances(balances, m.SummarySort); err != nil {
+		log.Printf("Error sorting summary: %v", err)
+	}
+	return balances
+}
+
+// branding returns the branding of the monitor's own alerts,
+// notify.DefaultBranding if unset
+func (m *Monitor) branding() notify.Branding {
+	if m.Branding == (notify.Branding{}) {
+		return notify.DefaultBranding
+	}
+	return m.Branding
+}
+
+// quote returns the current fiat quote, or nil if prices are disabled or
+// unavailable
+func (m *Monitor) quote() price.Quote {
+	if m.Prices == nil {
+		return nil
+	}
+	quote, err := m.Prices.Quote()
+	if err != nil {
+		log.Printf("Error fetching price: %v", err)
+		return nil
+	}
+	return quote
+}
+
+// WatchedAddresses returns the configured addresses followed by any added
+// at runtime, any in the address book and any matching a pattern
+func (m *Monitor) WatchedAddresses() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.watchedAddresses()
+}
+
+// watchedAddresses implements WatchedAddresses; callers must hold m.mu
+func (m *Monitor) watchedAddresses() []string {
+	addresses := append([]string{}, m.Addresses...)
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		seen[address] = true
+	}
+	for _, address := range m.state.WatchedAddresses {
+		if !seen[address] {
+			seen[address] = true
+			addresses = append(addresses, address)
+		}
+	}
+	for _, entry := range m.state.AddressBook {
+		if !seen[entry.Address] {
+			seen[entry.Address] = true
+			addresses = append(addresses, entry.Address)
+		}
+	}
+	for _, pattern := range m.Patterns {
+		for _, address := range m.state.PatternAddresses[pattern] {
+			if !seen[address] {
+				seen[address] = true
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// isConfigured reports whether an address is part of the configured list,
+// the synced address book or matches a configured pattern; callers must
+// hold m.mu
+func (m *Monitor) isConfigured(address string) bool {
+	for _, configured := range m.Addresses {
+		if configured == address {
+			return true
+		}
+	}
+	return m.isPatternMember(address) || m.inAddressBook(address)
+}
+
+// IsWatched reports whether an address is configured or was added at runtime
+func (m *Monitor) IsWatched(address string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.isWatched(address)
+}
+
+// isWatched implements IsWatched; callers must hold m.mu
+func (m *Monitor) isWatched(address string) bool {
+	for _, watched := range m.watchedAddresses() {
+		if watched == address {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch adds an address to the persisted runtime watchlist
+func (m *Monitor) Watch(address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isWatched(address) {
+		return ErrAlreadyWatched
+	}
+	m.state.WatchedAddresses = append(m.state.WatchedAddresses, address)
+	return m.Store.Save(m.state)
+}
+
+// Unwatch removes a runtime-added address along with its stored balance and mute
+func (m *Monitor) Unwatch(address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.isConfigured(address) {
+		return ErrConfiguredAddress
+	}
+	index := -1
+	for i, watched := range m.state.WatchedAddresses {
+		if watched == address {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return ErrNotWatched
+	}
+	m.state.WatchedAddresses = append(m.state.WatchedAddresses[:index], m.state.WatchedAddresses[index+1:]...)
+	m.forget(address)
+	m.updateCache()
+	return m.Store.Save(m.state)
+}
+
+// forget removes the stored balance, mute, subscriptions and other state of
+// an address that is no longer watched; callers must hold m.mu
+func (m *Monitor) forget(address string) {
+	m.drop(address)
+	kept := m.state.Subscriptions[:0]
+	for _, subscription := range m.state.Subscriptions {
+		if subscription.Address != address {
+			kept = append(kept, subscription)
+		}
+	}
+	m.state.Subscriptions = kept
+	m.dropUnusedPrefs()
+}
+
+// drop removes the stored balance, mute, and other state of an address
+// that adopt copies; callers must hold m.mu
+func (m *Monitor) drop(address string) {
+	m.state.Balances.Delete(address)
+	delete(m.state.MutedUntil, address)
+	delete(m.state.BalanceHistory, address)
+	delete(m.state.CostBasis, address)
+	delete(m.state.SeenTransactions, address)
+	delete(m.state.UTXOs, address)
+	delete(m.state.LockedBalances, address)
+	delete(m.state.AddressStatus, address)
+	delete(m.state.PendingZero, address)
+	delete(m.state.Flapping, address)
+	delete(m.state.LastActivity, address)
+	delete(m.state.Signers, address)
+	m.forgetNotified(address)
+}
+
+// Mute suppresses alerts for an address until the given time; a zero time
+// lifts the mute
+func (m *Monitor) Mute(address string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.isWatched(address) {
+		return ErrNotWatched
+	}
+	if until.IsZero() {
+		delete(m.state.MutedUntil, address)
+	} else {
+		if m.state.MutedUntil == nil {
+			m.state.MutedUntil = map[string]int64{}
+		}
+		m.state.MutedUntil[address] = until.Unix()
+	}
+	return m.Store.Save(m.state)
+}
+
+// isMuted reports whether alerts for an address are currently muted;
+// callers must hold m.mu
+func (m *Monitor) isMuted(address string, now time.Time) bool {
+	return m.state.MutedUntil[address] > now.Unix()
+}
+
+// CheckAll checks all watched addresses for balance changes
+func (m *Monitor) CheckAll() []CheckResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.catchingUp = m.offline(now)
+	m.combining = m.CombineChanges > 0
+	m.expandPatterns()
+
+	started := time.Now()
+	results, inTime := m.checkUntil(0, m.owned(m.watchedAddresses()), started, m.CycleDeadline)
+	if inTime {
+		for _, w := range m.ownedWallets() {
+			results = append(results, m.checkWallet(w)...)
+		}
+	} else if len(m.Wallets) > 0 {
+		log.Printf("Skipping wallets until a check cycle finishes within %s", m.CycleDeadline)
+	}
+
+	if m.catchingUp {
+		m.notifyCatchUp(m.caughtUp, time.Unix(m.state.LastChecked, 0), now)
+		m.catchingUp, m.caughtUp = false, nil
+	}
+	if m.combining {
+		m.notifyCombined(m.combined)
+		m.combining, m.combined = false, nil
+	}
+	m.state.LastChecked = now.Unix()
+	m.checkCircuits()
+
+	m.save()
+	return results
+}
+
+// checkUntil checks addresses of a shard with checkLogged until deadline
+// has passed since started, shuffled with ShuffleChecks, and reports
+// whether it checked them all in time. Those the shard's previous cycle
+// ran out of time for go first, in the same order, so the ones waiting
+// longest are checked first. Callers must hold m.mu.
+func (m *Monitor) checkUntil(shard int, addresses []string, started time.Time, deadline time.Duration) ([]CheckResult, bool) {
+	addresses = append([]string{}, addresses...)
+	if m.ShuffleChecks {
+		rand.Shuffle(len(addresses), func(i, j int) { addresses[i], addresses[j] = addresses[j], addresses[i] })
+	}
+	if left := m.leftOver[shard]; len(left) > 0 {
+		delete(m.leftOver, shard)
+		watched := map[string]bool{}
+		for _, address := range addresses {
+			watched[address] = true
+		}
+		ordered := make([]string, 0, len(addresses))
+		first := map[string]bool{}
+		for _, address := range left {
+			if watched[address] {
+				ordered = append(ordered, address)
+				first[address] = true
+			}
+		}
+		for _, address := range addresses {
+			if !first[address] {
+				ordered = append(ordered, address)
+			}
+		}
+		addresses = ordered
+	}
+	var results []CheckResult
+	for i, address := range addresses {
+		if deadline > 0 && time.Since(started) >= deadline {
+			log.Printf("Check cycle ran past its %s deadline; %d of %d addresses are left for the next cycle", deadline, len(addresses)-i, len(addresses))
+			if m.leftOver == nil {
+				m.leftOver = map[int][]string{}
+			}
+			m.leftOver[shard] = addresses[i:]
+			return results, false
+		}
+		results = append(results, m.checkLogged(address))
+	}
+	return results, deadline <= 0 || time.Since(started) < deadline
+}
+
+// checkLogged checks one address of a scheduled check, logging failures
+// into its result and looking for related addresses when it changed;
+// callers must hold m.mu
+func (m *Monitor) checkLogged(address string) CheckResult {
+	result, err := m.check(address)
+	if err != nil {
+		if !isCircuitOpen(err) {
+			log.Printf("Error checking balance for %s: %v", address, err)
+		}
+		result.Error = err.Error()
+	}
+	if result.Changed {
+		m.discover(address)
+	}
+	return result
+}
+
+// Check checks a single watched address for a balance change
+func (m *Monitor) Check(address string) (CheckResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.isWatched(address) {
+		return CheckResult{Address: address}, ErrNotWatched
+	}
+	if len(m.owned([]string{address})) == 0 {
+		return CheckResult{Address: address}, ErrNotOwned
+	}
+	result, err := m.check(address)
+	if err != nil {
+		return result, err
+	}
+	m.save()
+	return result, nil
+}
+
+// check queries a single address, records any change in state, and sends
+// the corresponding alert; callers must hold m.mu
+func (m *Monitor) check(address string) (CheckResult, error) {
+	result, change, err := m.update(address)
+	if err != nil {
+		return result, err
+	}
+	result.Transactions = m.newTransactions(address)
+	if result.Changed {
+		m.checkUTXOs(address, change.Initial)
+	}
+	m.checkLocked(result)
+	m.checkSigners(address)
+	if m.isMuted(address, m.now()) || !m.HasTag(address, m.AlertTags...) {
+		if result.Changed {
+			m.archiveChange(change)
+		}
+		return result, nil
+	}
+	fresh := result.Transactions
+	if m.DedupeTransactions {
+		fresh = m.unnotified(address, result.Transactions)
+		if len(result.Transactions) > 0 && len(fresh) == 0 {
+			log.Printf("Not alerting on %s again: its transactions were already reported", address)
+			return result, nil
+		}
+	}
+	if m.checkFlapping(result, change) {
+		return result, nil
+	}
+	if result.Changed {
+		if change.Delta() < 0 && len(result.Transactions) > 0 {
+			change.Fee = m.transactionFee(address, result.Transactions)
+		}
+		if change.Delta() < 0 && !change.Initial {
+			change.SentTo = m.counterparties(address)
+		}
+		if m.ShowMemos && len(result.Transactions) > 0 {
+			change.Memos = m.transactionMemos(address, result.Transactions)
+		}
+		if len(result.Transactions) > 0 {
+			change.Key = changeKey(address, change.OldBalance, change.NewBalance, 0, result.Transactions)
+		}
+		m.reportChange(change)
+		if m.MaxFee > 0 && change.Fee > m.MaxFee {
+			m.notifyHighFee(change, result.Transactions)
+		}
+		m.checkDormant(change, result.lastMoved)
+		m.markNotified(address, result.Transactions)
+	} else if len(fresh) > 0 && m.AlertOnTransactions {
+		m.notifyTransactions(address, fresh, result.CurrentBalance)
+		m.markNotified(address, fresh)
+	}
+	return result, nil
+}
+
+// update queries a single address and records any change in state without
+// alerting, returning the change to report; callers must hold m.mu
+func (m *Monitor) update(address string) (CheckResult, notify.Change, error) {
+	result := CheckResult{Address: address}
+	newBalance, err := m.Source.GetBalance(address)
+	m.recordCheck(address, err)
+	if err != nil {
+		return result, notify.Change{}, err
+	}
+	result.CurrentBalance = newBalance
+
+	stored, known := m.state.Balances.Get(address)
+	oldBalance, since := stored.CurrentBalance, stored.LastUpdated
+	result.PreviousBalance = oldBalance
+
+	now := m.now()
+	if m.ConfirmZero && known && newBalance == 0 && oldBalance != 0 && m.state.PendingZero[address] == 0 {
+		// Indexer hiccups often read as an empty account, so wait for a
+		// second zero reading before alerting
+		log.Printf("Balance of %s reads as zero; waiting for the next check to confirm", address)
+		if m.state.PendingZero == nil {
+			m.state.PendingZero = map[string]int64{}
+		}
+		m.state.PendingZero[address] = now.Unix()
+		result.CurrentBalance = oldBalance
+		result.ZeroUnconfirmed = true
+		return result, notify.Change{}, nil
+	}
+	delete(m.state.PendingZero, address)
+
+	if !known || newBalance != oldBalance {
+		// New address, or balance changed
+		m.state.Balances.Set(BalanceData{
+			Address:        address,
+			CurrentBalance: newBalance,
+			LastUpdated:    now.Unix(),
+		})
+		result.Changed = true
+	}
+	if !result.Changed {
+		return result, notify.Change{}, nil
+	}
+	result.lastMoved = m.recordActivity(address, !known, since, now)
+	quote := m.quote()
+	m.recordBalance(address, newBalance, now, quote)
+	m.updateCostBasis(address, oldBalance, newBalance, !known, quote)
+
+	return result, notify.Change{
+		Address:    address,
+		Label:      m.Labels[address],
+		OldBalance: oldBalance,
+		NewBalance: newBalance,
+		Initial:    !known,
+		Time:       now,
+		Quote:      quote,
+		Key:        changeKey(address, oldBalance, newBalance, since, nil),
+	}, nil
+}
+
+// notifyChange sends a balance change alert to every notifier
+func (m *Monitor) notifyChange(change notify.Change) {
+	if change.Key == "" {
+		change.Key = changeKey(change.Address, change.OldBalance, change.NewBalance, change.Time.Truncate(idempotencyBucket).Unix(), nil)
+	}
+	rule, severity := changeRule(change)
+	change.Severity = m.severity(rule, severity)
+	decision := m.decide(Event{
+		Kind:       EventChange,
+		Rule:       rule,
+		Severity:   change.Severity.String(),
+		Address:    change.Address,
+		Label:      change.Label,
+		OldBalance: change.OldBalance,
+		NewBalance: change.NewBalance,
+		Delta:      change.Delta(),
+		Initial:    change.Initial,
+		Tags:       m.Tags[change.Address],
+		Time:       change.Time,
+	})
+	if decision.Suppress {
+		m.deliver(m.archives, &change, nil)
+		return
+	}
+	change.Severity = applySeverity(decision, change.Severity)
+	m.deliver(m.withArchives(m.routed(decision)), &change, nil)
+}
+
+// recordFailure keeps a failed delivery in state so it can be inspected
+// later; callers must hold m.mu
+func (m *Monitor) recordFailure(notifier, kind, address string, err error) {
+	m.state.DeliveryFailures = append(m.state.DeliveryFailures, DeliveryFailure{
+		Time:     m.now().Unix(),
+		Notifier: notifier,
+		Kind:     kind,
+		Address:  address,
+		Error:    err.Error(),
+	})
+	if excess := len(m.state.DeliveryFailures) - maxDeliveryFailures; excess > 0 {
+		m.state.DeliveryFailures = m.state.DeliveryFailures[excess:]
+	}
+}
+
+// DeliveryFailures returns the most recent failed deliveries, oldest first
+func (m *Monitor) DeliveryFailures() []DeliveryFailure {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DeliveryFailure{}, m.state.DeliveryFailures...)
+}
+
+// SendSummary sends a summary of all balances to every notifier
+func (m *Monitor) SendSummary() {
+	m.sendSummary(nil)
+}
+
+// SendSummaryTo sends a summary of all balances only to the notifiers with
+// the given names, e.g. "Slack", so each can follow its own schedule
+func (m *Monitor) SendSummaryTo(names ...string) {
+	m.sendSummary(names)
+}
+
+// sendSummary implements SendSummary and SendSummaryTo; nil names means
+// every notifier
+func (m *Monitor) sendSummary(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	balances := m.summary(m.SummaryTags)
+	var stats *notify.Alert
+	if m.SummaryStats {
+		now := m.now()
+		since := m.lastSummaryTo(names)
+		if since.IsZero() {
+			since = now.Add(-24 * time.Hour)
+		}
+		alert := m.summaryStatsAlert(m.summaryStats(since), now)
+		stats = &alert
+	}
+	for _, n := range m.notifiers {
+		if names != nil && !containsName(names, n.Name()) {
+			continue
+		}
+		if pinner, ok := n.(notify.Pinner); ok && m.PinnedSummary {
+			if m.state.PinnedSummary == nil {
+				m.state.PinnedSummary = &notify.PinnedSummary{}
+			}
+			if err := pinner.UpdatePinnedSummary(balances, m.state.PinnedSummary); err != nil {
+				log.Printf("Error updating %s summary: %v", n.Name(), err)
+				m.recordFailure(n.Name(), "summary", "", err)
+			}
+			continue
+		}
+		if err := n.NotifySummary(balances); err != nil {
+			log.Printf("Error sending %s summary: %v", n.Name(), err)
+			m.recordFailure(n.Name(), "summary", "", err)
+			continue
+		}
+		if stats != nil {
+			if err := n.NotifyAlert(*stats); err != nil {
+				log.Printf("Error sending %s summary stats: %v", n.Name(), err)
+				m.recordFailure(n.Name(), "alert", "", err)
+			}
+		}
+	}
+	if m.Chart != ChartNone {
+		m.sendChart(names)
+	}
+	m.archiveSummary()
+
+	now := m.now().Unix()
+	if names == nil {
+		m.state.LastSummary = now
+	}
+	if m.state.LastSummaries == nil {
+		m.state.LastSummaries = map[string]int64{}
+	}
+	for _, name := range names {
+		m.state.LastSummaries[name] = now
+	}
+	m.save()
+}
+
+// LastSummary returns when a summary was last sent to every notifier, or
+// the zero time if never
+func (m *Monitor) LastSummary() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return unixTime(m.state.LastSummary)
+}
+
+// LastSummaryTo returns when all of the named notifiers last got a
+// summary, or the zero time if any never did
+func (m *Monitor) LastSummaryTo(names ...string) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastSummaryTo(names)
+}
+
+// lastSummaryTo implements LastSummaryTo, with nil names meaning the last
+// summary to every notifier; callers must hold m.mu
+func (m *Monitor) lastSummaryTo(names []string) time.Time {
+	if names == nil {
+		return unixTime(m.state.LastSummary)
+	}
+	var earliest int64
+	for _, name := range names {
+		last := m.state.LastSummary
+		if sent, ok := m.state.LastSummaries[name]; ok && sent > last {
+			last = sent
+		}
+		if last == 0 {
+			return time.Time{}
+		}
+		if earliest == 0 || last < earliest {
+			earliest = last
+		}
+	}
+	return unixTime(earliest)
+}
+
+// containsName reports whether names includes a notifier name, ignoring
+// case
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
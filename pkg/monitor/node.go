@@ -0,0 +1,166 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/node"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Node alert conditions, kept in NodeState.Alerts while they last
+const (
+	nodeDown    = "down"
+	nodeBehind  = "behind"
+	nodeNoPeers = "peers"
+)
+
+// NodeStatusSource reports the status of a node, see node.Client
+type NodeStatusSource interface {
+	Status() (node.Status, error)
+}
+
+// HeightSource is implemented by balance sources that know the latest block
+// height, which a monitored node is compared against
+type HeightSource interface {
+	Height() (int64, error)
+}
+
+// Height implements HeightSource using the default adapter
+func (mc *MultiChain) Height() (int64, error) {
+	source, ok := mc.Default.(HeightSource)
+	if !ok {
+		return 0, fmt.Errorf("%s adapter doesn't report block height", mc.Default.Chain())
+	}
+	return source.Height()
+}
+
+// NodeRules configures node operator alerts
+type NodeRules struct {
+	MaxLag   int64 // Alert when the node is more than this many blocks behind the indexer
+	MinPeers int   // Alert when the node has fewer peers than this
+}
+
+// NodeState is the last observed node status
+type NodeState struct {
+	node.Status
+	ReferenceHeight int64           `json:"referenceHeight,omitempty"` // Height reported by the indexer
+	Error           string          `json:"error,omitempty"`
+	Checked         int64           `json:"checked"`
+	Alerts          map[string]bool `json:"alerts,omitempty"` // Conditions already alerted on
+}
+
+// NodeStatus returns the last observed node status, or nil if no node is
+// monitored
+func (m *Monitor) NodeStatus() *NodeState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.state.Node == nil {
+		return nil
+	}
+	state := *m.state.Node
+	return &state
+}
+
+// CheckNode polls the node and alerts once when it becomes unreachable,
+// falls more than NodeRules.MaxLag blocks behind the indexer, or drops below
+// NodeRules.MinPeers peers, and again when each condition clears
+func (m *Monitor) CheckNode() {
+	if m.Node == nil {
+		return
+	}
+	status, err := m.Node.Status()
+	var reference int64
+	if err == nil && m.NodeRules.MaxLag > 0 {
+		if source, ok := m.Source.(HeightSource); ok {
+			if reference, err = source.Height(); err != nil {
+				log.Printf("Error fetching indexer height: %v", err)
+				reference, err = 0, nil
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	previous := m.state.Node
+	if previous == nil {
+		previous = &NodeState{}
+	}
+	current := &NodeState{Status: status, ReferenceHeight: reference, Checked: now.Unix(), Alerts: map[string]bool{}}
+	if err != nil {
+		log.Printf("Error fetching node status: %v", err)
+		current.Status = previous.Status
+		current.Error = err.Error()
+	}
+
+	conditions := map[string]bool{nodeDown: err != nil}
+	if err == nil {
+		conditions[nodeBehind] = reference > 0 && m.NodeRules.MaxLag > 0 && reference-status.Height > m.NodeRules.MaxLag
+		conditions[nodeNoPeers] = status.Peers < m.NodeRules.MinPeers
+	} else {
+		// Keep lag and peer alerts as they were until the node answers again
+		current.Alerts[nodeBehind] = previous.Alerts[nodeBehind]
+		current.Alerts[nodeNoPeers] = previous.Alerts[nodeNoPeers]
+	}
+	for _, condition := range []string{nodeDown, nodeBehind, nodeNoPeers} {
+		active, checked := conditions[condition]
+		if !checked {
+			continue
+		}
+		if active {
+			current.Alerts[condition] = true
+		}
+		if active != previous.Alerts[condition] {
+			m.notifyAlert(m.nodeAlert(condition, active, current, now))
+		}
+	}
+	m.state.Node = current
+	m.save()
+}
+
+// nodeAlert builds the alert for a node condition starting or clearing
+func (m *Monitor) nodeAlert(condition string, active bool, state *NodeState, now time.Time) notify.Alert {
+	alert := notify.Alert{Time: now, Rule: RuleNode, Severity: notify.SeverityWarning}
+	if !active {
+		alert.Severity = notify.SeverityInfo
+	}
+	switch condition {
+	case nodeDown:
+		alert.Emoji, alert.Title = "🛑", "Node unreachable"
+		if active {
+			alert.Severity = notify.SeverityCritical
+		} else {
+			alert.Emoji, alert.Title = "✅", "Node reachable again"
+		}
+	case nodeBehind:
+		alert.Emoji, alert.Title = "🐢", "Node falling behind"
+		if !active {
+			alert.Emoji, alert.Title = "✅", "Node caught up"
+		}
+	case nodeNoPeers:
+		alert.Emoji, alert.Title = "🔌", "Node losing peers"
+		if !active {
+			alert.Emoji, alert.Title = "✅", "Node peers recovered"
+		}
+	}
+
+	if state.Error != "" {
+		alert.Fields = append(alert.Fields, notify.Field{Name: "Error", Value: state.Error})
+		return alert
+	}
+	alert.Fields = append(alert.Fields, notify.Field{Name: "Height", Value: fmt.Sprintf("%d", state.Height)})
+	if state.ReferenceHeight > 0 {
+		alert.Fields = append(alert.Fields, notify.Field{
+			Name:  "Indexer Height",
+			Value: fmt.Sprintf("%d (%d blocks behind, max %d)", state.ReferenceHeight, state.ReferenceHeight-state.Height, m.NodeRules.MaxLag),
+		})
+	}
+	alert.Fields = append(alert.Fields, notify.Field{Name: "Peers", Value: fmt.Sprintf("%d (min %d)", state.Peers, m.NodeRules.MinPeers)})
+	if state.Version != "" {
+		alert.Fields = append(alert.Fields, notify.Field{Name: "Version", Value: state.Version})
+	}
+	return alert
+}
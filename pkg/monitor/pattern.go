@@ -0,0 +1,91 @@
+package monitor
+
+import "log"
+
+// AddressMatcher is implemented by balance sources whose indexer can list
+// the addresses matching a pattern, such as a prefix or a pool account
+type AddressMatcher interface {
+	MatchAddresses(pattern string) ([]string, error)
+}
+
+// MatchAddresses implements AddressMatcher by asking the adapter of the
+// pattern's chain, given as chain:pattern, if it supports matching
+func (mc *MultiChain) MatchAddresses(pattern string) ([]string, error) {
+	chain, plain := SplitAddress(pattern)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	matcher, ok := adapter.(AddressMatcher)
+	if !ok {
+		return nil, nil
+	}
+	matched, err := matcher.MatchAddresses(plain)
+	if chain != "" {
+		for i := range matched {
+			matched[i] = chain + ":" + matched[i]
+		}
+	}
+	return matched, err
+}
+
+// expandPatterns refreshes the addresses matching each of Patterns. New
+// matches are watched from this check on; addresses that no longer match
+// any pattern are forgotten unless watched otherwise. A pattern that can't
+// be expanded keeps its previous members. Callers must hold m.mu.
+func (m *Monitor) expandPatterns() {
+	if len(m.Patterns) == 0 && len(m.state.PatternAddresses) == 0 {
+		return
+	}
+	matcher, ok := m.Source.(AddressMatcher)
+	if !ok {
+		log.Printf("Not expanding address patterns: the balance source can't match addresses")
+		return
+	}
+
+	previous := m.state.PatternAddresses
+	m.state.PatternAddresses = map[string][]string{}
+	for _, pattern := range m.Patterns {
+		matched, err := matcher.MatchAddresses(pattern)
+		if err != nil {
+			log.Printf("Error expanding address pattern %s: %v", pattern, err)
+			m.state.PatternAddresses[pattern] = previous[pattern]
+			continue
+		}
+		known := map[string]bool{}
+		for _, address := range previous[pattern] {
+			known[address] = true
+		}
+		for _, address := range matched {
+			if !known[address] {
+				log.Printf("Watching %s, which matches %s", address, pattern)
+			}
+		}
+		m.state.PatternAddresses[pattern] = matched
+	}
+
+	for pattern, members := range previous {
+		for _, address := range members {
+			if !m.isWatched(address) {
+				log.Printf("No longer watching %s, which stopped matching %s", address, pattern)
+				m.forget(address)
+			}
+		}
+	}
+	if len(m.state.PatternAddresses) == 0 {
+		m.state.PatternAddresses = nil
+	}
+}
+
+// isPatternMember reports whether an address matched one of Patterns in
+// the last expansion; callers must hold m.mu
+func (m *Monitor) isPatternMember(address string) bool {
+	for _, members := range m.state.PatternAddresses {
+		for _, member := range members {
+			if member == address {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// minPayoutsForCadence is how many payouts must be seen before an address's
+// usual cadence is trusted enough to alert on late payouts
+const minPayoutsForCadence = 3
+
+// PayoutStats describes the payouts an address has received within the
+// recorded history. The balance RPC doesn't report senders, so payouts are
+// tracked per receiving address: every balance increase counts as one.
+type PayoutStats struct {
+	Address      string        `json:"address"`
+	Label        string        `json:"label,omitempty"`
+	Count        int           `json:"count"`
+	Total        int64         `json:"total"`   // nick
+	Average      int64         `json:"average"` // nick
+	Cadence      time.Duration `json:"-"`       // Median time between payouts
+	CadenceSecs  int64         `json:"cadenceSeconds,omitempty"`
+	LastPayout   time.Time     `json:"lastPayout"`
+	NextExpected time.Time     `json:"nextExpected"` // Zero until the cadence is known
+}
+
+// payoutEvents returns the balance increases in a history, with Balance
+// holding the amount received
+func payoutEvents(history []BalanceSample) []BalanceSample {
+	var events []BalanceSample
+	for i := 1; i < len(history); i++ {
+		if delta := history[i].Balance - history[i-1].Balance; delta > 0 {
+			events = append(events, BalanceSample{Time: history[i].Time, Balance: delta})
+		}
+	}
+	return events
+}
+
+// Payouts returns payout statistics for every address
+func (m *Monitor) Payouts() []PayoutStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make([]PayoutStats, 0, m.state.Balances.Len())
+	for _, b := range m.state.Balances.List() {
+		stats = append(stats, m.payoutStats(b.Address))
+	}
+	return stats
+}
+
+// payoutStats computes the payout statistics of one address; callers must
+// hold m.mu
+func (m *Monitor) payoutStats(address string) PayoutStats {
+	stats := PayoutStats{Address: address, Label: m.Labels[address]}
+	events := payoutEvents(m.state.BalanceHistory[address])
+	if len(events) == 0 {
+		return stats
+	}
+	for _, event := range events {
+		stats.Count++
+		stats.Total += event.Balance
+	}
+	stats.Average = stats.Total / int64(stats.Count)
+	stats.LastPayout = time.Unix(events[len(events)-1].Time, 0)
+	if len(events) >= minPayoutsForCadence {
+		intervals := make([]int64, 0, len(events)-1)
+		for i := 1; i < len(events); i++ {
+			intervals = append(intervals, events[i].Time-events[i-1].Time)
+		}
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+		stats.Cadence = time.Duration(intervals[len(intervals)/2]) * time.Second
+		stats.CadenceSecs = int64(stats.Cadence.Seconds())
+		stats.NextExpected = stats.LastPayout.Add(stats.Cadence)
+	}
+	return stats
+}
+
+// CheckPayouts alerts once for every address whose next payout is overdue by
+// more than PayoutLatePercent of its usual cadence
+func (m *Monitor) CheckPayouts() {
+	if m.PayoutLatePercent <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	fired := false
+	for _, b := range m.state.Balances.List() {
+		stats := m.payoutStats(b.Address)
+		if stats.Cadence == 0 || m.isMuted(b.Address, now) {
+			continue
+		}
+		allowed := time.Duration(float64(stats.Cadence) * (1 + m.PayoutLatePercent/100))
+		late := now.Sub(stats.LastPayout)
+		if late <= allowed || m.state.PayoutAlertsFired[b.Address] >= stats.LastPayout.Unix() {
+			continue
+		}
+
+		m.notifyAlert(notify.Alert{
+			Emoji:    "⏰",
+			Title:    "Payout overdue",
+			Rule:     RulePayoutOverdue,
+			Severity: notify.SeverityWarning,
+			Fields: []notify.Field{
+				{Name: "Address", Value: b.Address + labelText(stats.Label)},
+				{Name: "Last Payout", Value: fmt.Sprintf("%s (%s ago)", stats.LastPayout.Format(time.RFC3339), formatWindow(late.Round(time.Minute)))},
+				{Name: "Usual Cadence", Value: "every " + formatWindow(stats.Cadence.Round(time.Minute))},
+				{Name: "Average Payout", Value: m.Format.Balance(stats.Average)},
+			},
+			Time: now,
+		})
+		if m.state.PayoutAlertsFired == nil {
+			m.state.PayoutAlertsFired = map[string]int64{}
+		}
+		m.state.PayoutAlertsFired[b.Address] = stats.LastPayout.Unix()
+		fired = true
+	}
+	if fired {
+		m.save()
+	}
+}
+
+// labelText formats an optional label as " (label)"
+func labelText(label string) string {
+	if label == "" {
+		return ""
+	}
+	return " (" + label + ")"
+}
@@ -0,0 +1,191 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// priceSampleInterval is the minimum spacing between stored price samples
+const priceSampleInterval = 5 * time.Minute
+
+// PriceSample is a recorded $NOCK price
+type PriceSample struct {
+	Time  int64   `json:"time"`
+	Price float64 `json:"price"`
+}
+
+// PriceChangeRule fires when the price moves by at least Percent within Window
+type PriceChangeRule struct {
+	Window  time.Duration
+	Percent float64
+}
+
+// PriceRules configures alerts on the $NOCK price itself
+type PriceRules struct {
+	Currency string  // Currency the thresholds are expressed in, e.g. usd
+	Above    float64 // Alert when the price crosses above this value; 0 disables
+	Below    float64 // Alert when the price drops below this value; 0 disables
+	Changes  []PriceChangeRule
+}
+
+// enabled reports whether any price rule is configured
+func (r PriceRules) enabled() bool {
+	return r.Above > 0 || r.Below > 0 || len(r.Changes) > 0
+}
+
+// maxWindow returns the longest change window, which bounds the history kept
+func (r PriceRules) maxWindow() time.Duration {
+	var longest time.Duration
+	for _, rule := range r.Changes {
+		if rule.Window > longest {
+			longest = rule.Window
+		}
+	}
+	return longest
+}
+
+// CheckPrice records the current price and sends alerts for any price rule
+// that fires
+func (m *Monitor) CheckPrice() {
+	if m.Prices == nil || !m.PriceRules.enabled() {
+		return
+	}
+	quote, err := m.Prices.Quote()
+	if err != nil {
+		log.Printf("Error fetching price: %v", err)
+		return
+	}
+	current, ok := quote[m.PriceRules.Currency]
+	if !ok {
+		log.Printf("Error checking price rules: no %s price in quote", m.PriceRules.Currency)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	history := m.state.PriceHistory
+	var previous *PriceSample
+	if len(history) > 0 {
+		previous = &history[len(history)-1]
+	}
+
+	currency := strings.ToUpper(m.PriceRules.Currency)
+	unit := m.Format.Unit()
+	if previous != nil {
+		if m.PriceRules.Above > 0 && previous.Price < m.PriceRules.Above && current >= m.PriceRules.Above {
+			m.notifyAlert(m.priceAlert("📈", fmt.Sprintf("%s crossed above %s %s", unit, m.Format.Number(m.PriceRules.Above, 4), currency), previous.Price, current, currency, now))
+		}
+		if m.PriceRules.Below > 0 && previous.Price > m.PriceRules.Below && current <= m.PriceRules.Below {
+			m.notifyAlert(m.priceAlert("📉", fmt.Sprintf("%s dropped below %s %s", unit, m.Format.Number(m.PriceRules.Below, 4), currency), previous.Price, current, currency, now))
+		}
+	}
+
+	for _, rule := range m.PriceRules.Changes {
+		reference, ok := priceAt(history, now.Add(-rule.Window))
+		if !ok || reference.Price == 0 {
+			continue // Not enough history yet
+		}
+		percent := (current - reference.Price) / reference.Price * 100
+		key := rule.Window.String()
+		if math.Abs(percent) < rule.Percent || m.state.PriceAlertsFired[key] > now.Add(-rule.Window).Unix() {
+			continue
+		}
+		emoji := "📈"
+		if percent < 0 {
+			emoji = "📉"
+		}
+		alert := m.priceAlert(emoji, fmt.Sprintf("%s moved %+.1f%% in %s", unit, percent, formatWindow(rule.Window)), reference.Price, current, currency, now)
+		m.notifyAlert(alert)
+		if m.state.PriceAlertsFired == nil {
+			m.state.PriceAlertsFired = map[string]int64{}
+		}
+		m.state.PriceAlertsFired[key] = now.Unix()
+	}
+
+	if previous == nil || now.Sub(time.Unix(previous.Time, 0)) >= priceSampleInterval {
+		history = append(history, PriceSample{Time: now.Unix(), Price: current})
+	}
+	m.state.PriceHistory = trimPriceHistory(history, now.Add(-m.PriceRules.maxWindow()))
+	m.save()
+}
+
+// formatWindow formats a duration without trailing zero units, e.g. "1h"
+// rather than "1h0m0s"
+func formatWindow(d time.Duration) string {
+	text := d.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+// priceAt returns the latest sample taken at or before t
+func priceAt(history []PriceSample, t time.Time) (PriceSample, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Time <= t.Unix() {
+			return history[i], true
+		}
+	}
+	return PriceSample{}, false
+}
+
+// trimPriceHistory drops samples older than cutoff, keeping the newest one
+// before it as the reference point for the longest window
+func trimPriceHistory(history []PriceSample, cutoff time.Time) []PriceSample {
+	for len(history) > 1 && history[1].Time <= cutoff.Unix() {
+		history = history[1:]
+	}
+	return history
+}
+
+// priceAlert builds a price movement alert
+func (m *Monitor) priceAlert(emoji, title string, from, to float64, currency string, now time.Time) notify.Alert {
+	return notify.Alert{
+		Emoji:    emoji,
+		Title:    title,
+		Rule:     RulePrice,
+		Severity: notify.SeverityInfo,
+		Fields: []notify.Field{
+			{Name: "Previous Price", Value: m.Format.Number(from, 4) + " " + currency},
+			{Name: "Current Price", Value: m.Format.Number(to, 4) + " " + currency},
+		},
+		Time: now,
+	}
+}
+
+// notifyAlert sends a generic alert to every notifier, at its rule's
+// configured severity; callers must hold m.mu
+func (m *Monitor) notifyAlert(alert notify.Alert) {
+	if alert.Key == "" {
+		keyed := alert
+		if keyed.Time.IsZero() {
+			keyed.Time = m.now()
+		}
+		alert.Key = alertKey(keyed)
+	}
+	alert.Severity = m.severity(alert.Rule, alert.Severity)
+	decision := m.decide(Event{
+		Kind:     EventAlert,
+		Rule:     alert.Rule,
+		Severity: alert.Severity.String(),
+		Title:    alert.Title,
+		Fields:   alert.Fields,
+		Time:     alert.Time,
+	})
+	if decision.Suppress {
+		m.deliver(m.archives, nil, &alert)
+		return
+	}
+	alert.Severity = applySeverity(decision, alert.Severity)
+	m.deliver(m.withArchives(m.routed(decision)), nil, &alert)
+}
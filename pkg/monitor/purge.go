@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"strings"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// PurgeResult reports whether Purge found anything stored for an address
+type PurgeResult struct {
+	Found    bool // Whether any state mentioned the address
+	Queued   int  // Queued alerts dropped
+	Failures int  // Delivery failures dropped
+}
+
+// Purge deletes everything the state holds about an address: its balance,
+// history, mutes, subscriptions, queued alerts, delivery failures and its
+// place in the runtime watchlist, address book and pattern matches. Unlike
+// Unwatch it also works for addresses that are no longer watched. It
+// refuses addresses listed in Addresses, since the next check would store
+// them again.
+func (m *Monitor) Purge(address string) (PurgeResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, configured := range m.Addresses {
+		if configured == address {
+			return PurgeResult{}, ErrConfiguredAddress
+		}
+	}
+	found := m.holds(address)
+
+	m.state.WatchedAddresses = without(m.state.WatchedAddresses, address)
+	book := m.state.AddressBook[:0]
+	for _, entry := range m.state.AddressBook {
+		if entry.Address != address {
+			book = append(book, entry)
+		}
+	}
+	m.state.AddressBook = book
+	for pattern, members := range m.state.PatternAddresses {
+		m.state.PatternAddresses[pattern] = without(members, address)
+	}
+	for wallet, derived := range m.state.WalletAddresses {
+		m.state.WalletAddresses[wallet] = without(derived, address)
+	}
+	delete(m.state.DiscoveredAddresses, address)
+	delete(m.state.InflowShortfalls, address)
+	delete(m.state.PayoutAlertsFired, address)
+	m.forget(address)
+
+	var result PurgeResult
+	failures := m.state.DeliveryFailures[:0]
+	for _, failure := range m.state.DeliveryFailures {
+		if failure.Address == address {
+			result.Failures++
+			continue
+		}
+		failures = append(failures, failure)
+	}
+	m.state.DeliveryFailures = failures
+	queue := m.state.Queue[:0]
+	for _, entry := range m.state.Queue {
+		if mentions(entry.Change, entry.Alert, address) {
+			result.Queued++
+			continue
+		}
+		queue = append(queue, entry)
+	}
+	m.state.Queue = queue
+
+	result.Found = found || result.Queued > 0 || result.Failures > 0
+	if !result.Found {
+		return result, nil
+	}
+	m.updateCache()
+	return result, m.Store.Save(m.state)
+}
+
+// holds reports whether the per-address state has an entry for address;
+// callers must hold m.mu
+func (m *Monitor) holds(address string) bool {
+	if m.isWatched(address) {
+		return true
+	}
+	if m.state.Balances.Has(address) {
+		return true
+	}
+	if _, ok := m.state.BalanceHistory[address]; ok {
+		return true
+	}
+	if _, ok := m.state.DiscoveredAddresses[address]; ok {
+		return true
+	}
+	for _, subscription := range m.state.Subscriptions {
+		if subscription.Address == address {
+			return true
+		}
+	}
+	if m.Notified != nil {
+		if notified, err := m.Notified.Notified(address); err == nil && len(notified) > 0 {
+			return true
+		}
+	}
+	if len(m.pendingNotified[address]) > 0 {
+		return true
+	}
+	for _, derived := range m.state.WalletAddresses {
+		for _, a := range derived {
+			if a == address {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mentions reports whether a queued change or alert concerns address
+func mentions(change *notify.Change, alert *notify.Alert, address string) bool {
+	if change != nil {
+		return change.Address == address
+	}
+	if alert == nil {
+		return false
+	}
+	for _, field := range alert.Fields {
+		if strings.Contains(field.Value, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// without returns list minus every occurrence of value
+func without(list []string, value string) []string {
+	kept := list[:0]
+	for _, item := range list {
+		if item != value {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,334 @@
+package monitor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// Queue limits and retry timing
+const (
+	maxQueued         = 1000             // Oldest entries are dropped beyond this
+	maxQueueAttempts  = 10               // Entries are recorded as failed after this many attempts
+	queueRetryInitial = 30 * time.Second // Doubled after each failed attempt
+	queueRetryMax     = 30 * time.Minute
+	queueIdleWait     = time.Minute // Longest the worker sleeps without being woken
+)
+
+// DefaultSendTimeout is how long a notifier gets to deliver one alert
+// before it counts as failed
+const DefaultSendTimeout = 30 * time.Second
+
+// QueuedAlert is a change or other alert waiting to be delivered to one
+// notifier
+type QueuedAlert struct {
+	ID          int64          `json:"id"`
+	Notifier    string         `json:"notifier"` // Notifier name, with #2, #3... for repeated names
+	Change      *notify.Change `json:"change,omitempty"`
+	Alert       *notify.Alert  `json:"alert,omitempty"`
+	Queued      int64          `json:"queued"`
+	Attempts    int            `json:"attempts,omitempty"`
+	NextAttempt int64          `json:"nextAttempt,omitempty"`
+	LastError   string         `json:"lastError,omitempty"`
+}
+
+// kind returns the delivery failure kind of the entry
+func (q QueuedAlert) kind() string {
+	if q.Change != nil {
+		return "change"
+	}
+	return "alert"
+}
+
+// address returns the address the entry is about, if any
+func (q QueuedAlert) address() string {
+	if q.Change != nil {
+		return q.Change.Address
+	}
+	return ""
+}
+
+// deliver sends a change or alert to every given notifier at once, so a
+// slow or hung one doesn't hold up the rest, or queues it for RunDelivery
+// when QueueDelivery is set; callers must hold m.mu
+func (m *Monitor) deliver(notifiers []notify.Notifier, change *notify.Change, alert *notify.Alert) {
+	key := entryKey(change, alert)
+	var pending []notify.Notifier
+	var names []string
+	for _, n := range notifiers {
+		name := m.notifierKey(n)
+		switch {
+		case m.QueueDelivery:
+			m.enqueue(name, change, alert)
+		case m.wasSent(name, key):
+			log.Printf("Not re-sending %s %s: already sent", name, key)
+		default:
+			pending = append(pending, n)
+			names = append(names, name)
+		}
+	}
+
+	for _, n := range notifiers {
+		// Archive-only copies of filtered alerts don't count as sent
+		if !isArchive(n) {
+			m.state.AlertsSent = m.recordTime(m.state.AlertsSent)
+			break
+		}
+	}
+
+	entry := QueuedAlert{Change: change, Alert: alert}
+	errs := m.sendConcurrently(len(pending), func(i int) error { return send(pending[i], entry) })
+	for i, n := range pending {
+		if errs[i] == nil {
+			m.markSent(names[i], key)
+			continue
+		}
+		if change != nil {
+			log.Printf("Error sending %s message: %v", n.Name(), errs[i])
+		} else {
+			log.Printf("Error sending %s alert: %v", n.Name(), errs[i])
+		}
+		m.recordFailure(n.Name(), entry.kind(), entry.address(), errs[i])
+	}
+}
+
+// sendConcurrently runs send for count notifiers at once and returns their
+// errors, waiting at most SendTimeout; notifiers still sending by then get
+// a timeout error and are left to finish in the background
+func (m *Monitor) sendConcurrently(count int, send func(i int) error) []error {
+	errs := make([]error, count)
+	if count == 0 {
+		return errs
+	}
+	timeout := m.SendTimeout
+	if timeout <= 0 {
+		timeout = DefaultSendTimeout
+	}
+	type result struct {
+		i   int
+		err error
+	}
+	results := make(chan result, count) // Buffered so late senders don't block
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			results <- result{i, send(i)}
+		}(i)
+	}
+	done := make([]bool, count)
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for received := 0; received < count; received++ {
+		select {
+		case r := <-results:
+			errs[r.i], done[r.i] = r.err, true
+		case <-deadline.C:
+			for i := range errs {
+				if !done[i] {
+					errs[i] = fmt.Errorf("no response within %s", timeout)
+				}
+			}
+			return errs
+		}
+	}
+	return errs
+}
+
+// send delivers a queue entry once
+func send(n notify.Notifier, entry QueuedAlert) error {
+	if entry.Change != nil {
+		return n.NotifyChange(*entry.Change)
+	}
+	return n.NotifyAlert(*entry.Alert)
+}
+
+// enqueue persists an entry and wakes the delivery worker; callers must
+// hold m.mu
+func (m *Monitor) enqueue(notifier string, change *notify.Change, alert *notify.Alert) {
+	// Copy, since the same change or alert is queued for every notifier
+	if change != nil {
+		copied := *change
+		change = &copied
+	}
+	if alert != nil {
+		copied := *alert
+		alert = &copied
+	}
+	m.state.QueueSeq++
+	m.state.Queue = append(m.state.Queue, QueuedAlert{
+		ID:       m.state.QueueSeq,
+		Notifier: notifier,
+		Change:   change,
+		Alert:    alert,
+		Queued:   m.now().Unix(),
+	})
+	if excess := len(m.state.Queue) - maxQueued; excess > 0 {
+		for _, dropped := range m.state.Queue[:excess] {
+			m.recordFailure(dropped.Notifier, dropped.kind(), dropped.address(), errors.New("dropped from full delivery queue"))
+		}
+		log.Printf("Delivery queue full, dropped the %d oldest alerts", excess)
+		m.state.Queue = m.state.Queue[excess:]
+	}
+	m.save()
+	select {
+	case m.wakeup() <- struct{}{}:
+	default:
+	}
+}
+
+// wakeup returns the channel that wakes the delivery worker; callers must
+// hold m.mu
+func (m *Monitor) wakeup() chan struct{} {
+	if m.wake == nil {
+		m.wake = make(chan struct{}, 1)
+	}
+	return m.wake
+}
+
+// notifierKey returns the name a notifier is queued under: its name, with
+// #2, #3... appended for later notifiers of the same name, such as Slack
+// workspaces; callers must hold m.mu
+func (m *Monitor) notifierKey(n notify.Notifier) string {
+	seen := 0
+	for _, other := range m.withArchives(m.notifiers) {
+		if other.Name() != n.Name() {
+			continue
+		}
+		seen++
+		if other == n {
+			break
+		}
+	}
+	if seen <= 1 {
+		return n.Name()
+	}
+	return fmt.Sprintf("%s#%d", n.Name(), seen)
+}
+
+// Queued returns the alerts waiting to be delivered, oldest first
+func (m *Monitor) Queued() []QueuedAlert {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]QueuedAlert{}, m.state.Queue...)
+}
+
+// RunDelivery delivers queued alerts until stop is closed, including any
+// left in the queue by a previous run. Each notifier gets its alerts in
+// order; one that fails is retried with growing delays, or after the delay
+// it asked for when rate limited, without holding up other notifiers.
+func (m *Monitor) RunDelivery(stop <-chan struct{}) {
+	m.mu.Lock()
+	wake := m.wakeup()
+	m.mu.Unlock()
+	for {
+		wait := m.deliverQueued()
+		select {
+		case <-stop:
+			return
+		case <-wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliverQueued attempts the first due entry of every notifier, and
+// returns how long to wait before the next attempt
+func (m *Monitor) deliverQueued() time.Duration {
+	m.mu.Lock()
+	now := m.now()
+	wait := queueIdleWait
+	notifiers := map[string]notify.Notifier{}
+	for _, n := range m.withArchives(m.notifiers) {
+		notifiers[m.notifierKey(n)] = n
+	}
+	var due []QueuedAlert
+	heads := map[string]bool{}
+	for _, entry := range m.state.Queue {
+		if heads[entry.Notifier] {
+			continue
+		}
+		heads[entry.Notifier] = true
+		if delay := time.Unix(entry.NextAttempt, 0).Sub(now); delay > 0 {
+			if delay < wait {
+				wait = delay
+			}
+			continue
+		}
+		due = append(due, entry)
+	}
+	m.mu.Unlock()
+	if len(due) == 0 {
+		return wait
+	}
+
+	errs := m.sendConcurrently(len(due), func(i int) error {
+		entry := due[i]
+		n, ok := notifiers[entry.Notifier]
+		if !ok {
+			return errNotifierGone
+		}
+		key := entryKey(entry.Change, entry.Alert)
+		m.mu.Lock()
+		sent := m.wasSent(entry.Notifier, key)
+		m.mu.Unlock()
+		if sent {
+			return nil // Sent before a crash left it queued
+		}
+		if err := send(n, entry); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.markSent(entry.Notifier, key)
+		m.mu.Unlock()
+		return nil
+	})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, entry := range due {
+		m.finishQueued(entry, errs[i])
+	}
+	m.save()
+	return 0
+}
+
+// errNotifierGone drops entries for notifiers that are no longer configured
+var errNotifierGone = errors.New("notifier is no longer configured")
+
+// finishQueued removes a delivered entry, or schedules its retry; callers
+// must hold m.mu
+func (m *Monitor) finishQueued(entry QueuedAlert, err error) {
+	i := 0
+	for i < len(m.state.Queue) && m.state.Queue[i].ID != entry.ID {
+		i++
+	}
+	if i == len(m.state.Queue) {
+		return
+	}
+	if err == nil {
+		m.state.Queue = append(m.state.Queue[:i], m.state.Queue[i+1:]...)
+		return
+	}
+
+	queued := &m.state.Queue[i]
+	queued.Attempts++
+	queued.LastError = err.Error()
+	if err == errNotifierGone || queued.Attempts >= maxQueueAttempts {
+		log.Printf("Giving up sending %s %s after %d attempts: %v", entry.Notifier, entry.kind(), queued.Attempts, err)
+		m.recordFailure(entry.Notifier, entry.kind(), entry.address(), err)
+		m.state.Queue = append(m.state.Queue[:i], m.state.Queue[i+1:]...)
+		return
+	}
+	delay := queueRetryInitial << (queued.Attempts - 1)
+	if delay > queueRetryMax {
+		delay = queueRetryMax
+	}
+	var rateLimit *notify.RateLimitError
+	if errors.As(err, &rateLimit) && rateLimit.RetryAfter > 0 {
+		delay = rateLimit.RetryAfter
+	}
+	queued.NextAttempt = m.now().Add(delay).Unix()
+	log.Printf("Error sending %s %s, retrying in %s: %v", entry.Notifier, entry.kind(), delay, err)
+}
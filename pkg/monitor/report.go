@@ -0,0 +1,175 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+)
+
+// Earnings summarises the incoming transfers to an address over a period.
+// Every balance increase counts as one payout.
+type Earnings struct {
+	Address  string        `json:"address"`
+	Label    string        `json:"label,omitempty"`
+	Received int64         `json:"received"` // nick
+	Payouts  int           `json:"payouts"`
+	Period   time.Duration `json:"period"`
+}
+
+// AveragePayout returns the mean payout size in nick
+func (e Earnings) AveragePayout() int64 {
+	if e.Payouts == 0 {
+		return 0
+	}
+	return e.Received / int64(e.Payouts)
+}
+
+// DailyRate returns the estimated nick received per day
+func (e Earnings) DailyRate() int64 {
+	days := e.Period.Hours() / 24
+	if days <= 0 {
+		return 0
+	}
+	return int64(float64(e.Received) / days)
+}
+
+// projectionBasis is how far back the earn rate behind report projections
+// is measured, and minProjectionBasis the least history it needs
+const (
+	projectionBasis    = 7 * 24 * time.Hour
+	minProjectionBasis = 24 * time.Hour
+)
+
+// projectedRate returns an address's current earn rate in nick per day,
+// measured over the trailing projectionBasis or as much of it as the
+// history covers, or false if the history is too short; callers must hold
+// m.mu
+func (m *Monitor) projectedRate(address string, now time.Time) (int64, bool) {
+	history := m.state.BalanceHistory[address]
+	if len(history) == 0 {
+		return 0, false
+	}
+	basis := projectionBasis
+	if covered := now.Sub(time.Unix(history[0].Time, 0)); covered < basis {
+		basis = covered
+	}
+	if basis < minProjectionBasis {
+		return 0, false
+	}
+	var received int64
+	since := now.Add(-basis).Unix()
+	for _, payout := range payoutEvents(history) {
+		if payout.Time > since {
+			received += payout.Balance
+		}
+	}
+	return Earnings{Received: received, Period: basis}.DailyRate(), true
+}
+
+// Earnings returns the income of every address over the trailing period,
+// derived from the recorded balance history
+func (m *Monitor) Earnings(period time.Duration) []Earnings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.earnings(period, m.now())
+}
+
+// earnings implements Earnings; callers must hold m.mu
+func (m *Monitor) earnings(period time.Duration, now time.Time) []Earnings {
+	since := now.Add(-period).Unix()
+	result := make([]Earnings, 0, m.state.Balances.Len())
+	for _, b := range m.state.Balances.List() {
+		e := Earnings{Address: b.Address, Label: m.Labels[b.Address], Period: period}
+		for _, payout := range payoutEvents(m.state.BalanceHistory[b.Address]) {
+			if payout.Time > since {
+				e.Received += payout.Balance
+				e.Payouts++
+			}
+		}
+		result = append(result, e)
+	}
+	return result
+}
+
+// SendReport sends an earnings report for the trailing period to every
+// notifier; name is shown in the title, e.g. "Daily"
+func (m *Monitor) SendReport(name string, period time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	quote := m.quote()
+	alert := notify.Alert{
+		Emoji:    "⛏️",
+		Title:    name + " Earnings Report",
+		Time:     now,
+		Rule:     RuleReport,
+		Severity: notify.SeverityInfo,
+	}
+	var total Earnings
+	var totalRate int64
+	projected := false
+	var earnings []Earnings
+	m.withPeers(func() { earnings = m.earnings(period, now) })
+	for _, e := range earnings {
+		total.Received += e.Received
+		total.Payouts += e.Payouts
+		total.Period = e.Period
+
+		name := e.Address
+		if e.Label != "" {
+			name = e.Label + " (" + e.Address + ")"
+		}
+		value := m.formatEarnings(e, quote)
+		if m.ReportProjection {
+			if rate, ok := m.projectedRate(e.Address, now); ok {
+				value += "\n" + m.formatProjection(rate, quote)
+				totalRate += rate
+				projected = true
+			}
+		}
+		alert.Fields = append(alert.Fields, notify.Field{Name: name, Value: value})
+	}
+	value := m.formatEarnings(total, quote)
+	if projected {
+		value += "\n" + m.formatProjection(totalRate, quote)
+	}
+	alert.Fields = append(alert.Fields, notify.Field{Name: "Total", Value: value})
+	m.notifyAlert(alert)
+	m.state.LastReport = now.Unix()
+	m.save()
+}
+
+// LastReport returns when an earnings report was last sent, or the zero
+// time if never
+func (m *Monitor) LastReport() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return unixTime(m.state.LastReport)
+}
+
+// formatEarnings formats the report line for one address
+func (m *Monitor) formatEarnings(e Earnings, quote price.Quote) string {
+	if e.Payouts == 0 {
+		return "No payouts"
+	}
+	return fmt.Sprintf("Received %s in %d payouts · avg %s · ~%s/day",
+		m.formatAmount(e.Received, quote), e.Payouts, m.formatAmount(e.AveragePayout(), nil), m.formatAmount(e.DailyRate(), quote))
+}
+
+// formatProjection formats the projection of a daily earn rate over the
+// next week and month
+func (m *Monitor) formatProjection(rate int64, quote price.Quote) string {
+	return fmt.Sprintf("At the current rate: +%s next 7 days · +%s next 30 days", m.formatAmount(7*rate, quote), m.formatAmount(30*rate, quote))
+}
+
+// formatAmount formats nick as $NOCK, adding its fiat value when quoted
+func (m *Monitor) formatAmount(nick int64, quote price.Quote) string {
+	text := m.Format.Units(nick)
+	if len(quote) > 0 {
+		text += " (" + m.Format.Fiat(nick, quote) + ")"
+	}
+	return text
+}
@@ -0,0 +1,57 @@
+package monitor
+
+import "github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+
+// Alert rules, whose severity can be overridden with Monitor.Severities
+const (
+	RuleNew            = "new"      // First balance of an address
+	RuleIncrease       = "increase" // Balance went up
+	RuleDecrease       = "decrease" // Balance went down
+	RuleTransaction    = "transaction"
+	RuleHighFee        = "high_fee"
+	RuleUnlock         = "unlock"
+	RuleLockedDecrease = "locked_decrease"
+	RuleCatchUp        = "catch_up"
+	RuleReport         = "report"
+	RulePayoutOverdue  = "payout_overdue"
+	RuleUTXO           = "utxo"
+	RuleDust           = "dust"
+	RuleFlapping       = "flapping"
+	RuleNode           = "node"
+	RuleDiscovery      = "discovery"
+	RulePrice          = "price"
+	RuleStartup        = "startup"
+	RuleInflow         = "inflow"
+	RuleDormant        = "dormant"
+	RuleSigners        = "signers"
+	RuleRPC            = "rpc"
+)
+
+// AlertRules lists every alert rule
+var AlertRules = []string{
+	RuleNew, RuleIncrease, RuleDecrease, RuleTransaction, RuleHighFee, RuleUnlock, RuleLockedDecrease, RuleCatchUp,
+	RuleReport, RulePayoutOverdue, RuleUTXO, RuleDust, RuleFlapping, RuleNode, RuleDiscovery, RulePrice, RuleStartup,
+	RuleInflow, RuleDormant, RuleSigners, RuleRPC,
+}
+
+// changeRule returns the rule of a balance change alert and its default
+// severity: decreases are warnings, everything else is informational
+func changeRule(change notify.Change) (string, notify.Severity) {
+	switch {
+	case change.Initial:
+		return RuleNew, notify.SeverityInfo
+	case change.Delta() < 0:
+		return RuleDecrease, notify.SeverityWarning
+	default:
+		return RuleIncrease, notify.SeverityInfo
+	}
+}
+
+// severity returns the configured severity of a rule, or def when it isn't
+// overridden
+func (m *Monitor) severity(rule string, def notify.Severity) notify.Severity {
+	if severity, ok := m.Severities[rule]; ok {
+		return severity
+	}
+	return def
+}
@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"hash/fnv"
+	"log"
+	"time"
+)
+
+// CheckShard checks the next of the CheckShards slices of the watchlist,
+// which are called in turn, and reports whether it completed a cycle
+// through all of them. An address always falls in the same slice, so
+// adding addresses doesn't move the others. The position is saved with the
+// state, so a restart resumes with the slice it was due to check.
+//
+// Work that concerns the whole watchlist follows the cycle: pattern
+// membership is refreshed before the first slice, and wallets are checked,
+// catch-up messages sent and LastChecked updated after the last. Changes are
+// combined per slice. Each slice gets its share of CycleDeadline. Only the slice's results are returned, and m.mu is
+// only held while checking the slice, so API requests and alerts needn't
+// wait for the whole watchlist.
+func (m *Monitor) CheckShard() (results []CheckResult, complete bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	shards := m.CheckShards
+	if shards < 1 {
+		shards = 1
+	}
+	if m.state.CheckShards != shards || m.state.NextShard >= shards {
+		m.state.CheckShards, m.state.NextShard = shards, 0
+	}
+	shard := m.state.NextShard
+	last := shard == shards-1
+
+	now := m.now()
+	if !m.catchingUp {
+		m.catchingUp = m.offline(now)
+	}
+	m.combining = m.CombineChanges > 0
+	if shard == 0 {
+		m.expandPatterns()
+	}
+
+	var addresses []string
+	for _, address := range m.watchedAddresses() {
+		if shardOf(address, shards) == shard {
+			addresses = append(addresses, address)
+		}
+	}
+	deadline := m.CycleDeadline / time.Duration(shards)
+	results, inTime := m.checkUntil(shard, m.owned(addresses), time.Now(), deadline)
+	if last && inTime {
+		for _, w := range m.ownedWallets() {
+			results = append(results, m.checkWallet(w)...)
+		}
+	} else if last && len(m.Wallets) > 0 {
+		log.Printf("Skipping wallets until a slice finishes within %s", deadline)
+	}
+
+	if m.combining {
+		m.notifyCombined(m.combined)
+		m.combining, m.combined = false, nil
+	}
+	if last {
+		if m.catchingUp {
+			m.notifyCatchUp(m.caughtUp, time.Unix(m.state.LastChecked, 0), now)
+			m.catchingUp, m.caughtUp = false, nil
+		}
+		m.state.LastChecked = now.Unix()
+	}
+	m.state.NextShard = (shard + 1) % shards
+	m.checkCircuits()
+
+	m.save()
+	return results, last
+}
+
+// shardOf returns which of shards slices an address belongs to
+func shardOf(address string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(address))
+	return int(h.Sum32() % uint32(shards))
+}
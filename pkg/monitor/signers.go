@@ -0,0 +1,163 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// SignerSource is implemented by balance sources that report the signing
+// configuration guarding an address's funds, such as an m-of-n multisig
+// lock
+type SignerSource interface {
+	// Signers returns how many of which keys must sign to spend the funds
+	Signers(address string) (threshold int, signers []string, err error)
+}
+
+// SigningConfig is who can spend an address's funds: any Threshold of the
+// Signers' keys
+type SigningConfig struct {
+	Threshold int      `json:"threshold"`
+	Signers   []string `json:"signers"` // Sorted
+	Updated   int64    `json:"updated"`
+}
+
+// Multisig reports whether spending takes more than one key to choose from
+func (c SigningConfig) Multisig() bool {
+	return len(c.Signers) > 1
+}
+
+// String describes the configuration, e.g. "2-of-3 multisig"
+func (c SigningConfig) String() string {
+	if !c.Multisig() {
+		return "single signer"
+	}
+	return fmt.Sprintf("%d-of-%d multisig", c.Threshold, len(c.Signers))
+}
+
+// sameSigners reports whether two configurations take the same threshold
+// of the same keys
+func (c SigningConfig) sameSigners(other SigningConfig) bool {
+	if c.Threshold != other.Threshold || len(c.Signers) != len(other.Signers) {
+		return false
+	}
+	for i := range c.Signers {
+		if c.Signers[i] != other.Signers[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Signers implements SignerSource by asking the address's adapter, if it
+// reports signing configurations
+func (mc *MultiChain) Signers(address string) (int, []string, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(SignerSource)
+	if !ok {
+		return 0, nil, nil
+	}
+	return source.Signers(plain)
+}
+
+// SigningConfigs returns the signing configuration of each address, for
+// addresses checked while signer tracking is enabled
+func (m *Monitor) SigningConfigs() map[string]SigningConfig {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	configs := make(map[string]SigningConfig, len(m.state.Signers))
+	for address, config := range m.state.Signers {
+		configs[address] = config
+	}
+	return configs
+}
+
+// signing describes the signing configuration of a multisig address for
+// summaries, or returns "" for single-signer and untracked addresses;
+// callers must hold m.mu
+func (m *Monitor) signing(address string) string {
+	if config := m.state.Signers[address]; config.Multisig() {
+		return config.String()
+	}
+	return ""
+}
+
+// checkSigners refreshes the signing configuration of an address and
+// alerts when its threshold or signer set changed, a critical event for a
+// treasury; callers must hold m.mu
+func (m *Monitor) checkSigners(address string) {
+	source, ok := m.Source.(SignerSource)
+	if !ok || !m.TrackSigners {
+		return
+	}
+	threshold, signers, err := source.Signers(address)
+	if err != nil {
+		log.Printf("Error fetching signers for %s: %v", address, err)
+		return
+	}
+	if len(signers) == 0 {
+		return
+	}
+	now := m.now()
+	config := SigningConfig{Threshold: threshold, Signers: append([]string{}, signers...), Updated: now.Unix()}
+	sort.Strings(config.Signers)
+
+	previous, known := m.state.Signers[address]
+	if m.state.Signers == nil {
+		m.state.Signers = map[string]SigningConfig{}
+	}
+	m.state.Signers[address] = config
+	if !known || previous.sameSigners(config) || m.isMuted(address, now) {
+		return
+	}
+
+	added, removed := diffSigners(previous.Signers, config.Signers)
+	fields := []notify.Field{
+		{Name: "Address", Value: address + labelText(m.Labels[address])},
+		{Name: "Before", Value: previous.String()},
+		{Name: "After", Value: config.String()},
+	}
+	if len(added) > 0 {
+		fields = append(fields, notify.Field{Name: "Signers Added", Value: strings.Join(added, "\n")})
+	}
+	if len(removed) > 0 {
+		fields = append(fields, notify.Field{Name: "Signers Removed", Value: strings.Join(removed, "\n")})
+	}
+	m.notifyAlert(notify.Alert{
+		Emoji:    "🔐",
+		Title:    "Signer set changed",
+		Rule:     RuleSigners,
+		Severity: notify.SeverityCritical,
+		Fields:   fields,
+		Time:     now,
+	})
+}
+
+// diffSigners returns the keys only in after and those only in before,
+// both sorted
+func diffSigners(before, after []string) (added, removed []string) {
+	had := make(map[string]bool, len(before))
+	for _, key := range before {
+		had[key] = true
+	}
+	has := make(map[string]bool, len(after))
+	for _, key := range after {
+		has[key] = true
+		if !had[key] {
+			added = append(added, key)
+		}
+	}
+	for _, key := range before {
+		if !has[key] {
+			removed = append(removed, key)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,37 @@
+package monitor
+
+import (
+	"fmt"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// SendStartup sends a one-time snapshot of every balance, labelled as the
+// start of monitoring rather than a change, so operators can confirm the
+// alerter is live and see the baseline
+func (m *Monitor) SendStartup() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	balances := m.summary(m.SummaryTags)
+	quote := m.quote()
+	alert := notify.Alert{
+		Emoji:    "🟢",
+		Title:    "Monitoring started",
+		Time:     m.now(),
+		Rule:     RuleStartup,
+		Severity: notify.SeverityInfo,
+		Fields:   []notify.Field{{Name: "Watching", Value: fmt.Sprintf("%d addresses", len(balances))}},
+	}
+	var total int64
+	for _, b := range balances {
+		total += b.CurrentBalance
+		name := b.Address
+		if b.Label != "" {
+			name = b.Label + " (" + b.Address + ")"
+		}
+		alert.Fields = append(alert.Fields, notify.Field{Name: name, Value: m.formatAmount(b.CurrentBalance, quote)})
+	}
+	alert.Fields = append(alert.Fields, notify.Field{Name: "Total", Value: m.formatAmount(total, quote)})
+	m.notifyAlert(alert)
+}
@@ -0,0 +1,389 @@
+package monitor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// BalanceData stores the balance information for an address
+type BalanceData struct {
+	Address        string `json:"address"`
+	CurrentBalance int64  `json:"currentBalance"`
+	LastUpdated    int64  `json:"lastUpdated"`
+}
+
+// Balances holds the stored balance of each address, looked up by address
+// in constant time but kept in the order addresses were first checked,
+// which is how they serialize and how unsorted summaries list them. The
+// zero value is empty and ready to use.
+type Balances struct {
+	list  []BalanceData
+	index map[string]int // Position of each address in list
+}
+
+// Get returns the stored balance of address
+func (b *Balances) Get(address string) (BalanceData, bool) {
+	i, ok := b.index[address]
+	if !ok {
+		return BalanceData{}, false
+	}
+	return b.list[i], true
+}
+
+// Has reports whether a balance is stored for address
+func (b *Balances) Has(address string) bool {
+	_, ok := b.index[address]
+	return ok
+}
+
+// Set stores balance, replacing the one of its address if any, or adding
+// it after the others
+func (b *Balances) Set(balance BalanceData) {
+	if i, ok := b.index[balance.Address]; ok {
+		b.list[i] = balance
+		return
+	}
+	if b.index == nil {
+		b.index = map[string]int{}
+	}
+	b.index[balance.Address] = len(b.list)
+	b.list = append(b.list, balance)
+}
+
+// Delete removes the stored balance of address. It shifts the ones after
+// it to keep their order, which is fine for the rare removals of forget.
+func (b *Balances) Delete(address string) {
+	i, ok := b.index[address]
+	if !ok {
+		return
+	}
+	delete(b.index, address)
+	b.list = append(b.list[:i], b.list[i+1:]...)
+	for ; i < len(b.list); i++ {
+		b.index[b.list[i].Address] = i
+	}
+}
+
+// Len returns the number of stored balances
+func (b *Balances) Len() int {
+	return len(b.list)
+}
+
+// List returns the stored balances in order. The slice is shared, so
+// callers must not modify it, nor keep it across changes.
+func (b *Balances) List() []BalanceData {
+	return b.list
+}
+
+// MarshalJSON encodes the balances as an array in their stored order
+func (b Balances) MarshalJSON() ([]byte, error) {
+	if b.list == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal(b.list)
+}
+
+// UnmarshalJSON decodes an array of balances, keeping the last of any
+// address listed twice at the position of its first
+func (b *Balances) UnmarshalJSON(data []byte) error {
+	var list []BalanceData
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*b = Balances{}
+	for _, balance := range list {
+		b.Set(balance)
+	}
+	return nil
+}
+
+// DeliveryFailure records a notification that could not be delivered
+type DeliveryFailure struct {
+	Time     int64  `json:"time"`
+	Notifier string `json:"notifier"`
+	Kind     string `json:"kind"` // "change", "summary", or "alert"
+	Address  string `json:"address,omitempty"`
+	Error    string `json:"error"`
+}
+
+// State holds the current state of balances
+type State struct {
+	Balances            Balances                   `json:"balances"`
+	LastChecked         int64                      `json:"lastChecked,omitempty"`   // When CheckAll last completed
+	LastSummary         int64                      `json:"lastSummary,omitempty"`   // When SendSummary last ran
+	LastSummaries       map[string]int64           `json:"lastSummaries,omitempty"` // When SendSummaryTo last ran, by notifier name
+	LastReport          int64                      `json:"lastReport,omitempty"`    // When SendReport last ran
+	AddressStatus       map[string]AddressStatus   `json:"addressStatus,omitempty"`
+	WatchedAddresses    []string                   `json:"watchedAddresses,omitempty"`
+	MutedUntil          map[string]int64           `json:"mutedUntil,omitempty"`
+	PinnedSummary       *notify.PinnedSummary      `json:"pinnedSummary,omitempty"`
+	DeliveryFailures    []DeliveryFailure          `json:"deliveryFailures,omitempty"`
+	PriceHistory        []PriceSample              `json:"priceHistory,omitempty"`
+	PriceAlertsFired    map[string]int64           `json:"priceAlertsFired,omitempty"`
+	BalanceHistory      map[string][]BalanceSample `json:"balanceHistory,omitempty"`
+	CostBasis           map[string]CostBasis       `json:"costBasis,omitempty"`
+	WalletAddresses     map[string][]string        `json:"walletAddresses,omitempty"`     // Derived addresses by wallet name
+	DiscoveredAddresses map[string]int64           `json:"discoveredAddresses,omitempty"` // When each related address was first seen
+	PayoutAlertsFired   map[string]int64           `json:"payoutAlertsFired,omitempty"`   // Last payout time alerted on as overdue
+	SeenTransactions    map[string][]string        `json:"seenTransactions,omitempty"`    // Recent transaction IDs by address
+	UTXOs               map[string]UTXOStats       `json:"utxos,omitempty"`
+	LockedBalances      map[string]int64           `json:"lockedBalances,omitempty"` // Locked or staked nick by address
+	Node                *NodeState                 `json:"node,omitempty"`
+	PendingZero         map[string]int64           `json:"pendingZero,omitempty"`      // When an unconfirmed zero balance was first read
+	Flapping            map[string]FlapState       `json:"flapping,omitempty"`         // Addresses whose readings are flapping
+	PatternAddresses    map[string][]string        `json:"patternAddresses,omitempty"` // Addresses matching each watched pattern
+	Queue               []QueuedAlert              `json:"queue,omitempty"`            // Alerts waiting for RunDelivery
+	QueueSeq            int64                      `json:"queueSeq,omitempty"`         // ID of the last queued alert
+	SentKeys            map[string]int64           `json:"sentKeys,omitempty"`         // When each "notifier key" idempotency key was sent
+	AlertsSent          []int64                    `json:"alertsSent,omitempty"`       // When each alert was delivered, for summary stats
+	CheckErrors         []int64                    `json:"checkErrors,omitempty"`      // When each balance query failed, for summary stats
+	Subscriptions       []Subscription             `json:"subscriptions,omitempty"`    // Chats that subscribed themselves to an address
+	SubscriberPrefs     map[int64]SubscriberPrefs  `json:"subscriberPrefs,omitempty"`  // Settings of subscriber chats
+	AddressBook         []AddressBookEntry         `json:"addressBook,omitempty"`      // Addresses last synced from the address book
+	InflowShortfalls    map[string]int64           `json:"inflowShortfalls,omitempty"` // When each address was alerted on as short of its expected inflow
+	LastActivity        map[string]int64           `json:"lastActivity,omitempty"`     // When each address's balance last moved, for dormancy alerts
+	Signers             map[string]SigningConfig   `json:"signers,omitempty"`          // Signing configuration of each address
+	CheckShards         int                        `json:"checkShards,omitempty"`      // Number of shards NextShard counts in
+	NextShard           int                        `json:"nextShard,omitempty"`        // Shard CheckShard checks next
+	OpenCircuits        map[string]int64           `json:"openCircuits,omitempty"`     // When each RPC endpoint's circuit opened
+}
+
+// Store persists the monitor state between runs
+type Store interface {
+	Load() (State, error)
+	Save(state State) error
+}
+
+// BalanceStreamer is implemented by a Store that can read its stored
+// balances one at a time, so tools that only need balances don't load the
+// whole state, which a database backend may hold far more of than fits in
+// memory
+type BalanceStreamer interface {
+	// EachBalance calls fn with every stored balance in order, stopping at
+	// the first error fn returns
+	EachBalance(fn func(BalanceData) error) error
+}
+
+// SentRecorder is implemented by a Store that can record the idempotency
+// key of a sent alert the moment it is sent, without saving the whole
+// state, so a crash before the next save doesn't send the alert again.
+// Its Load must return the keys recorded since the last Save in
+// State.SentKeys.
+type SentRecorder interface {
+	// RecordSent records that the alert with a "notifier key" key was sent
+	RecordSent(key string, at time.Time) error
+}
+
+// EachStoredBalance calls fn with every balance in store, streaming them if
+// it is a BalanceStreamer and loading its state otherwise
+func EachStoredBalance(store Store, fn func(BalanceData) error) error {
+	if streamer, ok := store.(BalanceStreamer); ok {
+		return streamer.EachBalance(fn)
+	}
+	state, err := store.Load()
+	if err != nil {
+		return err
+	}
+	for _, b := range state.Balances.List() {
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileStore keeps the state in a JSON file
+type FileStore struct {
+	Path string
+}
+
+// Load loads the previous balances from file
+func (f FileStore) Load() (State, error) {
+	var state State
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// Alerts may have been sent before the first save
+			return state, f.loadSent(&state)
+		}
+		return state, err
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return state, err
+	}
+	return state, f.loadSent(&state)
+}
+
+// Save saves the current balances to file. The file is replaced in one
+// step, so other instances of a cluster never read it half written. Sent
+// keys recorded since the last save are in the new file, so their log is
+// removed.
+func (f FileStore) Save(state State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := f.Path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, f.Path); err != nil {
+		return err
+	}
+	if err := os.Remove(f.sentPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// sentPath is the log of keys recorded by RecordSent since the last Save
+func (f FileStore) sentPath() string {
+	return f.Path + ".sent"
+}
+
+// RecordSent implements SentRecorder, appending the key to a log next to
+// the state file and syncing it to disk before returning
+func (f FileStore) RecordSent(key string, at time.Time) error {
+	file, err := os.OpenFile(f.sentPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(file, "%d %s\n", at.Unix(), key); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// loadSent adds the keys recorded by RecordSent since the last Save to
+// state. A line cut short by a crash while it was written is skipped.
+func (f FileStore) loadSent(state *State) error {
+	file, err := os.Open(f.sentPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		at, key, ok := strings.Cut(scanner.Text(), " ")
+		sent, err := strconv.ParseInt(at, 10, 64)
+		if !ok || err != nil || key == "" {
+			continue
+		}
+		if state.SentKeys == nil {
+			state.SentKeys = map[string]int64{}
+		}
+		if sent > state.SentKeys[key] {
+			state.SentKeys[key] = sent
+		}
+	}
+	return scanner.Err()
+}
+
+// EachBalance implements BalanceStreamer, decoding the balances array one
+// entry at a time and stopping after it without decoding the rest
+func (f FileStore) EachBalance(fn func(BalanceData) error) error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key != "balances" {
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		token, err := decoder.Token()
+		if err != nil || token == nil {
+			return err // A null array has no balances
+		}
+		if token != json.Delim('[') {
+			return fmt.Errorf("expected [ in state, got %v", token)
+		}
+		for decoder.More() {
+			var b BalanceData
+			if err := decoder.Decode(&b); err != nil {
+				return err
+			}
+			if err := fn(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// expectDelim reads the next token of decoder, failing unless it is delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %s in state, got %v", delim, token)
+	}
+	return nil
+}
+
+// MemoryStore keeps the state in memory only, for replays and tests
+type MemoryStore struct {
+	mu    sync.Mutex
+	state State
+}
+
+// Load returns the last saved state
+func (s *MemoryStore) Load() (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state, nil
+}
+
+// Save keeps the state until the next Load
+func (s *MemoryStore) Save(state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	return nil
+}
+
+// EachBalance implements BalanceStreamer
+func (s *MemoryStore) EachBalance(fn func(BalanceData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.state.Balances.List() {
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package monitor
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// balanceAddresses lists the addresses of balances in order
+func balanceAddresses(b *Balances) []string {
+	addresses := []string{}
+	for _, balance := range b.List() {
+		addresses = append(addresses, balance.Address)
+	}
+	return addresses
+}
+
+// checkIndex fails unless every listed balance is found by Get
+func checkIndex(t *testing.T, b *Balances) {
+	t.Helper()
+	for _, want := range b.List() {
+		got, ok := b.Get(want.Address)
+		if !ok || got != want {
+			t.Errorf("Get(%q) = %+v, %v; want %+v", want.Address, got, ok, want)
+		}
+	}
+	if len(b.index) != b.Len() {
+		t.Errorf("index has %d entries for %d balances", len(b.index), b.Len())
+	}
+}
+
+func TestBalancesSet(t *testing.T) {
+	tests := []struct {
+		name string
+		set  []BalanceData
+		want []BalanceData
+	}{
+		{"empty", nil, nil},
+		{
+			"appends in order",
+			[]BalanceData{{Address: "a", CurrentBalance: 1}, {Address: "b", CurrentBalance: 2}},
+			[]BalanceData{{Address: "a", CurrentBalance: 1}, {Address: "b", CurrentBalance: 2}},
+		},
+		{
+			"replaces in place",
+			[]BalanceData{{Address: "a", CurrentBalance: 1}, {Address: "b", CurrentBalance: 2}, {Address: "a", CurrentBalance: 3}},
+			[]BalanceData{{Address: "a", CurrentBalance: 3}, {Address: "b", CurrentBalance: 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Balances
+			for _, balance := range tt.set {
+				b.Set(balance)
+			}
+			if got := b.List(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %+v, want %+v", got, tt.want)
+			}
+			checkIndex(t, &b)
+		})
+	}
+}
+
+func TestBalancesDelete(t *testing.T) {
+	tests := []struct {
+		name   string
+		delete string
+		want   []string
+	}{
+		{"first", "a", []string{"b", "c"}},
+		{"middle", "b", []string{"a", "c"}},
+		{"last", "c", []string{"a", "b"}},
+		{"missing", "d", []string{"a", "b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Balances
+			for _, address := range []string{"a", "b", "c"} {
+				b.Set(BalanceData{Address: address})
+			}
+			b.Delete(tt.delete)
+			if got := balanceAddresses(&b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addresses = %v, want %v", got, tt.want)
+			}
+			if b.Has(tt.delete) {
+				t.Errorf("Has(%q) after Delete", tt.delete)
+			}
+			checkIndex(t, &b)
+		})
+	}
+}
+
+func TestBalancesUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []BalanceData
+		wantErr bool
+	}{
+		{"empty", `[]`, nil, false},
+		{"null", `null`, nil, false},
+		{
+			"in order",
+			`[{"address":"b","currentBalance":2},{"address":"a","currentBalance":1}]`,
+			[]BalanceData{{Address: "b", CurrentBalance: 2}, {Address: "a", CurrentBalance: 1}},
+			false,
+		},
+		{
+			"duplicate keeps last at first position",
+			`[{"address":"a","currentBalance":1},{"address":"b","currentBalance":2},{"address":"a","currentBalance":3}]`,
+			[]BalanceData{{Address: "a", CurrentBalance: 3}, {Address: "b", CurrentBalance: 2}},
+			false,
+		},
+		{"not an array", `{"address":"a"}`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := Balances{}
+			b.Set(BalanceData{Address: "stale"})
+			err := json.Unmarshal([]byte(tt.data), &b)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := b.List(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %+v, want %+v", got, tt.want)
+			}
+			checkIndex(t, &b)
+		})
+	}
+}
+
+func TestBalancesRoundTrip(t *testing.T) {
+	var b Balances
+	for _, address := range []string{"c", "a", "b"} {
+		b.Set(BalanceData{Address: address, CurrentBalance: 5})
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Balances
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.List(), b.List()) {
+		t.Errorf("round trip = %+v, want %+v", decoded.List(), b.List())
+	}
+	if data, _ := json.Marshal(Balances{}); string(data) != "[]" {
+		t.Errorf("empty Balances marshal to %s, want []", data)
+	}
+}
+
+func TestFileStoreEachBalance(t *testing.T) {
+	tests := []struct {
+		name    string
+		state   string // "" for no file
+		want    []string
+		wantErr bool
+	}{
+		{"no file", "", []string{}, false},
+		{"balances first", `{"balances":[{"address":"a"},{"address":"b"}],"lastChecked":1}`, []string{"a", "b"}, false},
+		{
+			"balances after other keys",
+			`{"lastChecked":1,"mutedUntil":{"a":2},"balanceHistory":{"a":[{"time":1,"balance":2}]},"balances":[{"address":"b"},{"address":"a"}]}`,
+			[]string{"b", "a"},
+			false,
+		},
+		{"missing balances", `{"lastChecked":1,"watchedAddresses":["a"]}`, []string{}, false},
+		{"null balances", `{"balances":null,"lastChecked":1}`, []string{}, false},
+		{"empty balances", `{"balances":[]}`, []string{}, false},
+		{"balances not an array", `{"balances":{"a":1}}`, nil, true},
+		{"not an object", `[]`, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "balances.json")
+			if tt.state != "" {
+				if err := os.WriteFile(path, []byte(tt.state), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			got := []string{}
+			err := FileStore{Path: path}.EachBalance(func(b BalanceData) error {
+				got = append(got, b.Address)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("EachBalance error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EachBalance visited %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
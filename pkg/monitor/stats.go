@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// RuleSummaryStats is the rule of the alerting activity message sent with
+// summaries; it isn't an alert rule, so it isn't in AlertRules
+const RuleSummaryStats = "summary_stats"
+
+// maxActivityTimes caps how many alert and check error times are kept
+const maxActivityTimes = 10000
+
+// SummaryStats is the alerting activity over a period
+type SummaryStats struct {
+	Since          time.Time
+	AlertsSent     int    // Change and other alerts delivered to notifiers
+	CheckErrors    int    // Failed balance queries
+	LargestAddress string // Address of the largest single change, empty if none
+	LargestDelta   int64
+	NetFlow        int64 // Sum of every change
+}
+
+// recordTime appends now to a list of activity times, dropping times older
+// than the history retention and beyond maxActivityTimes; callers must
+// hold m.mu
+func (m *Monitor) recordTime(times []int64) []int64 {
+	now := m.now()
+	times = append(times, now.Unix())
+	cutoff := now.Add(-historyRetention).Unix()
+	for len(times) > 0 && times[0] < cutoff {
+		times = times[1:]
+	}
+	if excess := len(times) - maxActivityTimes; excess > 0 {
+		times = times[excess:]
+	}
+	return times
+}
+
+// summaryStats counts the activity after since; callers must hold m.mu
+func (m *Monitor) summaryStats(since time.Time) SummaryStats {
+	stats := SummaryStats{Since: since}
+	after := func(t int64) bool { return t > since.Unix() }
+	for _, t := range m.state.AlertsSent {
+		if after(t) {
+			stats.AlertsSent++
+		}
+	}
+	for _, t := range m.state.CheckErrors {
+		if after(t) {
+			stats.CheckErrors++
+		}
+	}
+	for address, history := range m.state.BalanceHistory {
+		if !m.HasTag(address, m.SummaryTags...) {
+			continue
+		}
+		for i := 1; i < len(history); i++ {
+			if !after(history[i].Time) {
+				continue
+			}
+			delta := history[i].Balance - history[i-1].Balance
+			stats.NetFlow += delta
+			if magnitude(delta) > magnitude(stats.LargestDelta) {
+				stats.LargestAddress, stats.LargestDelta = address, delta
+			}
+		}
+	}
+	return stats
+}
+
+// summaryStatsAlert renders stats as the message sent after a summary
+func (m *Monitor) summaryStatsAlert(stats SummaryStats, now time.Time) notify.Alert {
+	largest := "-"
+	if stats.LargestAddress != "" {
+		name := stats.LargestAddress
+		if label := m.Labels[name]; label != "" {
+			name = label
+		}
+		largest = fmt.Sprintf("%s (%s)", m.Format.Delta(stats.LargestDelta), name)
+	}
+	return notify.Alert{
+		Emoji: "📋",
+		Title: "Alerting Activity",
+		Fields: []notify.Field{
+			{Name: "Since", Value: stats.Since.In(now.Location()).Format("2006-01-02 15:04")},
+			{Name: "Alerts Sent", Value: fmt.Sprint(stats.AlertsSent)},
+			{Name: "RPC Errors", Value: fmt.Sprint(stats.CheckErrors)},
+			{Name: "Largest Change", Value: largest},
+			{Name: "Net Flow", Value: m.Format.Delta(stats.NetFlow)},
+		},
+		Time:     now,
+		Rule:     RuleSummaryStats,
+		Severity: notify.SeverityInfo,
+	}
+}
+
+// magnitude returns the absolute value of an amount
+func magnitude(nick int64) int64 {
+	if nick < 0 {
+		return -nick
+	}
+	return nick
+}
@@ -0,0 +1,61 @@
+package monitor
+
+import "time"
+
+// DefaultStaleAfter is how long an address can go without a successful
+// check before summaries mark it stale
+const DefaultStaleAfter = 15 * time.Minute
+
+// AddressStatus records the outcome of the latest checks of an address
+type AddressStatus struct {
+	LastSuccess int64  `json:"lastSuccess,omitempty"`
+	LastError   string `json:"lastError,omitempty"` // Cleared by the next success
+	LastErrorAt int64  `json:"lastErrorAt,omitempty"`
+}
+
+// AddressStatuses returns the check status of every address checked so far
+func (m *Monitor) AddressStatuses() map[string]AddressStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make(map[string]AddressStatus, len(m.state.AddressStatus))
+	for address, status := range m.state.AddressStatus {
+		statuses[address] = status
+	}
+	return statuses
+}
+
+// recordCheck records the outcome of querying an address; callers must hold
+// m.mu
+func (m *Monitor) recordCheck(address string, err error) {
+	if m.state.AddressStatus == nil {
+		m.state.AddressStatus = map[string]AddressStatus{}
+	}
+	status := m.state.AddressStatus[address]
+	now := m.now().Unix()
+	if err != nil {
+		status.LastError, status.LastErrorAt = err.Error(), now
+		m.state.CheckErrors = m.recordTime(m.state.CheckErrors)
+	} else {
+		status.LastSuccess, status.LastError, status.LastErrorAt = now, "", 0
+	}
+	m.state.AddressStatus[address] = status
+}
+
+// lastSuccess returns when an address was last checked successfully and
+// whether that is longer than StaleAfter ago; callers must hold m.mu
+func (m *Monitor) lastSuccess(address string, now time.Time) (time.Time, bool) {
+	status, ok := m.state.AddressStatus[address]
+	if !ok || status.LastSuccess == 0 {
+		return time.Time{}, false
+	}
+	last := time.Unix(status.LastSuccess, 0)
+	return last, m.StaleAfter > 0 && now.Sub(last) > m.StaleAfter
+}
+
+// unixTime converts a stored Unix timestamp, where 0 means never, to a time
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
@@ -0,0 +1,172 @@
+package monitor
+
+import (
+	"errors"
+	"sort"
+)
+
+// ErrAlreadySubscribed is returned when a chat subscribes to an address it
+// is already subscribed to
+var ErrAlreadySubscribed = errors.New("already subscribed to this address")
+
+// ErrNotSubscribed is returned when a subscription doesn't exist
+var ErrNotSubscribed = errors.New("not subscribed to this address")
+
+// Subscription is a chat that subscribed itself to the alerts of one
+// address, e.g. a miner following their payout address
+type Subscription struct {
+	ChatID   int64  `json:"chatID"`
+	Username string `json:"username,omitempty"` // Of the user who subscribed, for the operator
+	Address  string `json:"address"`
+	Since    int64  `json:"since"`
+	Approved bool   `json:"approved"`           // Pending subscriptions get no alerts
+	Verified bool   `json:"verified,omitempty"` // The subscriber proved they hold the address's key
+}
+
+// SubscriberPrefs are a subscriber chat's own settings for the alerts of
+// every address it subscribed to
+type SubscriberPrefs struct {
+	MinDelta   int64  `json:"minDelta,omitempty"`   // Smallest change alerted on, in nick
+	QuietStart string `json:"quietStart,omitempty"` // HH:MM; alerts in quiet hours are sent silently
+	QuietEnd   string `json:"quietEnd,omitempty"`   // HH:MM, may be before QuietStart to span midnight
+	Timezone   string `json:"timezone,omitempty"`   // Of the quiet hours; empty for the alerter's
+	Language   string `json:"language,omitempty"`   // Of the alerts; empty for the alerter's
+}
+
+// Subscribe records a subscription of a chat to an address, approved or
+// waiting for approval, and whether the subscriber proved they own it
+func (m *Monitor) Subscribe(chatID int64, username, address string, approved, verified bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.subscription(chatID, address) != nil {
+		return ErrAlreadySubscribed
+	}
+	m.state.Subscriptions = append(m.state.Subscriptions, Subscription{
+		ChatID:   chatID,
+		Username: username,
+		Address:  address,
+		Since:    m.now().Unix(),
+		Approved: approved,
+		Verified: verified,
+	})
+	return m.Store.Save(m.state)
+}
+
+// ApproveSubscription starts sending alerts to a pending subscription
+func (m *Monitor) ApproveSubscription(chatID int64, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscription := m.subscription(chatID, address)
+	if subscription == nil {
+		return ErrNotSubscribed
+	}
+	subscription.Approved = true
+	return m.Store.Save(m.state)
+}
+
+// Unsubscribe removes a chat's subscription to an address, or to every
+// address when address is empty
+func (m *Monitor) Unsubscribe(chatID int64, address string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.state.Subscriptions[:0]
+	for _, subscription := range m.state.Subscriptions {
+		if subscription.ChatID != chatID || address != "" && subscription.Address != address {
+			kept = append(kept, subscription)
+		}
+	}
+	if len(kept) == len(m.state.Subscriptions) {
+		return ErrNotSubscribed
+	}
+	m.state.Subscriptions = kept
+	m.dropUnusedPrefs()
+	return m.Store.Save(m.state)
+}
+
+// SubscriberPrefs returns the settings of every subscriber chat that
+// changed any
+func (m *Monitor) SubscriberPrefs() map[int64]SubscriberPrefs {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prefs := make(map[int64]SubscriberPrefs, len(m.state.SubscriberPrefs))
+	for chatID, p := range m.state.SubscriberPrefs {
+		prefs[chatID] = p
+	}
+	return prefs
+}
+
+// SetSubscriberPrefs replaces the settings of a chat, which must have a
+// subscription
+func (m *Monitor) SetSubscriberPrefs(chatID int64, prefs SubscriberPrefs) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscribed := false
+	for _, subscription := range m.state.Subscriptions {
+		subscribed = subscribed || subscription.ChatID == chatID
+	}
+	if !subscribed {
+		return ErrNotSubscribed
+	}
+	if m.state.SubscriberPrefs == nil {
+		m.state.SubscriberPrefs = map[int64]SubscriberPrefs{}
+	}
+	if prefs == (SubscriberPrefs{}) {
+		delete(m.state.SubscriberPrefs, chatID)
+	} else {
+		m.state.SubscriberPrefs[chatID] = prefs
+	}
+	return m.Store.Save(m.state)
+}
+
+// dropUnusedPrefs removes the settings of chats left without
+// subscriptions; callers must hold m.mu
+func (m *Monitor) dropUnusedPrefs() {
+	subscribed := map[int64]bool{}
+	for _, subscription := range m.state.Subscriptions {
+		subscribed[subscription.ChatID] = true
+	}
+	for chatID := range m.state.SubscriberPrefs {
+		if !subscribed[chatID] {
+			delete(m.state.SubscriberPrefs, chatID)
+		}
+	}
+}
+
+// Subscriptions returns every subscription, approved or pending, ordered
+// by address and then by when it was made
+func (m *Monitor) Subscriptions() []Subscription {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	subscriptions := append([]Subscription(nil), m.state.Subscriptions...)
+	sort.SliceStable(subscriptions, func(i, j int) bool {
+		if subscriptions[i].Address != subscriptions[j].Address {
+			return subscriptions[i].Address < subscriptions[j].Address
+		}
+		return subscriptions[i].Since < subscriptions[j].Since
+	})
+	return subscriptions
+}
+
+// Subscribers returns the chats with an approved subscription to an address
+func (m *Monitor) Subscribers(address string) []int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var chats []int64
+	for _, subscription := range m.state.Subscriptions {
+		if subscription.Approved && subscription.Address == address {
+			chats = append(chats, subscription.ChatID)
+		}
+	}
+	return chats
+}
+
+// subscription returns a chat's subscription to an address, or nil;
+// callers must hold m.mu
+func (m *Monitor) subscription(chatID int64, address string) *Subscription {
+	for i := range m.state.Subscriptions {
+		if m.state.Subscriptions[i].ChatID == chatID && m.state.Subscriptions[i].Address == address {
+			return &m.state.Subscriptions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package monitor
+
+// Summary subtotals, see Monitor.SummaryGroupBy
+const (
+	GroupByGroup = "group" // Subtotal by Groups, or by wallet for derived addresses
+	GroupByTag   = "tag"   // Subtotal by each address's first tag
+)
+
+// HasTag reports whether an address carries any of the given tags; every
+// address matches when no tags are given
+func (m *Monitor) HasTag(address string, tags ...string) bool {
+	return hasAnyTag(m.Tags[address], tags)
+}
+
+// hasAnyTag reports whether tags contains any of wanted, or wanted is empty
+func hasAnyTag(tags, wanted []string) bool {
+	if len(wanted) == 0 {
+		return true
+	}
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
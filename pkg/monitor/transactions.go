@@ -0,0 +1,453 @@
+package monitor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// maxMemoLength caps the characters of a memo shown in alerts
+const maxMemoLength = 120
+
+// TransactionSource is implemented by balance sources that can list the
+// recent transactions touching an address
+type TransactionSource interface {
+	// TransactionIDs returns the IDs of the most recent transactions
+	TransactionIDs(address string) ([]string, error)
+}
+
+// FeeSource is implemented by balance sources that report the network fee
+// of the recent transactions touching an address
+type FeeSource interface {
+	// TransactionFees returns the fee in nick of each transaction by ID
+	TransactionFees(address string) (map[string]int64, error)
+}
+
+// MemoSource is implemented by balance sources that report the memos,
+// such as pool round identifiers, attached to recent transactions
+type MemoSource interface {
+	// TransactionMemos returns the raw memo of each transaction by ID
+	TransactionMemos(address string) (map[string]string, error)
+}
+
+// RawTransactionSource is implemented by balance sources that can return
+// the recent transactions of an address as the endpoint sent them, for
+// engineers inspecting the exact on-chain data
+type RawTransactionSource interface {
+	// RawTransactions returns the endpoint's unmodified JSON response
+	RawTransactions(address string) (json.RawMessage, error)
+}
+
+// TransactionIDs implements TransactionSource by asking the address's
+// adapter, if it can list transactions
+func (mc *MultiChain) TransactionIDs(address string) ([]string, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(TransactionSource)
+	if !ok {
+		return nil, nil
+	}
+	return source.TransactionIDs(plain)
+}
+
+// TransactionFees implements FeeSource by asking the address's adapter, if
+// it reports fees
+func (mc *MultiChain) TransactionFees(address string) (map[string]int64, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(FeeSource)
+	if !ok {
+		return nil, nil
+	}
+	return source.TransactionFees(plain)
+}
+
+// TransactionMemos implements MemoSource by asking the address's adapter,
+// if it reports memos
+func (mc *MultiChain) TransactionMemos(address string) (map[string]string, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(MemoSource)
+	if !ok {
+		return nil, nil
+	}
+	return source.TransactionMemos(plain)
+}
+
+// RawTransactions implements RawTransactionSource by asking the address's
+// adapter, if it returns raw transactions
+func (mc *MultiChain) RawTransactions(address string) (json.RawMessage, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(RawTransactionSource)
+	if !ok {
+		return nil, nil
+	}
+	return source.RawTransactions(plain)
+}
+
+// trackTransactions reports whether new transactions need to be tracked,
+// either to alert on them or to find the fees and memos of transfers
+func (m *Monitor) trackTransactions() bool {
+	return m.AlertOnTransactions || m.TrackFees || m.MaxFee > 0 || m.DedupeTransactions || m.ShowMemos
+}
+
+// NotifiedStore keeps which transactions alerts have reported, by address
+// and transaction ID, for DedupeTransactions, such as the SQLite database
+// of dedupe.Store
+type NotifiedStore interface {
+	// Notified returns when each reported transaction of an address was
+	// reported, as Unix time by transaction ID
+	Notified(address string) (map[string]int64, error)
+	// Record records transactions, by address, as reported at a time
+	Record(ids map[string][]string, at time.Time) error
+	// Expire forgets transactions reported before a time
+	Expire(before time.Time) error
+	// Forget forgets the reported transactions of an address
+	Forget(address string) error
+}
+
+// MemoryNotified is a NotifiedStore that only keeps its records in memory,
+// e.g. for tests
+type MemoryNotified struct {
+	mu       sync.Mutex
+	notified map[string]map[string]int64
+}
+
+// Notified implements NotifiedStore
+func (s *MemoryNotified) Notified(address string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	notified := map[string]int64{}
+	for id, at := range s.notified[address] {
+		notified[id] = at
+	}
+	return notified, nil
+}
+
+// Record implements NotifiedStore
+func (s *MemoryNotified) Record(ids map[string][]string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.notified == nil {
+		s.notified = map[string]map[string]int64{}
+	}
+	for address, txids := range ids {
+		if s.notified[address] == nil {
+			s.notified[address] = map[string]int64{}
+		}
+		for _, id := range txids {
+			s.notified[address][id] = at.Unix()
+		}
+	}
+	return nil
+}
+
+// Expire implements NotifiedStore
+func (s *MemoryNotified) Expire(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for address, notified := range s.notified {
+		for id, at := range notified {
+			if at < before.Unix() {
+				delete(notified, id)
+			}
+		}
+		if len(notified) == 0 {
+			delete(s.notified, address)
+		}
+	}
+	return nil
+}
+
+// Forget implements NotifiedStore
+func (s *MemoryNotified) Forget(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.notified, address)
+	return nil
+}
+
+// unnotified returns the transactions of an address that no alert has
+// reported yet. If the record can't be read, every transaction counts as
+// unreported, since a repeated alert beats a missed one. Callers must hold
+// m.mu.
+func (m *Monitor) unnotified(address string, ids []string) []string {
+	if m.Notified == nil {
+		return ids
+	}
+	notified, err := m.Notified.Notified(address)
+	if err != nil {
+		log.Printf("Error reading reported transactions of %s: %v", address, err)
+	}
+	pending := map[string]bool{}
+	for _, id := range m.pendingNotified[address] {
+		pending[id] = true
+	}
+	var fresh []string
+	for _, id := range ids {
+		if notified[id] == 0 && !pending[id] {
+			fresh = append(fresh, id)
+		}
+	}
+	return fresh
+}
+
+// markNotified records that an alert reported the given transactions,
+// writing them to Notified straight away so a restart, even after a crash
+// before the state is saved, never reports them again. Callers must hold
+// m.mu.
+func (m *Monitor) markNotified(address string, ids []string) {
+	if !m.DedupeTransactions || m.Notified == nil || len(ids) == 0 {
+		return
+	}
+	m.recordNotified(address, ids)
+}
+
+// recordNotified writes reported transactions of an address to Notified.
+// If that fails they are kept, still counting as reported, and retried when
+// the state is next saved. Callers must hold m.mu.
+func (m *Monitor) recordNotified(address string, ids []string) {
+	if err := m.Notified.Record(map[string][]string{address: ids}, m.now()); err != nil {
+		log.Printf("Error recording reported transactions of %s: %v", address, err)
+		if m.pendingNotified == nil {
+			m.pendingNotified = map[string][]string{}
+		}
+		m.pendingNotified[address] = append(m.pendingNotified[address], ids...)
+	}
+}
+
+// flushNotified retries writing the transactions recordNotified couldn't,
+// and forgets those reported longer ago than the history retention.
+// Callers must hold m.mu.
+func (m *Monitor) flushNotified() {
+	if m.Notified == nil {
+		return
+	}
+	now := m.now()
+	if len(m.pendingNotified) > 0 {
+		if err := m.Notified.Record(m.pendingNotified, now); err != nil {
+			log.Printf("Error recording reported transactions: %v", err)
+			return
+		}
+		m.pendingNotified = nil
+	}
+	if err := m.Notified.Expire(now.Add(-historyRetention)); err != nil {
+		log.Printf("Error expiring reported transactions: %v", err)
+	}
+}
+
+// forgetNotified drops the reported transactions of an address; callers
+// must hold m.mu
+func (m *Monitor) forgetNotified(address string) {
+	delete(m.pendingNotified, address)
+	if m.Notified == nil {
+		return
+	}
+	if err := m.Notified.Forget(address); err != nil {
+		log.Printf("Error forgetting reported transactions of %s: %v", address, err)
+	}
+}
+
+// adoptNotified copies the reported transactions of an address from
+// another instance's record, see adopt; callers must hold m.mu
+func (m *Monitor) adoptNotified(address string, from NotifiedStore) {
+	if m.Notified == nil || from == nil {
+		return
+	}
+	notified, err := from.Notified(address)
+	if err != nil {
+		log.Printf("Error reading reported transactions of %s from another instance: %v", address, err)
+		return
+	}
+	if len(notified) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(notified))
+	for id := range notified {
+		ids = append(ids, id)
+	}
+	m.recordNotified(address, ids)
+}
+
+// newTransactions returns the IDs of transactions touching an address since
+// the last check and remembers them. The first check of an address only
+// records its existing transactions. Callers must hold m.mu.
+func (m *Monitor) newTransactions(address string) []string {
+	source, ok := m.Source.(TransactionSource)
+	if !ok || !m.trackTransactions() {
+		return nil
+	}
+	ids, err := source.TransactionIDs(address)
+	if err != nil {
+		log.Printf("Error listing transactions for %s: %v", address, err)
+		return nil
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	seen, known := m.state.SeenTransactions[address]
+	if m.state.SeenTransactions == nil {
+		m.state.SeenTransactions = map[string][]string{}
+	}
+	m.state.SeenTransactions[address] = ids
+	if !known {
+		return nil
+	}
+
+	previous := map[string]bool{}
+	for _, id := range seen {
+		previous[id] = true
+	}
+	var fresh []string
+	for _, id := range ids {
+		if !previous[id] {
+			fresh = append(fresh, id)
+		}
+	}
+	return fresh
+}
+
+// notifyTransactions alerts on new transactions that left an address's
+// balance unchanged, such as self-transfers and consolidations; callers
+// must hold m.mu
+func (m *Monitor) notifyTransactions(address string, ids []string, balance int64) {
+	title := "New transaction"
+	if len(ids) > 1 {
+		title = fmt.Sprintf("%d new transactions", len(ids))
+	}
+	m.notifyAlert(notify.Alert{
+		Emoji:    "🔁",
+		Title:    title,
+		Rule:     RuleTransaction,
+		Severity: notify.SeverityInfo,
+		Fields: []notify.Field{
+			{Name: "Address", Value: address + labelText(m.Labels[address])},
+			{Name: "Transactions", Value: strings.Join(ids, "\n")},
+			{Name: "Balance (unchanged)", Value: m.Format.Balance(balance)},
+		},
+		Time: m.now(),
+	})
+}
+
+// transactionFee returns the total fee paid by the given new transactions
+// of an address, or 0 if the source doesn't report fees; callers must hold
+// m.mu
+func (m *Monitor) transactionFee(address string, ids []string) int64 {
+	source, ok := m.Source.(FeeSource)
+	if !ok {
+		return 0
+	}
+	fees, err := source.TransactionFees(address)
+	if err != nil {
+		log.Printf("Error fetching transaction fees for %s: %v", address, err)
+		return 0
+	}
+	var total int64
+	for _, id := range ids {
+		total += fees[id]
+	}
+	return total
+}
+
+// transactionMemos returns the sanitized memos of the given new
+// transactions of an address, or nil if the source doesn't report memos;
+// callers must hold m.mu
+func (m *Monitor) transactionMemos(address string, ids []string) []string {
+	source, ok := m.Source.(MemoSource)
+	if !ok {
+		return nil
+	}
+	memos, err := source.TransactionMemos(address)
+	if err != nil {
+		log.Printf("Error fetching transaction memos for %s: %v", address, err)
+		return nil
+	}
+	var decoded []string
+	for _, id := range ids {
+		if memo := SanitizeMemo(DecodeMemo(memos[id])); memo != "" {
+			decoded = append(decoded, memo)
+		}
+	}
+	return decoded
+}
+
+// DecodeMemo decodes a 0x-prefixed hex memo to text when it is valid
+// UTF-8, and returns other memos as they are
+func DecodeMemo(memo string) string {
+	if !strings.HasPrefix(memo, "0x") {
+		return memo
+	}
+	data, err := hex.DecodeString(memo[2:])
+	if err != nil || !utf8.Valid(data) {
+		return memo
+	}
+	return string(data)
+}
+
+// SanitizeMemo makes a sender-controlled memo safe to show in an alert: it
+// drops control and invisible formatting characters, such as right-to-left
+// overrides that could disguise an address, collapses whitespace, and caps
+// the length at maxMemoLength characters
+func SanitizeMemo(memo string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range memo {
+		switch {
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		case r == utf8.RuneError || unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || !unicode.IsPrint(r):
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	text := b.String()
+	if utf8.RuneCountInString(text) > maxMemoLength {
+		text = string([]rune(text)[:maxMemoLength-1]) + "…"
+	}
+	return text
+}
+
+// notifyHighFee alerts when an outgoing change paid more than MaxFee;
+// callers must hold m.mu
+func (m *Monitor) notifyHighFee(change notify.Change, ids []string) {
+	m.notifyAlert(notify.Alert{
+		Emoji:    "⛽",
+		Title:    "Fee above maximum",
+		Rule:     RuleHighFee,
+		Severity: notify.SeverityWarning,
+		Fields: []notify.Field{
+			{Name: "Address", Value: change.Address + labelText(change.Label)},
+			{Name: "Fee", Value: m.Format.Balance(change.Fee)},
+			{Name: "Maximum", Value: m.Format.Balance(m.MaxFee)},
+			{Name: "Transactions", Value: strings.Join(ids, "\n")},
+		},
+		Time: change.Time,
+	})
+}
@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// failingNotified is a NotifiedStore that can't be read
+type failingNotified struct{ MemoryNotified }
+
+// Notified implements NotifiedStore
+func (*failingNotified) Notified(address string) (map[string]int64, error) {
+	return nil, errors.New("database is locked")
+}
+
+func TestUnnotifiedAcrossRestart(t *testing.T) {
+	tests := []struct {
+		name    string
+		dedupe  bool
+		save    bool // Save before checking
+		restart bool // Check from a new monitor loaded from the store
+		want    []string
+	}{
+		{"before save", true, false, false, []string{"tx3"}},
+		{"saved", true, true, false, []string{"tx3"}},
+		{"saved and restarted", true, true, true, []string{"tx3"}},
+		{"crash before save", true, false, true, []string{"tx3"}},
+		{"dedupe off", false, true, true, []string{"tx1", "tx2", "tx3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := FileStore{Path: filepath.Join(t.TempDir(), "balances.json")}
+			notified := &MemoryNotified{}
+			m := &Monitor{Store: store, Notified: notified, DedupeTransactions: tt.dedupe}
+			m.mu.Lock()
+			m.markNotified("addr", []string{"tx1", "tx2"})
+			if tt.save {
+				m.save()
+			}
+			m.mu.Unlock()
+
+			if tt.restart {
+				m = &Monitor{Store: store, Notified: notified, DedupeTransactions: tt.dedupe}
+				if err := m.Load(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if got := m.unnotified("addr", []string{"tx1", "tx2", "tx3"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unnotified = %v, want %v", got, tt.want)
+			}
+			if got := m.unnotified("other", []string{"tx1"}); !reflect.DeepEqual(got, []string{"tx1"}) {
+				t.Errorf("unnotified of another address = %v, want [tx1]", got)
+			}
+		})
+	}
+}
+
+// flakyNotified is a NotifiedStore whose Record fails while down
+type flakyNotified struct {
+	MemoryNotified
+	down bool
+}
+
+// Record implements NotifiedStore
+func (s *flakyNotified) Record(ids map[string][]string, at time.Time) error {
+	if s.down {
+		return errors.New("disk I/O error")
+	}
+	return s.MemoryNotified.Record(ids, at)
+}
+
+func TestMarkNotifiedRetriesFailedRecord(t *testing.T) {
+	notified := &flakyNotified{down: true}
+	m := &Monitor{Store: &MemoryStore{}, Notified: notified, DedupeTransactions: true}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markNotified("addr", []string{"tx1"})
+	if got := m.unnotified("addr", []string{"tx1", "tx2"}); !reflect.DeepEqual(got, []string{"tx2"}) {
+		t.Errorf("unnotified while the record is down = %v, want [tx2]", got)
+	}
+	notified.down = false
+	m.save()
+	if recorded, _ := notified.Notified("addr"); recorded["tx1"] == 0 {
+		t.Error("tx1 not recorded once the record is back")
+	}
+	if len(m.pendingNotified) != 0 {
+		t.Errorf("pendingNotified = %v after a successful save, want none", m.pendingNotified)
+	}
+}
+
+func TestUnnotifiedExpires(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := &Monitor{
+		Store:              &MemoryStore{},
+		Notified:           &MemoryNotified{},
+		DedupeTransactions: true,
+		Clock:              func() time.Time { return now },
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markNotified("addr", []string{"old"})
+	m.save()
+	now = now.Add(historyRetention + time.Hour)
+	m.markNotified("addr", []string{"new"})
+	m.save()
+	if got, want := m.unnotified("addr", []string{"old", "new"}), []string{"old"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unnotified = %v, want %v", got, want)
+	}
+}
+
+func TestUnnotifiedUnreadable(t *testing.T) {
+	m := &Monitor{Store: &MemoryStore{}, Notified: &failingNotified{}, DedupeTransactions: true}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// A repeated alert beats a missed one
+	if got, want := m.unnotified("addr", []string{"tx1", "tx2"}), []string{"tx1", "tx2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unnotified = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// UTXOSource is implemented by balance sources that can list the unspent
+// outputs held by an address
+type UTXOSource interface {
+	// UTXOs returns the amount of each unspent output in nick
+	UTXOs(address string) ([]int64, error)
+}
+
+// UTXOStats summarizes the unspent outputs of an address
+type UTXOStats struct {
+	Count      int   `json:"count"`
+	Dust       int   `json:"dust"`                 // Outputs below the dust threshold
+	Fragmented bool  `json:"fragmented,omitempty"` // A fragmentation alert was sent
+	Updated    int64 `json:"updated"`
+}
+
+// UTXOs implements UTXOSource by asking the address's adapter, if it can
+// list unspent outputs
+func (mc *MultiChain) UTXOs(address string) ([]int64, error) {
+	chain, plain := SplitAddress(address)
+	adapter := mc.Default
+	if chain != "" {
+		adapter = mc.adapters[chain]
+	}
+	source, ok := adapter.(UTXOSource)
+	if !ok {
+		return nil, nil
+	}
+	return source.UTXOs(plain)
+}
+
+// UTXOStats returns the unspent output counts of each address, for
+// addresses checked while UTXO tracking is enabled
+func (m *Monitor) UTXOStats() map[string]UTXOStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]UTXOStats, len(m.state.UTXOs))
+	for address, s := range m.state.UTXOs {
+		stats[address] = s
+	}
+	return stats
+}
+
+// trackUTXOs is enabled by either UTXO threshold
+func (m *Monitor) trackUTXOs() bool {
+	return m.MaxUTXOs > 0 || m.DustThreshold > 0
+}
+
+// checkUTXOs refreshes an address's unspent outputs after its balance
+// changed, alerting once when they fragment past MaxUTXOs and whenever new
+// dust outputs appear; callers must hold m.mu
+func (m *Monitor) checkUTXOs(address string, initial bool) {
+	source, ok := m.Source.(UTXOSource)
+	if !ok || !m.trackUTXOs() {
+		return
+	}
+	amounts, err := source.UTXOs(address)
+	if err != nil {
+		log.Printf("Error listing UTXOs for %s: %v", address, err)
+		return
+	}
+
+	now := m.now()
+	previous := m.state.UTXOs[address]
+	stats := UTXOStats{Count: len(amounts), Fragmented: previous.Fragmented, Updated: now.Unix()}
+	for _, amount := range amounts {
+		if amount < m.DustThreshold {
+			stats.Dust++
+		}
+	}
+
+	muted := m.isMuted(address, now)
+	if m.MaxUTXOs > 0 && stats.Count > m.MaxUTXOs {
+		if !stats.Fragmented && !muted {
+			m.notifyAlert(notify.Alert{
+				Emoji:    "🧩",
+				Title:    "UTXOs fragmented",
+				Rule:     RuleUTXO,
+				Severity: notify.SeverityWarning,
+				Fields: []notify.Field{
+					{Name: "Address", Value: address + labelText(m.Labels[address])},
+					{Name: "UTXOs", Value: fmt.Sprintf("%d (threshold %d)", stats.Count, m.MaxUTXOs)},
+					{Name: "Suggestion", Value: "Consolidate them into fewer outputs to keep future transactions small"},
+				},
+				Time: now,
+			})
+			stats.Fragmented = true
+		}
+	} else {
+		stats.Fragmented = false
+	}
+
+	if m.DustThreshold > 0 && !initial && stats.Dust > previous.Dust && !muted {
+		m.notifyAlert(notify.Alert{
+			Emoji:    "🧹",
+			Title:    "Dust received",
+			Rule:     RuleDust,
+			Severity: notify.SeverityInfo,
+			Fields: []notify.Field{
+				{Name: "Address", Value: address + labelText(m.Labels[address])},
+				{Name: "New Dust Outputs", Value: fmt.Sprintf("%d", stats.Dust-previous.Dust)},
+				{Name: "Dust Threshold", Value: m.Format.Balance(m.DustThreshold)},
+				{Name: "Total Dust Outputs", Value: fmt.Sprintf("%d of %d", stats.Dust, stats.Count)},
+			},
+			Time: now,
+		})
+	}
+	if m.state.UTXOs == nil {
+		m.state.UTXOs = map[string]UTXOStats{}
+	}
+	m.state.UTXOs[address] = stats
+}
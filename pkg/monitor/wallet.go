@@ -0,0 +1,130 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/notify"
+)
+
+// DefaultGapLimit is how many unused addresses are derived past the last
+// used one when a wallet doesn't set its own limit
+const DefaultGapLimit = 20
+
+// Deriver derives receive addresses from an extended public key or output
+// descriptor
+type Deriver interface {
+	DeriveAddress(index int) (string, error)
+}
+
+// Wallet is a set of addresses derived from one extended public key whose
+// changes are alerted as a single wallet balance
+type Wallet struct {
+	Name     string
+	Deriver  Deriver
+	GapLimit int // Unused addresses kept past the last used one; DefaultGapLimit if 0
+}
+
+// gapLimit returns the wallet's gap limit or the default
+func (w Wallet) gapLimit() int {
+	if w.GapLimit > 0 {
+		return w.GapLimit
+	}
+	return DefaultGapLimit
+}
+
+// checkWallet checks every derived address of a wallet, deriving more as
+// addresses near the end of the gap get used, and sends one alert for the
+// change in the wallet's total; callers must hold m.mu
+func (m *Monitor) checkWallet(w Wallet) []CheckResult {
+	if m.state.WalletAddresses == nil {
+		m.state.WalletAddresses = map[string][]string{}
+	}
+	derived := m.state.WalletAddresses[w.Name]
+	initial := len(derived) == 0
+
+	var results []CheckResult
+	var oldTotal, newTotal int64
+	failed := false
+	for i := 0; i < len(derived) || m.needsMore(derived, w.gapLimit()); i++ {
+		if i == len(derived) {
+			address, err := w.Deriver.DeriveAddress(i)
+			if err != nil {
+				log.Printf("Error deriving address %d of wallet %s: %v", i, w.Name, err)
+				failed = true
+				break
+			}
+			derived = append(derived, address)
+		}
+
+		result, _, err := m.update(derived[i])
+		if err != nil {
+			log.Printf("Error checking balance for %s: %v", derived[i], err)
+			result.Error = err.Error()
+			failed = true
+		}
+		results = append(results, result)
+		oldTotal += result.PreviousBalance
+		newTotal += result.CurrentBalance
+	}
+	m.state.WalletAddresses[w.Name] = derived
+
+	used := 0
+	for _, address := range derived {
+		if m.isUsed(address) {
+			used++
+		}
+	}
+
+	now := m.now()
+	if failed || (!initial && oldTotal == newTotal) || m.isMuted(w.Name, now) || !m.HasTag(w.Name, m.AlertTags...) {
+		return results
+	}
+	m.reportChange(notify.Change{
+		Address:    w.Name,
+		Label:      fmt.Sprintf("wallet, %d used addresses", used),
+		OldBalance: oldTotal,
+		NewBalance: newTotal,
+		Initial:    initial,
+		Time:       now,
+		Quote:      m.quote(),
+	})
+	return results
+}
+
+// needsMore reports whether fewer than gap unused addresses follow the last
+// used one; callers must hold m.mu
+func (m *Monitor) needsMore(derived []string, gap int) bool {
+	lastUsed := -1
+	for i, address := range derived {
+		if m.isUsed(address) {
+			lastUsed = i
+		}
+	}
+	return len(derived)-1-lastUsed < gap
+}
+
+// isUsed reports whether an address has ever held a balance; callers must
+// hold m.mu
+func (m *Monitor) isUsed(address string) bool {
+	history := m.state.BalanceHistory[address]
+	for _, sample := range history {
+		if sample.Balance != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// walletOf returns the name of the wallet an address was derived for, if
+// any; callers must hold m.mu
+func (m *Monitor) walletOf(address string) string {
+	for name, derived := range m.state.WalletAddresses {
+		for _, a := range derived {
+			if a == address {
+				return name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,48 @@
+package nockrpc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CodeMethodNotFound is the JSON-RPC error code for an unsupported method
+const CodeMethodNotFound = -32601
+
+// maxErrorBody is how much of a failed response body HTTPError keeps
+const maxErrorBody = 512
+
+// Error is an error returned by the endpoint in a JSON-RPC response
+type Error struct {
+	Method  string `json:"-"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements error
+func (e *Error) Error() string {
+	if e.Code == 0 {
+		return fmt.Sprintf("nockrpc: %s: %s", e.Method, e.Message)
+	}
+	return fmt.Sprintf("nockrpc: %s: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
+// MethodNotFound reports whether the endpoint doesn't support the method
+func (e *Error) MethodNotFound() bool {
+	return e.Code == CodeMethodNotFound
+}
+
+// HTTPError is a response with a status other than 200 OK
+type HTTPError struct {
+	Method     string
+	StatusCode int
+	Body       string
+}
+
+// Error implements error
+func (e *HTTPError) Error() string {
+	body := strings.TrimSpace(e.Body)
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "…"
+	}
+	return fmt.Sprintf("nockrpc: %s: HTTP %d: %s", e.Method, e.StatusCode, body)
+}
@@ -0,0 +1,240 @@
+// Package nockrpc is a typed client for the nockblocks JSON-RPC API, with
+// context support and error types, usable on its own outside the alerter.
+package nockrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultURL is the public nockblocks JSON-RPC endpoint
+const DefaultURL = "https://nockblocks.com/rpc"
+
+// DefaultUserAgent identifies requests when no User-Agent is configured
+const DefaultUserAgent = "nockchain-balance-alerter"
+
+// DefaultPageSize is how many transactions a page holds when Page.Limit
+// isn't set
+const DefaultPageSize = 20
+
+// Request is a JSON-RPC request
+type Request struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+	ID      string        `json:"id"`
+}
+
+// Transaction is a transaction touching an address
+type Transaction struct {
+	ID   string `json:"id"`
+	Fee  int64  `json:"fee"`                 // Network fee in nick
+	Memo string `json:"memo,omitempty"`      // Note attached by the sender, as text or 0x-prefixed hex
+	Time int64  `json:"timestamp,omitempty"` // Unix time of the including block, if reported
+
+	// Counterparties are the other addresses the transaction spent from or
+	// paid to, if the endpoint reports them
+	Counterparties []string `json:"counterparties,omitempty"`
+}
+
+// Lock is the signing configuration guarding an address's funds: any
+// Threshold of the Signers' keys can spend them
+type Lock struct {
+	Threshold int      `json:"threshold"`
+	Signers   []string `json:"signers"` // Public keys, as the endpoint encodes them
+}
+
+// UTXO is an unspent output held by an address
+type UTXO struct {
+	ID     string `json:"id"`
+	Amount int64  `json:"amount"` // In nick
+}
+
+// AddressTransactions is the result of getTransactionsByAddress: the
+// balances of an address and one page of its transactions, newest first
+type AddressTransactions struct {
+	Address        string        `json:"address"`
+	CurrentBalance int64         `json:"currentBalance"` // nick
+	LockedBalance  int64         `json:"lockedBalance"`  // Locked or staked part of CurrentBalance
+	Transactions   []Transaction `json:"transactions"`
+}
+
+// Page selects a page of transactions
+type Page struct {
+	Limit  int // DefaultPageSize if zero
+	Offset int
+}
+
+// Client calls a nockblocks-compatible JSON-RPC endpoint
+type Client struct {
+	URL        string
+	UserAgent  string // DefaultUserAgent if empty
+	Headers    map[string]string
+	HTTPClient *http.Client // http.DefaultClient if nil
+}
+
+// New returns a client for the given endpoint, or DefaultURL if empty
+func New(url string) *Client {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Client{URL: url}
+}
+
+// GetBalance returns the balance of an address in nick
+func (c *Client) GetBalance(ctx context.Context, address string) (int64, error) {
+	result, err := c.GetTransactionsByAddress(ctx, address, Page{Limit: 1})
+	if err != nil {
+		return 0, err
+	}
+	return result.CurrentBalance, nil
+}
+
+// GetTransactionsByAddress returns the balances of an address and one page
+// of its transactions
+func (c *Client) GetTransactionsByAddress(ctx context.Context, address string, page Page) (AddressTransactions, error) {
+	if page.Limit <= 0 {
+		page.Limit = DefaultPageSize
+	}
+	var result AddressTransactions
+	err := c.Call(ctx, "getTransactionsByAddress", map[string]interface{}{
+		"address": address,
+		"limit":   page.Limit,
+		"offset":  page.Offset,
+	}, &result)
+	return result, err
+}
+
+// AllTransactions pages through every transaction of an address, newest
+// first, stopping at the first short page
+func (c *Client) AllTransactions(ctx context.Context, address string) ([]Transaction, error) {
+	var all []Transaction
+	page := Page{Limit: DefaultPageSize}
+	for {
+		result, err := c.GetTransactionsByAddress(ctx, address, page)
+		if err != nil {
+			return nil, err
+		}
+		// An endpoint that ignores the offset returns the first page again
+		if page.Offset > 0 && len(result.Transactions) > 0 && len(all) > 0 && result.Transactions[0].ID == all[0].ID {
+			return all, nil
+		}
+		all = append(all, result.Transactions...)
+		if len(result.Transactions) < page.Limit {
+			return all, nil
+		}
+		page.Offset += page.Limit
+	}
+}
+
+// GetBlockHeight returns the latest block height known to the endpoint
+func (c *Client) GetBlockHeight(ctx context.Context) (int64, error) {
+	var result struct {
+		Height int64 `json:"height"`
+	}
+	if err := c.Call(ctx, "getBlockHeight", map[string]interface{}{}, &result); err != nil {
+		return 0, err
+	}
+	return result.Height, nil
+}
+
+// GetUTXOsByAddress returns the unspent outputs of an address. Endpoints
+// that don't expose UTXOs return an *Error for which MethodNotFound is true.
+func (c *Client) GetUTXOsByAddress(ctx context.Context, address string) ([]UTXO, error) {
+	var result struct {
+		UTXOs []UTXO `json:"utxos"`
+	}
+	if err := c.Call(ctx, "getUtxosByAddress", map[string]interface{}{"address": address}, &result); err != nil {
+		return nil, err
+	}
+	return result.UTXOs, nil
+}
+
+// GetLockByAddress returns the signing configuration of an address.
+// Endpoints that don't expose locks return an *Error for which
+// MethodNotFound is true.
+func (c *Client) GetLockByAddress(ctx context.Context, address string) (Lock, error) {
+	var result Lock
+	err := c.Call(ctx, "getLockByAddress", map[string]interface{}{"address": address}, &result)
+	return result, err
+}
+
+// GetAddressesByPattern returns the addresses the indexer matches against
+// pattern, such as a prefix glob or an account name
+func (c *Client) GetAddressesByPattern(ctx context.Context, pattern string) ([]string, error) {
+	var result struct {
+		Addresses []string `json:"addresses"`
+	}
+	if err := c.Call(ctx, "getAddressesByPattern", map[string]interface{}{"pattern": pattern}, &result); err != nil {
+		return nil, err
+	}
+	return result.Addresses, nil
+}
+
+// Call invokes a JSON-RPC method with a single object parameter and decodes
+// its result into result. A non-200 response is an *HTTPError, and an error
+// returned by the endpoint is an *Error.
+func (c *Client) Call(ctx context.Context, method string, params map[string]interface{}, result interface{}) error {
+	body, err := json.Marshal(Request{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  []interface{}{params},
+		ID:      fmt.Sprintf("%d", time.Now().UnixNano()),
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	userAgent := c.UserAgent
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &HTTPError{Method: method, StatusCode: resp.StatusCode, Body: string(responseBody)}
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage `json:"result"`
+		Error  *Error          `json:"error"`
+	}
+	if err := json.Unmarshal(responseBody, &rpcResp); err != nil {
+		return fmt.Errorf("nockrpc: %s: decoding response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		rpcResp.Error.Method = method
+		return rpcResp.Error
+	}
+	if len(rpcResp.Result) == 0 || result == nil {
+		return nil
+	}
+	return json.Unmarshal(rpcResp.Result, result)
+}
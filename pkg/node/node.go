@@ -0,0 +1,43 @@
+// Package node polls the status endpoint of a nockchain node.
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Status is a node's view of the chain and network
+type Status struct {
+	Height  int64  `json:"height"`
+	Peers   int    `json:"peers"`
+	Version string `json:"version"`
+}
+
+// Client fetches the status of a node from an HTTP endpoint returning JSON
+// such as {"height": 12345, "peers": 8, "version": "0.1.0"}
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// Status fetches the node's current status
+func (c *Client) Status() (Status, error) {
+	var status Status
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Get(c.URL)
+	if err != nil {
+		return status, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return status, fmt.Errorf("node status returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return status, err
+	}
+	return status, nil
+}
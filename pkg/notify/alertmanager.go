@@ -0,0 +1,216 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Alertmanager delivery modes
+const (
+	// AlertmanagerWebhook posts the payload Alertmanager sends to webhook
+	// receivers, as Grafana OnCall's Alertmanager integration expects
+	AlertmanagerWebhook = "webhook"
+	// AlertmanagerAPI posts alerts to an Alertmanager's /api/v2/alerts, so
+	// its routing tree, inhibitions and silences apply
+	AlertmanagerAPI = "api"
+)
+
+// AlertmanagerReceiver is the receiver named in webhook payloads
+const AlertmanagerReceiver = "nockchain-balance-alerter"
+
+// ParseAlertmanagerMode validates a delivery mode; empty means
+// AlertmanagerWebhook
+func ParseAlertmanagerMode(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "":
+		return AlertmanagerWebhook, nil
+	case AlertmanagerWebhook, AlertmanagerAPI:
+		return value, nil
+	default:
+		return "", fmt.Errorf("alertmanager mode must be %q or %q, got %q", AlertmanagerWebhook, AlertmanagerAPI, value)
+	}
+}
+
+// Alertmanager sends change and other alerts in Alertmanager's format, so
+// Grafana OnCall, Alertmanager silences and existing routing trees can
+// manage them. Every alert is labelled alertname="nockchain_<rule>",
+// severity and, for changes, address. Alerts are events rather than
+// conditions, so they fire once and resolve after Alertmanager's
+// resolve_timeout. Summaries aren't alerts and are not sent.
+type Alertmanager struct {
+	URL         string            // Webhook receiver URL, or the Alertmanager base URL in AlertmanagerAPI mode
+	Mode        string            // See the Alertmanager constants; AlertmanagerWebhook if empty
+	Labels      map[string]string // Extra labels on every alert, e.g. env=prod
+	Headers     map[string]string // Extra request headers, e.g. Authorization
+	ExplorerURL string            // Generator URL pattern of change alerts; DefaultExplorerURL if empty
+	HTTPClient  *http.Client      // http.DefaultClient if nil
+	Format      Formatter         // Denomination and number format of amounts
+	Branding    Branding          // Change alert title; DefaultBranding if zero
+}
+
+// AlertmanagerAlert is one alert in Alertmanager's format
+type AlertmanagerAlert struct {
+	Status       string            `json:"status,omitempty"` // Webhook payloads only
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"` // Zero while firing
+	GeneratorURL string            `json:"generatorURL,omitempty"`
+	Fingerprint  string            `json:"fingerprint,omitempty"` // Webhook payloads only
+}
+
+// AlertmanagerMessage is the payload Alertmanager posts to webhook receivers
+type AlertmanagerMessage struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	TruncatedAlerts   int                 `json:"truncatedAlerts"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+// Name implements Notifier
+func (a *Alertmanager) Name() string { return "Alertmanager" }
+
+// NotifyChange implements Notifier
+func (a *Alertmanager) NotifyChange(change Change) error {
+	alert := a.alert(change.Rule(), a.Branding.orDefault().ChangeTitle, change.Severity, change.Time, change.Key, changeFields(change, a.Format))
+	alert.Labels["address"] = change.Address
+	if change.Label != "" {
+		alert.Labels["address_label"] = change.Label
+	}
+	explorer := a.ExplorerURL
+	if explorer == "" {
+		explorer = DefaultExplorerURL
+	}
+	alert.GeneratorURL = fmt.Sprintf(explorer, change.Address)
+	return a.send(alert)
+}
+
+// NotifySummary implements Notifier; summaries aren't alerts, so nothing
+// is sent
+func (a *Alertmanager) NotifySummary(balances []Balance) error {
+	return nil
+}
+
+// NotifyAlert implements Notifier
+func (a *Alertmanager) NotifyAlert(alert Alert) error {
+	rule := alert.Rule
+	if rule == "" {
+		rule = "alert"
+	}
+	return a.send(a.alert(rule, alert.Title, alert.Severity, alert.Time, alert.Key, alert.Fields))
+}
+
+// alert builds a firing alert with the common labels and annotations
+func (a *Alertmanager) alert(rule, title string, severity Severity, at time.Time, key string, fields []Field) AlertmanagerAlert {
+	labels := map[string]string{}
+	for name, value := range a.Labels {
+		labels[name] = value
+	}
+	labels["alertname"] = "nockchain_" + rule
+	labels["severity"] = severity.String()
+
+	lines := make([]string, len(fields))
+	for i, field := range fields {
+		lines[i] = field.Name + ": " + field.Value
+	}
+	if at.IsZero() {
+		at = time.Now()
+	}
+	if key == "" {
+		key = alertmanagerFingerprint(labels, at)
+	}
+	return AlertmanagerAlert{
+		Labels:      labels,
+		Annotations: map[string]string{"summary": title, "description": strings.Join(lines, "\n")},
+		StartsAt:    at.UTC(),
+		Fingerprint: key,
+	}
+}
+
+// alertmanagerFingerprint identifies an alert without an idempotency key
+// by its labels and start time
+func alertmanagerFingerprint(labels map[string]string, at time.Time) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + "\x00" + labels[name] + "\x00")
+	}
+	b.WriteString(strconv.FormatInt(at.UnixNano(), 10))
+	return sha256Hex([]byte(b.String()))[:16]
+}
+
+// send delivers one alert, retrying if the receiver asks to back off
+func (a *Alertmanager) send(alert AlertmanagerAlert) error {
+	target := a.URL
+	var payload interface{}
+	if a.Mode == AlertmanagerAPI {
+		target = strings.TrimSuffix(target, "/") + "/api/v2/alerts"
+		alert.Status, alert.Fingerprint = "", ""
+		payload = []AlertmanagerAlert{alert}
+	} else {
+		alert.Status = "firing"
+		payload = AlertmanagerMessage{
+			Version:           "4",
+			GroupKey:          "{}:{alertname=\"" + alert.Labels["alertname"] + "\"}",
+			Status:            "firing",
+			Receiver:          AlertmanagerReceiver,
+			GroupLabels:       map[string]string{"alertname": alert.Labels["alertname"]},
+			CommonLabels:      alert.Labels,
+			CommonAnnotations: alert.Annotations,
+			Alerts:            []AlertmanagerAlert{alert},
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return withRateLimitRetry(func() error { return a.post(target, body) })
+}
+
+// post delivers a request body once
+func (a *Alertmanager) post(target string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range a.Headers {
+		req.Header.Set(key, value)
+	}
+	client := a.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &RateLimitError{Platform: "alertmanager", RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("alertmanager: %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
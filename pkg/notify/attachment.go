@@ -0,0 +1,88 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// summaryCSV returns the full per-address table of a summary as CSV, with
+// amounts in both the base unit and the display unit, what was received
+// and sent when flows are tracked and, when a price is known, the value in
+// each quoted currency
+func summaryCSV(balances []Balance, format Formatter) ([]byte, error) {
+	quote := summaryQuote(balances)
+	currencies := make([]string, 0, len(quote))
+	for currency := range quote {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	denomination := format.denomination()
+	unit := strings.TrimPrefix(strings.ToLower(denomination.Unit), "$")
+	header := []string{"address", "label", "group", "tags",
+		"balance_" + denomination.BaseUnit, "balance_" + unit, "locked_" + denomination.BaseUnit,
+		"last_updated", "stale"}
+	flows := PortfolioFlow(balances) != nil
+	if flows {
+		header = append(header, "received_"+denomination.BaseUnit, "sent_"+denomination.BaseUnit)
+	}
+	for _, currency := range currencies {
+		header = append(header, "value_"+strings.ToLower(currency))
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(header)
+	for _, b := range balances {
+		lastUpdated := ""
+		if !b.LastUpdated.IsZero() {
+			lastUpdated = b.LastUpdated.UTC().Format(time.RFC3339)
+		}
+		row := []string{
+			b.Address,
+			b.Label,
+			b.Group,
+			strings.Join(b.Tags, ";"),
+			strconv.FormatInt(b.CurrentBalance, 10),
+			strconv.FormatFloat(format.ToUnits(b.CurrentBalance), 'f', -1, 64),
+			strconv.FormatInt(b.Locked, 10),
+			lastUpdated,
+			strconv.FormatBool(b.Stale),
+		}
+		if flows {
+			var flow Flow
+			if b.Flow != nil {
+				flow = *b.Flow
+			}
+			row = append(row, strconv.FormatInt(flow.Received, 10), strconv.FormatInt(flow.Sent, 10))
+		}
+		for _, currency := range currencies {
+			row = append(row, strconv.FormatFloat(format.ToUnits(b.CurrentBalance)*quote[currency], 'f', 2, 64))
+		}
+		writer.Write(row)
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// summaryFilename names the CSV attachment of a summary
+func summaryFilename(now time.Time) string {
+	return fmt.Sprintf("balances-%s.csv", now.UTC().Format("2006-01-02"))
+}
+
+// summaryHeadline returns the short message sent with a summary attachment:
+// the number of addresses and the totals
+func summaryHeadline(balances []Balance, s style) Alert {
+	fields := []Field{{Name: "Addresses", Value: s.tr.Sprintf("%d, full table attached", len(balances))}}
+	return Alert{
+		Emoji:  s.branding.SummaryEmoji,
+		Title:  s.branding.SummaryTitle,
+		Fields: append(fields, formatTotalLines(balances, s)...),
+		Time:   time.Now(),
+	}
+}
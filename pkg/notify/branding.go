@@ -0,0 +1,33 @@
+package notify
+
+// Branding sets the emojis, titles, and accent colors of change alerts and
+// summaries, so teams can tell their alert streams apart, e.g. a shield for
+// the treasury and a pickaxe for mining
+type Branding struct {
+	ChangeEmoji   string // e.g. "💸"
+	ChangeTitle   string // e.g. "Balance Change Alert"
+	SummaryEmoji  string // e.g. "📊"
+	SummaryTitle  string // e.g. "Balance Summary"
+	IncreaseColor string // Slack accent of increases, e.g. "#2eb886"
+	DecreaseColor string // Slack accent of decreases
+	NewColor      string // Slack accent of newly seen addresses
+}
+
+// DefaultBranding is the built-in look of alerts and summaries
+var DefaultBranding = Branding{
+	ChangeEmoji:   "💸",
+	ChangeTitle:   "Balance Change Alert",
+	SummaryEmoji:  "📊",
+	SummaryTitle:  "Balance Summary",
+	IncreaseColor: "#2eb886",
+	DecreaseColor: "#e01e5a",
+	NewColor:      "#439fe0",
+}
+
+// orDefault returns the branding, or DefaultBranding if it is unset
+func (b Branding) orDefault() Branding {
+	if b == (Branding{}) {
+		return DefaultBranding
+	}
+	return b
+}
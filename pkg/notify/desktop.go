@@ -0,0 +1,110 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// DesktopAppName is the application desktop notifications are raised as on
+// Linux
+const DesktopAppName = "Nockchain Balance Alerter"
+
+// desktopWindowsAppID is PowerShell's app ID; toasts from an unregistered
+// app ID are silently dropped
+const desktopWindowsAppID = `{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe`
+
+// desktopPowerShell shows a toast on Windows with the title and body from
+// the environment, so neither has to be quoted for PowerShell
+const desktopPowerShell = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $template.GetElementsByTagName('text')
+$text.Item(0).AppendChild($template.CreateTextNode($env:NOTIFY_TITLE)) > $null
+$text.Item(1).AppendChild($template.CreateTextNode($env:NOTIFY_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($env:NOTIFY_APP).Show([Windows.UI.Notifications.ToastNotification]::new($template))`
+
+// Desktop raises OS desktop notifications, for running the alerter on a
+// workstation without any chat accounts. It uses notify-send on Linux and
+// the BSDs, osascript on macOS and PowerShell on Windows.
+type Desktop struct {
+	Units    Units     // Amounts to show, see the Units constants
+	Format   Formatter // Denomination and number format of amounts
+	Branding Branding  // Emojis and titles; DefaultBranding if zero
+}
+
+// Name implements Notifier
+func (d *Desktop) Name() string { return "Desktop" }
+
+// NotifyChange implements Notifier
+func (d *Desktop) NotifyChange(change Change) error {
+	s := d.style()
+	body := s.balance(change.NewBalance, change.Quote)
+	if !change.Initial {
+		body = formatChangeLine(change, s) + "\n→ " + body
+	}
+	return d.show(s.branding.ChangeEmoji+" "+addressTitle(change.Address, change.Label), body, change.Severity)
+}
+
+// NotifySummary implements Notifier
+func (d *Desktop) NotifySummary(balances []Balance) error {
+	s := d.style()
+	total, _ := SummaryTotals(balances)
+	body := fmt.Sprintf("Total: %s\n%d addresses", s.balance(total, summaryQuote(balances)), len(balances))
+	return d.show(s.branding.SummaryEmoji+" "+s.branding.SummaryTitle, body, SeverityInfo)
+}
+
+// style returns how notifications show amounts
+func (d *Desktop) style() style {
+	return style{units: d.Units, format: d.Format, branding: d.Branding.orDefault()}
+}
+
+// NotifyAlert implements Notifier
+func (d *Desktop) NotifyAlert(alert Alert) error {
+	lines := make([]string, len(alert.Fields))
+	for i, field := range alert.Fields {
+		lines[i] = field.Name + ": " + field.Value
+	}
+	return d.show(strings.TrimSpace(alert.Emoji+" "+alert.Title), strings.Join(lines, "\n"), alert.Severity)
+}
+
+// addressTitle names an address by its label, or a shortened address
+func addressTitle(address, label string) string {
+	if label != "" {
+		return label
+	}
+	if len(address) > 16 {
+		return address[:8] + "…" + address[len(address)-6:]
+	}
+	return address
+}
+
+// show raises one notification, critical ones urgently where supported
+func (d *Desktop) show(title, body string, severity Severity) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript",
+			"-e", "on run argv",
+			"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+			"-e", "end run",
+			title, body)
+	case "windows":
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", desktopPowerShell)
+		cmd.Env = append(os.Environ(), "NOTIFY_APP="+desktopWindowsAppID, "NOTIFY_TITLE="+title, "NOTIFY_BODY="+body)
+	default:
+		urgency := "normal"
+		if severity.level() == SeverityCritical {
+			urgency = "critical"
+		}
+		cmd = exec.Command("notify-send", "--app-name", DesktopAppName, "--urgency", urgency, "--", title, body)
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if output := strings.TrimSpace(string(output)); output != "" {
+			return fmt.Errorf("desktop notification: %w: %s", err, output)
+		}
+		return fmt.Errorf("desktop notification: %w", err)
+	}
+	return nil
+}
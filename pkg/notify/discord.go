@@ -0,0 +1,225 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Discord sends markdown messages to a Discord channel through a bot session
+type Discord struct {
+	Session   *discordgo.Session
+	ChannelID string
+	Templates Templates
+	Units     Units    // Amounts to show, see the Units constants
+	Delivery  Delivery // Which severities are sent silently, and the mention for critical alerts
+	Overflow  string   // What to do with messages over 2000 characters, see the Overflow constants
+}
+
+// Name implements Notifier
+func (d *Discord) Name() string { return "Discord" }
+
+// NotifyChange implements Notifier
+func (d *Discord) NotifyChange(change Change) error {
+	if d.Templates.Change != nil {
+		message, err := d.Templates.renderChange(change)
+		if err != nil {
+			return err
+		}
+		return d.send(message, change.Severity)
+	}
+	return d.send(createDiscordBalanceChangeMessage(change, d.Templates.style(d.Units)), change.Severity)
+}
+
+// NotifySummary implements Notifier
+func (d *Discord) NotifySummary(balances []Balance) error {
+	message := CreateDiscordSummaryMessage(balances, d.Units, d.Templates)
+	if d.Templates.Summary != nil {
+		var err error
+		if message, err = d.Templates.renderSummary(balances); err != nil {
+			return err
+		}
+	}
+	if d.Overflow == OverflowAttach && len(message) > d.maxLength(SeverityInfo) {
+		return d.sendSummaryAttachment(balances)
+	}
+	pages := splitMessage(message, d.maxLength(SeverityInfo))
+	if d.Overflow == OverflowTruncate {
+		pages = []string{truncateMessage(message, d.maxLength(SeverityInfo), func(n int) string {
+			return "_" + moreAddresses(n, d.Templates.Translations) + "_"
+		})}
+	}
+	for _, page := range pages {
+		if err := d.send(page, SeverityInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyAlert implements Notifier
+func (d *Discord) NotifyAlert(alert Alert) error {
+	alerts := fitAlert(alert, d.Overflow, func(a Alert) bool {
+		return len(createDiscordAlertMessage(a, d.Templates.Translations)) <= d.maxLength(alert.Severity)
+	})
+	for _, a := range alerts {
+		if err := d.send(createDiscordAlertMessage(a, d.Templates.Translations), alert.Severity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxLength returns how long a message may be once send prefixes the
+// mention for its severity
+func (d *Discord) maxLength(severity Severity) int {
+	if mention := d.Delivery.mention(severity); mention != "" {
+		return discordMaxLength - len(mention) - 1
+	}
+	return discordMaxLength
+}
+
+// sendSummaryAttachment sends the summary headline with the full table
+// attached as CSV
+func (d *Discord) sendSummaryAttachment(balances []Balance) error {
+	data, err := summaryCSV(balances, d.Templates.Format)
+	if err != nil {
+		return err
+	}
+	headline := summaryHeadline(balances, d.Templates.style(d.Units))
+	_, err = d.Session.ChannelMessageSendComplex(d.ChannelID, &discordgo.MessageSend{
+		Content: createDiscordAlertMessage(headline, d.Templates.Translations),
+		Files:   []*discordgo.File{{Name: summaryFilename(headline.Time), ContentType: "text/csv", Reader: bytes.NewReader(data)}},
+		Flags:   d.flags(SeverityInfo),
+	})
+	return err
+}
+
+// SendChart implements ChartSender by attaching the chart to a message
+func (d *Discord) SendChart(png []byte, caption string) error {
+	_, err := d.Session.ChannelMessageSendComplex(d.ChannelID, &discordgo.MessageSend{
+		Content: caption,
+		Files:   []*discordgo.File{{Name: "summary.png", Reader: bytes.NewReader(png)}},
+		Flags:   d.flags(SeverityInfo),
+	})
+	return err
+}
+
+// send sends a message to the Discord channel, mentioning or silent as the
+// severity calls for
+func (d *Discord) send(message string, severity Severity) error {
+	if mention := d.Delivery.mention(severity); mention != "" {
+		message = mention + " " + message
+	}
+	_, err := d.Session.ChannelMessageSendComplex(d.ChannelID, &discordgo.MessageSend{
+		Content: message,
+		Flags:   d.flags(severity),
+	})
+	return err
+}
+
+// flags returns the message flags for a severity
+func (d *Discord) flags(severity Severity) discordgo.MessageFlags {
+	if d.Delivery.silent(severity) {
+		return discordgo.MessageFlagsSuppressNotifications
+	}
+	return 0
+}
+
+// createDiscordBalanceChangeMessage creates a Discord markdown message for a balance change
+func createDiscordBalanceChangeMessage(change Change, s style) string {
+	changeLine := ""
+	if !change.Initial {
+		changeLine = fmt.Sprintf("**%s**: %s\n", s.tr.T("Change"), formatChangeLine(change, s))
+	}
+	if change.Fee > 0 {
+		changeLine += fmt.Sprintf("**%s**: %s\n", s.tr.T("Fee"), s.balance(change.Fee, change.Quote))
+	}
+	if len(change.SentTo) > 0 {
+		changeLine += fmt.Sprintf("**%s**: %s\n", s.tr.T("Sent To"), FormatCounterparties(change.SentTo))
+	}
+	for _, memo := range change.Memos {
+		changeLine += fmt.Sprintf("**%s**: `%s`\n", s.tr.T("Memo"), strings.ReplaceAll(memo, "`", "'"))
+	}
+	return fmt.Sprintf(
+		"%s **%s**\n\n"+
+			"**%s**: `%s`%s\n"+
+			"**%s**: %s\n"+
+			"**%s**: %s\n"+
+			"%s"+
+			"──────────\n"+
+			"_%s_",
+		s.branding.ChangeEmoji,
+		s.tr.T(s.branding.ChangeTitle),
+		s.tr.T("Address"),
+		change.Address,
+		labelSuffix(change.Label),
+		s.tr.T("Old Balance"),
+		formatOldBalance(change, s),
+		s.tr.T("New Balance"),
+		s.balance(change.NewBalance, change.Quote),
+		changeLine,
+		s.tr.Sprintf("Updated at %s", change.Time.Format(time.RFC3339)),
+	)
+}
+
+// CreateDiscordSummaryMessage creates a Discord markdown message for the balance summary
+func CreateDiscordSummaryMessage(balances []Balance, units Units, templates Templates) string {
+	s := templates.style(units)
+	message := fmt.Sprintf("%s **%s**\n\n", s.branding.SummaryEmoji, s.tr.T(s.branding.SummaryTitle))
+	for i, balance := range balances {
+		changeLine := ""
+		if balance.Locked > 0 {
+			changeLine = fmt.Sprintf("**%s**: %s\n**%s**: %s\n", s.tr.T("Locked"), s.balance(balance.Locked, balance.Quote), s.tr.T("Liquid"), s.balance(balance.Liquid(), balance.Quote))
+		}
+		if balance.Signing != "" {
+			changeLine += fmt.Sprintf("**%s**: %s\n", s.tr.T("Signing"), balance.Signing)
+		}
+		if len(balance.Changes) > 0 {
+			changeLine += fmt.Sprintf("**%s**: %s\n", s.tr.T("Change"), formatPeriodChanges(balance.Changes, s))
+		}
+		if balance.Flow != nil {
+			changeLine += fmt.Sprintf("**%s**: %s\n", s.tr.T("Flow"), formatFlow(*balance.Flow, s))
+		}
+		if pnl, percent, ok := balance.UnrealizedPnL(s.format); ok && s.units.fiat() {
+			changeLine += fmt.Sprintf("**%s**: %s\n", s.tr.T("Unrealized P&L"), s.format.pnl(pnl, percent, balance.CostCurrency))
+		}
+		message += fmt.Sprintf(
+			"**%s**: `%s`%s%s%s\n"+
+				"**%s**: %s\n"+
+				"%s"+
+				"**%s**: %s\n"+
+				"──────────\n",
+			s.tr.Sprintf("Address %d", i+1),
+			balance.Address,
+			labelSuffix(balance.Label),
+			sparklineSuffix(balance),
+			staleSuffix(balance, s.tr),
+			s.tr.T("Balance"),
+			s.balance(balance.CurrentBalance, balance.Quote),
+			changeLine,
+			s.tr.T("Last Updated"),
+			balance.LastUpdated.Format(time.RFC3339),
+		)
+	}
+	for _, field := range formatTotalLines(balances, s) {
+		message += fmt.Sprintf("**%s**: %s\n", field.Name, field.Value)
+	}
+	message += fmt.Sprintf("_%s_", s.tr.Sprintf("Generated at %s", time.Now().Format(time.RFC3339)))
+	return message
+}
+
+// createDiscordAlertMessage creates a Discord markdown message for a generic alert
+func createDiscordAlertMessage(alert Alert, tr Translations) string {
+	alert = tr.alert(alert)
+	message := fmt.Sprintf("%s **%s**\n\n", alert.Emoji, alert.Title)
+	for _, field := range alert.Fields {
+		message += fmt.Sprintf("**%s**: %s\n", field.Name, field.Value)
+	}
+	message += "──────────\n"
+	message += fmt.Sprintf("_%s_", tr.Sprintf("Updated at %s", alert.Time.Format(time.RFC3339)))
+	return message
+}
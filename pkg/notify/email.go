@@ -0,0 +1,82 @@
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Email sends each message as a plain-text email over SMTP, upgrading to
+// TLS with STARTTLS when the server offers it, e.g. to a compliance inbox
+type Email struct {
+	Addr      string // SMTP server as host:port, e.g. smtp.example.com:587
+	Username  string // Authenticates with PLAIN when set
+	Password  string
+	From      string
+	To        []string
+	Templates Templates
+	Units     Units // Amounts to show, see the Units constants
+}
+
+// Name implements Notifier
+func (e *Email) Name() string { return "Email" }
+
+// NotifyChange implements Notifier
+func (e *Email) NotifyChange(change Change) error {
+	subject := e.Templates.Branding.orDefault().ChangeTitle + ": " + addressTitle(change.Address, change.Label)
+	if e.Templates.Change != nil {
+		body, err := e.Templates.renderChange(change)
+		if err != nil {
+			return err
+		}
+		return e.send(subject, body, change.Time)
+	}
+	return e.send(subject, createDiscordBalanceChangeMessage(change, e.Templates.style(e.Units)), change.Time)
+}
+
+// NotifySummary implements Notifier
+func (e *Email) NotifySummary(balances []Balance) error {
+	if e.Templates.Summary != nil {
+		body, err := e.Templates.renderSummary(balances)
+		if err != nil {
+			return err
+		}
+		return e.send(e.Templates.Branding.orDefault().SummaryTitle, body, time.Now())
+	}
+	return e.send(e.Templates.Branding.orDefault().SummaryTitle, CreateDiscordSummaryMessage(balances, e.Units, e.Templates), time.Now())
+}
+
+// NotifyAlert implements Notifier
+func (e *Email) NotifyAlert(alert Alert) error {
+	sent := alert.Time
+	if sent.IsZero() {
+		sent = time.Now()
+	}
+	return e.send(alert.Title, createDiscordAlertMessage(alert, e.Templates.Translations), sent)
+}
+
+// send delivers one message to every recipient
+func (e *Email) send(subject, body string, date time.Time) error {
+	host, _, err := net.SplitHostPort(e.Addr)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP address %q: %w", e.Addr, err)
+	}
+	var auth smtp.Auth
+	if e.Username != "" {
+		auth = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	var message strings.Builder
+	fmt.Fprintf(&message, "From: %s\r\n", e.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(e.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	message.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	message.WriteString("\r\n")
+	return smtp.SendMail(e.Addr, auth, e.From, e.To, []byte(message.String()))
+}
@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultEventBridgeSource is the EventBridge source of events when
+// EventBridge.Source isn't set; sources starting with "aws." are reserved
+const DefaultEventBridgeSource = "nockchain.balance-alerter"
+
+// eventBridgeRetryAfter is how long to wait when EventBridge throttles
+// PutEvents, which doesn't say how long to back off
+const eventBridgeRetryAfter = time.Second
+
+// AWSCredentials sign requests to AWS
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // Only for temporary credentials
+}
+
+// EventBridge puts alerts and summaries on an Amazon EventBridge event bus,
+// where rules can route them to Lambda, Step Functions or incident tooling.
+// The detail of each event is the CloudEvent Webhook would post.
+type EventBridge struct {
+	Region      string
+	Credentials AWSCredentials
+	EventBus    string       // Name or ARN; the default bus if empty
+	DetailType  string       // Detail type of every event; the CloudEvents type if empty
+	Source      string       // DefaultEventBridgeSource if empty
+	Endpoint    string       // https://events.<region>.amazonaws.com if empty
+	HTTPClient  *http.Client // http.DefaultClient if nil
+}
+
+// Name implements Notifier
+func (e *EventBridge) Name() string { return "EventBridge" }
+
+// NotifyChange implements Notifier
+func (e *EventBridge) NotifyChange(change Change) error {
+	return e.send(newChangeEvent(change))
+}
+
+// NotifySummary implements Notifier
+func (e *EventBridge) NotifySummary(balances []Balance) error {
+	return e.send(newSummaryEvent(balances))
+}
+
+// NotifyAlert implements Notifier
+func (e *EventBridge) NotifyAlert(alert Alert) error {
+	return e.send(newAlertEvent(alert))
+}
+
+// eventBridgeEntry is one entry of a PutEvents request
+type eventBridgeEntry struct {
+	EventBusName string `json:"EventBusName,omitempty"`
+	Source       string `json:"Source"`
+	DetailType   string `json:"DetailType"`
+	Detail       string `json:"Detail"`
+	Time         int64  `json:"Time"`
+}
+
+// eventBridgeResponse is the response to PutEvents
+type eventBridgeResponse struct {
+	FailedEntryCount int `json:"FailedEntryCount"`
+	Entries          []struct {
+		EventID      string `json:"EventId"`
+		ErrorCode    string `json:"ErrorCode"`
+		ErrorMessage string `json:"ErrorMessage"`
+	} `json:"Entries"`
+}
+
+// send puts one event on the bus, retrying if EventBridge throttles it
+func (e *EventBridge) send(event CloudEvent) error {
+	source := e.Source
+	if source == "" {
+		source = DefaultEventBridgeSource
+	}
+	detail, err := event.encode("")
+	if err != nil {
+		return err
+	}
+	detailType := e.DetailType
+	if detailType == "" {
+		detailType = event.Type
+	}
+	body, err := json.Marshal(map[string][]eventBridgeEntry{"Entries": {{
+		EventBusName: e.EventBus,
+		Source:       source,
+		DetailType:   detailType,
+		Detail:       string(detail),
+		Time:         time.Now().Unix(),
+	}}})
+	if err != nil {
+		return err
+	}
+	return withRateLimitRetry(func() error { return e.put(body) })
+}
+
+// put sends a PutEvents request once
+func (e *EventBridge) put(body []byte) error {
+	endpoint := e.Endpoint
+	if endpoint == "" {
+		endpoint = "https://events." + e.Region + ".amazonaws.com"
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AWSEvents.PutEvents")
+	signAWSRequest(req, body, e.Credentials, e.Region, "events", time.Now())
+
+	client := e.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(data, &failure)
+		if strings.HasSuffix(failure.Type, "ThrottlingException") {
+			return &RateLimitError{Platform: "eventbridge", RetryAfter: eventBridgeRetryAfter}
+		}
+		if failure.Type == "" {
+			return fmt.Errorf("eventbridge: %s: %s", resp.Status, bytes.TrimSpace(data))
+		}
+		return fmt.Errorf("eventbridge: %s: %s", failure.Type[strings.LastIndex(failure.Type, "#")+1:], failure.Message)
+	}
+	var result eventBridgeResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("eventbridge: decoding response: %w", err)
+	}
+	if result.FailedEntryCount > 0 && len(result.Entries) > 0 {
+		entry := result.Entries[0]
+		if entry.ErrorCode == "ThrottlingException" {
+			return &RateLimitError{Platform: "eventbridge", RetryAfter: eventBridgeRetryAfter}
+		}
+		return fmt.Errorf("eventbridge: %s: %s", entry.ErrorCode, entry.ErrorMessage)
+	}
+	return nil
+}
+
+// signAWSRequest adds AWS Signature Version 4 headers to a request
+func signAWSRequest(req *http.Request, body []byte, credentials AWSCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	// Sign the host and every x-amz-* and content-type header
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-") || name == "content-type" {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method, path, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,240 @@
+package notify
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+)
+
+// Denomination describes the units balances are reported in, so forks,
+// testnets, or a future redenomination can be tracked
+type Denomination struct {
+	BaseUnit         string // Smallest unit, as returned by the RPC, e.g. "nick"
+	Unit             string // Display unit, e.g. "$NOCK"
+	BaseUnitsPerUnit int64  // e.g. 65536 nick per $NOCK
+	Decimals         int    // Decimal places shown for Unit amounts
+}
+
+// DefaultDenomination is nockchain's nick/$NOCK denomination
+var DefaultDenomination = Denomination{
+	BaseUnit:         "nick",
+	Unit:             "$NOCK",
+	BaseUnitsPerUnit: NickPerNock,
+	Decimals:         2,
+}
+
+// FormatUnits formats an amount of base units in the display unit of the
+// default denomination, e.g. "526.18 $NOCK"
+func FormatUnits(nick int64) string {
+	return Formatter{}.Units(nick)
+}
+
+// NumberFormat controls how amounts are written in messages
+type NumberFormat struct {
+	Decimal   string // Decimal mark, e.g. "." or ","
+	Thousands string // Group separator, e.g. "," or "."; empty disables grouping
+	Compact   bool   // Abbreviate large nick amounts, e.g. 1.25M nick
+}
+
+// Locales are the number formats selectable by name
+var Locales = map[string]NumberFormat{
+	"en":  {Decimal: ".", Thousands: ","},      // 1,234,567.89
+	"de":  {Decimal: ",", Thousands: "."},      // 1.234.567,89
+	"fr":  {Decimal: ",", Thousands: "\u202f"}, // 1 234 567,89 (narrow no-break space)
+	"ch":  {Decimal: ".", Thousands: "'"},      // 1'234'567.89
+	"raw": {Decimal: "."},                      // 1234567.89
+}
+
+// FormatNumber formats a number with the given number of decimal places in
+// the default number format
+func FormatNumber(value float64, decimals int) string {
+	return Formatter{}.Number(value, decimals)
+}
+
+// Formatter writes amounts in a denomination and number format. Each
+// notifier has its own, in its Templates, so tenants and notifiers can
+// differ; the zero Formatter uses DefaultDenomination and the "en" format.
+type Formatter struct {
+	Denomination Denomination
+	NumberFormat NumberFormat
+}
+
+// denomination returns the denomination, DefaultDenomination if unset
+func (f Formatter) denomination() Denomination {
+	if f.Denomination.BaseUnitsPerUnit == 0 {
+		return DefaultDenomination
+	}
+	return f.Denomination
+}
+
+// numberFormat returns the number format, the "en" format if unset
+func (f Formatter) numberFormat() NumberFormat {
+	if f.NumberFormat.Decimal == "" {
+		format := Locales["en"]
+		format.Compact = f.NumberFormat.Compact
+		return format
+	}
+	return f.NumberFormat
+}
+
+// Unit returns the display unit, e.g. "$NOCK"
+func (f Formatter) Unit() string {
+	return f.denomination().Unit
+}
+
+// BaseUnitsPerUnit returns how many base units make one display unit
+func (f Formatter) BaseUnitsPerUnit() int64 {
+	return f.denomination().BaseUnitsPerUnit
+}
+
+// ToUnits converts base units (nick) to display units ($NOCK)
+func (f Formatter) ToUnits(nick int64) float64 {
+	return float64(nick) / float64(f.denomination().BaseUnitsPerUnit)
+}
+
+// Balance formats a balance in both nick and $NOCK
+func (f Formatter) Balance(nick int64) string {
+	return fmt.Sprintf("%s %s (%s)", f.nick(nick, false), f.denomination().BaseUnit, f.Units(nick))
+}
+
+// Delta formats a signed change in both nick and $NOCK
+func (f Formatter) Delta(nick int64) string {
+	d := f.denomination()
+	return fmt.Sprintf("%s %s (%s %s)", f.nick(nick, true), d.BaseUnit, f.number(f.ToUnits(nick), d.Decimals, true), d.Unit)
+}
+
+// Units formats an amount of base units in the display unit, e.g.
+// "526.18 $NOCK"
+func (f Formatter) Units(nick int64) string {
+	d := f.denomination()
+	return f.Number(f.ToUnits(nick), d.Decimals) + " " + d.Unit
+}
+
+// Number formats a number with the given number of decimal places using
+// the decimal mark and thousands separator
+func (f Formatter) Number(value float64, decimals int) string {
+	return f.number(value, decimals, false)
+}
+
+// number implements Number, optionally with a leading + for non-negative
+// values
+func (f Formatter) number(value float64, decimals int, signed bool) string {
+	format := f.numberFormat()
+	text := fmt.Sprintf("%.*f", decimals, math.Abs(value))
+	negative := value < 0 && strings.Trim(text, "0.") != "" // Not rounded to zero
+	whole, fraction, _ := strings.Cut(text, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(format.Thousands)
+		}
+		grouped.WriteRune(digit)
+	}
+	text = grouped.String()
+	if fraction != "" {
+		text += format.Decimal + fraction
+	}
+
+	switch {
+	case negative:
+		return "-" + text
+	case signed:
+		return "+" + text
+	}
+	return text
+}
+
+// compactUnits are the suffixes used in compact notation
+var compactUnits = []struct {
+	Suffix string
+	Size   float64
+}{
+	{"T", 1e12},
+	{"B", 1e9},
+	{"M", 1e6},
+	{"K", 1e3},
+}
+
+// nick formats an amount of base units, compactly when configured
+func (f Formatter) nick(nick int64, signed bool) string {
+	if f.numberFormat().Compact {
+		abs := math.Abs(float64(nick))
+		for _, unit := range compactUnits {
+			if abs >= unit.Size {
+				return f.number(float64(nick)/unit.Size, 2, signed) + unit.Suffix
+			}
+		}
+	}
+	return f.number(float64(nick), 0, signed)
+}
+
+// Units selects which amounts a notifier shows
+type Units string
+
+const (
+	UnitsAll      Units = ""          // nick, $NOCK, and fiat when prices are enabled
+	UnitsBase     Units = "nick"      // nick only
+	UnitsUnit     Units = "nock"      // $NOCK only
+	UnitsUnitFiat Units = "nock+fiat" // $NOCK, and fiat when prices are enabled
+)
+
+// ParseUnits validates a units setting
+func ParseUnits(s string) (Units, error) {
+	switch units := Units(strings.ToLower(s)); units {
+	case UnitsAll, UnitsBase, UnitsUnit, UnitsUnitFiat:
+		return units, nil
+	}
+	return "", fmt.Errorf("units must be %q, %q or %q, got %q", UnitsBase, UnitsUnit, UnitsUnitFiat, s)
+}
+
+// fiat reports whether fiat values are shown
+func (u Units) fiat() bool {
+	return u == UnitsAll || u == UnitsUnitFiat
+}
+
+// style is how a notifier writes its messages: the amounts it shows and
+// their format, its emojis, titles and colors, and its language
+type style struct {
+	units    Units
+	format   Formatter
+	branding Branding
+	tr       Translations
+}
+
+// balance formats an amount in the selected units
+func (s style) balance(nick int64, quote price.Quote) string {
+	var text string
+	switch s.units {
+	case UnitsBase:
+		text = s.format.nick(nick, false) + " " + s.format.denomination().BaseUnit
+	case UnitsUnit, UnitsUnitFiat:
+		text = s.format.Units(nick)
+	default:
+		text = s.format.Balance(nick)
+	}
+	if s.units.fiat() && len(quote) > 0 {
+		text += " ≈ " + s.format.Fiat(nick, quote)
+	}
+	return text
+}
+
+// delta formats a signed change in the selected units
+func (s style) delta(nick int64, quote price.Quote) string {
+	var text string
+	switch s.units {
+	case UnitsBase:
+		text = s.format.nick(nick, true) + " " + s.format.denomination().BaseUnit
+	case UnitsUnit, UnitsUnitFiat:
+		d := s.format.denomination()
+		text = s.format.number(s.format.ToUnits(nick), d.Decimals, true) + " " + d.Unit
+	default:
+		text = s.format.Delta(nick)
+	}
+	if s.units.fiat() && len(quote) > 0 {
+		text += " ≈ " + s.format.FiatDelta(nick, quote)
+	}
+	return text
+}
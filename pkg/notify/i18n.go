@@ -0,0 +1,409 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultLanguage is the language messages are written in
+const DefaultLanguage = "en"
+
+// Translations maps the English text of built-in messages to another
+// language; text without a translation is left in English, so a nil
+// Translations is English
+type Translations map[string]string
+
+// T returns the translation of text, or text itself if there is none
+func (t Translations) T(text string) string {
+	if translated, ok := t[text]; ok {
+		return translated
+	}
+	return text
+}
+
+// Sprintf formats with the translation of format
+func (t Translations) Sprintf(format string, args ...interface{}) string {
+	return fmt.Sprintf(t.T(format), args...)
+}
+
+// alert translates the title and field names of an alert; titles and
+// field values that include amounts or names stay in English
+func (t Translations) alert(alert Alert) Alert {
+	alert.Title = t.T(alert.Title)
+	fields := make([]Field, len(alert.Fields))
+	for i, field := range alert.Fields {
+		fields[i] = Field{Name: t.T(field.Name), Value: field.Value}
+	}
+	alert.Fields = fields
+	return alert
+}
+
+// Languages returns the languages with built-in translations, English first
+func Languages() []string {
+	languages := []string{DefaultLanguage}
+	for language := range builtinTranslations {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages[1:])
+	return languages
+}
+
+// LoadTranslations returns the built-in translations for a language,
+// overridden by <dir>/<language>.json if it exists. The file is a JSON
+// object from English text to its translation, and can add languages
+// without built-in translations.
+func LoadTranslations(dir, language string) (Translations, error) {
+	language = strings.ToLower(language)
+	if language == "" || language == DefaultLanguage && dir == "" {
+		return nil, nil
+	}
+	translations := Translations{}
+	builtin, known := builtinTranslations[language]
+	for text, translated := range builtin {
+		translations[text] = translated
+	}
+
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, language+".json"))
+		switch {
+		case err == nil:
+			var overrides Translations
+			if err := json.Unmarshal(data, &overrides); err != nil {
+				return nil, fmt.Errorf("parsing %s translations: %w", language, err)
+			}
+			for text, translated := range overrides {
+				translations[text] = translated
+			}
+			known = true
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+	if !known && language != DefaultLanguage {
+		return nil, fmt.Errorf("no translations for language %q; built in are %s", language, strings.Join(Languages(), ", "))
+	}
+	return translations, nil
+}
+
+// builtinTranslations are the translations shipped with the alerter
+var builtinTranslations = map[string]Translations{
+	"zh": {
+		"Balance Change Alert":          "余额变动提醒",
+		"Balance Summary":               "余额汇总",
+		"Address":                       "地址",
+		"Address %d":                    "地址 %d",
+		"Old Balance":                   "原余额",
+		"New Balance":                   "新余额",
+		"Initial balance":               "初始余额",
+		"Balance":                       "余额",
+		"Balance (unchanged)":           "余额（未变）",
+		"Change":                        "变动",
+		"Changes":                       "变动次数",
+		"Fee":                           "手续费",
+		"Sent To":                       "转至",
+		"Memo":                          "备注",
+		"Details":                       "详情",
+		"Locked":                        "锁定",
+		"Liquid":                        "可用",
+		"Unrealized P&L":                "未实现盈亏",
+		"Last Updated":                  "最后更新",
+		"Updated at %s":                 "更新于 %s",
+		"Generated at %s":               "生成于 %s",
+		"Total":                         "总计",
+		"Total Locked":                  "锁定总计",
+		"Total Liquid":                  "可用总计",
+		"Total Change":                  "总变动",
+		"Ungrouped":                     "未分组",
+		"stale (last success %s ago)":   "数据过期（上次成功于 %s 前）",
+		"Transactions":                  "交易",
+		"Maximum":                       "上限",
+		"Fee above maximum":             "手续费超过上限",
+		"Funds unlocked":                "资金已解锁",
+		"Locked balance decreased":      "锁定余额减少",
+		"Unlocked":                      "已解锁",
+		"Still Locked":                  "仍锁定",
+		"Locked Change":                 "锁定变动",
+		"Not Returned to Liquid":        "未转为可用",
+		"Payout overdue":                "收益逾期",
+		"Last Payout":                   "上次收益",
+		"Usual Cadence":                 "通常间隔",
+		"Average Payout":                "平均收益",
+		"Inflow below expectation":      "流入低于预期",
+		"Inflow back on schedule":       "流入已恢复正常",
+		"Expected":                      "预期",
+		"Received":                      "已收到",
+		"Shortfall":                     "缺口",
+		"Dormant funds moved":           "休眠资金被转出",
+		"Dormant For":                   "休眠时长",
+		"Last Activity":                 "上次活动",
+		"Moved":                         "转出",
+		"Signing":                       "签名",
+		"Signer set changed":            "签名者集合已变更",
+		"Before":                        "变更前",
+		"After":                         "变更后",
+		"Signers Added":                 "新增签名者",
+		"Signers Removed":               "移除签名者",
+		"UTXOs fragmented":              "UTXO 过于分散",
+		"UTXOs":                         "UTXO",
+		"Suggestion":                    "建议",
+		"Dust received":                 "收到粉尘",
+		"New Dust Outputs":              "新增粉尘输出",
+		"Dust Threshold":                "粉尘阈值",
+		"Total Dust Outputs":            "粉尘输出总数",
+		"New related address":           "发现关联地址",
+		"Seen In":                       "出现于",
+		"Action":                        "操作",
+		"Unstable balance readings":     "余额读数不稳定",
+		"Balance readings stable again": "余额读数已恢复稳定",
+		"Readings":                      "读数",
+		"Alerts":                        "提醒",
+		"Unstable For":                  "不稳定时长",
+		"Node unreachable":              "节点无法访问",
+		"Node reachable again":          "节点已恢复访问",
+		"Node falling behind":           "节点落后",
+		"Node caught up":                "节点已同步",
+		"Node losing peers":             "节点连接数不足",
+		"Node peers recovered":          "节点连接已恢复",
+		"RPC endpoint failing":          "RPC 端点故障",
+		"RPC endpoint recovered":        "RPC 端点已恢复",
+		"Endpoint":                      "端点",
+		"Height":                        "高度",
+		"Indexer Height":                "索引器高度",
+		"Peers":                         "连接数",
+		"Version":                       "版本",
+		"Error":                         "错误",
+		"Offline":                       "离线",
+		"Net Change":                    "净变动",
+		"Previous Price":                "原价格",
+		"Current Price":                 "当前价格",
+		"Monitoring started":            "开始监控",
+		"Watching":                      "监控中",
+		"…and %d more addresses":        "……还有 %d 个地址",
+		"More":                          "更多",
+		"Addresses":                     "地址数",
+		"%d, full table attached":       "%d 个，完整表格见附件",
+		"Alerting Activity":             "告警活动",
+		"Since":                         "起始",
+		"Alerts Sent":                   "已发送告警",
+		"RPC Errors":                    "RPC 错误",
+		"Largest Change":                "最大单笔变动",
+		"Net Flow":                      "净流量",
+		"Flow":                          "资金流",
+		"Total Flow":                    "总资金流",
+		"received %s, sent %s, net %s over the last %s": "过去 %[4]s 收到 %[1]s，发出 %[2]s，净额 %[3]s",
+	},
+	"ru": {
+		"Balance Change Alert":          "Изменение баланса",
+		"Balance Summary":               "Сводка балансов",
+		"Address":                       "Адрес",
+		"Address %d":                    "Адрес %d",
+		"Old Balance":                   "Прежний баланс",
+		"New Balance":                   "Новый баланс",
+		"Initial balance":               "Начальный баланс",
+		"Balance":                       "Баланс",
+		"Balance (unchanged)":           "Баланс (без изменений)",
+		"Change":                        "Изменение",
+		"Changes":                       "Изменения",
+		"Fee":                           "Комиссия",
+		"Sent To":                       "Отправлено на",
+		"Memo":                          "Примечание",
+		"Details":                       "Подробности",
+		"Locked":                        "Заблокировано",
+		"Liquid":                        "Доступно",
+		"Unrealized P&L":                "Нереализованная прибыль/убыток",
+		"Last Updated":                  "Обновлено",
+		"Updated at %s":                 "Обновлено %s",
+		"Generated at %s":               "Сформировано %s",
+		"Total":                         "Итого",
+		"Total Locked":                  "Всего заблокировано",
+		"Total Liquid":                  "Всего доступно",
+		"Total Change":                  "Общее изменение",
+		"Ungrouped":                     "Без группы",
+		"stale (last success %s ago)":   "устарело (последний успех %s назад)",
+		"Transactions":                  "Транзакции",
+		"Maximum":                       "Максимум",
+		"Fee above maximum":             "Комиссия выше максимума",
+		"Funds unlocked":                "Средства разблокированы",
+		"Locked balance decreased":      "Заблокированный баланс уменьшился",
+		"Unlocked":                      "Разблокировано",
+		"Still Locked":                  "Ещё заблокировано",
+		"Locked Change":                 "Изменение блокировки",
+		"Not Returned to Liquid":        "Не вернулось в доступные",
+		"Payout overdue":                "Выплата задерживается",
+		"Last Payout":                   "Последняя выплата",
+		"Usual Cadence":                 "Обычный интервал",
+		"Average Payout":                "Средняя выплата",
+		"Inflow below expectation":      "Поступления ниже ожидаемых",
+		"Inflow back on schedule":       "Поступления вернулись к графику",
+		"Expected":                      "Ожидается",
+		"Received":                      "Получено",
+		"Shortfall":                     "Недостача",
+		"Dormant funds moved":           "Перемещены спящие средства",
+		"Dormant For":                   "Без движения",
+		"Last Activity":                 "Последняя активность",
+		"Moved":                         "Перемещено",
+		"Signing":                       "Подпись",
+		"Signer set changed":            "Изменён состав подписантов",
+		"Before":                        "До",
+		"After":                         "После",
+		"Signers Added":                 "Добавлены подписанты",
+		"Signers Removed":               "Удалены подписанты",
+		"UTXOs fragmented":              "UTXO раздроблены",
+		"UTXOs":                         "UTXO",
+		"Suggestion":                    "Рекомендация",
+		"Dust received":                 "Получена пыль",
+		"New Dust Outputs":              "Новые пылевые выходы",
+		"Dust Threshold":                "Порог пыли",
+		"Total Dust Outputs":            "Всего пылевых выходов",
+		"New related address":           "Новый связанный адрес",
+		"Seen In":                       "Найден в",
+		"Action":                        "Действие",
+		"Unstable balance readings":     "Нестабильные показания баланса",
+		"Balance readings stable again": "Показания баланса снова стабильны",
+		"Readings":                      "Показания",
+		"Alerts":                        "Оповещения",
+		"Unstable For":                  "Нестабильно в течение",
+		"Node unreachable":              "Узел недоступен",
+		"Node reachable again":          "Узел снова доступен",
+		"Node falling behind":           "Узел отстаёт",
+		"Node caught up":                "Узел догнал сеть",
+		"Node losing peers":             "Узел теряет пиров",
+		"Node peers recovered":          "Пиры узла восстановлены",
+		"RPC endpoint failing":          "RPC-эндпоинт не отвечает",
+		"RPC endpoint recovered":        "RPC-эндпоинт восстановлен",
+		"Endpoint":                      "Эндпоинт",
+		"Height":                        "Высота",
+		"Indexer Height":                "Высота индексатора",
+		"Peers":                         "Пиры",
+		"Version":                       "Версия",
+		"Error":                         "Ошибка",
+		"Offline":                       "Не в сети",
+		"Net Change":                    "Чистое изменение",
+		"Previous Price":                "Прежняя цена",
+		"Current Price":                 "Текущая цена",
+		"Monitoring started":            "Мониторинг запущен",
+		"Watching":                      "Отслеживается",
+		"…and %d more addresses":        "…и ещё %d адресов",
+		"More":                          "Ещё",
+		"Addresses":                     "Адреса",
+		"%d, full table attached":       "%d, полная таблица во вложении",
+		"Alerting Activity":             "Активность оповещений",
+		"Since":                         "С",
+		"Alerts Sent":                   "Отправлено оповещений",
+		"RPC Errors":                    "Ошибки RPC",
+		"Largest Change":                "Крупнейшее изменение",
+		"Net Flow":                      "Чистый поток",
+		"Flow":                          "Поток",
+		"Total Flow":                    "Общий поток",
+		"received %s, sent %s, net %s over the last %s": "получено %s, отправлено %s, итого %s за последние %s",
+	},
+	"es": {
+		"Balance Change Alert":          "Alerta de cambio de saldo",
+		"Balance Summary":               "Resumen de saldos",
+		"Address":                       "Dirección",
+		"Address %d":                    "Dirección %d",
+		"Old Balance":                   "Saldo anterior",
+		"New Balance":                   "Saldo nuevo",
+		"Initial balance":               "Saldo inicial",
+		"Balance":                       "Saldo",
+		"Balance (unchanged)":           "Saldo (sin cambios)",
+		"Change":                        "Cambio",
+		"Changes":                       "Cambios",
+		"Fee":                           "Comisión",
+		"Sent To":                       "Enviado a",
+		"Memo":                          "Nota",
+		"Details":                       "Detalles",
+		"Locked":                        "Bloqueado",
+		"Liquid":                        "Disponible",
+		"Unrealized P&L":                "Ganancia/pérdida no realizada",
+		"Last Updated":                  "Última actualización",
+		"Updated at %s":                 "Actualizado el %s",
+		"Generated at %s":               "Generado el %s",
+		"Total":                         "Total",
+		"Total Locked":                  "Total bloqueado",
+		"Total Liquid":                  "Total disponible",
+		"Total Change":                  "Cambio total",
+		"Ungrouped":                     "Sin grupo",
+		"stale (last success %s ago)":   "desactualizado (último éxito hace %s)",
+		"Transactions":                  "Transacciones",
+		"Maximum":                       "Máximo",
+		"Fee above maximum":             "Comisión por encima del máximo",
+		"Funds unlocked":                "Fondos desbloqueados",
+		"Locked balance decreased":      "El saldo bloqueado disminuyó",
+		"Unlocked":                      "Desbloqueado",
+		"Still Locked":                  "Aún bloqueado",
+		"Locked Change":                 "Cambio bloqueado",
+		"Not Returned to Liquid":        "No devuelto a disponible",
+		"Payout overdue":                "Pago atrasado",
+		"Last Payout":                   "Último pago",
+		"Usual Cadence":                 "Frecuencia habitual",
+		"Average Payout":                "Pago promedio",
+		"Inflow below expectation":      "Entradas por debajo de lo esperado",
+		"Inflow back on schedule":       "Entradas de nuevo al día",
+		"Expected":                      "Esperado",
+		"Received":                      "Recibido",
+		"Shortfall":                     "Déficit",
+		"Dormant funds moved":           "Se movieron fondos inactivos",
+		"Dormant For":                   "Inactivos durante",
+		"Last Activity":                 "Última actividad",
+		"Moved":                         "Movido",
+		"Signing":                       "Firma",
+		"Signer set changed":            "Cambió el conjunto de firmantes",
+		"Before":                        "Antes",
+		"After":                         "Después",
+		"Signers Added":                 "Firmantes añadidos",
+		"Signers Removed":               "Firmantes eliminados",
+		"UTXOs fragmented":              "UTXOs fragmentados",
+		"UTXOs":                         "UTXOs",
+		"Suggestion":                    "Sugerencia",
+		"Dust received":                 "Polvo recibido",
+		"New Dust Outputs":              "Nuevas salidas de polvo",
+		"Dust Threshold":                "Umbral de polvo",
+		"Total Dust Outputs":            "Total de salidas de polvo",
+		"New related address":           "Nueva dirección relacionada",
+		"Seen In":                       "Vista en",
+		"Action":                        "Acción",
+		"Unstable balance readings":     "Lecturas de saldo inestables",
+		"Balance readings stable again": "Lecturas de saldo estables de nuevo",
+		"Readings":                      "Lecturas",
+		"Alerts":                        "Alertas",
+		"Unstable For":                  "Inestable durante",
+		"Node unreachable":              "Nodo inaccesible",
+		"Node reachable again":          "Nodo accesible de nuevo",
+		"Node falling behind":           "El nodo se está retrasando",
+		"Node caught up":                "Nodo sincronizado",
+		"Node losing peers":             "El nodo está perdiendo pares",
+		"Node peers recovered":          "Pares del nodo recuperados",
+		"RPC endpoint failing":          "Endpoint RPC con fallos",
+		"RPC endpoint recovered":        "Endpoint RPC recuperado",
+		"Endpoint":                      "Endpoint",
+		"Height":                        "Altura",
+		"Indexer Height":                "Altura del indexador",
+		"Peers":                         "Pares",
+		"Version":                       "Versión",
+		"Error":                         "Error",
+		"Offline":                       "Sin conexión",
+		"Net Change":                    "Cambio neto",
+		"Previous Price":                "Precio anterior",
+		"Current Price":                 "Precio actual",
+		"Monitoring started":            "Monitoreo iniciado",
+		"Watching":                      "Vigilando",
+		"…and %d more addresses":        "…y %d direcciones más",
+		"More":                          "Más",
+		"Addresses":                     "Direcciones",
+		"%d, full table attached":       "%d, tabla completa adjunta",
+		"Alerting Activity":             "Actividad de alertas",
+		"Since":                         "Desde",
+		"Alerts Sent":                   "Alertas enviadas",
+		"RPC Errors":                    "Errores de RPC",
+		"Largest Change":                "Mayor cambio",
+		"Net Flow":                      "Flujo neto",
+		"Flow":                          "Flujo",
+		"Total Flow":                    "Flujo total",
+		"received %s, sent %s, net %s over the last %s": "recibido %s, enviado %s, neto %s en las últimas %s",
+	},
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DefaultJournalSocket is where journald listens for native protocol
+// entries
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// Journal writes alerts and summaries to the systemd journal with
+// structured fields, queryable with e.g. journalctl NOCKCHAIN_RULE=decrease
+type Journal struct {
+	Socket     string    // DefaultJournalSocket if empty
+	Identifier string    // SYSLOG_IDENTIFIER; DefaultSyslogAppName if empty
+	Format     Formatter // Denomination and number format of amounts
+	Branding   Branding  // Message titles; DefaultBranding if zero
+}
+
+// Name implements Notifier
+func (j *Journal) Name() string { return "Journal" }
+
+// NotifyChange implements Notifier
+func (j *Journal) NotifyChange(change Change) error {
+	return j.send(newChangeLogEvent(change, j.Format, j.Branding.orDefault()))
+}
+
+// NotifySummary implements Notifier
+func (j *Journal) NotifySummary(balances []Balance) error {
+	return j.send(newSummaryLogEvent(balances, j.Format, j.Branding.orDefault()))
+}
+
+// NotifyAlert implements Notifier
+func (j *Journal) NotifyAlert(alert Alert) error {
+	return j.send(newAlertLogEvent(alert))
+}
+
+// send writes one entry in journald's native protocol
+func (j *Journal) send(event logEvent) error {
+	socket := j.Socket
+	if socket == "" {
+		socket = DefaultJournalSocket
+	}
+	identifier := j.Identifier
+	if identifier == "" {
+		identifier = DefaultSyslogAppName
+	}
+
+	var entry bytes.Buffer
+	writeJournalField(&entry, "MESSAGE", event.Message)
+	writeJournalField(&entry, "PRIORITY", fmt.Sprint(syslogSeverity(event.Severity)))
+	writeJournalField(&entry, "SYSLOG_IDENTIFIER", identifier)
+	writeJournalField(&entry, "NOCKCHAIN_KIND", event.Kind)
+	writeJournalField(&entry, "NOCKCHAIN_SEVERITY", event.Severity.String())
+	if event.Rule != "" {
+		writeJournalField(&entry, "NOCKCHAIN_RULE", event.Rule)
+	}
+	for _, field := range event.Fields {
+		if field.Value != "" {
+			writeJournalField(&entry, "NOCKCHAIN_"+strings.ToUpper(field.Name), field.Value)
+		}
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(entry.Bytes()); err != nil {
+		return fmt.Errorf("journal: %w", err)
+	}
+	return nil
+}
+
+// writeJournalField appends a field in the native protocol: NAME=value, or
+// for values with newlines NAME, the little-endian length and the value
+func writeJournalField(entry *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		entry.WriteString(name + "=" + value + "\n")
+		return
+	}
+	entry.WriteString(name + "\n")
+	binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.WriteString(value + "\n")
+}
@@ -0,0 +1,576 @@
+// Package notify delivers balance change alerts and summaries to chat
+// platforms.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+	"github.com/slack-go/slack"
+)
+
+// NickPerNock is the number of nick in one $NOCK (2^16), see DefaultDenomination
+const NickPerNock = 65536
+
+const (
+	maxRateLimitRetries = 3           // Retries of a rate-limited request before giving up
+	maxRetryAfter       = time.Minute // Longer waits are returned as errors instead
+)
+
+// RateLimitError reports that a platform asked the caller to back off
+type RateLimitError struct {
+	Platform   string
+	RetryAfter time.Duration
+}
+
+// Error implements error
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s rate limit exceeded, retry after %s", e.Platform, e.RetryAfter)
+}
+
+// Change describes a balance change for a single address
+type Change struct {
+	Address    string
+	Label      string
+	OldBalance int64
+	NewBalance int64
+	Initial    bool           // First time the address was seen; OldBalance is meaningless
+	Fee        int64          // Network fee paid by an outgoing change in nick, 0 if unknown
+	SentTo     []Counterparty // Other addresses in an outgoing change's transactions, nil if unknown
+	Memos      []string       // Sanitized memos of the change's transactions, if any
+	Time       time.Time
+	Quote      price.Quote // Fiat price of $NOCK, nil when unavailable
+	Severity   Severity
+	Key        string // Idempotency key, the same whenever this change is re-sent
+}
+
+// Counterparty is another address in the transactions of a change
+type Counterparty struct {
+	Address string `json:"address"`
+	Label   string `json:"label,omitempty"`
+	Known   bool   `json:"known,omitempty"` // A known third party, such as an exchange
+}
+
+// String returns the counterparty's label, or its address when unnamed
+func (c Counterparty) String() string {
+	if c.Label == "" {
+		return c.Address
+	}
+	return c.Label
+}
+
+// FormatCounterparties lists counterparties by name, separated by commas
+func FormatCounterparties(parties []Counterparty) string {
+	names := make([]string, len(parties))
+	for i, party := range parties {
+		names[i] = party.String()
+	}
+	return strings.Join(names, ", ")
+}
+
+// Balance is a single row of a balance summary
+type Balance struct {
+	Address        string
+	Label          string
+	Group          string
+	Tags           []string
+	CurrentBalance int64
+	Locked         int64  // Locked or staked part of CurrentBalance, 0 when untracked
+	Signing        string // Multisig configuration, e.g. "2-of-3 multisig"; empty for single-signer or untracked
+	LastUpdated    time.Time
+	LastSuccess    time.Time      // Last successful check, zero if unknown
+	Stale          bool           // The balance couldn't be refreshed for a while
+	Changes        []PeriodChange // Change over 24h/7d/30d where history allows
+	Flow           *Flow          // Received and sent over the flow period, nil when untracked
+	Trend          []int64        // Latest balance snapshots, oldest first, for a sparkline; nil when off
+	Quote          price.Quote    // Fiat price of $NOCK, nil when unavailable
+
+	// Cost basis of CostNick of the balance, empty CostCurrency when untracked
+	CostBasis    float64
+	CostNick     int64
+	CostCurrency string
+}
+
+// Liquid returns the part of the balance that isn't locked or staked
+func (b Balance) Liquid() int64 {
+	return b.CurrentBalance - b.Locked
+}
+
+// UnrealizedPnL returns the current value minus the cost of the part of the
+// balance with a known cost, and that as a percentage of the cost; format
+// gives the display unit the price is quoted in
+func (b Balance) UnrealizedPnL(format Formatter) (pnl, percent float64, ok bool) {
+	unitPrice, priced := b.Quote[b.CostCurrency]
+	if b.CostCurrency == "" || !priced || b.CostNick == 0 {
+		return 0, 0, false
+	}
+	pnl = format.ToUnits(b.CostNick)*unitPrice - b.CostBasis
+	if b.CostBasis > 0 {
+		percent = pnl / b.CostBasis * 100
+	}
+	return pnl, percent, true
+}
+
+// Field is a labelled value shown in an Alert
+type Field struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Alert is a generic notification for events other than balance changes,
+// such as price movements
+type Alert struct {
+	Emoji    string
+	Title    string
+	Fields   []Field
+	Time     time.Time
+	Rule     string // Kind of alert, e.g. "price", used to override its severity
+	Severity Severity
+	Key      string // Idempotency key, the same whenever this alert is re-sent
+}
+
+// Notifier delivers alerts and summaries to one destination
+type Notifier interface {
+	// Name identifies the notifier in logs, e.g. "Slack"
+	Name() string
+	NotifyChange(change Change) error
+	NotifySummary(balances []Balance) error
+	NotifyAlert(alert Alert) error
+}
+
+// PinnedSummary identifies the summary messages that are edited in place
+type PinnedSummary struct {
+	SlackChannelID    string `json:"slackChannelID,omitempty"`
+	SlackTimestamp    string `json:"slackTimestamp,omitempty"`
+	TelegramMessageID int64  `json:"telegramMessageID,omitempty"`
+}
+
+// Pinner is implemented by notifiers that can keep a single pinned summary
+// up to date instead of posting a new one each time
+type Pinner interface {
+	UpdatePinnedSummary(balances []Balance, pinned *PinnedSummary) error
+}
+
+// ChartSender is implemented by notifiers that can post a chart image
+// alongside the summary
+type ChartSender interface {
+	SendChart(png []byte, caption string) error
+}
+
+// ConvertToNock converts base units (nick) to display units ($NOCK) in the
+// default denomination
+func ConvertToNock(nick int64) float64 {
+	return Formatter{}.ToUnits(nick)
+}
+
+// FormatBalance formats the balance in both nick and $NOCK, in the default
+// denomination and number format
+func FormatBalance(nick int64) string {
+	return Formatter{}.Balance(nick)
+}
+
+// currencySymbols are the prefixes used when formatting fiat amounts;
+// other currencies are suffixed with their code
+var currencySymbols = map[string]string{
+	"usd": "$",
+	"eur": "€",
+	"gbp": "£",
+	"jpy": "¥",
+}
+
+// FormatFiat formats the fiat value of an amount of nick in every quoted
+// currency, e.g. "$12.34 · €11.20", in the default formats
+func FormatFiat(nick int64, quote price.Quote) string {
+	return Formatter{}.Fiat(nick, quote)
+}
+
+// FormatFiatDelta formats a signed fiat change, e.g. "+$1.23 · +€1.10", in
+// the default formats
+func FormatFiatDelta(nick int64, quote price.Quote) string {
+	return Formatter{}.FiatDelta(nick, quote)
+}
+
+// Fiat formats the fiat value of an amount of nick in every quoted
+// currency, e.g. "$12.34 · €11.20"
+func (f Formatter) Fiat(nick int64, quote price.Quote) string {
+	return f.fiat(nick, quote, false)
+}
+
+// FiatDelta formats a signed fiat change, e.g. "+$1.23 · +€1.10"
+func (f Formatter) FiatDelta(nick int64, quote price.Quote) string {
+	return f.fiat(nick, quote, true)
+}
+
+// fiat implements Fiat and FiatDelta
+func (f Formatter) fiat(nick int64, quote price.Quote, signed bool) string {
+	currencies := make([]string, 0, len(quote))
+	for currency := range quote {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	parts := make([]string, 0, len(currencies))
+	for _, currency := range currencies {
+		parts = append(parts, f.currency(f.ToUnits(nick)*quote[currency], currency, signed))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// currency formats a fiat amount with its currency symbol or code
+func (f Formatter) currency(value float64, currency string, signed bool) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	} else if signed {
+		sign = "+"
+	}
+	if symbol, ok := currencySymbols[currency]; ok {
+		return sign + symbol + f.Number(value, 2)
+	}
+	return sign + f.Number(value, 2) + " " + strings.ToUpper(currency)
+}
+
+// pnl formats unrealized P&L, e.g. "+$12.34 (+5.2%)"
+func (f Formatter) pnl(pnl, percent float64, currency string) string {
+	return fmt.Sprintf("%s (%s%%)", f.currency(pnl, currency, true), f.number(percent, 1, true))
+}
+
+// PeriodChange is the change in a balance over a trailing period such as 24h
+type PeriodChange struct {
+	Period string
+	Delta  int64
+}
+
+// Flow is what a balance received and sent over a trailing period such as
+// 24h
+type Flow struct {
+	Period   string
+	Received int64
+	Sent     int64 // Positive
+}
+
+// Net returns what was received minus what was sent
+func (f Flow) Net() int64 {
+	return f.Received - f.Sent
+}
+
+// add accumulates another flow over the same period
+func (f *Flow) add(other Flow) {
+	f.Period = other.Period
+	f.Received += other.Received
+	f.Sent += other.Sent
+}
+
+// GroupTotal is the combined balance of the addresses in one group
+type GroupTotal struct {
+	Group   string
+	Count   int
+	Balance int64
+	Flow    *Flow // Combined flow of the addresses that have one
+}
+
+// SummaryTotals returns the grand total across all balances and, if any
+// address is grouped, subtotals per group sorted by name; ungrouped
+// addresses are reported under "Ungrouped"
+func SummaryTotals(balances []Balance) (int64, []GroupTotal) {
+	var total int64
+	byGroup := map[string]*GroupTotal{}
+	grouped := false
+	for _, balance := range balances {
+		total += balance.CurrentBalance
+		group := balance.Group
+		if group == "" {
+			group = "Ungrouped"
+		} else {
+			grouped = true
+		}
+		if byGroup[group] == nil {
+			byGroup[group] = &GroupTotal{Group: group}
+		}
+		byGroup[group].Count++
+		byGroup[group].Balance += balance.CurrentBalance
+		if balance.Flow != nil {
+			if byGroup[group].Flow == nil {
+				byGroup[group].Flow = &Flow{}
+			}
+			byGroup[group].Flow.add(*balance.Flow)
+		}
+	}
+	if !grouped {
+		return total, nil
+	}
+
+	groups := make([]GroupTotal, 0, len(byGroup))
+	for _, g := range byGroup {
+		groups = append(groups, *g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Group < groups[j].Group })
+	return total, groups
+}
+
+// PortfolioChanges sums the period changes of all balances; addresses whose
+// history doesn't cover a period don't contribute to it
+func PortfolioChanges(balances []Balance) []PeriodChange {
+	var changes []PeriodChange
+	index := map[string]int{}
+	for _, balance := range balances {
+		for _, change := range balance.Changes {
+			i, ok := index[change.Period]
+			if !ok {
+				i = len(changes)
+				index[change.Period] = i
+				changes = append(changes, PeriodChange{Period: change.Period})
+			}
+			changes[i].Delta += change.Delta
+		}
+	}
+	return changes
+}
+
+// PortfolioFlow sums the flows of all balances, nil if none has one
+func PortfolioFlow(balances []Balance) *Flow {
+	var total *Flow
+	for _, balance := range balances {
+		if balance.Flow != nil {
+			if total == nil {
+				total = &Flow{}
+			}
+			total.add(*balance.Flow)
+		}
+	}
+	return total
+}
+
+// PortfolioPnL sums the unrealized P&L of every balance with a known cost
+func PortfolioPnL(balances []Balance, format Formatter) (pnl, percent float64, ok bool) {
+	var cost float64
+	for _, balance := range balances {
+		if p, _, priced := balance.UnrealizedPnL(format); priced {
+			pnl += p
+			cost += balance.CostBasis
+			ok = true
+		}
+	}
+	if cost > 0 {
+		percent = pnl / cost * 100
+	}
+	return pnl, percent, ok
+}
+
+// formatPeriodChanges formats period changes as "24h +5 nick (...) · 7d ..."
+func formatPeriodChanges(changes []PeriodChange, s style) string {
+	parts := make([]string, 0, len(changes))
+	for _, change := range changes {
+		parts = append(parts, change.Period+" "+s.delta(change.Delta, nil))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatFlow formats a flow as "received 5 nick, sent 3 nick, net +2 nick
+// over the last 24h"
+func formatFlow(flow Flow, s style) string {
+	return s.tr.Sprintf("received %s, sent %s, net %s over the last %s", s.balance(flow.Received, nil), s.balance(flow.Sent, nil), s.delta(flow.Net(), nil), flow.Period)
+}
+
+// summaryQuote returns the fiat quote attached to a summary, if any
+func summaryQuote(balances []Balance) price.Quote {
+	if len(balances) == 0 {
+		return nil
+	}
+	return balances[0].Quote
+}
+
+// formatTotalLines formats the grand total and group subtotals as fields for
+// notifiers to render in their own markup
+func formatTotalLines(balances []Balance, s style) []Field {
+	total, groups := SummaryTotals(balances)
+	quote := summaryQuote(balances)
+	fields := []Field{{Name: s.tr.T("Total"), Value: s.balance(total, quote)}}
+	var locked int64
+	for _, balance := range balances {
+		locked += balance.Locked
+	}
+	if locked > 0 {
+		fields = append(fields,
+			Field{Name: s.tr.T("Total Locked"), Value: s.balance(locked, quote)},
+			Field{Name: s.tr.T("Total Liquid"), Value: s.balance(total-locked, quote)},
+		)
+	}
+	if pnl, percent, ok := PortfolioPnL(balances, s.format); ok && s.units.fiat() {
+		fields = append(fields, Field{Name: s.tr.T("Unrealized P&L"), Value: s.format.pnl(pnl, percent, balances[0].CostCurrency)})
+	}
+	if changes := PortfolioChanges(balances); len(changes) > 0 {
+		fields = append(fields, Field{Name: s.tr.T("Total Change"), Value: formatPeriodChanges(changes, s)})
+	}
+	if flow := PortfolioFlow(balances); flow != nil {
+		fields = append(fields, Field{Name: s.tr.T("Total Flow"), Value: formatFlow(*flow, s)})
+	}
+	for _, g := range groups {
+		value := s.balance(g.Balance, quote)
+		if g.Flow != nil {
+			value += "; " + formatFlow(*g.Flow, s)
+		}
+		fields = append(fields, Field{
+			Name:  fmt.Sprintf("%s (%d)", s.tr.T(g.Group), g.Count),
+			Value: value,
+		})
+	}
+	return fields
+}
+
+// Delta returns the signed change in nick
+func (c Change) Delta() int64 {
+	return c.NewBalance - c.OldBalance
+}
+
+// Rule returns the alert rule of the change: "new", "increase" or
+// "decrease"
+func (c Change) Rule() string {
+	switch {
+	case c.Initial:
+		return "new"
+	case c.Delta() < 0:
+		return "decrease"
+	default:
+		return "increase"
+	}
+}
+
+// changeFields describes a change as plain text fields, for notifiers
+// without a message format of their own
+func changeFields(change Change, format Formatter) []Field {
+	fields := []Field{{Name: "Address", Value: change.Address + labelSuffix(change.Label)}}
+	if !change.Initial {
+		fields = append(fields,
+			Field{Name: "Old Balance", Value: format.Balance(change.OldBalance)},
+			Field{Name: "Change", Value: format.Delta(change.Delta())},
+		)
+	}
+	fields = append(fields, Field{Name: "New Balance", Value: format.Balance(change.NewBalance)})
+	if change.Fee > 0 {
+		fields = append(fields, Field{Name: "Fee", Value: format.Balance(change.Fee)})
+	}
+	if len(change.SentTo) > 0 {
+		fields = append(fields, Field{Name: "Sent To", Value: FormatCounterparties(change.SentTo)})
+	}
+	if len(change.Memos) > 0 {
+		fields = append(fields, Field{Name: "Memo", Value: strings.Join(change.Memos, "\n")})
+	}
+	return fields
+}
+
+// FormatDelta formats a signed change in both nick and $NOCK, in the
+// default denomination and number format
+func FormatDelta(nick int64) string {
+	return Formatter{}.Delta(nick)
+}
+
+// DirectionEmoji returns 📈 for increases and 📉 for decreases
+func DirectionEmoji(delta int64) string {
+	if delta < 0 {
+		return "📉"
+	}
+	return "📈"
+}
+
+// formatChangeLine formats the direction and signed delta of a change
+func formatChangeLine(change Change, s style) string {
+	return DirectionEmoji(change.Delta()) + " " + s.delta(change.Delta(), change.Quote)
+}
+
+// formatOldBalance formats the previous balance of a change
+func formatOldBalance(change Change, s style) string {
+	if change.Initial {
+		return s.tr.T("Initial balance")
+	}
+	return s.balance(change.OldBalance, change.Quote)
+}
+
+// staleSuffix marks a summary row whose balance couldn't be refreshed lately
+func staleSuffix(balance Balance, tr Translations) string {
+	if !balance.Stale {
+		return ""
+	}
+	return " ⚠️ " + tr.Sprintf("stale (last success %s ago)", formatAge(time.Since(balance.LastSuccess)))
+}
+
+// sparklineBars are the levels of a sparkline, lowest first
+var sparklineBars = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws values as a row of bars scaled between their minimum and
+// maximum, e.g. ▁▃▅▇; a flat series is drawn at mid height
+func Sparkline(values []int64) string {
+	if len(values) == 0 {
+		return ""
+	}
+	low, high := values[0], values[0]
+	for _, v := range values {
+		low, high = min(low, v), max(high, v)
+	}
+	bars := make([]rune, len(values))
+	for i, v := range values {
+		level := len(sparklineBars) / 2
+		if high > low {
+			level = int(float64(v-low) / float64(high-low) * float64(len(sparklineBars)-1))
+		}
+		bars[i] = sparklineBars[level]
+	}
+	return string(bars)
+}
+
+// sparklineSuffix draws a summary row's trend after its address
+func sparklineSuffix(balance Balance) string {
+	if len(balance.Trend) < 2 {
+		return ""
+	}
+	return " " + Sparkline(balance.Trend)
+}
+
+// formatAge formats a duration coarsely, e.g. "45m", "3h" or "2d"
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
+
+// labelSuffix renders an address label for display after the address
+func labelSuffix(label string) string {
+	if label == "" {
+		return ""
+	}
+	return " (" + label + ")"
+}
+
+// retryAfter returns how long to wait before retrying err, if it is a rate limit
+func retryAfter(err error) (time.Duration, bool) {
+	var rateLimit *RateLimitError
+	if errors.As(err, &rateLimit) {
+		return rateLimit.RetryAfter, true
+	}
+	var slackRateLimit *slack.RateLimitedError
+	if errors.As(err, &slackRateLimit) {
+		return slackRateLimit.RetryAfter, true
+	}
+	return 0, false
+}
+
+// withRateLimitRetry calls fn, waiting out and retrying rate-limit responses
+func withRateLimitRetry(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		wait, limited := retryAfter(err)
+		if !limited || attempt >= maxRateLimitRetries || wait > maxRetryAfter {
+			return err
+		}
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Overflow strategies for messages over a platform's size limit
+const (
+	OverflowSplit    = "split"    // Send the rest in continuation messages
+	OverflowTruncate = "truncate" // Send only what fits, ending "…and N more"
+	OverflowAttach   = "attach"   // Send summaries as a headline with the full table attached as CSV, and split alerts
+)
+
+// ParseOverflow validates an overflow strategy; empty means OverflowSplit
+func ParseOverflow(value string) (string, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "":
+		return OverflowSplit, nil
+	case OverflowSplit, OverflowTruncate, OverflowAttach:
+		return value, nil
+	default:
+		return "", fmt.Errorf("overflow must be %q, %q or %q, got %q", OverflowSplit, OverflowTruncate, OverflowAttach, value)
+	}
+}
+
+// moreAddresses is the line ending a truncated summary
+func moreAddresses(n int, tr Translations) string {
+	return tr.Sprintf("…and %d more addresses", n)
+}
+
+// truncateMessage shortens a text summary to at most max bytes by dropping
+// whole address entries from the end, keeping the totals that follow them
+// and noting how many were left out with more
+func truncateMessage(message string, max int, more func(n int) string) string {
+	if len(message) <= max {
+		return message
+	}
+	segments := strings.SplitAfter(message, summaryEntrySuffix)
+	entries, footer := segments[:len(segments)-1], segments[len(segments)-1]
+	kept := ""
+	for i, entry := range entries {
+		line := more(len(entries)-i) + "\n"
+		if len(kept)+len(entry)+len(line)+len(footer) > max {
+			if kept == "" {
+				break
+			}
+			return kept + line + footer
+		}
+		kept += entry
+	}
+	// Not even one entry fits with the totals
+	return splitMessage(message, max)[0]
+}
+
+// truncateBlocks shortens summary blocks to at most max blocks by dropping
+// whole address groups from the end, keeping the totals that follow the
+// last divider and noting how many were left out with more
+func truncateBlocks(blocks []slack.Block, max int, more func(n int) string) []slack.Block {
+	if len(blocks) <= max {
+		return blocks
+	}
+	var groups [][]slack.Block
+	var group []slack.Block
+	for _, block := range blocks {
+		group = append(group, block)
+		if block.BlockType() == slack.MBTDivider {
+			groups = append(groups, group)
+			group = nil
+		}
+	}
+	footer := group
+	var kept []slack.Block
+	for i, g := range groups {
+		if len(kept)+len(g)+1+len(footer) > max {
+			if len(kept) == 0 {
+				break
+			}
+			note := slack.NewContextBlock("", slack.NewTextBlockObject("mrkdwn", "_"+more(len(groups)-i)+"_", false, false))
+			return append(append(kept, note), footer...)
+		}
+		kept = append(kept, g...)
+	}
+	return splitBlocks(blocks, max)[0]
+}
+
+// fitAlert returns the alert as one or more alerts that each fit, per
+// fits. Fields that don't fit continue in further alerts numbered (1/2),
+// (2/2) with OverflowSplit, or are dropped for a final "…and N more" field
+// with OverflowTruncate.
+func fitAlert(alert Alert, overflow string, fits func(Alert) bool) []Alert {
+	if fits(alert) || len(alert.Fields) < 2 {
+		return []Alert{alert}
+	}
+	page := func(fields []Field) Alert {
+		paged := alert
+		paged.Fields = fields
+		return paged
+	}
+
+	if overflow == OverflowTruncate {
+		for n := len(alert.Fields) - 1; n > 0; n-- {
+			more := Field{Name: "More", Value: fmt.Sprintf("…and %d more", len(alert.Fields)-n)}
+			truncated := page(append(append([]Field{}, alert.Fields[:n]...), more))
+			if fits(truncated) {
+				return []Alert{truncated}
+			}
+		}
+		return []Alert{page(alert.Fields[:1])}
+	}
+
+	// Room is left for the widest page number the title could get
+	numbered := alert
+	numbered.Title += fmt.Sprintf(" (%d/%d)", len(alert.Fields), len(alert.Fields))
+	var pages [][]Field
+	start := 0
+	for start < len(alert.Fields) {
+		end := start + 1
+		for end < len(alert.Fields) {
+			numbered.Fields = alert.Fields[start : end+1]
+			if !fits(numbered) {
+				break
+			}
+			end++
+		}
+		pages = append(pages, alert.Fields[start:end])
+		start = end
+	}
+	alerts := make([]Alert, len(pages))
+	for i, fields := range pages {
+		alerts[i] = page(fields)
+		alerts[i].Title = fmt.Sprintf("%s (%d/%d)", alert.Title, i+1, len(pages))
+	}
+	return alerts
+}
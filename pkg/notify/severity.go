@@ -0,0 +1,76 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity is how urgently an alert should reach people; it decides whether
+// it is delivered silently or mentions the channel
+type Severity int
+
+const (
+	SeverityDefault Severity = iota // Not set; treated as a warning
+	SeverityInfo
+	SeverityWarning
+	SeverityCritical
+)
+
+// ParseSeverity parses a severity name from configuration
+func ParseSeverity(name string) (Severity, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "info":
+		return SeverityInfo, nil
+	case "warning":
+		return SeverityWarning, nil
+	case "critical":
+		return SeverityCritical, nil
+	default:
+		return SeverityDefault, fmt.Errorf("unknown severity %q, expected info, warning, or critical", name)
+	}
+}
+
+// String returns the name used for the severity in configuration
+func (s Severity) String() string {
+	switch s.level() {
+	case SeverityInfo:
+		return "info"
+	case SeverityCritical:
+		return "critical"
+	default:
+		return "warning"
+	}
+}
+
+// level resolves SeverityDefault to a warning
+func (s Severity) level() Severity {
+	if s == SeverityDefault {
+		return SeverityWarning
+	}
+	return s
+}
+
+// Delivery decides how loudly messages of each severity are delivered.
+// Summaries count as info.
+type Delivery struct {
+	// SilentUpTo delivers messages of this severity and lower without a
+	// notification sound; SeverityDefault silences nothing
+	SilentUpTo Severity
+	// Mention is prepended to critical alerts, e.g. "<!channel>" on Slack
+	// or "@here" on Discord
+	Mention string
+}
+
+// silent reports whether a message of the given severity is delivered
+// without a notification sound
+func (d Delivery) silent(severity Severity) bool {
+	return d.SilentUpTo != SeverityDefault && severity.level() <= d.SilentUpTo
+}
+
+// mention returns the mention to prepend to a message of the given severity
+func (d Delivery) mention(severity Severity) string {
+	if severity.level() != SeverityCritical {
+		return ""
+	}
+	return d.Mention
+}
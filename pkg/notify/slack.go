@@ -0,0 +1,433 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackActionDetails is the action ID of the Details button, whose value
+// is the alert's address
+const SlackActionDetails = "details"
+
+// Slack posts block kit messages to a Slack channel
+type Slack struct {
+	BotToken  string
+	Channel   string
+	Templates Templates
+	Units     Units // Amounts to show, see the Units constants
+
+	// Installation, when set, supplies the bot token and channel of a
+	// workspace installed through SlackApp instead of BotToken and Channel
+	Installation func() (token, channel string, err error)
+
+	Delivery Delivery // Mention for critical alerts; Slack can't post silently
+	Overflow string   // What to do with messages over 50 blocks, see the Overflow constants
+
+	// Actions attaches a Details button to change alerts; presses are sent
+	// to the app's interactivity request URL
+	Actions bool
+
+	channelID string // ID of Channel, learned from the last post; file uploads need it
+}
+
+// Name implements Notifier
+func (s *Slack) Name() string { return "Slack" }
+
+// NotifyChange implements Notifier
+func (s *Slack) NotifyChange(change Change) error {
+	if s.Templates.Change != nil {
+		text, err := s.Templates.renderChange(change)
+		if err != nil {
+			return err
+		}
+		if mention := s.Delivery.mention(change.Severity); mention != "" {
+			text = mention + " " + text
+		}
+		return s.send(slack.MsgOptionText(text, false))
+	}
+	blocks := createBalanceChangeBlocks(change, s.Templates.style(s.Units))
+	if s.Actions {
+		blocks = append(blocks, slack.NewActionBlock("actions",
+			slack.NewButtonBlockElement(SlackActionDetails, change.Address,
+				slack.NewTextBlockObject("plain_text", "📄 "+s.Templates.Translations.T("Details"), true, false)),
+		))
+	}
+	attachment := slack.MsgOptionAttachments(slack.Attachment{
+		Color:  changeColor(change, s.Templates.Branding.orDefault()),
+		Blocks: slack.Blocks{BlockSet: blocks},
+	})
+	if mention := s.Delivery.mention(change.Severity); mention != "" {
+		return s.send(attachment, slack.MsgOptionText(mention, false))
+	}
+	return s.send(attachment)
+}
+
+// changeColor returns the attachment accent for a change: green for
+// increases, red for decreases, neutral for new addresses
+func changeColor(change Change, branding Branding) string {
+	switch {
+	case change.Initial:
+		return branding.NewColor
+	case change.Delta() < 0:
+		return branding.DecreaseColor
+	default:
+		return branding.IncreaseColor
+	}
+}
+
+// NotifySummary implements Notifier
+func (s *Slack) NotifySummary(balances []Balance) error {
+	if s.Overflow == OverflowAttach && s.Templates.Summary == nil &&
+		len(createSummaryBlocks(balances, s.Templates.style(s.Units))) > slackMaxBlocks {
+		return s.sendSummaryAttachment(balances)
+	}
+	contents, err := s.summaryContents(balances, s.Overflow)
+	if err != nil {
+		return err
+	}
+	for _, content := range contents {
+		if err := s.send(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyAlert implements Notifier
+func (s *Slack) NotifyAlert(alert Alert) error {
+	mention := s.Delivery.mention(alert.Severity)
+	room := slackMaxBlocks
+	if mention != "" {
+		room--
+	}
+	alerts := fitAlert(alert, s.Overflow, func(a Alert) bool {
+		return len(createAlertBlocks(a, s.Templates.Translations)) <= room
+	})
+	for _, a := range alerts {
+		blocks := createAlertBlocks(a, s.Templates.Translations)
+		if mention != "" {
+			blocks = append([]slack.Block{slack.NewSectionBlock(slack.NewTextBlockObject("mrkdwn", mention, false, false), nil, nil)}, blocks...)
+		}
+		if err := s.send(slack.MsgOptionBlocks(blocks...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// summaryContents renders the summary from the template or as block kit,
+// split into as many messages as Slack's block limit requires or truncated
+// to one, per overflow
+func (s *Slack) summaryContents(balances []Balance, overflow string) ([]slack.MsgOption, error) {
+	if s.Templates.Summary != nil {
+		text, err := s.Templates.renderSummary(balances)
+		if err != nil {
+			return nil, err
+		}
+		return []slack.MsgOption{slack.MsgOptionText(text, false)}, nil
+	}
+	blocks := createSummaryBlocks(balances, s.Templates.style(s.Units))
+	if overflow == OverflowTruncate {
+		more := func(n int) string { return moreAddresses(n, s.Templates.Translations) }
+		return []slack.MsgOption{slack.MsgOptionBlocks(truncateBlocks(blocks, slackMaxBlocks, more)...)}, nil
+	}
+	var contents []slack.MsgOption
+	for _, page := range splitBlocks(blocks, slackMaxBlocks) {
+		contents = append(contents, slack.MsgOptionBlocks(page...))
+	}
+	return contents, nil
+}
+
+// credentials returns the bot token and channel to post with
+func (s *Slack) credentials() (string, string, error) {
+	if s.Installation != nil {
+		return s.Installation()
+	}
+	return s.BotToken, s.Channel, nil
+}
+
+// send sends a formatted message to the Slack channel
+func (s *Slack) send(content ...slack.MsgOption) error {
+	token, channel, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	api := slack.New(token)
+	return withRateLimitRetry(func() error {
+		channelID, _, err := api.PostMessage(
+			channel,
+			append(content, slack.MsgOptionAsUser(true))...,
+		)
+		if err == nil {
+			s.channelID = channelID
+		}
+		return err
+	})
+}
+
+// sendSummaryAttachment posts the summary headline and uploads the full
+// table as CSV
+func (s *Slack) sendSummaryAttachment(balances []Balance) error {
+	data, err := summaryCSV(balances, s.Templates.Format)
+	if err != nil {
+		return err
+	}
+	headline := summaryHeadline(balances, s.Templates.style(s.Units))
+	if err := s.send(slack.MsgOptionBlocks(createAlertBlocks(headline, s.Templates.Translations)...)); err != nil {
+		return err
+	}
+	return s.upload(data, summaryFilename(headline.Time), s.Templates.Translations.T(s.Templates.Branding.orDefault().SummaryTitle), "")
+}
+
+// SendChart implements ChartSender by uploading the chart to the channel
+func (s *Slack) SendChart(png []byte, caption string) error {
+	return s.upload(png, "summary.png", caption, caption)
+}
+
+// upload uploads a file to the channel of the last post
+func (s *Slack) upload(data []byte, filename, title, comment string) error {
+	if s.channelID == "" {
+		return fmt.Errorf("slack channel ID unknown until a message has been posted")
+	}
+	token, _, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	api := slack.New(token)
+	return withRateLimitRetry(func() error {
+		_, err := api.UploadFileV2(slack.UploadFileV2Parameters{
+			Reader:         bytes.NewReader(data),
+			FileSize:       len(data),
+			Filename:       filename,
+			Title:          title,
+			InitialComment: comment,
+			Channel:        s.channelID,
+		})
+		return err
+	})
+}
+
+// SlackReplyFile uploads a file into the thread of a message, such as the
+// alert whose Details button was pressed
+func SlackReplyFile(token, channelID, threadTS, filename, title string, data []byte) error {
+	api := slack.New(token)
+	return withRateLimitRetry(func() error {
+		_, err := api.UploadFileV2(slack.UploadFileV2Parameters{
+			Reader:          bytes.NewReader(data),
+			FileSize:        len(data),
+			Filename:        filename,
+			Title:           title,
+			Channel:         channelID,
+			ThreadTimestamp: threadTS,
+		})
+		return err
+	})
+}
+
+// UpdatePinnedSummary edits the pinned Slack summary in place, posting and
+// pinning a new message if none exists yet or the old one can't be edited.
+// A long summary is truncated to fit the pinned message. Workspaces
+// installed through SlackApp get a new summary each time, since
+// only one pinned message is tracked.
+func (s *Slack) UpdatePinnedSummary(balances []Balance, pinned *PinnedSummary) error {
+	if s.Installation != nil {
+		return s.NotifySummary(balances)
+	}
+	contents, err := s.summaryContents(balances, OverflowTruncate)
+	if err != nil {
+		return err
+	}
+	content := contents[0]
+	api := slack.New(s.BotToken)
+	if pinned.SlackTimestamp != "" {
+		s.channelID = pinned.SlackChannelID
+		err := withRateLimitRetry(func() error {
+			_, _, _, err := api.UpdateMessage(
+				pinned.SlackChannelID,
+				pinned.SlackTimestamp,
+				content,
+			)
+			return err
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("Error updating pinned Slack summary, posting a new one: %v", err)
+	}
+
+	var channelID, timestamp string
+	err = withRateLimitRetry(func() error {
+		var err error
+		channelID, timestamp, err = api.PostMessage(
+			s.Channel,
+			content,
+			slack.MsgOptionAsUser(true),
+		)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	pinned.SlackChannelID = channelID
+	pinned.SlackTimestamp = timestamp
+	s.channelID = channelID
+	err = withRateLimitRetry(func() error {
+		return api.AddPin(channelID, slack.NewRefToMessage(channelID, timestamp))
+	})
+	if err != nil {
+		return fmt.Errorf("pinning summary: %w", err)
+	}
+	return nil
+}
+
+// createBalanceChangeBlocks creates Slack blocks for a balance change alert
+func createBalanceChangeBlocks(change Change, s style) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject("plain_text", s.branding.ChangeEmoji+" "+s.tr.T(s.branding.ChangeTitle), true, false),
+		),
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: `%s`%s", s.tr.T("Address"), change.Address, labelSuffix(change.Label)), false, false),
+			nil,
+			nil,
+		),
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", s.tr.T("Old Balance"), formatOldBalance(change, s)), false, false),
+			nil,
+			nil,
+		),
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", s.tr.T("New Balance"), s.balance(change.NewBalance, change.Quote)), false, false),
+			nil,
+			nil,
+		),
+	}
+	if !change.Initial {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", s.tr.T("Change"), formatChangeLine(change, s)), false, false),
+			nil,
+			nil,
+		))
+	}
+	if change.Fee > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", s.tr.T("Fee"), s.balance(change.Fee, change.Quote)), false, false),
+			nil,
+			nil,
+		))
+	}
+	if len(change.SentTo) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", s.tr.T("Sent To"), FormatCounterparties(change.SentTo)), false, false),
+			nil,
+			nil,
+		))
+	}
+	if len(change.Memos) > 0 {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("plain_text", s.tr.T("Memo")+": "+strings.Join(change.Memos, "\n"), false, false),
+			nil,
+			nil,
+		))
+	}
+	return append(blocks,
+		slack.NewDividerBlock(),
+		slack.NewContextBlock(
+			"",
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("_%s_", s.tr.Sprintf("Updated at %s", change.Time.Format(time.RFC3339))), false, false),
+		),
+	)
+}
+
+// createSummaryBlocks creates Slack blocks for the balance summary
+func createSummaryBlocks(balances []Balance, s style) []slack.Block {
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject("plain_text", s.branding.SummaryEmoji+" "+s.tr.T(s.branding.SummaryTitle), true, false),
+		),
+	}
+
+	for i, balance := range balances {
+		balanceText := fmt.Sprintf("*%s*: %s", s.tr.T("Balance"), s.balance(balance.CurrentBalance, balance.Quote))
+		if balance.Locked > 0 {
+			balanceText += fmt.Sprintf("\n*%s*: %s\n*%s*: %s", s.tr.T("Locked"), s.balance(balance.Locked, balance.Quote), s.tr.T("Liquid"), s.balance(balance.Liquid(), balance.Quote))
+		}
+		if balance.Signing != "" {
+			balanceText += fmt.Sprintf("\n*%s*: %s", s.tr.T("Signing"), balance.Signing)
+		}
+		if len(balance.Changes) > 0 {
+			balanceText += fmt.Sprintf("\n*%s*: %s", s.tr.T("Change"), formatPeriodChanges(balance.Changes, s))
+		}
+		if balance.Flow != nil {
+			balanceText += fmt.Sprintf("\n*%s*: %s", s.tr.T("Flow"), formatFlow(*balance.Flow, s))
+		}
+		if pnl, percent, ok := balance.UnrealizedPnL(s.format); ok && s.units.fiat() {
+			balanceText += fmt.Sprintf("\n*%s*: %s", s.tr.T("Unrealized P&L"), s.format.pnl(pnl, percent, balance.CostCurrency))
+		}
+		blocks = append(blocks,
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: `%s`%s%s%s", s.tr.Sprintf("Address %d", i+1), balance.Address, labelSuffix(balance.Label), sparklineSuffix(balance), staleSuffix(balance, s.tr)), false, false),
+				nil,
+				nil,
+			),
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject("mrkdwn", balanceText, false, false),
+				nil,
+				nil,
+			),
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", s.tr.T("Last Updated"), balance.LastUpdated.Format(time.RFC3339)), false, false),
+				nil,
+				nil,
+			),
+			slack.NewDividerBlock(),
+		)
+	}
+
+	totals := ""
+	for _, field := range formatTotalLines(balances, s) {
+		totals += fmt.Sprintf("*%s*: %s\n", field.Name, field.Value)
+	}
+	blocks = append(blocks,
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", totals, false, false),
+			nil,
+			nil,
+		),
+		slack.NewContextBlock(
+			"",
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("_Generated at %s_", time.Now().Format(time.RFC3339)), false, false),
+		),
+	)
+
+	return blocks
+}
+
+// createAlertBlocks creates Slack blocks for a generic alert
+func createAlertBlocks(alert Alert, tr Translations) []slack.Block {
+	alert = tr.alert(alert)
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject("plain_text", alert.Emoji+" "+alert.Title, true, false),
+		),
+	}
+	for _, field := range alert.Fields {
+		blocks = append(blocks, slack.NewSectionBlock(
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("*%s*: %s", field.Name, field.Value), false, false),
+			nil,
+			nil,
+		))
+	}
+	return append(blocks,
+		slack.NewDividerBlock(),
+		slack.NewContextBlock(
+			"",
+			slack.NewTextBlockObject("mrkdwn", fmt.Sprintf("_%s_", tr.Sprintf("Updated at %s", alert.Time.Format(time.RFC3339))), false, false),
+		),
+	)
+}
@@ -0,0 +1,195 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackAppScopes are the bot scopes requested when a workspace installs the
+// app; incoming-webhook has the installer pick the channel for alerts
+var SlackAppScopes = []string{"chat:write", "pins:write", "files:write", "incoming-webhook"}
+
+// slackRefreshMargin is how long before it expires a rotating token is
+// refreshed
+const slackRefreshMargin = 5 * time.Minute
+
+// SlackInstallation is a workspace that installed the app through OAuth
+type SlackInstallation struct {
+	TeamID       string `json:"teamId"`
+	TeamName     string `json:"teamName"`
+	ChannelID    string `json:"channelId"`
+	Channel      string `json:"channel"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken,omitempty"` // Only with token rotation enabled
+	ExpiresAt    int64  `json:"expiresAt,omitempty"`
+}
+
+// SlackApp installs the alerter into Slack workspaces through OAuth and
+// keeps each workspace's bot token fresh, storing the installations in a
+// JSON file
+type SlackApp struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string       // OAuth redirect URL registered with the app
+	Path         string       // Installations file
+	HTTPClient   *http.Client // Defaults to http.DefaultClient
+
+	mu            sync.Mutex
+	installations map[string]SlackInstallation
+}
+
+// Load reads the stored installations, if any
+func (a *SlackApp) Load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.installations = map[string]SlackInstallation{}
+	data, err := os.ReadFile(a.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var installations []SlackInstallation
+	if err := json.Unmarshal(data, &installations); err != nil {
+		return err
+	}
+	for _, installation := range installations {
+		a.installations[installation.TeamID] = installation
+	}
+	return nil
+}
+
+// Installations returns the installed workspaces ordered by team ID
+func (a *SlackApp) Installations() []SlackInstallation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	installations := make([]SlackInstallation, 0, len(a.installations))
+	for _, installation := range a.installations {
+		installations = append(installations, installation)
+	}
+	sort.Slice(installations, func(i, j int) bool { return installations[i].TeamID < installations[j].TeamID })
+	return installations
+}
+
+// AuthorizeURL returns the Slack page that installs the app; state is
+// echoed back to the redirect URL
+func (a *SlackApp) AuthorizeURL(state string) string {
+	query := url.Values{
+		"client_id":    {a.ClientID},
+		"scope":        {strings.Join(SlackAppScopes, ",")},
+		"redirect_uri": {a.RedirectURL},
+		"state":        {state},
+	}
+	return "https://slack.com/oauth/v2/authorize?" + query.Encode()
+}
+
+// Install exchanges the code Slack sent to the redirect URL for a bot token
+// and stores the installation, replacing any earlier one of the same
+// workspace. It reports whether the workspace is new.
+func (a *SlackApp) Install(code string) (SlackInstallation, bool, error) {
+	resp, err := slack.GetOAuthV2Response(a.httpClient(), a.ClientID, a.ClientSecret, code, a.RedirectURL)
+	if err != nil {
+		return SlackInstallation{}, false, fmt.Errorf("exchanging Slack OAuth code: %w", err)
+	}
+	if resp.IncomingWebhook.ChannelID == "" {
+		return SlackInstallation{}, false, fmt.Errorf("no channel was chosen while installing into %s", resp.Team.Name)
+	}
+	installation := SlackInstallation{
+		TeamID:      resp.Team.ID,
+		TeamName:    resp.Team.Name,
+		ChannelID:   resp.IncomingWebhook.ChannelID,
+		Channel:     resp.IncomingWebhook.Channel,
+		AccessToken: resp.AccessToken,
+	}
+	setSlackRefresh(&installation, resp)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, known := a.installations[installation.TeamID]
+	a.installations[installation.TeamID] = installation
+	return installation, !known, a.save()
+}
+
+// Notifier returns a Slack notifier posting to an installed workspace
+func (a *SlackApp) Notifier(teamID string, templates Templates, units Units) *Slack {
+	return &Slack{
+		Templates: templates,
+		Units:     units,
+		Installation: func() (string, string, error) {
+			return a.credentials(teamID)
+		},
+	}
+}
+
+// Token returns the bot token of an installed workspace, refreshing it if
+// it is about to expire
+func (a *SlackApp) Token(teamID string) (string, error) {
+	token, _, err := a.credentials(teamID)
+	return token, err
+}
+
+// credentials returns a workspace's bot token and alert channel, refreshing
+// a rotating token that is about to expire
+func (a *SlackApp) credentials(teamID string) (string, string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	installation, ok := a.installations[teamID]
+	if !ok {
+		return "", "", fmt.Errorf("slack workspace %s is not installed", teamID)
+	}
+	if installation.RefreshToken != "" && time.Until(time.Unix(installation.ExpiresAt, 0)) < slackRefreshMargin {
+		resp, err := slack.RefreshOAuthV2Token(a.httpClient(), a.ClientID, a.ClientSecret, installation.RefreshToken)
+		if err != nil {
+			return "", "", fmt.Errorf("refreshing Slack token for %s: %w", installation.TeamName, err)
+		}
+		installation.AccessToken = resp.AccessToken
+		setSlackRefresh(&installation, resp)
+		a.installations[teamID] = installation
+		if err := a.save(); err != nil {
+			return "", "", fmt.Errorf("saving refreshed Slack token: %w", err)
+		}
+	}
+	return installation.AccessToken, installation.ChannelID, nil
+}
+
+// setSlackRefresh records the refresh token and expiry of a rotating token
+func setSlackRefresh(installation *SlackInstallation, resp *slack.OAuthV2Response) {
+	installation.RefreshToken, installation.ExpiresAt = "", 0
+	if resp.RefreshToken != "" && resp.ExpiresIn > 0 {
+		installation.RefreshToken = resp.RefreshToken
+		installation.ExpiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second).Unix()
+	}
+}
+
+// save writes the installations to file, readable only by the owner since
+// it holds bot tokens; callers must hold a.mu
+func (a *SlackApp) save() error {
+	installations := make([]SlackInstallation, 0, len(a.installations))
+	for _, installation := range a.installations {
+		installations = append(installations, installation)
+	}
+	sort.Slice(installations, func(i, j int) bool { return installations[i].TeamID < installations[j].TeamID })
+	data, err := json.MarshalIndent(installations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(a.Path, data, 0600)
+}
+
+// httpClient returns the client for OAuth requests
+func (a *SlackApp) httpClient() *http.Client {
+	if a.HTTPClient != nil {
+		return a.HTTPClient
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// Summary sort orders
+const (
+	SortNone    = ""        // Configured order
+	SortBalance = "balance" // Largest balance first
+	SortChange  = "change"  // Largest 24h move, up or down, first
+	SortLabel   = "label"   // Alphabetical by label, then address
+)
+
+// Platform limits on the size of a single message
+const (
+	slackMaxBlocks     = 50
+	telegramMaxLength  = 4096
+	discordMaxLength   = 2000
+	summaryEntrySuffix = "──────────\n"
+)
+
+// SortBalances sorts summary rows in place by the given order
+func SortBalances(balances []Balance, by string) error {
+	var less func(a, b Balance) bool
+	switch by {
+	case SortNone:
+		return nil
+	case SortBalance:
+		less = func(a, b Balance) bool { return a.CurrentBalance > b.CurrentBalance }
+	case SortChange:
+		less = func(a, b Balance) bool { return recentMove(a) > recentMove(b) }
+	case SortLabel:
+		less = func(a, b Balance) bool {
+			if (a.Label == "") != (b.Label == "") {
+				return a.Label != "" // Unlabelled addresses last
+			}
+			return strings.ToLower(a.Label+a.Address) < strings.ToLower(b.Label+b.Address)
+		}
+	default:
+		return fmt.Errorf("unknown summary sort %q", by)
+	}
+	sort.SliceStable(balances, func(i, j int) bool { return less(balances[i], balances[j]) })
+	return nil
+}
+
+// recentMove returns the size of the shortest period change, or -1 when the
+// balance has no history so it sorts after addresses that do
+func recentMove(balance Balance) int64 {
+	if len(balance.Changes) == 0 {
+		return -1
+	}
+	if delta := balance.Changes[0].Delta; delta < 0 {
+		return -delta
+	}
+	return balance.Changes[0].Delta
+}
+
+// splitBlocks splits summary blocks into messages of at most max blocks,
+// cutting only after dividers so an address's sections stay together
+func splitBlocks(blocks []slack.Block, max int) [][]slack.Block {
+	var pages [][]slack.Block
+	var page, group []slack.Block
+	flush := func() {
+		if len(page)+len(group) > max && len(page) > 0 {
+			pages = append(pages, page)
+			page = nil
+		}
+		page = append(page, group...)
+		group = nil
+	}
+	for _, block := range blocks {
+		group = append(group, block)
+		if block.BlockType() == slack.MBTDivider {
+			flush()
+		}
+	}
+	flush()
+	return append(pages, page)
+}
+
+// splitMessage splits a text summary into messages of at most max bytes,
+// cutting after entry separators where possible and otherwise between lines
+func splitMessage(message string, max int) []string {
+	if len(message) <= max {
+		return []string{message}
+	}
+	var segments []string
+	for _, entry := range strings.SplitAfter(message, summaryEntrySuffix) {
+		if len(entry) <= max {
+			segments = append(segments, entry)
+			continue
+		}
+		segments = append(segments, strings.SplitAfter(entry, "\n")...)
+	}
+
+	var pages []string
+	page := ""
+	for _, segment := range segments {
+		if len(page)+len(segment) > max && page != "" {
+			pages = append(pages, page)
+			page = ""
+		}
+		page += segment
+	}
+	if page != "" {
+		pages = append(pages, page)
+	}
+	return pages
+}
@@ -0,0 +1,252 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSyslogAppName is the APP-NAME of syslog messages and the
+// SYSLOG_IDENTIFIER of journal entries when none is set
+const DefaultSyslogAppName = "nockchain-balance-alerter"
+
+// syslogSDID is the structured data ID of syslog messages, under the
+// private enterprise number RFC 5612 reserves for documentation
+const syslogSDID = "nockchain@32473"
+
+// syslogFacilities maps facility names to their RFC 5424 codes
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// ParseSyslogFacility validates a facility name such as daemon or local0;
+// empty means daemon
+func ParseSyslogFacility(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return "daemon", nil
+	}
+	if _, ok := syslogFacilities[name]; !ok {
+		return "", fmt.Errorf("unknown syslog facility %q, expected e.g. daemon, user or local0-local7", name)
+	}
+	return name, nil
+}
+
+// syslogSeverity maps an alert severity to a syslog severity: critical
+// alerts are crit, warnings warning, and the rest informational. Journal
+// priorities use the same numbers.
+func syslogSeverity(severity Severity) int {
+	switch severity.level() {
+	case SeverityCritical:
+		return 2
+	case SeverityInfo:
+		return 6
+	default:
+		return 4
+	}
+}
+
+// logEvent is an alert as a one-line message with structured fields, for
+// syslog and the journal
+type logEvent struct {
+	Kind     string // "change", "summary" or "alert"
+	Rule     string
+	Severity Severity
+	Time     time.Time
+	Message  string
+	Fields   []Field // Structured fields, named in lower snake case
+}
+
+// newChangeLogEvent returns the log event of a balance change
+func newChangeLogEvent(change Change, format Formatter, branding Branding) logEvent {
+	event := logEvent{Kind: "change", Rule: change.Rule(), Severity: change.Severity, Time: change.Time}
+	event.Message = fmt.Sprintf("%s: %s%s %s", branding.ChangeTitle, change.Address, labelSuffix(change.Label), format.Balance(change.NewBalance))
+	if !change.Initial {
+		event.Message += " (" + format.Delta(change.Delta()) + ")"
+	}
+	event.Fields = []Field{
+		{Name: "address", Value: change.Address},
+		{Name: "label", Value: change.Label},
+		{Name: "old_balance", Value: strconv.FormatInt(change.OldBalance, 10)},
+		{Name: "new_balance", Value: strconv.FormatInt(change.NewBalance, 10)},
+		{Name: "delta", Value: strconv.FormatInt(change.Delta(), 10)},
+	}
+	if change.Fee > 0 {
+		event.Fields = append(event.Fields, Field{Name: "fee", Value: strconv.FormatInt(change.Fee, 10)})
+	}
+	if len(change.SentTo) > 0 {
+		event.Fields = append(event.Fields, Field{Name: "sent_to", Value: FormatCounterparties(change.SentTo)})
+	}
+	if len(change.Memos) > 0 {
+		event.Fields = append(event.Fields, Field{Name: "memo", Value: strings.Join(change.Memos, " | ")})
+	}
+	return event.withKey(change.Key)
+}
+
+// newSummaryLogEvent returns the log event of a balance summary
+func newSummaryLogEvent(balances []Balance, format Formatter, branding Branding) logEvent {
+	total, _ := SummaryTotals(balances)
+	return logEvent{
+		Kind:     "summary",
+		Severity: SeverityInfo,
+		Time:     time.Now(),
+		Message:  fmt.Sprintf("%s: %d addresses, total %s", branding.SummaryTitle, len(balances), format.Balance(total)),
+		Fields: []Field{
+			{Name: "addresses", Value: strconv.Itoa(len(balances))},
+			{Name: "total", Value: strconv.FormatInt(total, 10)},
+		},
+	}
+}
+
+// newAlertLogEvent returns the log event of any other alert, with its
+// fields in the message
+func newAlertLogEvent(alert Alert) logEvent {
+	parts := make([]string, len(alert.Fields))
+	for i, field := range alert.Fields {
+		parts[i] = field.Name + ": " + field.Value
+	}
+	message := alert.Title
+	if len(parts) > 0 {
+		message += ": " + strings.Join(parts, "; ")
+	}
+	event := logEvent{Kind: "alert", Rule: alert.Rule, Severity: alert.Severity, Time: alert.Time, Message: message}
+	return event.withKey(alert.Key)
+}
+
+// withKey adds the idempotency key to the fields, if there is one
+func (e logEvent) withKey(key string) logEvent {
+	if key != "" {
+		e.Fields = append(e.Fields, Field{Name: "key", Value: key})
+	}
+	return e
+}
+
+// Syslog sends alerts and summaries as RFC 5424 syslog messages, with the
+// alert's details as structured data
+type Syslog struct {
+	// Address is where to send messages: udp://host:514, tcp://host:601
+	// (octet-counted framing, RFC 6587) or unix:///dev/log
+	Address  string
+	Facility string    // Facility name, e.g. daemon or local0; daemon if empty
+	AppName  string    // DefaultSyslogAppName if empty
+	Format   Formatter // Denomination and number format of amounts
+	Branding Branding  // Message titles; DefaultBranding if zero
+}
+
+// Name implements Notifier
+func (s *Syslog) Name() string { return "Syslog" }
+
+// NotifyChange implements Notifier
+func (s *Syslog) NotifyChange(change Change) error {
+	return s.send(newChangeLogEvent(change, s.Format, s.Branding.orDefault()))
+}
+
+// NotifySummary implements Notifier
+func (s *Syslog) NotifySummary(balances []Balance) error {
+	return s.send(newSummaryLogEvent(balances, s.Format, s.Branding.orDefault()))
+}
+
+// NotifyAlert implements Notifier
+func (s *Syslog) NotifyAlert(alert Alert) error {
+	return s.send(newAlertLogEvent(alert))
+}
+
+// send formats and writes one message over a fresh connection, so a
+// restarted collector is picked up again
+func (s *Syslog) send(event logEvent) error {
+	target, err := url.Parse(s.Address)
+	if err != nil {
+		return fmt.Errorf("syslog: invalid address %q: %w", s.Address, err)
+	}
+	network, address := target.Scheme, target.Host
+	if network == "unix" {
+		network, address = "unixgram", target.Path
+	}
+	conn, err := net.DialTimeout(network, address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+	message := s.format(event)
+	if network == "tcp" {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	if _, err := conn.Write([]byte(message)); err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	return nil
+}
+
+// format renders an RFC 5424 message:
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD] MSG
+func (s *Syslog) format(event logEvent) string {
+	facility, ok := syslogFacilities[s.Facility]
+	if !ok {
+		facility = syslogFacilities["daemon"]
+	}
+	appName := s.AppName
+	if appName == "" {
+		appName = DefaultSyslogAppName
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	at := event.Time
+	if at.IsZero() {
+		at = time.Now()
+	}
+	msgID := event.Kind
+	if event.Rule != "" {
+		msgID = event.Rule
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID + ` kind="` + syslogParam(event.Kind) + `" severity="` + event.Severity.String() + `"`)
+	if event.Rule != "" {
+		sd.WriteString(` rule="` + syslogParam(event.Rule) + `"`)
+	}
+	for _, field := range event.Fields {
+		if field.Value != "" {
+			sd.WriteString(" " + field.Name + `="` + syslogParam(field.Value) + `"`)
+		}
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s \ufeff%s",
+		facility*8+syslogSeverity(event.Severity),
+		at.UTC().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeader(hostname, 255), syslogHeader(appName, 48), os.Getpid(), syslogHeader(msgID, 32),
+		sd.String(), event.Message)
+}
+
+// syslogParam escapes a structured data parameter value
+func syslogParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// syslogHeader makes a header field printable ASCII without spaces, at most
+// max characters, or "-" if nothing is left
+func syslogHeader(value string, max int) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+		return r
+	}, value)
+	if len(cleaned) > max {
+		cleaned = cleaned[:max]
+	}
+	if cleaned == "" {
+		return "-"
+	}
+	return cleaned
+}
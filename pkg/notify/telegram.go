@@ -0,0 +1,602 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TelegramResponse represents the envelope returned by the Telegram Bot API
+type TelegramResponse struct {
+	OK          bool            `json:"ok"`
+	Result      json.RawMessage `json:"result"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Telegram sends MarkdownV2 messages to a Telegram chat
+type Telegram struct {
+	BotToken  string
+	ChatID    string
+	Templates Templates
+	Units     Units // Amounts to show, see the Units constants
+
+	// Topics of a forum supergroup to post alerts and summaries in; 0 posts
+	// to the General topic
+	AlertThreadID   int64
+	SummaryThreadID int64
+
+	// Actions attaches Mute 1h, explorer, recent transaction, and raw
+	// transaction details buttons to change alerts; their callbacks are read with Updates
+	Actions bool
+
+	Delivery Delivery // Which severities are sent silently; Mention is unused
+	Overflow string   // What to do with messages over 4096 characters, see the Overflow constants
+}
+
+// Telegram callback actions, sent as "action:key" where key is the
+// TelegramAddressKey of the alert's address
+const (
+	TelegramActionMute         = "mute"
+	TelegramActionTransactions = "txs"
+	TelegramActionDetails      = "details"
+)
+
+// Telegram subscription approval actions, sent as "action:chat:key" to the
+// operator's chat, where chat is the ID of the chat asking to subscribe
+const (
+	TelegramActionApprove = "approve"
+	TelegramActionDeny    = "deny"
+)
+
+// TelegramUpdate is an update read with getUpdates
+type TelegramUpdate struct {
+	UpdateID      int64                  `json:"update_id"`
+	Message       *TelegramMessage       `json:"message"`
+	CallbackQuery *TelegramCallbackQuery `json:"callback_query"`
+}
+
+// TelegramMessage is a message sent to the bot, such as a command
+type TelegramMessage struct {
+	MessageID int64 `json:"message_id"`
+	From      struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID   int64  `json:"id"`
+		Type string `json:"type"` // "private", "group", "supergroup" or "channel"
+	} `json:"chat"`
+	Text string `json:"text"`
+}
+
+// TelegramButton is an inline keyboard button that sends Data back to the
+// bot when pressed
+type TelegramButton struct {
+	Text string
+	Data string
+}
+
+// TelegramCallbackQuery is a press of an inline keyboard button
+type TelegramCallbackQuery struct {
+	ID   string `json:"id"`
+	From struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Message *struct {
+		MessageID       int64 `json:"message_id"`
+		MessageThreadID int64 `json:"message_thread_id"`
+		Chat            struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+	Data string `json:"data"`
+}
+
+// TelegramAddressKey shortens an address to fit in callback data, which
+// Telegram limits to 64 bytes
+func TelegramAddressKey(address string) string {
+	sum := sha256.Sum256([]byte(address))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Name implements Notifier
+func (t *Telegram) Name() string { return "Telegram" }
+
+// NotifyChange implements Notifier
+func (t *Telegram) NotifyChange(change Change) error {
+	if t.Templates.Change != nil {
+		message, err := t.Templates.renderChange(change)
+		if err != nil {
+			return err
+		}
+		return t.sendChange(message, change)
+	}
+	return t.sendChange(createTelegramBalanceChangeMessage(change, t.Templates.style(t.Units)), change)
+}
+
+// sendChange sends a change alert, with action buttons when enabled
+func (t *Telegram) sendChange(message string, change Change) error {
+	payload := t.payload(message, t.AlertThreadID, change.Severity)
+	if t.Actions {
+		payload["reply_markup"] = t.actionKeyboard(change.Address)
+	}
+	_, err := t.call("sendMessage", payload)
+	return err
+}
+
+// actionKeyboard returns the inline keyboard attached to change alerts
+func (t *Telegram) actionKeyboard(address string) map[string]interface{} {
+	key := TelegramAddressKey(address)
+	row := []map[string]string{
+		{"text": "🔕 Mute 1h", "callback_data": TelegramActionMute + ":" + key},
+	}
+	if link := t.Templates.explorerLink(address); link != "" {
+		row = append(row, map[string]string{"text": "🔎 Explorer", "url": link})
+	}
+	row = append(row,
+		map[string]string{"text": "🧾 Recent txs", "callback_data": TelegramActionTransactions + ":" + key},
+		map[string]string{"text": "📄 Details", "callback_data": TelegramActionDetails + ":" + key})
+	return map[string]interface{}{"inline_keyboard": [][]map[string]string{row}}
+}
+
+// Updates long-polls for button presses after offset, waiting up to
+// timeout for one to arrive
+func (t *Telegram) Updates(offset int64, timeout time.Duration) ([]TelegramUpdate, error) {
+	result, err := t.callOnce("getUpdates", map[string]interface{}{
+		"offset":          offset,
+		"timeout":         int(timeout.Seconds()),
+		"allowed_updates": []string{"message", "callback_query"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var updates []TelegramUpdate
+	if err := json.Unmarshal(result, &updates); err != nil {
+		return nil, err
+	}
+	return updates, nil
+}
+
+// AnswerCallback acknowledges a button press, showing text as a
+// notification to the user who pressed it
+func (t *Telegram) AnswerCallback(id, text string) error {
+	_, err := t.call("answerCallbackQuery", map[string]interface{}{
+		"callback_query_id": id,
+		"text":              text,
+	})
+	return err
+}
+
+// Reply sends a MarkdownV2 message in reply to the alert whose button was
+// pressed, in the same chat and topic
+func (t *Telegram) Reply(query TelegramCallbackQuery, message string) error {
+	if query.Message == nil {
+		return fmt.Errorf("telegram callback %s has no message to reply to", query.ID)
+	}
+	payload := messagePayload(strconv.FormatInt(query.Message.Chat.ID, 10), message, query.Message.MessageThreadID)
+	payload["reply_to_message_id"] = query.Message.MessageID
+	_, err := t.call("sendMessage", payload)
+	return err
+}
+
+// SendTo sends a MarkdownV2 message to any chat, such as a subscriber's,
+// with a row of buttons if there are any
+func (t *Telegram) SendTo(chatID, message string, buttons ...TelegramButton) error {
+	payload := messagePayload(chatID, message, 0)
+	if len(buttons) > 0 {
+		row := make([]map[string]string, len(buttons))
+		for i, button := range buttons {
+			row[i] = map[string]string{"text": button.Text, "callback_data": button.Data}
+		}
+		payload["reply_markup"] = map[string]interface{}{"inline_keyboard": [][]map[string]string{row}}
+	}
+	_, err := t.call("sendMessage", payload)
+	return err
+}
+
+// Username returns the bot's username, which deep links start with
+func (t *Telegram) Username() (string, error) {
+	result, err := t.callOnce("getMe", map[string]interface{}{})
+	if err != nil {
+		return "", err
+	}
+	var me struct {
+		Username string `json:"username"`
+	}
+	if err := json.Unmarshal(result, &me); err != nil {
+		return "", err
+	}
+	return me.Username, nil
+}
+
+// NotifySummary implements Notifier
+func (t *Telegram) NotifySummary(balances []Balance) error {
+	message, err := t.summaryMessage(balances)
+	if err != nil {
+		return err
+	}
+	if t.Overflow == OverflowAttach && len(message) > telegramMaxLength {
+		return t.sendSummaryAttachment(balances)
+	}
+	pages := splitMessage(message, telegramMaxLength)
+	if t.Overflow == OverflowTruncate {
+		pages = []string{t.truncate(message)}
+	}
+	for _, page := range pages {
+		if err := t.send(page, t.SummaryThreadID, SeverityInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotifyAlert implements Notifier
+func (t *Telegram) NotifyAlert(alert Alert) error {
+	alerts := fitAlert(alert, t.Overflow, func(a Alert) bool {
+		return len(createTelegramAlertMessage(a, t.Templates.Translations)) <= telegramMaxLength
+	})
+	for _, a := range alerts {
+		if err := t.send(createTelegramAlertMessage(a, t.Templates.Translations), t.AlertThreadID, alert.Severity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// truncate shortens a summary to one message
+func (t *Telegram) truncate(message string) string {
+	return truncateMessage(message, telegramMaxLength, func(n int) string {
+		return "_" + EscapeMarkdownV2(moreAddresses(n, t.Templates.Translations)) + "_"
+	})
+}
+
+// summaryMessage renders the summary from the template or the built-in format
+func (t *Telegram) summaryMessage(balances []Balance) (string, error) {
+	if t.Templates.Summary != nil {
+		return t.Templates.renderSummary(balances)
+	}
+	return createTelegramSummaryMessage(balances, t.Templates.style(t.Units)), nil
+}
+
+// send sends a formatted message to a topic of the Telegram chat,
+// returning the API's error description if Telegram rejects it
+func (t *Telegram) send(message string, threadID int64, severity Severity) error {
+	_, err := t.call("sendMessage", t.payload(message, threadID, severity))
+	return err
+}
+
+// payload builds a sendMessage request to the chat, silent when the
+// severity calls for it
+func (t *Telegram) payload(message string, threadID int64, severity Severity) map[string]interface{} {
+	payload := messagePayload(t.ChatID, message, threadID)
+	if t.Delivery.silent(severity) {
+		payload["disable_notification"] = true
+	}
+	return payload
+}
+
+// messagePayload builds a sendMessage request, leaving out the topic when
+// posting to the General topic
+func messagePayload(chatID, message string, threadID int64) map[string]interface{} {
+	payload := map[string]interface{}{
+		"chat_id":    chatID,
+		"text":       message,
+		"parse_mode": "MarkdownV2",
+	}
+	if threadID != 0 {
+		payload["message_thread_id"] = threadID
+	}
+	return payload
+}
+
+// call invokes a Telegram Bot API method, retrying when rate limited, and
+// returns its result
+func (t *Telegram) call(method string, payload map[string]interface{}) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := withRateLimitRetry(func() error {
+		var err error
+		result, err = t.callOnce(method, payload)
+		return err
+	})
+	return result, err
+}
+
+// callOnce invokes a Telegram Bot API method and returns its result
+func (t *Telegram) callOnce(method string, payload map[string]interface{}) (json.RawMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return t.post(method, "application/json", body)
+}
+
+// post sends a request body to a Telegram Bot API method and returns its result
+func (t *Telegram) post(method, contentType string, body []byte) (json.RawMessage, error) {
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", t.BotToken, method)
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var tgResp TelegramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tgResp); err != nil {
+		return nil, fmt.Errorf("telegram %s: unexpected %s response: %w", method, resp.Status, err)
+	}
+	if tgResp.ErrorCode == http.StatusTooManyRequests {
+		return nil, &RateLimitError{
+			Platform:   "telegram",
+			RetryAfter: time.Duration(tgResp.Parameters.RetryAfter) * time.Second,
+		}
+	}
+	if !tgResp.OK {
+		return nil, fmt.Errorf("telegram %s: %s", method, tgResp.Description)
+	}
+	return tgResp.Result, nil
+}
+
+// sendSummaryAttachment sends the summary headline and the full table as a
+// CSV document
+func (t *Telegram) sendSummaryAttachment(balances []Balance) error {
+	data, err := summaryCSV(balances, t.Templates.Format)
+	if err != nil {
+		return err
+	}
+	headline := summaryHeadline(balances, t.Templates.style(t.Units))
+	if err := t.send(createTelegramAlertMessage(headline, t.Templates.Translations), t.SummaryThreadID, SeverityInfo); err != nil {
+		return err
+	}
+	return t.sendFile("sendDocument", "document", summaryFilename(headline.Time), data, "")
+}
+
+// SendChart implements ChartSender by sending the chart as a photo
+func (t *Telegram) SendChart(png []byte, caption string) error {
+	return t.sendFile("sendPhoto", "photo", "summary.png", png, caption)
+}
+
+// sendFile uploads a file to the summary topic of the chat with a Bot API
+// method such as sendPhoto, in the form field that method expects
+func (t *Telegram) sendFile(method, field, filename string, data []byte, caption string) error {
+	fields := map[string]string{"chat_id": t.ChatID}
+	if caption != "" {
+		fields["caption"] = caption
+	}
+	if t.Delivery.silent(SeverityInfo) {
+		fields["disable_notification"] = "true"
+	}
+	if t.SummaryThreadID != 0 {
+		fields["message_thread_id"] = strconv.FormatInt(t.SummaryThreadID, 10)
+	}
+	return t.postFile(method, fields, field, filename, data)
+}
+
+// ReplyDocument uploads a file in reply to the message whose button was
+// pressed, in the same chat and thread
+func (t *Telegram) ReplyDocument(query TelegramCallbackQuery, filename string, data []byte, caption string) error {
+	if query.Message == nil {
+		return fmt.Errorf("telegram callback %s has no message to reply to", query.ID)
+	}
+	fields := map[string]string{
+		"chat_id":             strconv.FormatInt(query.Message.Chat.ID, 10),
+		"reply_to_message_id": strconv.FormatInt(query.Message.MessageID, 10),
+	}
+	if caption != "" {
+		fields["caption"] = caption
+	}
+	if query.Message.MessageThreadID != 0 {
+		fields["message_thread_id"] = strconv.FormatInt(query.Message.MessageThreadID, 10)
+	}
+	return t.postFile("sendDocument", fields, "document", filename, data)
+}
+
+// postFile uploads data as a multipart form alongside fields
+func (t *Telegram) postFile(method string, fields map[string]string, field, filename string, data []byte) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writer.WriteField(name, fields[name]); err != nil {
+			return err
+		}
+	}
+	part, err := writer.CreateFormFile(field, filename)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return withRateLimitRetry(func() error {
+		_, err := t.post(method, writer.FormDataContentType(), body.Bytes())
+		return err
+	})
+}
+
+// UpdatePinnedSummary edits the pinned Telegram summary in place, sending
+// and pinning a new message if none exists yet or the old one can't be
+// edited. A long summary is truncated to fit the pinned message.
+func (t *Telegram) UpdatePinnedSummary(balances []Balance, pinned *PinnedSummary) error {
+	message, err := t.summaryMessage(balances)
+	if err != nil {
+		return err
+	}
+	message = t.truncate(message)
+	if pinned.TelegramMessageID != 0 {
+		_, err := t.call("editMessageText", map[string]interface{}{
+			"chat_id":    t.ChatID,
+			"message_id": pinned.TelegramMessageID,
+			"text":       message,
+			"parse_mode": "MarkdownV2",
+		})
+		if err == nil {
+			return nil
+		}
+		log.Printf("Error updating pinned Telegram summary, sending a new one: %v", err)
+	}
+
+	result, err := t.call("sendMessage", t.payload(message, t.SummaryThreadID, SeverityInfo))
+	if err != nil {
+		return err
+	}
+	var sent struct {
+		MessageID int64 `json:"message_id"`
+	}
+	if err := json.Unmarshal(result, &sent); err != nil {
+		return err
+	}
+	pinned.TelegramMessageID = sent.MessageID
+	if _, err := t.call("pinChatMessage", map[string]interface{}{
+		"chat_id":              t.ChatID,
+		"message_id":           sent.MessageID,
+		"disable_notification": true,
+	}); err != nil {
+		return fmt.Errorf("pinning summary: %w", err)
+	}
+	return nil
+}
+
+// createTelegramBalanceChangeMessage creates a Telegram markdown message for a balance change
+func createTelegramBalanceChangeMessage(change Change, s style) string {
+	// Escape special characters for Telegram MarkdownV2
+	changeLine := ""
+	if !change.Initial {
+		changeLine = fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Change")), EscapeMarkdownV2(formatChangeLine(change, s)))
+	}
+	if change.Fee > 0 {
+		changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Fee")), EscapeMarkdownV2(s.balance(change.Fee, change.Quote)))
+	}
+	if len(change.SentTo) > 0 {
+		changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Sent To")), EscapeMarkdownV2(FormatCounterparties(change.SentTo)))
+	}
+	for _, memo := range change.Memos {
+		changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Memo")), EscapeMarkdownV2(memo))
+	}
+	return fmt.Sprintf(
+		"%s *%s*\n\n"+
+			"*%s*: `%s`%s\n"+
+			"*%s*: %s\n"+
+			"*%s*: %s\n"+
+			"%s"+
+			"──────────\n"+
+			"_%s_",
+		EscapeMarkdownV2(s.branding.ChangeEmoji),
+		EscapeMarkdownV2(s.tr.T(s.branding.ChangeTitle)),
+		EscapeMarkdownV2(s.tr.T("Address")),
+		EscapeMarkdownV2Code(change.Address),
+		telegramLabelSuffix(change.Label),
+		EscapeMarkdownV2(s.tr.T("Old Balance")),
+		EscapeMarkdownV2(formatOldBalance(change, s)),
+		EscapeMarkdownV2(s.tr.T("New Balance")),
+		EscapeMarkdownV2(s.balance(change.NewBalance, change.Quote)),
+		changeLine,
+		EscapeMarkdownV2(s.tr.Sprintf("Updated at %s", change.Time.Format(time.RFC3339))),
+	)
+}
+
+// createTelegramSummaryMessage creates a Telegram markdown message for the balance summary
+func createTelegramSummaryMessage(balances []Balance, s style) string {
+	message := fmt.Sprintf("%s *%s*\n\n", EscapeMarkdownV2(s.branding.SummaryEmoji), EscapeMarkdownV2(s.tr.T(s.branding.SummaryTitle)))
+	for i, balance := range balances {
+		changeLine := ""
+		if balance.Locked > 0 {
+			changeLine = fmt.Sprintf("*%s*: %s\n*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Locked")), EscapeMarkdownV2(s.balance(balance.Locked, balance.Quote)), EscapeMarkdownV2(s.tr.T("Liquid")), EscapeMarkdownV2(s.balance(balance.Liquid(), balance.Quote)))
+		}
+		if balance.Signing != "" {
+			changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Signing")), EscapeMarkdownV2(balance.Signing))
+		}
+		if len(balance.Changes) > 0 {
+			changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Change")), EscapeMarkdownV2(formatPeriodChanges(balance.Changes, s)))
+		}
+		if balance.Flow != nil {
+			changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Flow")), EscapeMarkdownV2(formatFlow(*balance.Flow, s)))
+		}
+		if pnl, percent, ok := balance.UnrealizedPnL(s.format); ok && s.units.fiat() {
+			changeLine += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(s.tr.T("Unrealized P&L")), EscapeMarkdownV2(s.format.pnl(pnl, percent, balance.CostCurrency)))
+		}
+		// Escape special characters for Telegram MarkdownV2
+		message += fmt.Sprintf(
+			"*%s*: `%s`%s%s%s\n"+
+				"*%s*: %s\n"+
+				"%s"+
+				"*%s*: %s\n"+
+				"──────────\n",
+			EscapeMarkdownV2(s.tr.Sprintf("Address %d", i+1)),
+			EscapeMarkdownV2Code(balance.Address),
+			telegramLabelSuffix(balance.Label),
+			EscapeMarkdownV2(sparklineSuffix(balance)),
+			EscapeMarkdownV2(staleSuffix(balance, s.tr)),
+			EscapeMarkdownV2(s.tr.T("Balance")),
+			EscapeMarkdownV2(s.balance(balance.CurrentBalance, balance.Quote)),
+			changeLine,
+			EscapeMarkdownV2(s.tr.T("Last Updated")),
+			EscapeMarkdownV2(balance.LastUpdated.Format(time.RFC3339)),
+		)
+	}
+	for _, field := range formatTotalLines(balances, s) {
+		message += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(field.Name), EscapeMarkdownV2(field.Value))
+	}
+	message += fmt.Sprintf("_%s_", EscapeMarkdownV2(s.tr.Sprintf("Generated at %s", time.Now().Format(time.RFC3339))))
+	return message
+}
+
+// createTelegramAlertMessage creates a Telegram markdown message for a generic alert
+func createTelegramAlertMessage(alert Alert, tr Translations) string {
+	alert = tr.alert(alert)
+	message := fmt.Sprintf("%s *%s*\n\n", EscapeMarkdownV2(alert.Emoji), EscapeMarkdownV2(alert.Title))
+	for _, field := range alert.Fields {
+		message += fmt.Sprintf("*%s*: %s\n", EscapeMarkdownV2(field.Name), EscapeMarkdownV2(field.Value))
+	}
+	message += "──────────\n"
+	message += fmt.Sprintf("_%s_", EscapeMarkdownV2(tr.Sprintf("Updated at %s", alert.Time.Format(time.RFC3339))))
+	return message
+}
+
+// telegramLabelSuffix renders an address label with MarkdownV2 escaping
+func telegramLabelSuffix(label string) string {
+	if label == "" {
+		return ""
+	}
+	return EscapeMarkdownV2(labelSuffix(label))
+}
+
+// markdownV2Escaper escapes every character reserved by Telegram MarkdownV2
+var markdownV2Escaper = strings.NewReplacer(
+	"\\", "\\\\",
+	"_", "\\_", "*", "\\*", "[", "\\[", "]", "\\]", "(", "\\(", ")", "\\)",
+	"~", "\\~", "`", "\\`", ">", "\\>", "#", "\\#", "+", "\\+", "-", "\\-",
+	"=", "\\=", "|", "\\|", "{", "\\{", "}", "\\}", ".", "\\.", "!", "\\!",
+)
+
+// markdownV2CodeEscaper escapes the characters reserved inside code spans
+var markdownV2CodeEscaper = strings.NewReplacer("\\", "\\\\", "`", "\\`")
+
+// EscapeMarkdownV2 escapes text for use in a Telegram MarkdownV2 message
+func EscapeMarkdownV2(text string) string {
+	return markdownV2Escaper.Replace(text)
+}
+
+// EscapeMarkdownV2Code escapes text for use inside a MarkdownV2 code span
+func EscapeMarkdownV2Code(text string) string {
+	return markdownV2CodeEscaper.Replace(text)
+}
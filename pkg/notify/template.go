@@ -0,0 +1,157 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/price"
+)
+
+// DefaultExplorerURL is the address page pattern used for explorer links
+const DefaultExplorerURL = "https://nockblocks.com/address/%s"
+
+// ChangeData is the data available to change alert templates
+type ChangeData struct {
+	Change
+	Delta       int64
+	ExplorerURL string
+}
+
+// BalanceRow is a summary row as seen by summary templates
+type BalanceRow struct {
+	Balance
+	ExplorerURL string
+}
+
+// SummaryData is the data available to summary templates
+type SummaryData struct {
+	Balances    []BalanceRow
+	Total       int64
+	Groups      []GroupTotal
+	Changes     []PeriodChange // Portfolio change over 24h/7d/30d
+	Flow        *Flow          // Portfolio flow, nil unless FlowPeriod is set
+	Quote       price.Quote
+	GeneratedAt time.Time
+}
+
+// Templates holds optional text/template overrides for a notifier's
+// messages; a nil template keeps the built-in format
+type Templates struct {
+	Change       *template.Template
+	Summary      *template.Template
+	ExplorerURL  string       // fmt pattern with a single %s for the address
+	Translations Translations // Language of the built-in format and the t template function
+	Format       Formatter    // Denomination and number format of amounts
+	Branding     Branding     // Emojis, titles and colors of the built-in format
+}
+
+// templateFuncs are the helper functions available inside templates
+var templateFuncs = template.FuncMap{
+	"formatBalance": FormatBalance,
+	"formatDelta":   FormatDelta,
+	"direction":     DirectionEmoji,
+	"fiat":          FormatFiat,
+	"fiatDelta":     FormatFiatDelta,
+	"nock":          ConvertToNock,
+	"number":        FormatNumber,
+	"sparkline":     Sparkline,
+	"escape":        EscapeMarkdownV2,
+	"escapeCode":    EscapeMarkdownV2Code,
+	"time":          func(t time.Time) string { return t.Format(time.RFC3339) },
+	"t":             Translations(nil).T, // This and the formatting functions are replaced by the notifier's when loading
+}
+
+// LoadTemplates loads <prefix>_change.tmpl and <prefix>_summary.tmpl from
+// dir; missing files leave the built-in format in place. Templates can
+// translate text with {{t "..."}}, and format amounts with format.
+func LoadTemplates(dir, prefix, explorerURL string, translations Translations, format Formatter, branding Branding) (Templates, error) {
+	templates := Templates{ExplorerURL: explorerURL, Translations: translations, Format: format, Branding: branding}
+	if dir == "" {
+		return templates, nil
+	}
+	funcs := template.FuncMap{
+		"t":             translations.T,
+		"formatBalance": format.Balance,
+		"formatDelta":   format.Delta,
+		"fiat":          format.Fiat,
+		"fiatDelta":     format.FiatDelta,
+		"nock":          format.ToUnits,
+		"number":        format.Number,
+	}
+	var err error
+	if templates.Change, err = loadTemplate(filepath.Join(dir, prefix+"_change.tmpl"), funcs); err != nil {
+		return templates, err
+	}
+	if templates.Summary, err = loadTemplate(filepath.Join(dir, prefix+"_summary.tmpl"), funcs); err != nil {
+		return templates, err
+	}
+	return templates, nil
+}
+
+// loadTemplate parses a template file, returning nil if it doesn't exist
+func loadTemplate(path string, funcs template.FuncMap) (*template.Template, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(filepath.Base(path)).Funcs(templateFuncs).Funcs(funcs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// style returns how the built-in format writes messages showing units
+func (t Templates) style(units Units) style {
+	return style{units: units, format: t.Format, branding: t.Branding.orDefault(), tr: t.Translations}
+}
+
+// explorerLink returns the explorer page for an address, or "" if no
+// pattern is configured
+func (t Templates) explorerLink(address string) string {
+	if t.ExplorerURL == "" {
+		return ""
+	}
+	return fmt.Sprintf(t.ExplorerURL, address)
+}
+
+// renderChange executes the change template
+func (t Templates) renderChange(change Change) (string, error) {
+	return execute(t.Change, ChangeData{
+		Change:      change,
+		Delta:       change.Delta(),
+		ExplorerURL: t.explorerLink(change.Address),
+	})
+}
+
+// renderSummary executes the summary template
+func (t Templates) renderSummary(balances []Balance) (string, error) {
+	data := SummaryData{GeneratedAt: time.Now(), Quote: summaryQuote(balances)}
+	data.Total, data.Groups = SummaryTotals(balances)
+	data.Changes = PortfolioChanges(balances)
+	data.Flow = PortfolioFlow(balances)
+	for _, balance := range balances {
+		data.Balances = append(data.Balances, BalanceRow{
+			Balance:     balance,
+			ExplorerURL: t.explorerLink(balance.Address),
+		})
+	}
+	return execute(t.Summary, data)
+}
+
+// execute renders a template to a string
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CloudEvents types sent by Webhook. The version suffix changes only when a
+// field is removed or changes meaning; new fields may be added to the same
+// version, so consumers should ignore fields they don't know.
+const (
+	EventTypeChange  = "com.nockchain.balance-alerter.change.v1"
+	EventTypeSummary = "com.nockchain.balance-alerter.summary.v1"
+	EventTypeAlert   = "com.nockchain.balance-alerter.alert.v1"
+)
+
+// DefaultEventSource is the CloudEvents source of events when Webhook.Source
+// isn't set
+const DefaultEventSource = "/nockchain-balance-alerter"
+
+// Headers of signed webhook requests
+const (
+	HeaderWebhookID        = "Webhook-Id"        // Event ID, unique per event and kept across retries
+	HeaderWebhookTimestamp = "Webhook-Timestamp" // Unix seconds the request was sent
+	HeaderWebhookSignature = "Webhook-Signature" // "v1=" and the hex HMAC-SHA256 of "<id>.<timestamp>.<body>"
+)
+
+// DefaultWebhookTolerance is how far VerifyWebhook lets a signed request's
+// timestamp be from now
+const DefaultWebhookTolerance = 5 * time.Minute
+
+// Errors returned by VerifyWebhook
+var (
+	ErrWebhookUnsigned  = errors.New("webhook: request is not signed")
+	ErrWebhookSignature = errors.New("webhook: signature does not match")
+	ErrWebhookExpired   = errors.New("webhook: timestamp outside tolerance")
+)
+
+// CloudEvent is a CloudEvents 1.0 event in structured JSON mode
+type CloudEvent struct {
+	SpecVersion     string      `json:"specversion"`
+	ID              string      `json:"id"`
+	Source          string      `json:"source"`
+	Type            string      `json:"type"`
+	Subject         string      `json:"subject,omitempty"` // Address of change events
+	Time            time.Time   `json:"time"`
+	DataContentType string      `json:"datacontenttype"`
+	Data            interface{} `json:"data"`
+}
+
+// ChangeEvent is the data of a change event; amounts are in nick
+type ChangeEvent struct {
+	Address    string         `json:"address"`
+	Label      string         `json:"label,omitempty"`
+	OldBalance int64          `json:"oldBalance"`
+	NewBalance int64          `json:"newBalance"`
+	Delta      int64          `json:"delta"`
+	Initial    bool           `json:"initial"`
+	Fee        int64          `json:"fee,omitempty"`
+	SentTo     []Counterparty `json:"sentTo,omitempty"`
+	Memos      []string       `json:"memos,omitempty"`
+	Severity   string         `json:"severity"`
+}
+
+// SummaryEvent is the data of a summary event; amounts are in nick
+type SummaryEvent struct {
+	Balances []SummaryEventBalance `json:"balances"`
+	Total    int64                 `json:"total"`
+}
+
+// SummaryEventBalance is one address of a summary event
+type SummaryEventBalance struct {
+	Address     string    `json:"address"`
+	Label       string    `json:"label,omitempty"`
+	Group       string    `json:"group,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Balance     int64     `json:"balance"`
+	Locked      int64     `json:"locked,omitempty"`
+	Signing     string    `json:"signing,omitempty"`
+	LastUpdated time.Time `json:"lastUpdated"`
+	Stale       bool      `json:"stale,omitempty"`
+}
+
+// AlertEvent is the data of any other alert
+type AlertEvent struct {
+	Rule     string  `json:"rule,omitempty"`
+	Title    string  `json:"title"`
+	Severity string  `json:"severity"`
+	Fields   []Field `json:"fields,omitempty"`
+}
+
+// Webhook posts alerts and summaries to a URL as CloudEvents, so event
+// routers such as Knative or EventBridge can route them natively
+type Webhook struct {
+	URL        string
+	Source     string            // CloudEvents source; DefaultEventSource if empty
+	Headers    map[string]string // Extra request headers, e.g. Authorization
+	Secret     string            // Signs requests with HMAC-SHA256 if set
+	HTTPClient *http.Client      // http.DefaultClient if nil
+}
+
+// Name implements Notifier
+func (w *Webhook) Name() string { return "Webhook" }
+
+// NotifyChange implements Notifier
+func (w *Webhook) NotifyChange(change Change) error {
+	return w.send(newChangeEvent(change))
+}
+
+// NotifySummary implements Notifier
+func (w *Webhook) NotifySummary(balances []Balance) error {
+	return w.send(newSummaryEvent(balances))
+}
+
+// NotifyAlert implements Notifier
+func (w *Webhook) NotifyAlert(alert Alert) error {
+	return w.send(newAlertEvent(alert))
+}
+
+// send posts one event, retrying if the receiver asks to back off
+func (w *Webhook) send(event CloudEvent) error {
+	body, err := event.encode(w.Source)
+	if err != nil {
+		return err
+	}
+	return withRateLimitRetry(func() error { return w.post(event.ID, body) })
+}
+
+// newChangeEvent returns the event of a balance change
+func newChangeEvent(change Change) CloudEvent {
+	return CloudEvent{ID: change.Key, Type: EventTypeChange, Subject: change.Address, Time: change.Time, Data: ChangeEvent{
+		Address:    change.Address,
+		Label:      change.Label,
+		OldBalance: change.OldBalance,
+		NewBalance: change.NewBalance,
+		Delta:      change.Delta(),
+		Initial:    change.Initial,
+		Fee:        change.Fee,
+		SentTo:     change.SentTo,
+		Memos:      change.Memos,
+		Severity:   change.Severity.String(),
+	}}
+}
+
+// newSummaryEvent returns the event of a balance summary
+func newSummaryEvent(balances []Balance) CloudEvent {
+	total, _ := SummaryTotals(balances)
+	data := SummaryEvent{Balances: make([]SummaryEventBalance, len(balances)), Total: total}
+	for i, b := range balances {
+		data.Balances[i] = SummaryEventBalance{
+			Address:     b.Address,
+			Label:       b.Label,
+			Group:       b.Group,
+			Tags:        b.Tags,
+			Balance:     b.CurrentBalance,
+			Locked:      b.Locked,
+			Signing:     b.Signing,
+			LastUpdated: b.LastUpdated,
+			Stale:       b.Stale,
+		}
+	}
+	return CloudEvent{Type: EventTypeSummary, Time: time.Now(), Data: data}
+}
+
+// newAlertEvent returns the event of any other alert
+func newAlertEvent(alert Alert) CloudEvent {
+	return CloudEvent{ID: alert.Key, Type: EventTypeAlert, Time: alert.Time, Data: AlertEvent{
+		Rule:     alert.Rule,
+		Title:    alert.Title,
+		Severity: alert.Severity.String(),
+		Fields:   alert.Fields,
+	}}
+}
+
+// encode fills in the source and envelope fields of an event, and a random
+// ID unless it has an idempotency key, and returns its JSON
+func (e *CloudEvent) encode(source string) ([]byte, error) {
+	if e.ID == "" {
+		id := make([]byte, 16)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		e.ID = hex.EncodeToString(id)
+	}
+	if source == "" {
+		source = DefaultEventSource
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.SpecVersion = "1.0"
+	e.Source = source
+	e.Time = e.Time.UTC()
+	e.DataContentType = "application/json"
+	return json.Marshal(e)
+}
+
+// post delivers an event body once, signing it with a fresh timestamp
+func (w *Webhook) post(id string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json; charset=utf-8")
+	for key, value := range w.Headers {
+		req.Header.Set(key, value)
+	}
+	if w.Secret != "" {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(HeaderWebhookID, id)
+		req.Header.Set(HeaderWebhookTimestamp, timestamp)
+		req.Header.Set(HeaderWebhookSignature, "v1="+webhookSignature(w.Secret, id, timestamp, body))
+	}
+	client := w.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		seconds, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &RateLimitError{Platform: "webhook", RetryAfter: time.Duration(seconds) * time.Second}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook: %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return nil
+}
+
+// webhookSignature returns the hex HMAC-SHA256 of a signed request
+func webhookSignature(secret, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhook checks that a request body was signed by a Webhook with
+// secret within tolerance of now, and returns the event ID. Receivers
+// should also reject IDs they have already accepted, which stops a captured
+// request being replayed within the tolerance.
+func VerifyWebhook(secret string, header http.Header, body []byte, tolerance time.Duration) (string, error) {
+	id, timestamp := header.Get(HeaderWebhookID), header.Get(HeaderWebhookTimestamp)
+	signature, ok := strings.CutPrefix(header.Get(HeaderWebhookSignature), "v1=")
+	if id == "" || timestamp == "" || !ok {
+		return "", ErrWebhookUnsigned
+	}
+	expected := webhookSignature(secret, id, timestamp, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return "", ErrWebhookSignature
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", ErrWebhookUnsigned
+	}
+	if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return "", ErrWebhookExpired
+	}
+	return id, nil
+}
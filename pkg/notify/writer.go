@@ -0,0 +1,52 @@
+package notify
+
+import (
+	"fmt"
+	"io"
+)
+
+// Writer prints messages in Discord's markdown format to an io.Writer, to
+// preview alerts without sending them anywhere
+type Writer struct {
+	W         io.Writer
+	Templates Templates
+	Units     Units // Amounts to show, see the Units constants
+}
+
+// Name implements Notifier
+func (w *Writer) Name() string { return "Preview" }
+
+// NotifyChange implements Notifier
+func (w *Writer) NotifyChange(change Change) error {
+	if w.Templates.Change != nil {
+		message, err := w.Templates.renderChange(change)
+		if err != nil {
+			return err
+		}
+		return w.print(message)
+	}
+	return w.print(createDiscordBalanceChangeMessage(change, w.Templates.style(w.Units)))
+}
+
+// NotifySummary implements Notifier
+func (w *Writer) NotifySummary(balances []Balance) error {
+	if w.Templates.Summary != nil {
+		message, err := w.Templates.renderSummary(balances)
+		if err != nil {
+			return err
+		}
+		return w.print(message)
+	}
+	return w.print(CreateDiscordSummaryMessage(balances, w.Units, w.Templates))
+}
+
+// NotifyAlert implements Notifier
+func (w *Writer) NotifyAlert(alert Alert) error {
+	return w.print(createDiscordAlertMessage(alert, w.Templates.Translations))
+}
+
+// print writes a message followed by a blank line
+func (w *Writer) print(message string) error {
+	_, err := fmt.Fprintf(w.W, "%s\n\n", message)
+	return err
+}
@@ -0,0 +1,174 @@
+// Package price fetches the fiat price of $NOCK from public price APIs.
+package price
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quote maps lowercase currency codes (usd, eur, ...) to the price of one $NOCK
+type Quote map[string]float64
+
+// Provider fetches the current $NOCK price
+type Provider interface {
+	Quote() (Quote, error)
+}
+
+// CoinGecko fetches prices from the CoinGecko simple price API
+type CoinGecko struct {
+	CoinID     string   // CoinGecko coin ID, e.g. "nockchain"
+	Currencies []string // Lowercase currency codes, e.g. usd, eur
+	APIKey     string   // Optional demo API key
+	HTTPClient *http.Client
+}
+
+// Quote implements Provider
+func (c *CoinGecko) Quote() (Quote, error) {
+	endpoint := fmt.Sprintf(
+		"https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=%s",
+		url.QueryEscape(c.CoinID),
+		url.QueryEscape(strings.Join(c.Currencies, ",")),
+	)
+	headers := map[string]string{}
+	if c.APIKey != "" {
+		headers["x-cg-demo-api-key"] = c.APIKey
+	}
+
+	var response map[string]Quote
+	if err := getJSON(c.HTTPClient, endpoint, headers, &response); err != nil {
+		return nil, err
+	}
+	quote, ok := response[c.CoinID]
+	if !ok || len(quote) == 0 {
+		return nil, fmt.Errorf("coingecko: no price for %q", c.CoinID)
+	}
+	return quote, nil
+}
+
+// CoinMarketCap fetches prices from the CoinMarketCap quotes API
+type CoinMarketCap struct {
+	Symbol     string // Ticker symbol, e.g. "NOCK"
+	Currencies []string
+	APIKey     string
+	HTTPClient *http.Client
+}
+
+// Quote implements Provider
+func (c *CoinMarketCap) Quote() (Quote, error) {
+	quote := Quote{}
+	// The basic plan allows a single convert currency per request
+	for _, currency := range c.Currencies {
+		endpoint := fmt.Sprintf(
+			"https://pro-api.coinmarketcap.com/v2/cryptocurrency/quotes/latest?symbol=%s&convert=%s",
+			url.QueryEscape(c.Symbol),
+			url.QueryEscape(strings.ToUpper(currency)),
+		)
+		var response struct {
+			Data map[string][]struct {
+				Quote map[string]struct {
+					Price float64 `json:"price"`
+				} `json:"quote"`
+			} `json:"data"`
+		}
+		if err := getJSON(c.HTTPClient, endpoint, map[string]string{"X-CMC_PRO_API_KEY": c.APIKey}, &response); err != nil {
+			return nil, err
+		}
+		entries := response.Data[strings.ToUpper(c.Symbol)]
+		if len(entries) == 0 {
+			return nil, fmt.Errorf("coinmarketcap: no price for %q", c.Symbol)
+		}
+		quote[strings.ToLower(currency)] = entries[0].Quote[strings.ToUpper(currency)].Price
+	}
+	return quote, nil
+}
+
+// URL fetches prices from a custom endpoint returning a flat JSON object
+// such as {"usd": 0.12, "eur": 0.11}
+type URL struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+// Quote implements Provider
+func (u *URL) Quote() (Quote, error) {
+	var quote Quote
+	if err := getJSON(u.HTTPClient, u.URL, nil, &quote); err != nil {
+		return nil, err
+	}
+	if len(quote) == 0 {
+		return nil, fmt.Errorf("price URL returned no prices")
+	}
+	lower := Quote{}
+	for currency, value := range quote {
+		lower[strings.ToLower(currency)] = value
+	}
+	return lower, nil
+}
+
+// Cache wraps a Provider, reusing a quote for TTL and falling back to the
+// last good quote when the provider fails
+type Cache struct {
+	Provider Provider
+	TTL      time.Duration
+
+	mu      sync.Mutex
+	last    Quote
+	fetched time.Time
+}
+
+// NewCache returns a caching wrapper around provider
+func NewCache(provider Provider, ttl time.Duration) *Cache {
+	return &Cache{Provider: provider, TTL: ttl}
+}
+
+// Quote implements Provider
+func (c *Cache) Quote() (Quote, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last != nil && time.Since(c.fetched) < c.TTL {
+		return c.last, nil
+	}
+
+	quote, err := c.Provider.Quote()
+	if err != nil {
+		if c.last != nil {
+			log.Printf("Error fetching price, using quote from %s: %v", c.fetched.Format(time.RFC3339), err)
+			return c.last, nil
+		}
+		return nil, err
+	}
+	c.last = quote
+	c.fetched = time.Now()
+	return quote, nil
+}
+
+// getJSON performs a GET request and decodes the JSON response into v
+func getJSON(client *http.Client, endpoint string, headers map[string]string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("price API returned %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,158 @@
+package rpc
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBreakerThreshold is how many requests in a row must fail to
+	// open an endpoint's circuit
+	DefaultBreakerThreshold = 5
+	// DefaultBreakerProbe is how often an open circuit lets a request
+	// through to test whether the endpoint has recovered
+	DefaultBreakerProbe = 30 * time.Second
+)
+
+// CircuitOpenError is returned for requests a Breaker refused without
+// sending, because the endpoint's circuit is open
+type CircuitOpenError struct {
+	Endpoint  string
+	Since     time.Time // When the circuit opened
+	LastError string    // Why the last request to the endpoint failed
+}
+
+// Error implements error
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s failing since %s, not queried until it recovers: %s", e.Endpoint, e.Since.Format(time.RFC3339), e.LastError)
+}
+
+// CircuitOpen tells the monitor this request was never sent, so it doesn't
+// log each check failing with it
+func (e *CircuitOpenError) CircuitOpen() bool {
+	return true
+}
+
+// Breaker is an http.RoundTripper that stops sending requests to an
+// endpoint after Threshold of them in a row have failed, with a network
+// error or timeout, HTTP 429 or a 5xx status, so an outage doesn't mean
+// hammering the endpoint and logging an error for every address. While an
+// endpoint's circuit is open, requests fail right away with a
+// *CircuitOpenError, except one probe every Probe; the first probe that
+// succeeds closes the circuit again. Endpoints are told apart by scheme
+// and host.
+type Breaker struct {
+	Threshold int           // DefaultBreakerThreshold if 0
+	Probe     time.Duration // DefaultBreakerProbe if 0
+	Next      http.RoundTripper
+
+	// OnChange, if set, is called when an endpoint's circuit opens, with
+	// the error that opened it, or closes, with nil
+	OnChange func(endpoint string, open bool, err error)
+
+	mu        sync.Mutex
+	endpoints map[string]*circuit
+}
+
+// circuit is the state of one endpoint of a Breaker
+type circuit struct {
+	failures  int // Requests failed in a row
+	answered  bool
+	open      bool
+	since     time.Time
+	nextProbe time.Time
+	probing   bool
+	lastError string
+}
+
+// RoundTrip implements http.RoundTripper
+func (b *Breaker) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := req.URL.Scheme + "://" + req.URL.Host
+	now := time.Now()
+	b.mu.Lock()
+	if b.endpoints == nil {
+		b.endpoints = map[string]*circuit{}
+	}
+	c, ok := b.endpoints[endpoint]
+	if !ok {
+		c = &circuit{}
+		b.endpoints[endpoint] = c
+	}
+	if c.open {
+		if c.probing || now.Before(c.nextProbe) {
+			err := &CircuitOpenError{Endpoint: endpoint, Since: c.since, LastError: c.lastError}
+			b.mu.Unlock()
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, err
+		}
+		c.probing = true
+	}
+	b.mu.Unlock()
+
+	next := b.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	failure := err
+	if err == nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500) {
+		failure = fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	b.record(c, endpoint, failure)
+	return resp, err
+}
+
+// record counts the outcome of a request, opening or closing the circuit
+func (b *Breaker) record(c *circuit, endpoint string, failure error) {
+	threshold, probe := b.Threshold, b.Probe
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if probe <= 0 {
+		probe = DefaultBreakerProbe
+	}
+	now := time.Now()
+
+	b.mu.Lock()
+	c.probing = false
+	wasOpen := c.open
+	if failure == nil {
+		c.failures, c.answered, c.open = 0, true, false
+	} else {
+		c.failures++
+		c.lastError = failure.Error()
+		if !c.open && c.failures >= threshold {
+			c.open, c.since = true, now
+		}
+		c.nextProbe = now.Add(probe)
+	}
+	open := c.open
+	b.mu.Unlock()
+
+	if open != wasOpen && b.OnChange != nil {
+		b.OnChange(endpoint, open, failure)
+	}
+}
+
+// Circuits implements monitor.Circuits: the last error of each endpoint
+// whose circuit is open, and "" for each whose last request succeeded.
+// Endpoints failing but not yet open, or not answered since startup, are
+// left out.
+func (b *Breaker) Circuits() map[string]string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	circuits := map[string]string{}
+	for endpoint, c := range b.endpoints {
+		switch {
+		case c.open:
+			circuits[endpoint] = c.lastError
+		case c.answered && c.failures == 0:
+			circuits[endpoint] = ""
+		}
+	}
+	return circuits
+}
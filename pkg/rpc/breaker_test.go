@@ -0,0 +1,155 @@
+package rpc
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// scriptedTransport answers each request with the next of its outcomes: an
+// HTTP status, or 0 for a network error
+type scriptedTransport struct {
+	outcomes []int
+	sent     int
+}
+
+// RoundTrip implements http.RoundTripper
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := s.outcomes[s.sent]
+	s.sent++
+	if status == 0 {
+		return nil, errors.New("connection refused")
+	}
+	return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+}
+
+// breakerRequest sends one request through b, reporting whether the
+// breaker refused it without sending
+func breakerRequest(t *testing.T, b *Breaker) (refused bool) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, "http://rpc.example/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := b.RoundTrip(req)
+	var open *CircuitOpenError
+	if errors.As(err, &open) {
+		return true
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+	return false
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	const probe = 20 * time.Millisecond
+	tests := []struct {
+		name     string
+		outcomes []int  // What the endpoint answers, in order
+		wait     bool   // Wait out the probe interval before the last request
+		refused  []bool // Whether each request is refused, one more than outcomes when the last is
+		circuits map[string]string
+		changes  []bool // OnChange open arguments
+	}{
+		{
+			name:     "successes keep it closed",
+			outcomes: []int{200, 200, 200},
+			refused:  []bool{false, false, false},
+			circuits: map[string]string{"http://rpc.example": ""},
+		},
+		{
+			name:     "failures below the threshold keep it closed",
+			outcomes: []int{500, 0, 200, 429, 502},
+			refused:  []bool{false, false, false, false, false},
+			circuits: map[string]string{},
+		},
+		{
+			name:     "client errors don't count",
+			outcomes: []int{404, 404, 404, 400},
+			refused:  []bool{false, false, false, false},
+			circuits: map[string]string{"http://rpc.example": ""},
+		},
+		{
+			name:     "threshold failures in a row open it",
+			outcomes: []int{500, 0, 429},
+			refused:  []bool{false, false, false, true},
+			circuits: map[string]string{"http://rpc.example": "HTTP 429"},
+			changes:  []bool{true},
+		},
+		{
+			name:     "a successful probe closes it",
+			outcomes: []int{500, 500, 500, 200},
+			wait:     true,
+			refused:  []bool{false, false, false, false},
+			circuits: map[string]string{"http://rpc.example": ""},
+			changes:  []bool{true, false},
+		},
+		{
+			name:     "a failed probe keeps it open",
+			outcomes: []int{500, 500, 500, 503},
+			wait:     true,
+			refused:  []bool{false, false, false, false, true},
+			circuits: map[string]string{"http://rpc.example": "HTTP 503"},
+			changes:  []bool{true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var changes []bool
+			b := &Breaker{
+				Threshold: 3,
+				Probe:     probe,
+				Next:      &scriptedTransport{outcomes: tt.outcomes},
+				OnChange:  func(endpoint string, open bool, err error) { changes = append(changes, open) },
+			}
+			for i, want := range tt.refused {
+				if tt.wait && i == len(tt.outcomes)-1 {
+					time.Sleep(probe + 10*time.Millisecond)
+				}
+				if got := breakerRequest(t, b); got != want {
+					t.Fatalf("request %d refused = %v, want %v", i+1, got, want)
+				}
+			}
+			if got := b.Circuits(); !equalCircuits(got, tt.circuits) {
+				t.Errorf("Circuits() = %v, want %v", got, tt.circuits)
+			}
+			if len(changes) != len(tt.changes) {
+				t.Fatalf("OnChange calls = %v, want %v", changes, tt.changes)
+			}
+			for i := range changes {
+				if changes[i] != tt.changes[i] {
+					t.Errorf("OnChange calls = %v, want %v", changes, tt.changes)
+				}
+			}
+		})
+	}
+}
+
+func TestBreakerSeparatesEndpoints(t *testing.T) {
+	b := &Breaker{Threshold: 1, Next: &scriptedTransport{outcomes: []int{0, 200}}}
+	for _, url := range []string{"http://down.example/", "http://up.example/"} {
+		req, _ := http.NewRequest(http.MethodPost, url, nil)
+		if resp, err := b.RoundTrip(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	want := map[string]string{"http://down.example": "connection refused", "http://up.example": ""}
+	if got := b.Circuits(); !equalCircuits(got, want) {
+		t.Errorf("Circuits() = %v, want %v", got, want)
+	}
+}
+
+// equalCircuits compares two Circuits results
+func equalCircuits(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for endpoint, lastError := range a {
+		if other, ok := b[endpoint]; !ok || other != lastError {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// replayRoundGap separates replay rounds: recordings closer than this to the
+// start of a round belong to the same check
+const replayRoundGap = 30 * time.Second
+
+// Recording is one recorded JSON-RPC or GraphQL response, stored one per
+// line in a fixture file
+type Recording struct {
+	Time     time.Time       `json:"time"`
+	Method   string          `json:"method"` // JSON-RPC method, or "graphql"
+	Address  string          `json:"address,omitempty"`
+	Response json.RawMessage `json:"response"`
+}
+
+// requestKey extracts the method and address of a JSON-RPC or GraphQL
+// request body; requests matching addresses are keyed by their pattern
+func requestKey(body []byte) (method, address string) {
+	var request struct {
+		Method    string                   `json:"method"`
+		Params    []map[string]interface{} `json:"params"`
+		Variables map[string]interface{}   `json:"variables"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		return "", ""
+	}
+	method, params := request.Method, map[string]interface{}{}
+	if len(request.Params) > 0 {
+		params = request.Params[0]
+	}
+	if method == "" {
+		method, params = "graphql", request.Variables
+	}
+	address, _ = params["address"].(string)
+	if address == "" {
+		address, _ = params["pattern"].(string)
+	}
+	return method, address
+}
+
+// Recorder is an http.RoundTripper that appends every successful response
+// to a fixture file for later replay
+type Recorder struct {
+	Path string
+	Next http.RoundTripper // http.DefaultTransport if nil
+
+	mu sync.Mutex
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	method, address := requestKey(body)
+	if err := r.append(Recording{Time: time.Now().UTC(), Method: method, Address: address, Response: respBody}); err != nil {
+		return nil, fmt.Errorf("recording response: %w", err)
+	}
+	return resp, nil
+}
+
+// append writes a recording to the end of the fixture file
+func (r *Recorder) append(recording Recording) error {
+	line, err := json.Marshal(recording)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, err := os.OpenFile(r.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
+
+// Replayer is an http.RoundTripper that answers requests from recorded
+// responses instead of the network. Each request gets the latest recording
+// for its method and address made at or before the replay clock.
+type Replayer struct {
+	recordings map[string][]Recording // By method and address, oldest first
+	rounds     []time.Time
+
+	mu  sync.Mutex
+	now time.Time
+}
+
+// LoadReplay reads a fixture file written by Recorder
+func LoadReplay(path string) (*Replayer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var all []Recording
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var recording Recording
+		if err := json.Unmarshal(scanner.Bytes(), &recording); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		all = append(all, recording)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("%s has no recordings", path)
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+
+	r := &Replayer{recordings: map[string][]Recording{}}
+	var start time.Time
+	for _, recording := range all {
+		key := recording.Method + " " + recording.Address
+		r.recordings[key] = append(r.recordings[key], recording)
+		if len(r.rounds) == 0 || recording.Time.Sub(start) >= replayRoundGap {
+			start = recording.Time
+			r.rounds = append(r.rounds, recording.Time)
+		} else {
+			r.rounds[len(r.rounds)-1] = recording.Time
+		}
+	}
+	return r, nil
+}
+
+// Rounds returns the time of each recorded check, oldest first; recordings
+// made within seconds of each other form one round
+func (r *Replayer) Rounds() []time.Time {
+	return append([]time.Time{}, r.rounds...)
+}
+
+// SetTime moves the replay clock
+func (r *Replayer) SetTime(t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = t
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	method, address := requestKey(body)
+
+	r.mu.Lock()
+	now := r.now
+	r.mu.Unlock()
+	var response json.RawMessage
+	for _, recording := range r.recordings[method+" "+address] {
+		if recording.Time.After(now) {
+			break
+		}
+		response = recording.Response
+	}
+	if response == nil {
+		return nil, fmt.Errorf("replay: no %s recording for %q by %s", method, address, now.Format(time.RFC3339))
+	}
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(response)),
+		Request:    req,
+	}, nil
+}
@@ -0,0 +1,164 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GraphQL queries balances from a GraphQL indexer. Query receives the
+// address as the $address variable, and BalancePath is the dot-separated
+// path to the balance in nick within the response data, e.g.
+// "account.balance" for {"data": {"account": {"balance": "123"}}}.
+// RelatedPath optionally points at the addresses in the account's
+// transactions, e.g. "account.transactions.outputs.address"; lists along
+// the path are walked element by element. MatchQuery optionally lists the
+// addresses matching the $pattern variable, found at MatchPath.
+type GraphQL struct {
+	URL         string
+	Query       string
+	BalancePath string
+	RelatedPath string
+	MatchQuery  string
+	MatchPath   string
+	Name        string // Chain name; DefaultChain if empty
+	UserAgent   string // DefaultUserAgent if empty
+	Headers     map[string]string
+	HTTPClient  *http.Client
+}
+
+// Chain implements monitor.ChainAdapter
+func (g *GraphQL) Chain() string {
+	if g.Name == "" {
+		return DefaultChain
+	}
+	return g.Name
+}
+
+// GetBalance queries the balance in nick for a given address
+func (g *GraphQL) GetBalance(address string) (int64, error) {
+	data, err := g.query(g.Query, map[string]string{"address": address})
+	if err != nil {
+		return 0, err
+	}
+	values := lookup(data, strings.Split(g.BalancePath, "."))
+	if len(values) != 1 {
+		return 0, fmt.Errorf("graphql: no %q in response", g.BalancePath)
+	}
+	switch v := values[0].(type) {
+	case json.Number:
+		return strconv.ParseInt(v.String(), 10, 64)
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, fmt.Errorf("graphql: %q is not a number", g.BalancePath)
+}
+
+// RelatedAddresses implements monitor.AddressDiscoverer, returning the
+// addresses found at RelatedPath other than address itself
+func (g *GraphQL) RelatedAddresses(address string) ([]string, error) {
+	if g.RelatedPath == "" {
+		return nil, nil
+	}
+	data, err := g.query(g.Query, map[string]string{"address": address})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{address: true}
+	var related []string
+	for _, value := range lookup(data, strings.Split(g.RelatedPath, ".")) {
+		if s, ok := value.(string); ok && !seen[s] {
+			seen[s] = true
+			related = append(related, s)
+		}
+	}
+	return related, nil
+}
+
+// MatchAddresses implements monitor.AddressMatcher, returning the
+// addresses found at MatchPath
+func (g *GraphQL) MatchAddresses(pattern string) ([]string, error) {
+	if g.MatchQuery == "" {
+		return nil, fmt.Errorf("graphql: no query to match addresses with")
+	}
+	data, err := g.query(g.MatchQuery, map[string]string{"pattern": pattern})
+	if err != nil {
+		return nil, err
+	}
+	var matched []string
+	for _, value := range lookup(data, strings.Split(g.MatchPath, ".")) {
+		if s, ok := value.(string); ok {
+			matched = append(matched, s)
+		}
+	}
+	return matched, nil
+}
+
+// query runs a query with the given variables and returns the response data
+func (g *GraphQL) query(query string, variables map[string]string) (interface{}, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": variables,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, g.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, g.UserAgent, g.Headers)
+
+	client := g.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var gqlResp struct {
+		Data   interface{} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&gqlResp); err != nil {
+		return nil, fmt.Errorf("graphql: unexpected %s response: %w", resp.Status, err)
+	}
+	if len(gqlResp.Errors) > 0 {
+		return nil, fmt.Errorf("graphql: %s", gqlResp.Errors[0].Message)
+	}
+	return gqlResp.Data, nil
+}
+
+// lookup follows a path of keys through decoded JSON, descending into every
+// element of any list on the way, and returns the values found
+func lookup(value interface{}, path []string) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		var values []interface{}
+		for _, element := range list {
+			values = append(values, lookup(element, path)...)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		if value == nil {
+			return nil
+		}
+		return []interface{}{value}
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return lookup(object[path[0]], path[1:])
+}
@@ -0,0 +1,21 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/nockrpc"
+)
+
+// Lock is the signing configuration guarding an address's funds
+type Lock = nockrpc.Lock
+
+// Signers implements monitor.SignerSource using the getLockByAddress
+// method, returning how many of which keys must sign to spend an
+// address's funds. Endpoints that don't expose locks return an error.
+func (c *Client) Signers(address string) (int, []string, error) {
+	lock, err := c.client().GetLockByAddress(context.Background(), address)
+	if err != nil {
+		return 0, nil, err
+	}
+	return lock.Threshold, lock.Signers, nil
+}
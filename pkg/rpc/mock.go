@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mockHistory bounds how many transactions the mock returns per address,
+// matching the limit the client asks for
+const mockHistory = 20
+
+// mockAccount is an address on the mock chain
+type mockAccount struct {
+	balance      int64
+	locked       int64
+	transactions []Transaction // Newest first
+	utxos        []UTXO
+	lock         *Lock // Single-signer when nil
+}
+
+// MockServer is a nockblocks-compatible JSON-RPC endpoint whose balances
+// are set by hand or by a script, for testing the alert pipeline without a
+// real chain. Every balance change is recorded as a transaction; received
+// amounts become new UTXOs and spends consolidate the rest into one.
+type MockServer struct {
+	mu       sync.Mutex
+	accounts map[string]*mockAccount
+	height   int64
+	nextTx   int
+}
+
+// NewMockServer returns a mock chain with no funded addresses at height 1
+func NewMockServer() *MockServer {
+	return &MockServer{accounts: map[string]*mockAccount{}, height: 1}
+}
+
+// account returns an address's account, creating it; callers must hold s.mu
+func (s *MockServer) account(address string) *mockAccount {
+	if s.accounts[address] == nil {
+		s.accounts[address] = &mockAccount{}
+	}
+	return s.accounts[address]
+}
+
+// SetBalance changes an address's balance, recording a transaction that
+// paid fee if the balance changed
+func (s *MockServer) SetBalance(address string, balance, fee int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.account(address)
+	delta := balance - a.balance
+	if delta == 0 {
+		return
+	}
+	s.nextTx++
+	id := fmt.Sprintf("mock-%d", s.nextTx)
+	a.transactions = append([]Transaction{{ID: id, Fee: fee, Time: time.Now().Unix()}}, a.transactions...)
+	if len(a.transactions) > mockHistory {
+		a.transactions = a.transactions[:mockHistory]
+	}
+	if delta > 0 {
+		a.utxos = append(a.utxos, UTXO{ID: id, Amount: delta})
+	} else {
+		a.utxos = []UTXO{{ID: id, Amount: balance}}
+	}
+	a.balance = balance
+	if a.locked > balance {
+		a.locked = balance
+	}
+}
+
+// AddBalance changes an address's balance by delta, see SetBalance
+func (s *MockServer) AddBalance(address string, delta, fee int64) {
+	s.mu.Lock()
+	balance := s.account(address).balance + delta
+	s.mu.Unlock()
+	s.SetBalance(address, balance, fee)
+}
+
+// SetMemo attaches a memo to the latest transaction of an address
+func (s *MockServer) SetMemo(address, memo string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.account(address); len(a.transactions) > 0 {
+		a.transactions[0].Memo = memo
+	}
+}
+
+// SetCounterparties sets the other addresses of the latest transaction of
+// an address, such as where a spend went
+func (s *MockServer) SetCounterparties(address string, counterparties []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.account(address); len(a.transactions) > 0 {
+		a.transactions[0].Counterparties = append([]string{}, counterparties...)
+	}
+}
+
+// SetSigners locks an address's funds to any threshold of the signers'
+// keys; no signers makes it single-signer again
+func (s *MockServer) SetSigners(address string, threshold int, signers []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.account(address)
+	if len(signers) == 0 {
+		a.lock = nil
+		return
+	}
+	a.lock = &Lock{Threshold: threshold, Signers: append([]string{}, signers...)}
+}
+
+// SetLocked sets the locked part of an address's balance
+func (s *MockServer) SetLocked(address string, locked int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account(address).locked = locked
+}
+
+// AdvanceHeight adds blocks to the chain height
+func (s *MockServer) AdvanceHeight(blocks int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.height += blocks
+}
+
+// ServeHTTP implements http.Handler, answering getTransactionsByAddress,
+// getUtxosByAddress, getBlockHeight, and getAddressesByPattern, which
+// matches known addresses against a glob such as 3L1P*
+func (s *MockServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var request Request
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var params map[string]interface{}
+	if len(request.Params) > 0 {
+		params, _ = request.Params[0].(map[string]interface{})
+	}
+	address, _ := params["address"].(string)
+
+	s.mu.Lock()
+	var result interface{}
+	switch request.Method {
+	case "getTransactionsByAddress":
+		a := s.account(address)
+		result = map[string]interface{}{
+			"address":        address,
+			"currentBalance": a.balance,
+			"lockedBalance":  a.locked,
+			"transactions":   append([]Transaction{}, a.transactions...),
+		}
+	case "getUtxosByAddress":
+		result = map[string]interface{}{"utxos": append([]UTXO{}, s.account(address).utxos...)}
+	case "getLockByAddress":
+		lock := Lock{Threshold: 1, Signers: []string{address}}
+		if a := s.account(address); a.lock != nil {
+			lock = *a.lock
+		}
+		result = lock
+	case "getBlockHeight":
+		result = map[string]interface{}{"height": s.height}
+	case "getAddressesByPattern":
+		pattern, _ := params["pattern"].(string)
+		matched := []string{}
+		for known := range s.accounts {
+			if ok, _ := path.Match(pattern, known); ok {
+				matched = append(matched, known)
+			}
+		}
+		sort.Strings(matched)
+		result = map[string]interface{}{"addresses": matched}
+	}
+	s.mu.Unlock()
+
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": request.ID}
+	if result == nil {
+		response["error"] = map[string]interface{}{"code": -32601, "message": "method not found: " + request.Method}
+	} else {
+		response["result"] = result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ControlHandler lets tests change the mock chain over HTTP:
+//
+//	POST ?address=...&balance=N (or &delta=N) [&fee=N] [&locked=N]
+//	POST ?address=...&threshold=N&signers=key1,key2,...
+//	POST ?blocks=N
+func (s *MockServer) ControlHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "use POST", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		values := map[string]int64{}
+		for _, name := range []string{"balance", "delta", "fee", "locked", "threshold", "blocks"} {
+			value := query.Get(name)
+			if value == "" {
+				continue
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%s must be an integer, got %q", name, value), http.StatusBadRequest)
+				return
+			}
+			values[name] = n
+		}
+		balance, setBalance := values["balance"]
+		delta, addDelta := values["delta"]
+		locked, setLocked := values["locked"]
+		_, setSigners := query["signers"]
+		address := query.Get("address")
+		if address == "" && (setBalance || addDelta || setLocked || setSigners) {
+			http.Error(w, "address is required", http.StatusBadRequest)
+			return
+		}
+
+		switch {
+		case setBalance:
+			s.SetBalance(address, balance, values["fee"])
+		case addDelta:
+			s.AddBalance(address, delta, values["fee"])
+		}
+		if setLocked {
+			s.SetLocked(address, locked)
+		}
+		if setSigners {
+			var signers []string
+			if list := query.Get("signers"); list != "" {
+				signers = strings.Split(list, ",")
+			}
+			s.SetSigners(address, int(values["threshold"]), signers)
+		}
+		if blocks, ok := values["blocks"]; ok {
+			s.AdvanceHeight(blocks)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
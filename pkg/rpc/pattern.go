@@ -0,0 +1,11 @@
+package rpc
+
+import "context"
+
+// MatchAddresses implements monitor.AddressMatcher using the
+// getAddressesByPattern method, returning the addresses the indexer matches
+// against pattern, such as a prefix glob or an account name. Endpoints that
+// don't support it return an error.
+func (c *Client) MatchAddresses(pattern string) ([]string, error) {
+	return c.client().GetAddressesByPattern(context.Background(), pattern)
+}
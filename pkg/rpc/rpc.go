@@ -0,0 +1,252 @@
+// Package rpc adapts the nockblocks JSON-RPC client in package nockrpc, and
+// GraphQL indexers, to the balance sources the monitor uses.
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/nockrpc"
+)
+
+// DefaultURL is the public nockblocks JSON-RPC endpoint
+const DefaultURL = nockrpc.DefaultURL
+
+// Request is a JSON-RPC request
+type Request = nockrpc.Request
+
+// Transaction is a transaction touching the queried address
+type Transaction = nockrpc.Transaction
+
+// DefaultUserAgent identifies requests when no User-Agent is configured
+const DefaultUserAgent = nockrpc.DefaultUserAgent
+
+// DefaultChain is the chain name reported by clients that don't set one
+const DefaultChain = "nockchain"
+
+// snapshotTTL is how long the getTransactionsByAddress result GetBalance
+// fetched answers the other queries about that address, such as its
+// transactions, fees and locked balance, so a check makes one request for
+// all of them
+const snapshotTTL = 10 * time.Second
+
+// Client queries balances from a nockblocks-compatible JSON-RPC endpoint
+type Client struct {
+	URL        string
+	Name       string // Chain name, e.g. "testnet"; DefaultChain if empty
+	UserAgent  string // DefaultUserAgent if empty
+	Headers    map[string]string
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	snapshots map[string]snapshot // Recent getTransactionsByAddress results by address
+	pruned    time.Time
+}
+
+// snapshot is a getTransactionsByAddress result and when it was fetched
+type snapshot struct {
+	result  nockrpc.AddressTransactions
+	fetched time.Time
+}
+
+// Chain implements monitor.ChainAdapter
+func (c *Client) Chain() string {
+	if c.Name == "" {
+		return DefaultChain
+	}
+	return c.Name
+}
+
+// NewClient returns a client for the given endpoint, or DefaultURL if empty
+func NewClient(url string) *Client {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Client{URL: url, HTTPClient: http.DefaultClient}
+}
+
+// GetBalance queries the balance in nick for a given address. The other
+// queries about the address reuse the result for snapshotTTL.
+func (c *Client) GetBalance(address string) (int64, error) {
+	result, err := c.fetch(address)
+	if err != nil {
+		return 0, err
+	}
+	return result.CurrentBalance, nil
+}
+
+// LockedBalance implements monitor.LockSource, returning the locked or
+// staked part of an address's balance in nick
+func (c *Client) LockedBalance(address string) (int64, error) {
+	result, err := c.transactionsByAddress(address)
+	if err != nil {
+		return 0, err
+	}
+	return result.LockedBalance, nil
+}
+
+// Height implements monitor.HeightSource, returning the latest block height
+// known to the endpoint
+func (c *Client) Height() (int64, error) {
+	return c.client().GetBlockHeight(context.Background())
+}
+
+// TransactionIDs implements monitor.TransactionSource, returning the IDs of
+// the most recent transactions touching an address
+func (c *Client) TransactionIDs(address string) ([]string, error) {
+	result, err := c.transactionsByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(result.Transactions))
+	for _, tx := range result.Transactions {
+		if tx.ID != "" {
+			ids = append(ids, tx.ID)
+		}
+	}
+	return ids, nil
+}
+
+// TransactionFees implements monitor.FeeSource, returning the fee in nick of
+// each recent transaction touching an address by transaction ID
+func (c *Client) TransactionFees(address string) (map[string]int64, error) {
+	result, err := c.transactionsByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	fees := make(map[string]int64, len(result.Transactions))
+	for _, tx := range result.Transactions {
+		if tx.ID != "" {
+			fees[tx.ID] = tx.Fee
+		}
+	}
+	return fees, nil
+}
+
+// TransactionMemos implements monitor.MemoSource, returning the memo of
+// each recent transaction touching an address that carries one, by
+// transaction ID
+func (c *Client) TransactionMemos(address string) (map[string]string, error) {
+	result, err := c.transactionsByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	memos := map[string]string{}
+	for _, tx := range result.Transactions {
+		if tx.ID != "" && tx.Memo != "" {
+			memos[tx.ID] = tx.Memo
+		}
+	}
+	return memos, nil
+}
+
+// LastActivity implements monitor.ActivitySource, returning the time of
+// the newest transaction touching an address, or the zero time if the
+// endpoint doesn't report transaction times
+func (c *Client) LastActivity(address string) (time.Time, error) {
+	result, err := c.transactionsByAddress(address)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var newest int64
+	for _, tx := range result.Transactions {
+		newest = max(newest, tx.Time)
+	}
+	if newest == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(newest, 0), nil
+}
+
+// RelatedAddresses implements monitor.AddressDiscoverer, returning the
+// counterparties of the address's latest transactions other than itself,
+// newest first. It reuses the getTransactionsByAddress result GetBalance
+// fetched, so naming the recipients of a change costs no extra call.
+func (c *Client) RelatedAddresses(address string) ([]string, error) {
+	result, err := c.transactionsByAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{address: true}
+	var related []string
+	for _, tx := range result.Transactions {
+		for _, other := range tx.Counterparties {
+			if !seen[other] {
+				seen[other] = true
+				related = append(related, other)
+			}
+		}
+	}
+	return related, nil
+}
+
+// RawTransactions implements monitor.RawTransactionSource, returning the
+// getTransactionsByAddress result for an address exactly as the endpoint
+// sent it
+func (c *Client) RawTransactions(address string) (json.RawMessage, error) {
+	var result json.RawMessage
+	err := c.client().Call(context.Background(), "getTransactionsByAddress", map[string]interface{}{
+		"address": address,
+		"limit":   nockrpc.DefaultPageSize,
+		"offset":  0,
+	}, &result)
+	return result, err
+}
+
+// transactionsByAddress returns the getTransactionsByAddress result for
+// an address, reusing the one GetBalance fetched if it is recent enough
+func (c *Client) transactionsByAddress(address string) (nockrpc.AddressTransactions, error) {
+	c.mu.Lock()
+	recent, ok := c.snapshots[address]
+	c.mu.Unlock()
+	if ok && time.Since(recent.fetched) < snapshotTTL {
+		return recent.result, nil
+	}
+	return c.fetch(address)
+}
+
+// fetch calls getTransactionsByAddress for an address and keeps the result
+// for transactionsByAddress
+func (c *Client) fetch(address string) (nockrpc.AddressTransactions, error) {
+	result, err := c.client().GetTransactionsByAddress(context.Background(), address, nockrpc.Page{})
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.pruned) >= snapshotTTL {
+		for cached, recent := range c.snapshots {
+			if now.Sub(recent.fetched) >= snapshotTTL {
+				delete(c.snapshots, cached)
+			}
+		}
+		c.pruned = now
+	}
+	if err != nil {
+		delete(c.snapshots, address)
+		return result, err
+	}
+	if c.snapshots == nil {
+		c.snapshots = map[string]snapshot{}
+	}
+	c.snapshots[address] = snapshot{result: result, fetched: now}
+	return result, nil
+}
+
+// client returns the typed client the calls go through
+func (c *Client) client() *nockrpc.Client {
+	return &nockrpc.Client{URL: c.URL, UserAgent: c.UserAgent, Headers: c.Headers, HTTPClient: c.HTTPClient}
+}
+
+// setHeaders identifies a request with the User-Agent and any extra headers,
+// such as an operator contact in From
+func setHeaders(req *http.Request, userAgent string, headers map[string]string) {
+	if userAgent == "" {
+		userAgent = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+}
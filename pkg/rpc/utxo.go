@@ -0,0 +1,25 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/anilcse/nockchain-balance-alerter/pkg/nockrpc"
+)
+
+// UTXO is an unspent output held by an address
+type UTXO = nockrpc.UTXO
+
+// UTXOs implements monitor.UTXOSource using the getUtxosByAddress method,
+// returning the amounts of an address's unspent outputs in nick. Endpoints
+// that don't expose UTXOs return an error.
+func (c *Client) UTXOs(address string) ([]int64, error) {
+	utxos, err := c.client().GetUTXOsByAddress(context.Background(), address)
+	if err != nil {
+		return nil, err
+	}
+	amounts := make([]int64, len(utxos))
+	for i, utxo := range utxos {
+		amounts[i] = utxo.Amount
+	}
+	return amounts, nil
+}